@@ -1,17 +1,28 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"os"
     "log"
     "time"
+    // users may pick any IANA zone, even where the host has no zoneinfo
+    _ "time/tzdata"
 
     tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
     "github.com/hihikaAAa/task-manager/internal/config"
     "github.com/hihikaAAa/task-manager/internal/lib"
+    "github.com/hihikaAAa/task-manager/internal/storage"
+    "github.com/hihikaAAa/task-manager/internal/storage/memory"
+    "github.com/hihikaAAa/task-manager/internal/storage/postgres"
     "github.com/hihikaAAa/task-manager/internal/storage/sqlite"
 )
 
 func main() {
+    dryRun := flag.Bool("migrate-dry-run", false, "print pending schema migrations and exit")
+    restore := flag.String("restore", "", "replace the sqlite database with this backup `file` and exit")
+    flag.Parse()
+
     cfgPath := os.Getenv("CONFIG_PATH")
     if cfgPath == "" { 
 		cfgPath = "config/local.yaml" 
@@ -22,7 +33,23 @@ func main() {
 		log.Fatal("load config:", err)
 	 }
 
-    db, err := sqlite.Open(cfg.DBPath)
+    if *dryRun {
+        if err := printPendingMigrations(cfg); err != nil {
+            log.Fatal("migrations:", err)
+        }
+        return
+    }
+
+    if *restore != "" {
+        if cfg.DBDriver != "sqlite" { log.Fatalf("restore: db_driver %q is not sqlite", cfg.DBDriver) }
+        kept, err := sqlite.Restore(*restore, cfg.DBPath)
+        if err != nil { log.Fatal("restore:", err) }
+        if kept != "" { log.Printf("previous database kept as %s", kept) }
+        log.Printf("restored %s from %s", cfg.DBPath, *restore)
+        return
+    }
+
+    db, err := openStore(cfg)
     if err != nil { 
 		log.Fatal("open db:", err) 
 	}
@@ -39,9 +66,61 @@ func main() {
     }
 
     bot := lib.NewBot(botAPI, db, cfg.BossIDs, loc)
+    bot.TrashRetention = time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour
+    bot.BackupDir, bot.BackupInterval, bot.BackupKeep = cfg.BackupDir, cfg.BackupInterval, cfg.BackupKeep
+    bot.StateTTL = cfg.StateTTL
+    bot.DraftRetention = time.Duration(cfg.DraftRetentionDays) * 24 * time.Hour
+    if bot.WorkHours, err = lib.ParseWorkHours(cfg.WorkHours); err != nil {
+        log.Fatalf("work_hours %q: %v", cfg.WorkHours, err)
+    }
+    bot.UrgentReminders = cfg.UrgentReminders
+    for _, st := range cfg.Escalation {
+        bot.Escalation = append(bot.Escalation, lib.EscalationStep{After: st.After, To: st.To, Repeat: st.Repeat})
+    }
+    if err := lib.CheckEscalation(bot.Escalation); err != nil {
+        log.Fatalf("escalation: %v", err)
+    }
 
     log.Printf("Bot started as @%s with config %s", botAPI.Self.UserName, cfgPath)
     if err := bot.Start(); err != nil { 
 		log.Fatal(err) 
 	}
-}
\ No newline at end of file
+}
+
+func openStore(cfg *config.Config) (storage.Store, error) {
+    switch cfg.DBDriver {
+    case "sqlite":
+        return sqlite.Open(cfg.DBPath)
+    case "postgres":
+        return postgres.Open(cfg.DBDSN)
+    case "memory":
+        return memory.New(), nil
+    }
+    return nil, fmt.Errorf("unknown db_driver %q", cfg.DBDriver)
+}
+
+func printPendingMigrations(cfg *config.Config) error {
+    var names []string
+    latest := 0
+    switch cfg.DBDriver {
+    case "sqlite":
+        pending, err := sqlite.PendingMigrations(cfg.DBPath)
+        if err != nil { return err }
+        for _, m := range pending { names = append(names, fmt.Sprintf("%d: %s", m.Version, m.Name)) }
+        latest = sqlite.LatestVersion()
+    case "postgres":
+        pending, err := postgres.PendingMigrations(cfg.DBDSN)
+        if err != nil { return err }
+        for _, m := range pending { names = append(names, fmt.Sprintf("%d: %s", m.Version, m.Name)) }
+        latest = postgres.LatestVersion()
+    default:
+        return fmt.Errorf("db_driver %q has no migrations", cfg.DBDriver)
+    }
+    if len(names) == 0 {
+        log.Printf("schema is up to date (v%d)", latest)
+    }
+    for _, n := range names {
+        log.Printf("pending migration %s", n)
+    }
+    return nil
+}
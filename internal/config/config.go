@@ -1,7 +1,11 @@
 package config
 
 import (
+    "fmt"
     "os"
+    "path/filepath"
+    "strconv"
+    "strings"
     "time"
     goyaml "gopkg.in/yaml.v3"
 )
@@ -9,8 +13,37 @@ import (
 type Config struct {
     BotToken string  `yaml:"bot_token"`
     DBPath   string  `yaml:"db_path"`
+    DBDriver string  `yaml:"db_driver"`
+    DBDSN    string  `yaml:"db_dsn"`
     BossIDs  []int64 `yaml:"boss_ids"`
     Timezone string  `yaml:"timezone"`
+    // TrashRetentionDays is how long deleted tasks stay restorable.
+    TrashRetentionDays int `yaml:"trash_retention_days"`
+    // Backups of the sqlite database: every BackupInterval a snapshot is
+    // written to BackupDir, keeping the newest BackupKeep.
+    BackupDir      string        `yaml:"backup_dir"`
+    BackupInterval time.Duration `yaml:"backup_interval"`
+    BackupKeep     int           `yaml:"backup_keep"`
+    // StateTTL is how long an unanswered conversation lives; unfinished
+    // tasks are then kept as drafts for DraftRetentionDays.
+    StateTTL           time.Duration `yaml:"state_ttl"`
+    DraftRetentionDays int           `yaml:"draft_retention_days"`
+    // WorkHours is the default working window ("09:00-18:00 пн-пт", "off")
+    // for departments and users without their own. Reminders of the
+    // UrgentReminders kinds are sent outside it too.
+    WorkHours       string   `yaml:"work_hours"`
+    UrgentReminders []string `yaml:"urgent_reminders"`
+    // Escalation is the policy for assignments left unfinished past their
+    // deadline; an empty list turns it off.
+    Escalation []EscalationStep `yaml:"escalation"`
+}
+
+// EscalationStep notifies To ("assignee", "creator", "head", "bosses")
+// After the deadline and, when Repeat is set, again every Repeat.
+type EscalationStep struct {
+    After  time.Duration `yaml:"after"`
+    To     []string      `yaml:"to"`
+    Repeat time.Duration `yaml:"repeat"`
 }
 
 func MustLoad(path string) (*Config, error) {
@@ -24,14 +57,57 @@ func MustLoad(path string) (*Config, error) {
     }
     if v := os.Getenv("BOT_TOKEN"); v != "" { cfg.BotToken = v }
     if v := os.Getenv("DB_PATH"); v != "" { cfg.DBPath = v }
-    if v := os.Getenv("TZ"); v != "" { 
-		cfg.Timezone = v; _ = os.Setenv("TZ", v) 
-		} else if cfg.Timezone != "" { 
-			_ = os.Setenv("TZ", cfg.Timezone) }
-    if cfg.Timezone != "" {
-        if loc, err := time.LoadLocation(cfg.Timezone); err == nil {
-            time.Local = loc
+    if v := os.Getenv("DB_DRIVER"); v != "" { cfg.DBDriver = v }
+    if v := os.Getenv("DB_DSN"); v != "" { cfg.DBDSN = v }
+    if cfg.DBDriver == "" { cfg.DBDriver = "sqlite" }
+    if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil { return nil, fmt.Errorf("TRASH_RETENTION_DAYS: %w", err) }
+        cfg.TrashRetentionDays = n
+    }
+    if cfg.TrashRetentionDays <= 0 { cfg.TrashRetentionDays = 30 }
+    if v := os.Getenv("BACKUP_DIR"); v != "" { cfg.BackupDir = v }
+    if v := os.Getenv("BACKUP_INTERVAL"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil { return nil, fmt.Errorf("BACKUP_INTERVAL: %w", err) }
+        cfg.BackupInterval = d
+    }
+    if v := os.Getenv("BACKUP_KEEP"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil { return nil, fmt.Errorf("BACKUP_KEEP: %w", err) }
+        cfg.BackupKeep = n
+    }
+    if cfg.BackupDir == "" && cfg.DBPath != "" { cfg.BackupDir = filepath.Join(filepath.Dir(cfg.DBPath), "backups") }
+    if cfg.BackupInterval == 0 { cfg.BackupInterval = 24 * time.Hour }
+    if cfg.BackupKeep <= 0 { cfg.BackupKeep = 7 }
+    if v := os.Getenv("STATE_TTL"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil { return nil, fmt.Errorf("STATE_TTL: %w", err) }
+        cfg.StateTTL = d
+    }
+    if v := os.Getenv("DRAFT_RETENTION_DAYS"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil { return nil, fmt.Errorf("DRAFT_RETENTION_DAYS: %w", err) }
+        cfg.DraftRetentionDays = n
+    }
+    if cfg.StateTTL == 0 { cfg.StateTTL = 24 * time.Hour }
+    if cfg.DraftRetentionDays <= 0 { cfg.DraftRetentionDays = 14 }
+    if v := os.Getenv("WORK_HOURS"); v != "" { cfg.WorkHours = v }
+    if cfg.WorkHours == "" { cfg.WorkHours = "09:00-18:00 пн-пт" }
+    if v, ok := os.LookupEnv("URGENT_REMINDERS"); ok {
+        cfg.UrgentReminders = nil
+        for _, k := range strings.Split(v, ",") {
+            if k = strings.TrimSpace(k); k != "" { cfg.UrgentReminders = append(cfg.UrgentReminders, k) }
         }
     }
+    if cfg.Escalation == nil {
+        cfg.Escalation = []EscalationStep{{After: 15 * time.Minute, To: []string{"assignee", "creator"}}}
+    }
+    // Timezone is only the default for users who have not picked their own;
+    // the process-wide time.Local is left alone.
+    if v := os.Getenv("TZ"); v != "" { cfg.Timezone = v }
+    if cfg.Timezone != "" {
+        if _, err := time.LoadLocation(cfg.Timezone); err != nil { return nil, fmt.Errorf("timezone: %w", err) }
+    }
     return cfg, nil
 }
@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// lockUser serializes state updates of one user; call the returned func to
+// release.
+func (b *Bot) lockUser(tgID int64) func() {
+	mu, _ := b.userLocks.LoadOrStore(tgID, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// addTaskBody collects the task content: text is appended to the
+// description, files become attachments, until the boss says «готово».
+func (b *Bot) addTaskBody(m *tgbotapi.Message) {
+	ctx := context.Background()
+	defer b.lockUser(m.From.ID)()
+
+	d := &NewTaskDraft{}
+	if state, _ := b.DB.LoadState(ctx, m.From.ID, d); state != StateNewTaskBody {
+		return
+	}
+	if strings.EqualFold(strings.TrimSpace(m.Text), "готово") {
+		b.finishTaskBody(m.Chat.ID, m.From.ID, d)
+		return
+	}
+
+	switch f := messageMedia(m); {
+	case f != nil:
+		d.Attachments = append(d.Attachments, draftAttachment{Kind: f.Kind, FileID: f.FileID, FileName: f.FileName, Caption: m.Caption})
+	case strings.TrimSpace(m.Text) != "":
+		if d.Description != "" {
+			d.Description += "\n\n"
+		}
+		d.Description += m.Text
+	default:
+		b.reply(m.Chat.ID, "Пришлите текст, файл, фото, видео или голосовое.")
+		return
+	}
+
+	sameAlbum := m.MediaGroupID != "" && m.MediaGroupID == d.LastGroupID
+	d.LastGroupID = m.MediaGroupID
+	b.DB.SaveState(ctx, m.From.ID, StateNewTaskBody, d)
+	if sameAlbum {
+		return
+	}
+	msg := tgbotapi.NewMessage(m.Chat.ID, "Добавлено. Можно прислать ещё или нажать «Готово».")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("✅ Готово", "body_done")),
+		flowRow(StateNewTaskBody),
+	)
+	b.API.Send(msg)
+}
+
+func (b *Bot) onBodyDone(cq *tgbotapi.CallbackQuery) {
+	ctx := context.Background()
+	defer b.lockUser(cq.From.ID)()
+
+	d := &NewTaskDraft{}
+	if state, _ := b.DB.LoadState(ctx, cq.From.ID, d); state != StateNewTaskBody || cq.Message == nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Содержание уже принято"))
+		return
+	}
+	b.API.Send(tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID,
+		fmt.Sprintf("Содержание принято: вложений %d.", len(d.Attachments))))
+	b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+	b.finishTaskBody(cq.Message.Chat.ID, cq.From.ID, d)
+}
+
+// finishTaskBody moves the draft on to choosing assignees once it has a
+// description or at least one attachment.
+func (b *Bot) finishTaskBody(chatID, tgID int64, d *NewTaskDraft) {
+	if strings.TrimSpace(d.Description) == "" && len(d.Attachments) == 0 {
+		b.reply(chatID, "Содержание пустое. Пришлите текст, файл, фото, видео или голосовое.")
+		return
+	}
+	d.LastGroupID = ""
+	b.advance(chatID, tgID, d, StateNewTaskAssignees)
+}
@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+const (
+	backupPrefix = "tasks-"
+	backupExt    = ".db"
+	// maxUploadSize is the Bot API limit for documents sent by bots.
+	maxUploadSize = 50 << 20
+)
+
+var errNoBackups = errors.New("backups are not supported by this storage backend")
+
+// backup writes a snapshot to BackupDir and prunes old ones.
+func (b *Bot) backup(ctx context.Context) (string, error) {
+	bk, ok := b.DB.(storage.Backuper)
+	if !ok {
+		return "", errNoBackups
+	}
+	if b.BackupDir == "" {
+		return "", errors.New("backup_dir is not set")
+	}
+	if err := os.MkdirAll(b.BackupDir, 0o750); err != nil {
+		return "", err
+	}
+	path := filepath.Join(b.BackupDir, backupPrefix+time.Now().UTC().Format("20060102-150405")+backupExt)
+	if err := bk.Backup(ctx, path); err != nil {
+		return "", err
+	}
+	b.pruneBackups()
+	return path, nil
+}
+
+// pruneBackups removes all but the newest BackupKeep snapshots. Names sort
+// by time, so the newest are last.
+func (b *Bot) pruneBackups() {
+	if b.BackupKeep <= 0 {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(b.BackupDir, backupPrefix+"*"+backupExt))
+	if err != nil {
+		return
+	}
+	sort.Strings(files)
+	for len(files) > b.BackupKeep {
+		if err := os.Remove(files[0]); err != nil {
+			log.Println("prune backup:", err)
+		}
+		files = files[1:]
+	}
+}
+
+// startBackups snapshots the database every BackupInterval.
+func (b *Bot) startBackups() {
+	if _, ok := b.DB.(storage.Backuper); !ok || b.BackupInterval <= 0 || b.BackupDir == "" {
+		return
+	}
+	go func() {
+		t := time.NewTicker(b.BackupInterval)
+		defer t.Stop()
+		for range t.C {
+			path, err := b.backup(context.Background())
+			if err != nil {
+				log.Println("backup:", err)
+				continue
+			}
+			log.Println("backup written to", path)
+		}
+	}()
+}
+
+func (b *Bot) cmdBackup(m *tgbotapi.Message) {
+	b.reply(m.Chat.ID, "Делаю резервную копию…")
+	path, err := b.backup(context.Background())
+	if err != nil {
+		if errors.Is(err, errNoBackups) {
+			b.reply(m.Chat.ID, "Резервные копии доступны только для SQLite.")
+			return
+		}
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+
+	st, err := os.Stat(path)
+	if err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	if st.Size() > maxUploadSize {
+		b.reply(m.Chat.ID, fmt.Sprintf("Копия сохранена на сервере (%d МБ — слишком большая для отправки):\n%s", st.Size()>>20, path))
+		return
+	}
+	doc := tgbotapi.NewDocument(m.Chat.ID, tgbotapi.FilePath(path))
+	doc.Caption = "Резервная копия БД от " + time.Now().In(b.zone(m.From.ID)).Format("02.01.2006 15:04") +
+		"\nВосстановление: остановите бота и запустите с -restore " + filepath.Base(path)
+	if _, err := b.API.Send(doc); err != nil {
+		b.reply(m.Chat.ID, "Копия сохранена на сервере, но отправить не удалось: "+err.Error()+"\n"+path)
+	}
+}
@@ -5,21 +5,41 @@ import (
     "database/sql"
     "fmt"
     "log"
-    "regexp"
     "sort"
     "strconv"
     "strings"
+    "sync"
     "time"
 
     tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-    "github.com/hihikaAAa/task-manager/internal/storage/sqlite"
+    "github.com/hihikaAAa/task-manager/internal/storage"
 )
 
 type Bot struct {
     API    *tgbotapi.BotAPI
-    DB     *sqlite.DB
+    DB     storage.Store
     BossIDs map[int64]bool
     TZ     *time.Location
+    // TrashRetention is how long deleted tasks are kept before purging.
+    TrashRetention time.Duration
+    // Snapshots go to BackupDir every BackupInterval; the newest BackupKeep stay.
+    BackupDir      string
+    BackupInterval time.Duration
+    BackupKeep     int
+    // Conversations idle for StateTTL are cleared; unfinished tasks are kept
+    // as drafts for DraftRetention.
+    StateTTL       time.Duration
+    DraftRetention time.Duration
+    // WorkHours apply to users whose department has none; reminders of the
+    // UrgentReminders kinds ("before", "deadline", "overdue") ignore them.
+    WorkHours       WorkHours
+    UrgentReminders []string
+    // Escalation is applied to assignments left unfinished past the
+    // deadline; "overdue" in UrgentReminders lets it ignore working hours.
+    Escalation []EscalationStep
+    // userLocks serializes draft updates per user: the files of an album
+    // arrive as separate updates handled concurrently.
+    userLocks sync.Map
 }
 
 var menuKB = tgbotapi.NewReplyKeyboard(
@@ -31,7 +51,7 @@ func init() {
     menuKB.ResizeKeyboard = true
 }
 
-func NewBot(api *tgbotapi.BotAPI, db *sqlite.DB, bossIDs []int64, tz *time.Location) *Bot {
+func NewBot(api *tgbotapi.BotAPI, db storage.Store, bossIDs []int64, tz *time.Location) *Bot {
     m := map[int64]bool{}
     for _, id := range bossIDs { m[id] = true }
     return &Bot{API: api, DB: db, BossIDs: m, TZ: tz}
@@ -47,7 +67,10 @@ func (b *Bot) Start() error {
     updates := b.API.GetUpdatesChan(upd)
 
     b.startOrphansDailyPing(10) 
+    b.startTrashPurge(4)
+    b.startBackups()
     b.startRemindersLoop()       
+    b.startStateSweeper()
 
     for update := range updates {
         if update.Message != nil { go b.handleMessage(update.Message) }
@@ -62,6 +85,8 @@ func (b *Bot) startRemindersLoop() {
         defer ticker.Stop()
         for range ticker.C {
             b.dispatchReminders()
+            b.dispatchEscalations()
+            b.dispatchRecurring()
         }
     }()
 }
@@ -80,7 +105,6 @@ func (b *Bot) dispatchReminders() {
 			continue
 		}
 		title := nullStr(t.Title)
-		send := func(chatID int64, txt string) { _, _ = b.API.Send(tgbotapi.NewMessage(chatID, txt)) }
 
 		if r.UserID.Valid {
 			uid := r.UserID.Int64
@@ -91,31 +115,25 @@ func (b *Bot) dispatchReminders() {
 				continue
 			}
 		}
+		if b.deferReminder(ctx, r, t, now) {
+			continue
+		}
 
 		switch r.Kind {
 		case "before":
 			if r.UserID.Valid {
 				if u, err := b.DB.GetUserByID(ctx, r.UserID.Int64); err == nil {
-					send(u.TgID, "⏰ Напоминание: скоро дедлайн по задаче «"+title+"».")
+					b.sendReminder(u.TgID, "⏰ Напоминание: скоро дедлайн по задаче «"+title+"».", r)
 				}
 			}
 
 		case "deadline":
 			if r.UserID.Valid {
 				if u, err := b.DB.GetUserByID(ctx, r.UserID.Int64); err == nil {
-					send(u.TgID, "⌛ Дедлайн по задаче «"+title+"». Обновите статус или отправьте результат.")
+					b.sendReminder(u.TgID, "⌛ Дедлайн по задаче «"+title+"». Обновите статус или отправьте результат.", r)
 				}
 			}
 
-		case "overdue":
-			if r.UserID.Valid {
-				if u, err := b.DB.GetUserByID(ctx, r.UserID.Int64); err == nil {
-					send(u.TgID, "❗ Просрочено: задача «"+title+"».")
-				}
-			}
-			if creator, err := b.DB.GetUserByID(ctx, t.CreatorID); err == nil {
-				send(creator.TgID, "❗ Просрочена задача «"+title+"». Проверьте статус у исполнителей.")
-			}
 		}
 
 		_ = b.DB.MarkReminderSent(ctx, r.ID)
@@ -127,9 +145,9 @@ func (b *Bot) dispatchReminders() {
 func (b *Bot) showMenu(chatID int64, boss bool) {
     var txt string
     if boss {
-        txt = "Меню:\n/newtask — выдать задание\n/allactive — активные задачи\n/users — список сотрудников\n/del <tg_id> — удалить сотрудника\n/dept_add <name> - добавить отдел\n/dept_list - список отделов\n/dept_del <id> - удалить отдел\n/done — выполненные задачи\n/task_del <Имя задачи> - удалить задачу\n/error <сообщение> — отправить ошибку боссу"
+        txt = "Меню:\n/newtask — выдать задание\n/allactive — активные задачи\n/users — список сотрудников\n/del <tg_id> — удалить сотрудника\n/dept_add <name> - добавить отдел\n/dept_list - список отделов\n/dept_rename <id> <name> - переименовать отдел\n/dept_del <id> - удалить отдел\n/dept_hours <id> <время> - рабочее время отдела\n/dept_head <id> <tg_id|@username> - руководитель отдела\n/done — выполненные задачи\n/task_del <Имя задачи> - удалить задачу\n/task_deadline <id> <дедлайн> - перенести дедлайн (завтра 18:00, +3д, 25.10)\n/history <id> - история задачи\n/results <id> - результаты по задаче\n/trash - корзина удалённых задач\n/search <слова> - поиск по задачам и результатам\n/task_restore <id> - восстановить задачу\n/backup - резервная копия БД\n/drafts - отложенные черновики задач\n/recurring - повторяющиеся задачи\n/templates - шаблоны задач\n/template_save <id> [имя] - сохранить задачу как шаблон\n/import - создать задачи из файла CSV/XLSX\n/export [условия] - отчёт по задачам в XLSX/CSV\n/timezone - мой часовой пояс\n/workhours - моё рабочее время\n/error <сообщение> — отправить ошибку боссу\n/cancel — отменить текущее действие"
     } else {
-        txt = "Меню:\n/register — регистрация/обновить отдел\n/mytasks — мои задачи\n/teamtasks — задачи моей команды\n/mydone — мои выполненные задачи\n/error <сообщение> — отправить ошибку боссу"
+        txt = "Меню:\n/register — регистрация/обновить отдел\n/mytasks — мои задачи\n/teamtasks — задачи моей команды\n/mydone — мои выполненные задачи\n/search <слова> — поиск по моим задачам\n/timezone — мой часовой пояс\n/workhours — моё рабочее время\n/error <сообщение> — отправить ошибку боссу\n/cancel — отменить текущее действие"
     }
     msg := tgbotapi.NewMessage(chatID, txt)
     msg.ReplyMarkup = menuKB
@@ -143,32 +161,27 @@ func (b *Bot) handleMessage(m *tgbotapi.Message) {
     user, err := b.DB.UpsertUser(ctx, m.From.ID, username, role); if err != nil { log.Println("upsert user:", err) }
     state, _ := b.DB.LoadState(ctx, m.From.ID, nil) 
 
-    isNewTaskState := func(s string) bool {
-        switch s {
-        case StateNewTaskTitle, StateNewTaskBody, StateNewTaskAssignees, StateNewTaskDeadline, StateNewTaskReminders:
-            return true
-        }
-        return false
-    }
     if m.IsCommand() {
-        cmd := m.Command()
-        if isNewTaskState(state) && cmd != "newtask" {
-            _ = b.DB.ClearState(ctx, m.From.ID)
-            state = "" 
-        }   
+        // /template_save reads the draft in progress
+        if isNewTaskState(state) && m.Command() != "cancel" && m.Command() != "template_save" {
+            if title, ok := b.shelveDraft(ctx, m.From.ID); ok {
+                b.reply(m.Chat.ID, "Незавершённая задача «"+title+"» отложена. Продолжить: /drafts")
+            }
+            state = ""
+        }
         switch m.Command() {
         case "start":
             b.onStart(m)
         case "register":
             b.DB.SaveState(ctx, m.From.ID, StateRegName, nil)
-            b.reply(m.Chat.ID, "Введите ФИО сотрудника (пример: Иванов Иван):")
+            b.prompt(m.Chat.ID, StateRegName, "Введите ФИО сотрудника (пример: Иванов Иван):")
         case "newtask":
             if !b.isBoss(m.From.ID) { 
                 b.reply(m.Chat.ID, "Команда доступна только боссам."); 
             return 
             }
             b.DB.SaveState(ctx, m.From.ID, StateNewTaskTitle, &NewTaskDraft{})
-            b.reply(m.Chat.ID, "Введите НАЗВАНИЕ задачи (только текстом):")
+            b.askNewTaskStep(m.Chat.ID, StateNewTaskTitle, &NewTaskDraft{})
         case "mytasks":
             if b.isBoss(m.From.ID) { 
                 b.reply(m.Chat.ID, "Команда недоступна для боссов.");
@@ -215,8 +228,12 @@ func (b *Bot) handleMessage(m *tgbotapi.Message) {
                 }
             var sb strings.Builder
             sb.WriteString("Отделы (id → название):\n")
-            for _, d := range deps { sb.WriteString(fmt.Sprintf("- [%d] %s\n", d.ID, d.Name)) }
-            sb.WriteString("\nКоманды:\n• /dept_add <название> — создать отдел\n• /dept_del <id> — удалить отдел")
+            for _, d := range deps {
+                line := fmt.Sprintf("- [%d] %s", d.ID, d.Name)
+                if d.HeadID.Valid { line += ", руководитель: " + b.headLabel(ctx, d) }
+                sb.WriteString(line + "\n")
+            }
+            sb.WriteString("\nКоманды:\n• /dept_add <название> — создать отдел\n• /dept_rename <id> <название> — переименовать отдел\n• /dept_del <id> — удалить отдел\n• /dept_head <id> <tg_id|@username> — назначить руководителя")
             b.reply(m.Chat.ID, sb.String())
         case "dept_del":
             if !b.isBoss(m.From.ID) { 
@@ -229,13 +246,15 @@ func (b *Bot) handleMessage(m *tgbotapi.Message) {
                 return
             }       
             id, err := strconv.ParseInt(idStr, 10, 64); if err != nil { b.reply(m.Chat.ID, "id должен быть числом"); return }
-            if err := b.DB.DeleteDepartment(ctx, id); err != nil { b.reply(m.Chat.ID, "Ошибка: "+err.Error()); return }
-            b.reply(m.Chat.ID, "Отдел удалён.")
+            b.deleteDepartment(m.Chat.ID, id)
+        case "dept_rename":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdDeptRename(m)
         case "error":
             arg := strings.TrimSpace(m.CommandArguments())
             if arg == "" {
                 b.DB.SaveState(ctx, m.From.ID, StateErrorReport, nil)
-                b.reply(m.Chat.ID, "Опишите проблему одним сообщением — я передам её боссу.")
+                b.prompt(m.Chat.ID, StateErrorReport, "Опишите проблему одним сообщением — я передам её боссу.")
                 return
             }
             b.forwardError(m.From, arg)
@@ -250,12 +269,62 @@ func (b *Bot) handleMessage(m *tgbotapi.Message) {
         case "task_del":
 	        if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
             b.cmdTaskDelByName(m)
+        case "search":
+            b.cmdSearch(m)
         case "task_find":
             if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
             b.cmdTaskFind(m)
         case "task_del_all":
 	        if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
 	        b.cmdTaskDelAll(m)
+        case "task_deadline":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdTaskDeadline(m)
+        case "trash":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdTrash(m)
+        case "results":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdResults(m)
+        case "backup":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdBackup(m)
+        case "task_restore":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdTaskRestore(m)
+        case "history":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdHistory(m)
+        case "cancel":
+            b.cmdCancel(m)
+        case "drafts":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdDrafts(m)
+        case "timezone":
+            b.cmdTimezone(m)
+        case "workhours":
+            b.cmdWorkHours(m)
+        case "dept_hours":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdDeptHours(m)
+        case "recurring":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdRecurring(m)
+        case "dept_head":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdDeptHead(m)
+        case "template_save":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdTemplateSave(m)
+        case "templates":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdTemplates(m)
+        case "import":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdImport(m)
+        case "export":
+            if !b.isBoss(m.From.ID) { b.reply(m.Chat.ID, "Только для боссов."); return }
+            b.cmdExport(m)
 
         default:
             b.reply(m.Chat.ID, "Неизвестная команда.")
@@ -285,24 +354,10 @@ func (b *Bot) handleMessage(m *tgbotapi.Message) {
             d := &NewTaskDraft{}
             b.DB.LoadState(ctx, m.From.ID, d)
             d.Title = title
-            b.DB.SaveState(ctx, m.From.ID, StateNewTaskBody, d)
-            b.reply(m.Chat.ID, "Теперь отправьте содержание задачи: текст ИЛИ голосовое.")
+            b.advance(m.Chat.ID, m.From.ID, d, StateNewTaskBody)
             return
         case StateNewTaskBody:
-            d := &NewTaskDraft{}
-            b.DB.LoadState(ctx, m.From.ID, d)
-            if m.Text != "" { 
-                d.Description = m.Text 
-            }
-            if m.Voice != nil { 
-                d.VoiceFileID = m.Voice.FileID
-             }
-            if d.Description == "" && d.VoiceFileID == "" {
-                b.reply(m.Chat.ID, "Пришлите текст или голосовое сообщение.")
-                return
-            }
-            b.DB.SaveState(ctx, m.From.ID, StateNewTaskAssignees, d)
-            b.askAssignees(m.Chat.ID)
+            b.addTaskBody(m)
             return        
     }
     if state == StateErrorReport {
@@ -313,6 +368,11 @@ func (b *Bot) handleMessage(m *tgbotapi.Message) {
         b.reply(m.Chat.ID, "Спасибо! Сообщение об ошибке отправлено.")
         return
     }
+    if (state == "" || state == StateIdle || state == StateImportConfirm) && m.Document != nil &&
+        b.isBoss(m.From.ID) && isImportFile(m.Document.FileName) {
+        b.onImportFile(m)
+        return
+    }
     if state == StateAwaitResult {
 		var pld struct{ TaskID int64 `json:"task_id"` }
 		if _, err := b.DB.LoadState(ctx, m.From.ID, &pld); err != nil {
@@ -321,30 +381,13 @@ func (b *Bot) handleMessage(m *tgbotapi.Message) {
 		}
 
 
-        var text *string
-        var fileID *string
-        fileKind := "" 
-
-        if m.Text != "" { t := m.Text; text = &t }
-        switch {
-        case m.Document != nil:
-            f := m.Document.FileID; fileID = &f; fileKind = "document"
-        case m.Voice != nil:
-            f := m.Voice.FileID; fileID = &f; fileKind = "voice"
-        case m.Audio != nil:
-            f := m.Audio.FileID; fileID = &f; fileKind = "audio"
-        case len(m.Photo) > 0:
-            f := m.Photo[len(m.Photo)-1].FileID; fileID = &f; fileKind = "photo"
-        case m.Video != nil:
-            f := m.Video.FileID; fileID = &f; fileKind = "video"
-        }
-
-        if text == nil && fileID == nil {
+        res := resultFromMessage(m, pld.TaskID, user.ID)
+        if res == nil {
             b.reply(m.Chat.ID, "Пришлите текст результата или файл.")
             return
         }
 
-        if err := b.DB.AddResult(ctx, pld.TaskID, user.ID, text, fileID); err != nil {
+        if err := b.DB.AddResult(ctx, res); err != nil {
             log.Println("add result:", err)
         }
         _ = b.DB.MarkAllRemindersSentFor(ctx, pld.TaskID, user.ID)
@@ -364,26 +407,8 @@ func (b *Bot) handleMessage(m *tgbotapi.Message) {
         if _, err := b.API.Send(tgbotapi.NewMessage(creator.TgID, head)); err != nil {
             log.Println("send head to boss:", err)
         }
-        if text != nil {
-            if _, err := b.API.Send(tgbotapi.NewMessage(creator.TgID, *text)); err != nil {
-                log.Println("send text to boss:", err)
-            }
-        }
-        if fileID != nil {
-            var _, err error
-            switch fileKind {
-            case "document":
-                _, err = b.API.Send(tgbotapi.NewDocument(creator.TgID, tgbotapi.FileID(*fileID)))
-            case "voice":
-                _, err = b.API.Send(tgbotapi.NewVoice(creator.TgID, tgbotapi.FileID(*fileID)))
-            case "audio":
-                _, err = b.API.Send(tgbotapi.NewAudio(creator.TgID, tgbotapi.FileID(*fileID)))
-            case "photo":
-                _, err = b.API.Send(tgbotapi.NewPhoto(creator.TgID, tgbotapi.FileID(*fileID)))
-            case "video":
-                _, err = b.API.Send(tgbotapi.NewVideo(creator.TgID, tgbotapi.FileID(*fileID)))
-            }
-            if err != nil { log.Println("send file to boss:", err) }
+        if err := b.sendResult(creator.TgID, res); err != nil {
+            log.Println("send result to boss:", err)
         }
 
         _ = b.DB.ClearState(ctx, m.From.ID)
@@ -423,6 +448,7 @@ func (b *Bot) sendDeptKeyboard(chatID int64) {
             tgbotapi.NewInlineKeyboardButtonData(d.Name, fmt.Sprintf("choose_dept:%d", d.ID)),
         ))
     }
+    rows = append(rows, flowRow(StateRegTeam))
     kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
     msg := tgbotapi.NewMessage(chatID, "Выберите ваш отдел кнопкой:")
     msg.ReplyMarkup = kb
@@ -433,36 +459,72 @@ func (b *Bot) sendDeptKeyboard(chatID int64) {
 func (b *Bot) onStart(m *tgbotapi.Message) {
     txt := "Привет! Зарегистрируйтесь как сотрудник: /register\nКоманды:\n/mytasks — мои задачи\n/teamtasks — задачи команды\n/menu — показать меню"
     if b.isBoss(m.From.ID) {
-        txt = "Вы Босс. Команды:\n/newtask — выдать задание\n/allactive — активные задачи\n/users — список сотрудников\n/del <tg_id> — удалить сотрудника\n/dept_add <name> — создать отдел\n/dept_list — список отделов\n/dept_del <id> — удалить отдел\n/menu — показать меню"
+        txt = "Вы Босс. Команды:\n/newtask — выдать задание\n/allactive — активные задачи\n/users — список сотрудников\n/del <tg_id> — удалить сотрудника\n/dept_add <name> — создать отдел\n/dept_list — список отделов\n/dept_rename <id> <name> — переименовать отдел\n/dept_del <id> — удалить отдел\n/menu — показать меню"
     }
     msg := tgbotapi.NewMessage(m.Chat.ID, txt)
     msg.ReplyMarkup = menuKB                     
     b.API.Send(msg)
 }
 
-func (b *Bot) askAssignees(chatID int64) {
+func (b *Bot) askAssignees(chatID int64, d *NewTaskDraft) {
     ctx := context.Background()
     deps, _ := b.DB.ListDepartments(ctx)
+    picked := map[int64]bool{}
+    for _, id := range d.DeptIDs { picked[id] = true }
 
     var rows [][]tgbotapi.InlineKeyboardButton
-    for _, d := range deps {
+    for _, dep := range deps {
+        label := "Отдел: "+dep.Name
+        if picked[dep.ID] { label = "✅ "+label }
         rows = append(rows,
             tgbotapi.NewInlineKeyboardRow(
-                tgbotapi.NewInlineKeyboardButtonData("Отдел: "+d.Name, fmt.Sprintf("toggle_dept:%d", d.ID)),
+                tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("toggle_dept:%d", dep.ID)),
             ),
         )
     }
     rows = append(rows,
         tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Выбрать из всех сотрудников", "pick_people")),
         tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Далее ▶", "assignees_next")),
+        flowRow(StateNewTaskAssignees),
     )
 
-    kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
-    msg := tgbotapi.NewMessage(chatID, "Выберите исполнителей: можно отметить несколько отделов и/или отдельных людей. Нажмите «Далее», когда закончите.")
-    msg.ReplyMarkup = kb
+    text := "Выберите исполнителей: можно отметить несколько отделов и/или отдельных людей. Нажмите «Далее», когда закончите."
+    if len(d.DeptIDs) > 0 || len(d.AssigneeIDs) > 0 {
+        text += fmt.Sprintf("\nУже выбрано: отделов — %d, сотрудников — %d.", len(d.DeptIDs), len(d.AssigneeIDs))
+    }
+    msg := tgbotapi.NewMessage(chatID, text)
+    msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+    b.API.Send(msg)
+}
+
+func (b *Bot) askReminders(chatID int64, d *NewTaskDraft) {
+    msg := tgbotapi.NewMessage(chatID, "Выберите пресет напоминаний или введите ЧАСЫ до дедлайна через запятую (например: 48,24,6).")
+    msg.ReplyMarkup = remindersMarkup(d)
     b.API.Send(msg)
 }
 
+func remindersMarkup(d *NewTaskDraft) tgbotapi.InlineKeyboardMarkup {
+    count := "⏱ Часы: календарные"
+    if d.BusinessHours { count = "⏱ Часы: рабочие" }
+    return tgbotapi.NewInlineKeyboardMarkup(
+        tgbotapi.NewInlineKeyboardRow(
+            tgbotapi.NewInlineKeyboardButtonData("48,24,6 ч", "rem_preset:48,24,6"),
+            tgbotapi.NewInlineKeyboardButtonData("24,12,1 ч", "rem_preset:24,12,1"),
+        ),
+        tgbotapi.NewInlineKeyboardRow(
+            tgbotapi.NewInlineKeyboardButtonData("6,3,1 ч", "rem_preset:6,3,1"),
+            tgbotapi.NewInlineKeyboardButtonData("Без напоминаний", "rem_none"),
+        ),
+        tgbotapi.NewInlineKeyboardRow(
+            tgbotapi.NewInlineKeyboardButtonData("Ввести вручную", "rem_custom"),
+        ),
+        tgbotapi.NewInlineKeyboardRow(
+            tgbotapi.NewInlineKeyboardButtonData(count, "rem_business"),
+        ),
+        flowRow(StateNewTaskReminders),
+    )
+}
+
 func (b *Bot) handleCallback(cq *tgbotapi.CallbackQuery) {
     ctx := context.Background()
     data := cq.Data
@@ -470,9 +532,53 @@ func (b *Bot) handleCallback(cq *tgbotapi.CallbackQuery) {
     role := "worker"; if b.isBoss(from.ID) { role = "boss" }
     _, _ = b.DB.UpsertUser(ctx, from.ID, strPtrIf(from.UserName != "", from.UserName), role)
 
+    if strings.HasPrefix(data, "confirm:") {
+        b.onConfirmCallback(cq)
+        return
+    }
+    if data == "body_done" {
+        b.onBodyDone(cq)
+        return
+    }
+    if strings.HasPrefix(data, "cal:") {
+        b.onCalendarCallback(cq)
+        return
+    }
+    if strings.HasPrefix(data, "flow:") {
+        b.onFlowCallback(cq)
+        return
+    }
+    if strings.HasPrefix(data, "draft:") {
+        b.onDraftCallback(cq)
+        return
+    }
+    if strings.HasPrefix(data, "snooze:") {
+        b.onSnoozeCallback(cq)
+        return
+    }
+    if strings.HasPrefix(data, "tz:") {
+        b.onTimezoneCallback(cq)
+        return
+    }
+    if strings.HasPrefix(data, "rec:") {
+        b.onRecurringCallback(cq)
+        return
+    }
+    if strings.HasPrefix(data, "tpl:") {
+        b.onTemplateCallback(cq)
+        return
+    }
+    if strings.HasPrefix(data, "import:") {
+        b.onImportCallback(cq)
+        return
+    }
     if strings.HasPrefix(data, "pick_team:") {
-        team := strings.TrimPrefix(data, "pick_team:")
-        workers, _ := b.DB.ListWorkersByTeam(ctx, team)
+        defer b.lockUser(from.ID)()
+        if _, ok := b.stepDraft(ctx, cq, StateNewTaskAssignees); !ok { return }
+        depID, _ := strconv.ParseInt(strings.TrimPrefix(data, "pick_team:"), 10, 64)
+        dep, err := b.DB.GetDepartmentByID(ctx, depID)
+        if err != nil { b.API.Request(tgbotapi.NewCallback(cq.ID, "Отдел не найден")); return }
+        workers, _ := b.DB.ListWorkersByDepartment(ctx, dep.ID)
         var rows [][]tgbotapi.InlineKeyboardButton
         for _, w := range workers {
             label := fmt.Sprintf("%s [%s]", b.userLabel(w), nullStr(w.Team))
@@ -485,16 +591,19 @@ func (b *Bot) handleCallback(cq *tgbotapi.CallbackQuery) {
         rows = append(rows,
             tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅ Назад", "assignees_menu")),
             tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Далее ▶", "assignees_next")),
+            flowRow(""),
         )
         kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
         edit := tgbotapi.NewEditMessageTextAndMarkup(cq.Message.Chat.ID, cq.Message.MessageID,
             "Отметьте сотрудников (повторное нажатие снимает выбор):", kb)
         b.API.Send(edit)
-        b.API.Request(tgbotapi.NewCallback(cq.ID, "Команда: "+team))
+        b.API.Request(tgbotapi.NewCallback(cq.ID, "Команда: "+dep.Name))
         return
     }
 
     if data == "pick_people" {
+        defer b.lockUser(from.ID)()
+        if _, ok := b.stepDraft(ctx, cq, StateNewTaskAssignees); !ok { return }
         workers, _ := b.DB.ListAllWorkers(ctx)
         var rows [][]tgbotapi.InlineKeyboardButton
         for _, w := range workers {
@@ -508,6 +617,7 @@ func (b *Bot) handleCallback(cq *tgbotapi.CallbackQuery) {
         rows = append(rows,
             tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅ Назад", "assignees_menu")),
             tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Далее ▶", "assignees_next")),
+            flowRow(""),
         )
         kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
         edit := tgbotapi.NewEditMessageTextAndMarkup(cq.Message.Chat.ID, cq.Message.MessageID,
@@ -518,13 +628,18 @@ func (b *Bot) handleCallback(cq *tgbotapi.CallbackQuery) {
     }
 
     if data == "assignees_menu" {
-        b.askAssignees(cq.Message.Chat.ID)
+        defer b.lockUser(from.ID)()
+        d, ok := b.stepDraft(ctx, cq, StateNewTaskAssignees)
+        if !ok { return }
+        b.askAssignees(cq.Message.Chat.ID, d)
         b.API.Request(tgbotapi.NewCallback(cq.ID, "Меню исполнителей"))
         return
     }
     if strings.HasPrefix(data, "toggle_user:") {
         tgID, _ := strconv.ParseInt(strings.TrimPrefix(data, "toggle_user:"), 10, 64)
-        d := &NewTaskDraft{}; b.DB.LoadState(ctx, from.ID, d)
+        defer b.lockUser(from.ID)()
+        d, ok := b.stepDraft(ctx, cq, StateNewTaskAssignees)
+        if !ok { return }
         if d.AssigneeIDs == nil { d.AssigneeIDs = []int64{} }
         found := false
         for i, id := range d.AssigneeIDs { if id == tgID { d.AssigneeIDs = append(d.AssigneeIDs[:i], d.AssigneeIDs[i+1:]...); found = true; break } }
@@ -534,45 +649,31 @@ func (b *Bot) handleCallback(cq *tgbotapi.CallbackQuery) {
         return
     }
     if data == "assignees_next" {
-        d := &NewTaskDraft{}
-        b.DB.LoadState(ctx, from.ID, d)
-        set := map[int64]struct{}{}
-        for _, id := range d.AssigneeIDs { set[id] = struct{}{} }
-
-        for _, depID := range d.DeptIDs {
-            dep, err := b.DB.GetDepartmentByID(ctx, depID)
-            if err != nil { continue }
-            workers, _ := b.DB.ListWorkersByTeam(ctx, dep.Name)
-            for _, w := range workers {
-                set[w.TgID] = struct{}{}
-            }
-        }
-        d.AssigneeIDs = d.AssigneeIDs[:0]
-        for tg := range set { d.AssigneeIDs = append(d.AssigneeIDs, tg) }
-        b.DB.SaveState(ctx, from.ID, StateNewTaskDeadline, d)
-
-        msg := tgbotapi.NewMessage(cq.Message.Chat.ID,
-            "Введите дедлайн в формате DD.MM.YYYY HH:MM (время по "+b.TZ.String()+")")
-        b.API.Send(msg)
+        defer b.lockUser(from.ID)()
+        d, ok := b.stepDraft(ctx, cq, StateNewTaskAssignees)
+        if !ok { return }
+        b.advance(cq.Message.Chat.ID, from.ID, d, StateNewTaskDeadline)
         b.API.Request(tgbotapi.NewCallback(cq.ID, "Выбор дедлайна"))
         return
     }
 
     if strings.HasPrefix(data, "rem_preset:") {
         raw := strings.TrimPrefix(data, "rem_preset:")
-        d := &NewTaskDraft{}; b.DB.LoadState(ctx, from.ID, d)
+        defer b.lockUser(from.ID)()
+        d, ok := b.stepDraft(ctx, cq, StateNewTaskReminders)
+        if !ok { return }
         hours, _ := b.parseReminderHours(raw)
         d.RemindHours = hours
-        b.createTaskFromDraft(cq.Message.Chat.ID, from.ID, d)
-        b.DB.ClearState(ctx, from.ID)
+        b.advance(cq.Message.Chat.ID, from.ID, d, StateNewTaskConfirm)
         b.API.Request(tgbotapi.NewCallback(cq.ID, "Пресет применён"))
         return
     }
     if strings.HasPrefix(data, "toggle_dept:") {
         depID, _ := strconv.ParseInt(strings.TrimPrefix(data, "toggle_dept:"), 10, 64)
 
-        d := &NewTaskDraft{}
-        b.DB.LoadState(ctx, from.ID, d)
+        defer b.lockUser(from.ID)()
+        d, ok := b.stepDraft(ctx, cq, StateNewTaskAssignees)
+        if !ok { return }
         if d.DeptIDs == nil { d.DeptIDs = []int64{} }
 
         found := false
@@ -593,19 +694,35 @@ func (b *Bot) handleCallback(cq *tgbotapi.CallbackQuery) {
         }
 
     if data == "rem_none" {
-        d := &NewTaskDraft{}; b.DB.LoadState(ctx, from.ID, d)
+        defer b.lockUser(from.ID)()
+        d, ok := b.stepDraft(ctx, cq, StateNewTaskReminders)
+        if !ok { return }
         d.RemindHours = []int{}
-        b.createTaskFromDraft(cq.Message.Chat.ID, from.ID, d)
-        b.DB.ClearState(ctx, from.ID)
+        b.advance(cq.Message.Chat.ID, from.ID, d, StateNewTaskConfirm)
         b.API.Request(tgbotapi.NewCallback(cq.ID, "Без напоминаний"))
         return
     }
+    if data == "rem_business" {
+        b.onRemBusinessToggle(cq)
+        return
+    }
     if data == "rem_custom" {
+        defer b.lockUser(from.ID)()
+        if _, ok := b.stepDraft(ctx, cq, StateNewTaskReminders); !ok { return }
         b.API.Request(tgbotapi.NewCallback(cq.ID, "Введите часы вручную"))
-        b.API.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Введите ЧАСЫ до дедлайна через запятую (например: 48,24,6)."))
+        b.prompt(cq.Message.Chat.ID, StateNewTaskReminders, "Введите ЧАСЫ до дедлайна через запятую (например: 48,24,6).")
         return
     }
 
+    if strings.HasPrefix(data, "dept_move:") {
+        if !b.isBoss(from.ID) { b.API.Request(tgbotapi.NewCallback(cq.ID, "Только для боссов.")); return }
+        b.onDeptMove(cq)
+        return
+    }
+    if strings.HasPrefix(data, "page:") {
+        b.onPageCallback(cq)
+        return
+    }
     if strings.HasPrefix(data, "task_action:") {
         parts := strings.Split(strings.TrimPrefix(data, "task_action:"), ":")
         if len(parts) != 2 { return }
@@ -627,31 +744,22 @@ func (b *Bot) handleCallback(cq *tgbotapi.CallbackQuery) {
         if name == "" { name = fmt.Sprintf("user-%d", from.ID) }
     }
 
-    dep, _ := b.DB.GetDepartmentByID(ctx, depID)
-    _ = b.DB.SetWorkerProfile(ctx, from.ID, name, dep.Name)
+    dep, err := b.DB.GetDepartmentByID(ctx, depID)
+    if err != nil {
+        b.API.Request(tgbotapi.NewCallback(cq.ID, "Отдел не найден"))
+        b.sendDeptKeyboard(cq.Message.Chat.ID)
+        return
+    }
+    _ = b.DB.SetWorkerProfile(ctx, from.ID, name, dep.ID)
 
     b.DB.ClearState(ctx, from.ID)
     b.API.Send(tgbotapi.NewMessage(cq.Message.Chat.ID,
         fmt.Sprintf("Готово! Вы зарегистрированы как сотрудник: %s (%s).", name, dep.Name)))
+    b.askTimezone(cq.Message.Chat.ID, "Дедлайны будут показаны по времени "+zoneLabel(b.zone(from.ID))+
+        ". Если вы в другом часовом поясе, выберите его (позже: /timezone):")
     b.API.Request(tgbotapi.NewCallback(cq.ID, "Отдел выбран"))
     return
 }
-    if strings.HasPrefix(data, "assign_team:") {
-	team := strings.TrimPrefix(data, "assign_team:")
-	workers, _ := b.DB.ListWorkersByTeam(ctx, team)
-	var tgIDs []int64
-	for _, w := range workers { tgIDs = append(tgIDs, w.TgID) }
-
-	d := &NewTaskDraft{}; b.DB.LoadState(ctx, from.ID, d)
-	d.AssigneeIDs = uniqAppend(d.AssigneeIDs, tgIDs...)
-	b.DB.SaveState(ctx, from.ID, StateNewTaskDeadline, d)
-
-	msg := tgbotapi.NewMessage(cq.Message.Chat.ID,
-		fmt.Sprintf("Назначено отделу «%s» (%d сотрудн.). Введите дедлайн в формате DD.MM.YYYY HH:MM.", team, len(tgIDs)))
-	b.API.Send(msg)
-	b.API.Request(tgbotapi.NewCallback(cq.ID, "Назначено отделу"))
-	return
-}
 
 }
 
@@ -663,6 +771,11 @@ func (b *Bot) onTaskAction(userTgID int64, cq *tgbotapi.CallbackQuery, action st
 		return
 	}
 
+	if t, err := b.DB.GetTask(ctx, taskID); err != nil || t.DeletedAt.Valid {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Задача удалена"))
+		return
+	}
+
 	switch action {
 	case "accept":
 		_, _ = b.DB.UpdateAssigneeStatus(ctx, taskID, u.ID, "in_progress")
@@ -709,43 +822,31 @@ func (b *Bot) onTaskAction(userTgID int64, cq *tgbotapi.CallbackQuery, action st
 	case "upload":
 		b.DB.SaveState(ctx, userTgID, StateAwaitResult, map[string]any{"task_id": taskID})
 		b.API.Request(tgbotapi.NewCallback(cq.ID, "Пришлите результат сообщением или файлом"))
-		b.API.Send(tgbotapi.NewMessage(cq.Message.Chat.ID, "Пришлите результат (текст/файл/голосовое)."))
+		b.prompt(cq.Message.Chat.ID, StateAwaitResult, "Пришлите результат (текст/файл/голосовое).")
 	}
 }
 
 
 
 func (b *Bot) cmdMyTasks(m *tgbotapi.Message) {
-    ctx := context.Background()
-    u, _ := b.DB.GetUserByTgID(ctx, m.From.ID)
-    ts, err := b.DB.ListActiveTasksForUser(ctx, u.ID)
-    if err != nil || len(ts) == 0 { b.reply(m.Chat.ID, "Нет активных задач."); return }
-    b.reply(m.Chat.ID, b.formatTasks(ts, false))
+    b.sendTaskPage(m.Chat.ID, 0, m.From.ID, listMyTasks, storage.PageRequest{})
 }
 
 func (b *Bot) cmdTeamTasks(m *tgbotapi.Message) {
-    ctx := context.Background()
-    u, _ := b.DB.GetUserByTgID(ctx, m.From.ID)
-    team := nullStr(u.Team)
-    if team == "" { b.reply(m.Chat.ID, "В вашем профиле не указана команда. Используйте /register."); return }
-    ts, err := b.DB.ListActiveTasksForTeam(ctx, team)
-    if err != nil || len(ts) == 0 { b.reply(m.Chat.ID, "Нет активных задач по вашей команде."); return }
-    b.reply(m.Chat.ID, b.formatTasks(ts, false))
+    b.sendTaskPage(m.Chat.ID, 0, m.From.ID, listTeamTasks, storage.PageRequest{})
 }
 
 func (b *Bot) cmdAllActive(m *tgbotapi.Message) {
-	ctx := context.Background()
-	ts, err := b.DB.ListActiveTasksForBoss(ctx)
-	if err != nil || len(ts) == 0 {
-		b.reply(m.Chat.ID, "Нет активных задач.")
-		return
-	}
+	b.sendTaskPage(m.Chat.ID, 0, m.From.ID, listAllActive, storage.PageRequest{})
+}
 
+func (b *Bot) formatAllActive(ts []*storage.Task, loc *time.Location) string {
+	ctx := context.Background()
 	var out strings.Builder
 	for _, t := range ts {
 		out.WriteString(fmt.Sprintf("• «%s»\n", nullStr(t.Title)))
 		if t.DueAt.Valid {
-			out.WriteString("  Дедлайн: " + t.DueAt.Time.Format("02.01.2006 15:04") + "\n")
+			out.WriteString("  Дедлайн: " + t.DueAt.Time.In(loc).Format("02.01.2006 15:04") + "\n")
 		}
 
 		ass, _ := b.DB.ListAssigneesWithUsersAny(ctx, t.ID)
@@ -769,7 +870,7 @@ func (b *Bot) cmdAllActive(m *tgbotapi.Message) {
 		}
 		out.WriteString("\n")
 	}
-	b.reply(m.Chat.ID, out.String())
+	return out.String()
 }
 
 
@@ -798,11 +899,11 @@ func (b *Bot) cmdDeleteUser(m *tgbotapi.Message) {
     b.reply(m.Chat.ID, "Сотрудник удалён. Его напоминания удалены, задачи остались без исполнителя.")
 }
 
-func (b *Bot) formatTasks(ts []*sqlite.Task, withAssignees bool) string {
+func (b *Bot) formatTasks(ts []*storage.Task, withAssignees bool, loc *time.Location) string {
     var bld strings.Builder
     for _, t := range ts {
         bld.WriteString(fmt.Sprintf("• %s\n", nullStr(t.Title)))
-        if t.DueAt.Valid { bld.WriteString("Дедлайн: "+t.DueAt.Time.Format("02.01.2006 15:04")+"\n") }
+        if t.DueAt.Valid { bld.WriteString("Дедлайн: "+t.DueAt.Time.In(loc).Format("02.01.2006 15:04")+"\n") }
         if withAssignees {
             ass, _ := b.DB.ListAssigneesWithUsers(context.Background(), t.ID)
             for _, a := range ass {
@@ -830,13 +931,6 @@ func ifEmpty(s, d string) string { if strings.TrimSpace(s)=="" { return d }; ret
 func nullStr(ns sql.NullString) string { if ns.Valid { return ns.String }; return "" }
 
 
-var dlRx = regexp.MustCompile(`^([0-2]\d|3[01])\.(0\d|1[0-2])\.\d{4}\s([01]\d|2[0-3]):([0-5]\d)$`)
-
-func (b *Bot) parseDeadline(s string) (time.Time, error) {
-    s = strings.TrimSpace(s)
-    if !dlRx.MatchString(s) { return time.Time{}, fmt.Errorf("bad format") }
-    return time.ParseInLocation("02.01.2006 15:04", s, b.TZ)
-}
 
 func (b *Bot) parseReminderHours(s string) ([]int, error) {
     s = strings.ReplaceAll(s, " ", "")
@@ -858,31 +952,16 @@ func (b *Bot) HandleTextFlow(m *tgbotapi.Message) bool {
     state, _ := b.DB.LoadState(ctx, m.From.ID, nil)
 
     if state == StateNewTaskDeadline {
-        deadline, err := b.parseDeadline(m.Text)
+        loc := b.zone(m.From.ID)
+        deadline, err := parseDeadline(m.Text, loc)
         if err != nil {
-            b.reply(m.Chat.ID, "Неверный формат. Пример: 28.08.2025 14:30")
+            b.reply(m.Chat.ID, deadlineError(deadline, err, loc))
             return true
         }
         d := &NewTaskDraft{}; b.DB.LoadState(ctx, m.From.ID, d)
         d.DueAt = deadline.Format(time.RFC3339)
-        b.DB.SaveState(ctx, m.From.ID, StateNewTaskReminders, d)
-
-        kb := tgbotapi.NewInlineKeyboardMarkup(
-            tgbotapi.NewInlineKeyboardRow(
-                tgbotapi.NewInlineKeyboardButtonData("48,24,6 ч", "rem_preset:48,24,6"),
-                tgbotapi.NewInlineKeyboardButtonData("24,12,1 ч", "rem_preset:24,12,1"),
-            ),
-            tgbotapi.NewInlineKeyboardRow(
-                tgbotapi.NewInlineKeyboardButtonData("6,3,1 ч", "rem_preset:6,3,1"),
-                tgbotapi.NewInlineKeyboardButtonData("Без напоминаний", "rem_none"),
-            ),
-            tgbotapi.NewInlineKeyboardRow(
-                tgbotapi.NewInlineKeyboardButtonData("Ввести вручную", "rem_custom"),
-            ),
-        )
-        msg := tgbotapi.NewMessage(m.Chat.ID, "Выберите пресет напоминаний или введите ЧАСЫ до дедлайна через запятую (например: 48,24,6).")
-        msg.ReplyMarkup = kb
-        b.API.Send(msg)
+        b.reply(m.Chat.ID, "Дедлайн: "+formatDeadline(deadline, loc)+".")
+        b.advance(m.Chat.ID, m.From.ID, d, StateNewTaskReminders)
         return true
     }
 
@@ -891,8 +970,12 @@ func (b *Bot) HandleTextFlow(m *tgbotapi.Message) bool {
         hours, err := b.parseReminderHours(m.Text)
         if err != nil { b.reply(m.Chat.ID, "Не получилось разобрать список часов, пример: 48,24,6"); return true }
         d.RemindHours = hours
-        b.createTaskFromDraft(m.Chat.ID, m.From.ID, d)
-        b.DB.ClearState(ctx, m.From.ID)
+        b.advance(m.Chat.ID, m.From.ID, d, StateNewTaskConfirm)
+        return true
+    }
+
+    if state == StateNewTaskRepeat {
+        b.onRepeatInput(m)
         return true
     }
     return false
@@ -902,69 +985,77 @@ func (b *Bot) createTaskFromDraft(chatID, bossTgID int64, d *NewTaskDraft) {
     ctx := context.Background()
     boss, _ := b.DB.GetUserByTgID(ctx, bossTgID)
 
-    var uids []int64
-    for _, tg := range d.AssigneeIDs {
-        u, err := b.DB.GetUserByTgID(ctx, tg)
-        if err != nil { continue }
-        uids = append(uids, u.ID)
-    }
-
-    var due sql.NullTime
-    if d.DueAt != "" {
-        if t, err := time.Parse(time.RFC3339, d.DueAt); err==nil { due = sql.NullTime{Time: t, Valid: true} }
-    }
-
-    task := &sqlite.Task{
-        CreatorID:   boss.ID,
-        Title:       sql.NullString{String: d.Title, Valid: d.Title != ""},
-        Description: sql.NullString{String: d.Description, Valid: d.Description != ""},
-        VoiceFileID: sql.NullString{String: d.VoiceFileID, Valid: d.VoiceFileID != ""},
-        DueAt:       due,
-    }
       if strings.TrimSpace(d.Title) == "" {
         b.reply(chatID, "Название задачи пустое — пропустил создание.")
         _ = b.DB.ClearState(context.Background(), bossTgID)
         return
     }
-    if strings.TrimSpace(d.Description) == "" && strings.TrimSpace(d.VoiceFileID) == "" {
+    if strings.TrimSpace(d.Description) == "" && len(d.Attachments) == 0 {
         b.reply(chatID, "Содержание пустое — пропустил создание.")
         _ = b.DB.ClearState(context.Background(), bossTgID)
         return
     }
-    id, err := b.DB.CreateTask(ctx, task, uids)
+    if d.Repeat != "" {
+        b.createRecurringFromDraft(chatID, boss, d)
+        return
+    }
+    expandDraft(d, time.Now().In(b.userZone(boss)))
+
+    created, tgIDs, err := b.createDraftTask(ctx, boss.ID, d)
     if err != nil { b.reply(chatID, "Ошибка создания задачи: "+err.Error()); return }
+    b.reply(chatID, fmt.Sprintf("Задача «%s» создана и отправлена %d исполнителям.",
+        nullStr(created.Task.Title), len(tgIDs)))
 
-    if due.Valid {
-    now := time.Now().In(b.TZ).Add(5 * time.Second)
+}
 
-    var beforeTimes []time.Time
-    for _, h := range d.RemindHours {
-        t := due.Time.Add(-time.Duration(h) * time.Hour)
-        if t.After(now) { beforeTimes = append(beforeTimes, t) } 
-    }
-    if len(beforeTimes) > 0 { _ = b.DB.CreateReminders(ctx, id, uids, beforeTimes, "before") }
+// createDraftTask stores the task d describes, with its reminders and
+// attachments, and sends it to the assignees it resolves to now.
+func (b *Bot) createDraftTask(ctx context.Context, creatorID int64, d *NewTaskDraft) (*storage.CreatedTask, []int64, error) {
+    nt, tgIDs := b.draftNewTask(ctx, creatorID, d)
+    created, err := b.DB.CreateTask(ctx, nt)
+    if err != nil { return nil, nil, err }
 
-    if due.Time.After(now) {
-        _ = b.DB.CreateReminders(ctx, id, uids, []time.Time{due.Time}, "deadline")
-    }
-    ov := due.Time.Add(15 * time.Minute)
-    if ov.After(now) {
-        _ = b.DB.CreateReminders(ctx, id, uids, []time.Time{ov}, "overdue")
-    }
+    for _, tg := range tgIDs { b.sendTaskToAssignee(tg, created.Task.ID, created.Task) }
+    return created, tgIDs, nil
 }
 
+// draftNewTask is the task d describes and the Telegram ids of its
+// assignees.
+func (b *Bot) draftNewTask(ctx context.Context, creatorID int64, d *NewTaskDraft) (storage.NewTask, []int64) {
+    tgIDs := b.draftAssignees(ctx, d)
+    var uids []int64
+    for _, tg := range tgIDs {
+        u, err := b.DB.GetUserByTgID(ctx, tg)
+        if err != nil { continue }
+        uids = append(uids, u.ID)
+    }
 
-    for _, tg := range d.AssigneeIDs { b.sendTaskToAssignee(tg, id, task) }
-    b.reply(chatID, fmt.Sprintf("Задача «%s» создана и отправлена %d исполнителям.",
-        nullStr(task.Title), len(d.AssigneeIDs)))
+    task := &storage.Task{
+        CreatorID:   creatorID,
+        Title:       sql.NullString{String: d.Title, Valid: d.Title != ""},
+        Description: sql.NullString{String: d.Description, Valid: d.Description != ""},
+        DueAt:       draftDue(d),
+    }
+    reminders := b.draftReminders(ctx, d)
+
+    var atts []*storage.Attachment
+    for _, a := range d.Attachments {
+        atts = append(atts, &storage.Attachment{
+            Kind:     a.Kind,
+            FileID:   a.FileID,
+            FileName: sql.NullString{String: a.FileName, Valid: a.FileName != ""},
+            Caption:  sql.NullString{String: a.Caption, Valid: a.Caption != ""},
+        })
+    }
 
+    return storage.NewTask{Task: task, AssigneeIDs: uids, Reminders: reminders, Attachments: atts}, tgIDs
 }
 
-func (b *Bot) sendTaskToAssignee(tgID int64, taskID int64, t *sqlite.Task) {
+func (b *Bot) sendTaskToAssignee(tgID int64, taskID int64, t *storage.Task) {
     var text strings.Builder
     fmt.Fprintf(&text, "Задача «%s»\n", nullStr(t.Title))
     if t.Description.Valid { text.WriteString("\n"+t.Description.String+"\n") }
-    if t.DueAt.Valid { text.WriteString("\nДедлайн: "+t.DueAt.Time.Format("02.01.2006 15:04")+"\n") }
+    if t.DueAt.Valid { text.WriteString("\nДедлайн: "+t.DueAt.Time.In(b.zone(tgID)).Format("02.01.2006 15:04")+"\n") }
     kb := tgbotapi.NewInlineKeyboardMarkup(
         tgbotapi.NewInlineKeyboardRow(
             tgbotapi.NewInlineKeyboardButtonData("🚀 В работу", fmt.Sprintf("task_action:accept:%d", taskID)),
@@ -976,7 +1067,7 @@ func (b *Bot) sendTaskToAssignee(tgID int64, taskID int64, t *sqlite.Task) {
     )
     msg := tgbotapi.NewMessage(tgID, text.String()); msg.ReplyMarkup = kb
     b.API.Send(msg)
-    if t.VoiceFileID.Valid { b.API.Send(tgbotapi.NewVoice(tgID, tgbotapi.FileID(t.VoiceFileID.String))) }
+    if atts, err := b.DB.ListAttachments(context.Background(), taskID); err == nil { b.sendAttachments(tgID, atts) }
 }
 
 func strPtrIf(cond bool, s string) *string { if cond { 
@@ -985,7 +1076,7 @@ func strPtrIf(cond bool, s string) *string { if cond {
 	return nil 
 }
 
-func (b *Bot) userLabel(u *sqlite.User) string {
+func (b *Bot) userLabel(u *storage.User) string {
     if n := nullStr(u.Name); n != "" { return n }
     if un := nullStr(u.Username); un != "" { return "@" + un }
     return fmt.Sprintf("%d", u.TgID)
@@ -993,15 +1084,11 @@ func (b *Bot) userLabel(u *sqlite.User) string {
 
 
 func (b *Bot) cmdDone(m *tgbotapi.Message) {
-	ctx := context.Background()
-
-	boss, _ := b.DB.GetUserByTgID(ctx, m.From.ID)  
-	ts, comps, err := b.DB.ListDoneTasksForBoss(ctx, boss.ID, 50) 
-	if err != nil || len(ts) == 0 {
-		b.reply(m.Chat.ID, "Выполненных задач пока нет.")
-		return
-	}
+	b.sendTaskPage(m.Chat.ID, 0, m.From.ID, listDone, storage.PageRequest{})
+}
 
+func (b *Bot) formatDone(ts []*storage.Task, comps []time.Time, loc *time.Location) string {
+	ctx := context.Background()
 	var sb strings.Builder
 	sb.WriteString("Выполненные задачи:\n")
 	for i, t := range ts {
@@ -1013,27 +1100,27 @@ func (b *Bot) cmdDone(m *tgbotapi.Message) {
 			if un != "" { un = "(@" + un + ")" }
 			who = append(who, strings.TrimSpace(name+" "+un))
 		}
-		when := comps[i].In(b.TZ).Format("02.01 15:04")
+		when := comps[i].In(loc).Format("02.01 15:04")
 		sb.WriteString(fmt.Sprintf("• «%s» (готово: %s)\n  Исполнители: %s\n",
 			nullStr(t.Title), when, strings.Join(who, ", ")))
 	}
-	b.reply(m.Chat.ID, sb.String())
+	return sb.String()
 }
 
 
 
 func (b *Bot) cmdMyDone(m *tgbotapi.Message) {
-    ctx := context.Background()
-    u, _ := b.DB.GetUserByTgID(ctx, m.From.ID)
-    ts, comps, err := b.DB.ListDoneTasksForUser(ctx, u.ID, 30)
-    if err != nil || len(ts) == 0 { b.reply(m.Chat.ID, "У вас пока нет выполненных задач."); return }
+    b.sendTaskPage(m.Chat.ID, 0, m.From.ID, listMyDone, storage.PageRequest{})
+}
+
+func (b *Bot) formatMyDone(ts []*storage.Task, comps []time.Time, loc *time.Location) string {
     var sb strings.Builder
     sb.WriteString("Ваши выполненные задачи:\n")
     for i, t := range ts {
         sb.WriteString(fmt.Sprintf("• «%s» (готово: %s)\n",
-            nullStr(t.Title), comps[i].In(b.TZ).Format("02.01 15:04")))
+            nullStr(t.Title), comps[i].In(loc).Format("02.01 15:04")))
     }
-    b.reply(m.Chat.ID, sb.String())
+    return sb.String()
 }
 
 
@@ -1076,14 +1163,15 @@ func (b *Bot) cmdTaskDel(m *tgbotapi.Message) {
 	if err != nil { b.reply(m.Chat.ID, "Задача не найдена."); return }
 	tgIDs, _ := b.DB.ListAssigneeTgIDsByTask(ctx, taskID)
 
-	aff, err := b.DB.DeleteTask(ctx, taskID)
+	boss, _ := b.DB.GetUserByTgID(ctx, m.From.ID)
+	aff, err := b.DB.DeleteTask(ctx, taskID, boss.ID)
 	if err != nil || aff == 0 { b.reply(m.Chat.ID, "Не удалось удалить."); return }
 
 	title := nullStr(t.Title)
 	for _, tg := range tgIDs {
 		b.API.Send(tgbotapi.NewMessage(tg, "❌ Задача «"+title+"» удалена боссом."))
 	}
-	b.reply(m.Chat.ID, "Удалено.")
+	b.reply(m.Chat.ID, fmt.Sprintf("Перемещено в корзину. Восстановить: /task_restore %d", taskID))
 }
 
 func (b *Bot) cmdTaskDelAll(m *tgbotapi.Message) {
@@ -1097,12 +1185,13 @@ func (b *Bot) cmdTaskDelAll(m *tgbotapi.Message) {
 			b.API.Send(tgbotapi.NewMessage(tg, "❌ Задача «"+title+"» удалена боссом."))
 		}
 	}
-	aff, err := b.DB.DeleteAllTasks(ctx)
+	boss, _ := b.DB.GetUserByTgID(ctx, m.From.ID)
+	aff, err := b.DB.DeleteAllTasks(ctx, boss.ID)
 	if err != nil {
 		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
 		return
 	}
-	b.reply(m.Chat.ID, fmt.Sprintf("Удалено задач: %d", aff))
+	b.reply(m.Chat.ID, fmt.Sprintf("Перемещено в корзину задач: %d\nСписок: /trash", aff))
 }
 
 func uniqAppend(dst []int64, more ...int64) []int64 {
@@ -1128,6 +1217,35 @@ func (b *Bot) cmdTaskFind(m *tgbotapi.Message) {
     b.reply(m.Chat.ID, sb.String())
 }
 
+func (b *Bot) cmdSearch(m *tgbotapi.Message) {
+	ctx := context.Background()
+	q := strings.TrimSpace(m.CommandArguments())
+	if len(storage.SearchTerms(q)) == 0 {
+		b.reply(m.Chat.ID, "Использование: /search <слова>\nИщет по названию, описанию и текстам результатов.")
+		return
+	}
+
+	var assigneeID int64
+	if !b.isBoss(m.From.ID) {
+		u, err := b.DB.GetUserByTgID(ctx, m.From.ID)
+		if err != nil { b.reply(m.Chat.ID, "Сначала зарегистрируйтесь: /register"); return }
+		assigneeID = u.ID
+	}
+	hits, err := b.DB.SearchTasks(ctx, q, assigneeID, 10)
+	if err != nil { b.reply(m.Chat.ID, "Ошибка: "+err.Error()); return }
+	if len(hits) == 0 { b.reply(m.Chat.ID, "Ничего не найдено."); return }
+
+	var sb strings.Builder
+	sb.WriteString("Найдено:\n")
+	for _, h := range hits {
+		sb.WriteString(fmt.Sprintf("\n[%d] «%s»\n", h.Task.ID, nullStr(h.Task.Title)))
+		if snip := strings.Join(strings.Fields(h.Snippet), " "); snip != "" {
+			sb.WriteString("   " + snip + "\n")
+		}
+	}
+	b.reply(m.Chat.ID, sb.String())
+}
+
 func (b *Bot) cmdTaskDelByName(m *tgbotapi.Message) {
     ctx := context.Background()
     title := strings.TrimSpace(m.CommandArguments())
@@ -1141,10 +1259,11 @@ func (b *Bot) cmdTaskDelByName(m *tgbotapi.Message) {
         for _, tg := range tgIDs { notif[tg] = title }
     }
 
-    n, err := b.DB.DeleteTasksByExactTitle(ctx, title)
+    boss, _ := b.DB.GetUserByTgID(ctx, m.From.ID)
+    n, err := b.DB.DeleteTasksByExactTitle(ctx, title, boss.ID)
     if err != nil { b.reply(m.Chat.ID, "Ошибка: "+err.Error()); return }
     for tg, nm := range notif {
         b.API.Send(tgbotapi.NewMessage(tg, "❌ Задача «"+nm+"» удалена боссом."))
     }
-    b.reply(m.Chat.ID, fmt.Sprintf("Удалено задач: %d", n))
+    b.reply(m.Chat.ID, fmt.Sprintf("Перемещено в корзину задач: %d\nСписок: /trash", n))
 }
@@ -0,0 +1,207 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// An inline date-time picker: month grid, then hour, then minutes, all in
+// one message edited in place. Callback data is
+// "cal:<purpose>:<target>:<step>:<value>" where step is
+//
+//	m  show the month,          value YYYYMM
+//	d  day picked, show hours,  value YYYYMMDD
+//	h  hour picked, show mins,  value YYYYMMDDHH
+//	t  time picked,             value YYYYMMDDHHMM
+//
+// and "cal:x" is a button that does nothing (headers, past days).
+// The purpose selects what happens with the picked time; target is an id it
+// may need, such as a task, and 0 otherwise.
+
+// calendarPurpose is a prompt that uses the picker.
+type calendarPurpose struct {
+	// picked receives the chosen time, in the user's zone and never in the past.
+	picked func(b *Bot, cq *tgbotapi.CallbackQuery, target int64, at time.Time)
+	// footer returns rows shown under every view of the picker.
+	footer func(b *Bot, tgID int64) [][]tgbotapi.InlineKeyboardButton
+}
+
+const calNewTaskDeadline = "dl"
+
+// calendarPurposes is filled in init: the handlers lead back to the picker,
+// which a package-level initializer may not reference.
+var calendarPurposes map[string]calendarPurpose
+
+func init() {
+	calendarPurposes = map[string]calendarPurpose{
+		calNewTaskDeadline: {picked: (*Bot).onNewTaskDeadlinePicked, footer: (*Bot).newTaskDeadlineFooter},
+	}
+}
+
+var monthNames = [...]string{"Январь", "Февраль", "Март", "Апрель", "Май", "Июнь", "Июль", "Август", "Сентябрь", "Октябрь", "Ноябрь", "Декабрь"}
+
+var calNoop = tgbotapi.NewInlineKeyboardButtonData(" ", "cal:x")
+
+func calData(purpose string, target int64, step, value string) string {
+	return fmt.Sprintf("cal:%s:%d:%s:%s", purpose, target, step, value)
+}
+
+// sendCalendar starts a picker for purpose with text above it.
+func (b *Bot) sendCalendar(chatID, tgID int64, purpose string, target int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = b.calendarMonth(tgID, purpose, target, time.Now().In(b.zone(tgID)))
+	b.API.Send(msg)
+}
+
+func (b *Bot) calendarMonth(tgID int64, purpose string, target int64, month time.Time) tgbotapi.InlineKeyboardMarkup {
+	loc := month.Location()
+	now := time.Now().In(loc)
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	prev := calNoop
+	if first.After(today) {
+		prev = tgbotapi.NewInlineKeyboardButtonData("◀", calData(purpose, target, "m", first.AddDate(0, -1, 0).Format("200601")))
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		{
+			prev,
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s %d", monthNames[first.Month()-1], first.Year()), "cal:x"),
+			tgbotapi.NewInlineKeyboardButtonData("▶", calData(purpose, target, "m", first.AddDate(0, 1, 0).Format("200601"))),
+		},
+	}
+	var head []tgbotapi.InlineKeyboardButton
+	for _, wd := range []string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"} {
+		head = append(head, tgbotapi.NewInlineKeyboardButtonData(wd, "cal:x"))
+	}
+	rows = append(rows, head)
+
+	// weeks that are wholly in the past are left out
+	week := make([]tgbotapi.InlineKeyboardButton, (int(first.Weekday())+6)%7)
+	for i := range week {
+		week[i] = calNoop
+	}
+	open := false
+	for day := first; day.Month() == first.Month(); day = day.AddDate(0, 0, 1) {
+		btn := calNoop
+		switch {
+		case day.Equal(today):
+			btn, open = tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("•%d", day.Day()), calData(purpose, target, "d", day.Format("20060102"))), true
+		case day.After(today):
+			btn, open = tgbotapi.NewInlineKeyboardButtonData(strconv.Itoa(day.Day()), calData(purpose, target, "d", day.Format("20060102"))), true
+		}
+		week = append(week, btn)
+		if len(week) == 7 {
+			if open {
+				rows = append(rows, week)
+			}
+			week, open = nil, false
+		}
+	}
+	if len(week) > 0 {
+		for len(week) < 7 {
+			week = append(week, calNoop)
+		}
+		rows = append(rows, week)
+	}
+	return b.calendarMarkup(tgID, purpose, rows)
+}
+
+func (b *Bot) calendarHours(tgID int64, purpose string, target int64, day time.Time) tgbotapi.InlineKeyboardMarkup {
+	loc := day.Location()
+	now := time.Now().In(loc)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for h := 0; h < 24; h++ {
+		at := time.Date(day.Year(), day.Month(), day.Day(), h, 59, 0, 0, loc)
+		btn := calNoop
+		if at.After(now) {
+			btn = tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%02d", h), calData(purpose, target, "h", day.Format("20060102")+fmt.Sprintf("%02d", h)))
+		}
+		row = append(row, btn)
+		if len(row) == 6 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if eod := time.Date(day.Year(), day.Month(), day.Day(), workdayEndHour, 0, 0, 0, loc); eod.After(now) {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("Конец рабочего дня — %02d:00", workdayEndHour), calData(purpose, target, "t", eod.Format("200601021504")))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅ К календарю", calData(purpose, target, "m", day.Format("200601")))))
+	return b.calendarMarkup(tgID, purpose, rows)
+}
+
+func (b *Bot) calendarMinutes(tgID int64, purpose string, target int64, hour time.Time) tgbotapi.InlineKeyboardMarkup {
+	now := time.Now()
+	var row []tgbotapi.InlineKeyboardButton
+	for _, m := range []int{0, 15, 30, 45} {
+		at := hour.Add(time.Duration(m) * time.Minute)
+		btn := calNoop
+		if at.After(now) {
+			btn = tgbotapi.NewInlineKeyboardButtonData(at.Format("15:04"), calData(purpose, target, "t", at.Format("200601021504")))
+		}
+		row = append(row, btn)
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{row, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅ К часам", calData(purpose, target, "d", hour.Format("20060102"))))}
+	return b.calendarMarkup(tgID, purpose, rows)
+}
+
+func (b *Bot) calendarMarkup(tgID int64, purpose string, rows [][]tgbotapi.InlineKeyboardButton) tgbotapi.InlineKeyboardMarkup {
+	if p := calendarPurposes[purpose]; p.footer != nil {
+		rows = append(rows, p.footer(b, tgID)...)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func (b *Bot) onCalendarCallback(cq *tgbotapi.CallbackQuery) {
+	parts := strings.Split(cq.Data, ":")
+	if len(parts) != 5 || cq.Message == nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+		return
+	}
+	purpose, step, value := parts[1], parts[3], parts[4]
+	p, ok := calendarPurposes[purpose]
+	target, err := strconv.ParseInt(parts[2], 10, 64)
+	if !ok || err != nil {
+		return
+	}
+	layouts := map[string]string{"m": "200601", "d": "20060102", "h": "2006010215", "t": "200601021504"}
+	layout, ok := layouts[step]
+	if !ok {
+		return
+	}
+	at, err := time.ParseInLocation(layout, value, b.zone(cq.From.ID))
+	if err != nil {
+		return
+	}
+
+	chatID, msgID := cq.Message.Chat.ID, cq.Message.MessageID
+	var text string
+	var kb tgbotapi.InlineKeyboardMarkup
+	switch step {
+	case "m":
+		text, kb = "Выберите дату:", b.calendarMonth(cq.From.ID, purpose, target, at)
+	case "d":
+		text = at.Format("02.01.2006") + " (" + shortWeekdays[at.Weekday()] + "). Выберите час:"
+		kb = b.calendarHours(cq.From.ID, purpose, target, at)
+	case "h":
+		text = at.Format("02.01.2006 15") + ":__. Выберите минуты:"
+		kb = b.calendarMinutes(cq.From.ID, purpose, target, at)
+	case "t":
+		if !at.After(time.Now()) {
+			b.API.Request(tgbotapi.NewCallback(cq.ID, "Это время уже прошло"))
+			return
+		}
+		p.picked(b, cq, target, at)
+		return
+	}
+	b.API.Send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, text, kb))
+	b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+}
@@ -0,0 +1,231 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// maxCardDescription caps the description shown on the confirmation card.
+const maxCardDescription = 500
+
+// draftAssignees resolves the Telegram ids the task goes to: the people
+// picked one by one plus the current members of the picked departments.
+// Departments are expanded only here, so unticking one on the way back
+// leaves no trace of its workers.
+func (b *Bot) draftAssignees(ctx context.Context, d *NewTaskDraft) []int64 {
+	tgIDs := uniqAppend(nil, d.AssigneeIDs...)
+	for _, depID := range d.DeptIDs {
+		workers, _ := b.DB.ListWorkersByDepartment(ctx, depID)
+		for _, w := range workers {
+			tgIDs = uniqAppend(tgIDs, w.TgID)
+		}
+	}
+	return tgIDs
+}
+
+func draftDue(d *NewTaskDraft) sql.NullTime {
+	t, err := time.Parse(time.RFC3339, d.DueAt)
+	if err != nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t, Valid: true}
+}
+
+// draftReminders schedules a reminder RemindHours before the deadline and
+// one at the deadline, skipping those already past; what follows the
+// deadline is left to escalation.
+// With BusinessHours the hours before the deadline are counted in each
+// assignee's working hours, so those reminders are per assignee.
+func (b *Bot) draftReminders(ctx context.Context, d *NewTaskDraft) []storage.NewReminder {
+	due := draftDue(d)
+	if !due.Valid {
+		return nil
+	}
+	var reminders []storage.NewReminder
+	now := time.Now().Add(5 * time.Second)
+	before := func(userID int64, w WorkHours, loc *time.Location) {
+		for _, h := range d.RemindHours {
+			t := w.before(due.Time.In(loc), time.Duration(h)*time.Hour)
+			if t.After(now) {
+				reminders = append(reminders, storage.NewReminder{At: t, Kind: "before", UserID: userID})
+			}
+		}
+	}
+	if !d.BusinessHours {
+		before(0, WorkHours{}, time.UTC)
+	} else {
+		for _, tg := range b.draftAssignees(ctx, d) {
+			u, err := b.DB.GetUserByTgID(ctx, tg)
+			if err != nil {
+				continue
+			}
+			w, _ := b.workHours(ctx, u)
+			before(u.ID, w, b.userZone(u))
+		}
+	}
+	if due.Time.After(now) {
+		reminders = append(reminders, storage.NewReminder{At: due.Time, Kind: "deadline"})
+	}
+	return reminders
+}
+
+// showConfirm sends the summary of a finished draft. Nothing is sent to
+// workers until the boss presses "✅ Создать".
+func (b *Bot) showConfirm(chatID int64, d *NewTaskDraft) {
+	ctx := context.Background()
+	var sb strings.Builder
+	sb.WriteString("Проверьте задачу перед отправкой.\n\n")
+	// placeholders are shown filled in for today
+	today := time.Now().In(b.zone(chatID))
+	title := expandPlaceholders(d.Title, today)
+	sb.WriteString("Название: «" + title + "»\n")
+	if title != d.Title {
+		sb.WriteString("Шаблон названия: " + d.Title + "\n")
+	}
+
+	if d.Description != "" {
+		desc := expandPlaceholders(d.Description, today)
+		sb.WriteString("Описание:\n" + truncateText(desc, maxCardDescription) + "\n")
+	}
+	if len(d.Attachments) > 0 {
+		sb.WriteString(fmt.Sprintf("Вложений: %d\n", len(d.Attachments)))
+	}
+
+	tgIDs := b.draftAssignees(ctx, d)
+	sb.WriteString(fmt.Sprintf("\nИсполнители (%d):\n", len(tgIDs)))
+	for _, depID := range d.DeptIDs {
+		dep, err := b.DB.GetDepartmentByID(ctx, depID)
+		if err != nil {
+			continue
+		}
+		workers, _ := b.DB.ListWorkersByDepartment(ctx, depID)
+		var names []string
+		for _, w := range workers {
+			names = append(names, b.userLabel(w))
+		}
+		sb.WriteString(fmt.Sprintf("- отдел «%s»: %s\n", dep.Name, ifEmpty(strings.Join(names, ", "), "нет сотрудников")))
+	}
+	for _, tg := range d.AssigneeIDs {
+		if u, err := b.DB.GetUserByTgID(ctx, tg); err == nil {
+			sb.WriteString("- " + b.userLabel(u) + "\n")
+		}
+	}
+	if len(tgIDs) == 0 {
+		sb.WriteString("никто не выбран — задача попадёт в список задач без исполнителей\n")
+	}
+
+	due := draftDue(d)
+	switch {
+	case d.Repeat != "":
+		sb.WriteString("\n" + b.repeatSummary(chatID, d))
+	case !due.Valid:
+		sb.WriteString("\nДедлайн: нет\n")
+	default:
+		loc := b.zone(chatID)
+		sb.WriteString("\nДедлайн: " + due.Time.In(loc).Format("02.01.2006 15:04") + "\n")
+		reminders := b.draftReminders(ctx, d)
+		if len(reminders) == 0 {
+			sb.WriteString("Напоминания: нет\n")
+		} else {
+			sb.WriteString("Напоминания:\n")
+		}
+		if d.BusinessHours && len(d.RemindHours) > 0 {
+			var hs []string
+			for _, h := range d.RemindHours {
+				hs = append(hs, strconv.Itoa(h))
+			}
+			sb.WriteString("- за " + strings.Join(hs, ", ") + " ч до дедлайна по рабочему времени каждого исполнителя\n")
+		}
+		for _, r := range reminders {
+			if r.UserID != 0 {
+				continue
+			}
+			what := "заранее"
+			if r.Kind == "deadline" {
+				what = "в дедлайн"
+			}
+			sb.WriteString("- " + r.At.In(loc).Format("02.01 15:04") + " — " + what + "\n")
+		}
+		if len(b.Escalation) > 0 {
+			sb.WriteString("При просрочке: " + escalationSummary(b.Escalation) + "\n")
+		}
+	}
+
+	repeatButton := "🔁 Повторять"
+	if d.Repeat != "" {
+		repeatButton = "🔁 Изменить повтор"
+	}
+	msg := tgbotapi.NewMessage(chatID, truncateText(sb.String(), maxPageText))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Создать", "confirm:create"),
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить поле", "confirm:edit"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(repeatButton, "confirm:field:"+StateNewTaskRepeat),
+			tgbotapi.NewInlineKeyboardButtonData("💾 В шаблоны", "confirm:template"),
+		),
+		flowRow(StateNewTaskConfirm),
+	)
+	b.API.Send(msg)
+}
+
+// onConfirmCallback handles the card buttons: "confirm:create",
+// "confirm:edit" (pick a field), "confirm:field:<step>" and
+// "confirm:template".
+func (b *Bot) onConfirmCallback(cq *tgbotapi.CallbackQuery) {
+	ctx := context.Background()
+	if cq.Message == nil {
+		return
+	}
+	chatID, msgID := cq.Message.Chat.ID, cq.Message.MessageID
+	defer b.lockUser(cq.From.ID)()
+
+	d := &NewTaskDraft{}
+	if state, _ := b.DB.LoadState(ctx, cq.From.ID, d); state != StateNewTaskConfirm {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Черновик уже отправлен или отменён"))
+		return
+	}
+
+	switch {
+	case cq.Data == "confirm:create":
+		// cleared first, so a second tap cannot create the task twice
+		_ = b.DB.ClearState(ctx, cq.From.ID)
+		b.API.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, msgID, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Создаю задачу"))
+		b.createTaskFromDraft(chatID, cq.From.ID, d)
+
+	case cq.Data == "confirm:template":
+		b.onConfirmTemplate(ctx, cq, d)
+
+	case cq.Data == "confirm:edit":
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for _, step := range []string{StateNewTaskTitle, StateNewTaskBody, StateNewTaskAssignees, StateNewTaskDeadline, StateNewTaskReminders, StateNewTaskRepeat} {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("✏️ "+newTaskSteps[step], "confirm:field:"+step),
+			))
+		}
+		rows = append(rows, flowRow(StateNewTaskConfirm))
+		b.API.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, msgID, tgbotapi.NewInlineKeyboardMarkup(rows...)))
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Что изменить?"))
+
+	case strings.HasPrefix(cq.Data, "confirm:field:"):
+		step := strings.TrimPrefix(cq.Data, "confirm:field:")
+		if _, ok := newTaskSteps[step]; !ok || step == StateNewTaskConfirm {
+			return
+		}
+		d.Editing = true
+		b.DB.SaveState(ctx, cq.From.ID, step, d)
+		b.API.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, msgID, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+		b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+		b.askNewTaskStep(chatID, step, d)
+	}
+}
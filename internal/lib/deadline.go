@@ -0,0 +1,265 @@
+package lib
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errDeadlineFormat = errors.New("unrecognised deadline")
+	errDeadlinePast   = errors.New("deadline is in the past")
+)
+
+// workdayEndHour is the time of day given to deadlines entered without one.
+const workdayEndHour = 18
+
+// deadlineHelp lists what parseDeadline understands.
+const deadlineHelp = "Примеры: 25.10.2025 18:00, 25.10, завтра 18:00, пятница, в пн 10:00, +3д, через 2 часа, 2025-10-25T18:00, tomorrow 6pm. Дата без времени — конец рабочего дня (18:00)."
+
+var (
+	clockRx    = regexp.MustCompile(`(?:^|\s)(?:в|к|at|by)?\s*(\d{1,2}):(\d{2})(?:\s*(am|pm))?(?:\s|$)`)
+	ampmRx     = regexp.MustCompile(`(?:^|\s)(?:at|by)?\s*(\d{1,2})\s*(am|pm)(?:\s|$)`)
+	dateRx     = regexp.MustCompile(`^(\d{1,2})\.(\d{1,2})(?:\.(\d{4}|\d{2}))?$`)
+	relativeRx = regexp.MustCompile(`^(?:\+|через\s+|in\s+)(\d+|an?)?\s*([a-zа-я]+)$`)
+)
+
+// relativeUnits maps the unit words of "+3д", "через 2 часа", "in 3 days".
+var relativeUnits = map[string]time.Duration{}
+
+func init() {
+	units := []struct {
+		d     time.Duration
+		words string
+	}{
+		{time.Minute, "м мин минуту минуты минут m min mins minute minutes"},
+		{time.Hour, "ч час часа часов h hr hrs hour hours"},
+		{24 * time.Hour, "д дн день дня дней d day days"},
+		{7 * 24 * time.Hour, "н нед неделю недели недель w wk week weeks"},
+	}
+	for _, u := range units {
+		for _, w := range strings.Fields(u.words) {
+			relativeUnits[w] = u.d
+		}
+	}
+}
+
+// weekdays maps Russian and English day names, in the forms they take after
+// "в"/"on", to their weekday.
+var weekdays = map[string]time.Weekday{}
+
+func init() {
+	names := map[time.Weekday]string{
+		time.Monday:    "понедельник пн monday mon",
+		time.Tuesday:   "вторник вт tuesday tue tues",
+		time.Wednesday: "среда среду ср wednesday wed",
+		time.Thursday:  "четверг чт thursday thu thur thurs",
+		time.Friday:    "пятница пятницу пт friday fri",
+		time.Saturday:  "суббота субботу сб saturday sat",
+		time.Sunday:    "воскресенье вс sunday sun",
+	}
+	for wd, ws := range names {
+		for _, w := range strings.Fields(ws) {
+			weekdays[w] = wd
+		}
+	}
+}
+
+// parseDeadlineAt reads a deadline relative to now, in now's location. It
+// accepts
+//   - DD.MM.YYYY, DD.MM.YY and DD.MM (this year, or next once it has passed);
+//   - ISO 8601: 2025-10-25, 2025-10-25T18:00, RFC 3339 with an offset;
+//   - сегодня/завтра/послезавтра, today/tomorrow, weekday names;
+//   - +3д, +2ч, через 2 часа, через неделю, in 3 days, in an hour;
+//   - a time of day, HH:MM or 6pm, before or after any of the above.
+//
+// Dates without a time get workdayEndHour. A bare time means today, or
+// tomorrow once it has passed.
+func parseDeadlineAt(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, ok := parseISODeadline(s, now.Location()); ok {
+		return t, nil
+	}
+
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	s = strings.TrimSuffix(strings.ReplaceAll(s, "ё", "е"), ".")
+
+	hour, min, hasClock := -1, 0, false
+	if m := clockRx.FindStringSubmatchIndex(s); m != nil {
+		h, _ := strconv.Atoi(s[m[2]:m[3]])
+		min, _ = strconv.Atoi(s[m[4]:m[5]])
+		ampm := ""
+		if m[6] >= 0 {
+			ampm = s[m[6]:m[7]]
+		}
+		hour = to24(h, ampm)
+		hasClock = true
+		s = strings.TrimSpace(s[:m[0]] + " " + s[m[1]:])
+	} else if m := ampmRx.FindStringSubmatchIndex(s); m != nil {
+		h, _ := strconv.Atoi(s[m[2]:m[3]])
+		hour = to24(h, s[m[4]:m[5]])
+		hasClock = true
+		s = strings.TrimSpace(s[:m[0]] + " " + s[m[1]:])
+	}
+	if hasClock && (hour < 0 || hour > 23 || min > 59) {
+		return time.Time{}, errDeadlineFormat
+	}
+	if !hasClock {
+		hour = workdayEndHour
+	}
+	at := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, min, 0, 0, now.Location())
+	}
+
+	switch s {
+	case "":
+		if !hasClock {
+			return time.Time{}, errDeadlineFormat
+		}
+		t := at(now)
+		if !t.After(now) {
+			t = at(now.AddDate(0, 0, 1))
+		}
+		return t, nil
+	case "сегодня", "today":
+		return at(now), nil
+	case "завтра", "tomorrow":
+		return at(now.AddDate(0, 0, 1)), nil
+	case "послезавтра", "day after tomorrow":
+		return at(now.AddDate(0, 0, 2)), nil
+	}
+
+	if m := relativeRx.FindStringSubmatch(s); m != nil {
+		unit, ok := relativeUnits[m[2]]
+		if !ok {
+			return time.Time{}, errDeadlineFormat
+		}
+		n := 1
+		if m[1] != "" && m[1] != "a" && m[1] != "an" {
+			n, _ = strconv.Atoi(m[1])
+		}
+		if unit < 24*time.Hour {
+			if hasClock {
+				return time.Time{}, errDeadlineFormat
+			}
+			return now.Add(time.Duration(n) * unit), nil
+		}
+		return at(now.AddDate(0, 0, n*int(unit/(24*time.Hour)))), nil
+	}
+
+	if m := dateRx.FindStringSubmatch(s); m != nil {
+		day, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		year, explicitYear := now.Year(), m[3] != ""
+		if explicitYear {
+			year, _ = strconv.Atoi(m[3])
+			if len(m[3]) == 2 {
+				year += 2000
+			}
+		}
+		t := time.Date(year, time.Month(month), day, hour, min, 0, 0, now.Location())
+		if t.Day() != day || int(t.Month()) != month {
+			return time.Time{}, errDeadlineFormat
+		}
+		if !explicitYear && !t.After(now) {
+			t = t.AddDate(1, 0, 0)
+		}
+		return t, nil
+	}
+
+	if wd, next, ok := parseWeekday(s); ok {
+		days := (int(wd) - int(now.Weekday()) + 7) % 7
+		if next && days == 0 {
+			days = 7
+		}
+		t := at(now.AddDate(0, 0, days))
+		if !t.After(now) {
+			t = at(now.AddDate(0, 0, days+7))
+		}
+		return t, nil
+	}
+	return time.Time{}, errDeadlineFormat
+}
+
+// parseWeekday reads "пятница", "в пятницу", "в следующую пятницу",
+// "on friday", "next fri". next reports that today does not count.
+func parseWeekday(s string) (wd time.Weekday, next, ok bool) {
+	words := strings.Fields(s)
+	if len(words) > 0 && (words[0] == "в" || words[0] == "во" || words[0] == "on") {
+		words = words[1:]
+	}
+	if len(words) > 0 && (words[0] == "next" || strings.HasPrefix(words[0], "следующ")) {
+		words, next = words[1:], true
+	}
+	if len(words) != 1 {
+		return 0, false, false
+	}
+	wd, ok = weekdays[words[0]]
+	return wd, next, ok
+}
+
+func parseISODeadline(s string, loc *time.Location) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(loc), true
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, true
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return time.Date(t.Year(), t.Month(), t.Day(), workdayEndHour, 0, 0, 0, loc), true
+	}
+	return time.Time{}, false
+}
+
+// to24 converts a 12-hour clock reading; without am/pm h is returned as is.
+// Invalid readings give -1.
+func to24(h int, ampm string) int {
+	switch ampm {
+	case "":
+		return h
+	case "am", "pm":
+		if h < 1 || h > 12 {
+			return -1
+		}
+		h %= 12
+		if ampm == "pm" {
+			h += 12
+		}
+	}
+	return h
+}
+
+// parseDeadline reads a deadline typed by a boss whose zone is loc. A
+// deadline that has already passed is returned along with errDeadlinePast.
+func parseDeadline(s string, loc *time.Location) (time.Time, error) {
+	now := time.Now().In(loc)
+	t, err := parseDeadlineAt(s, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !t.After(now) {
+		return t, errDeadlinePast
+	}
+	return t, nil
+}
+
+// deadlineError explains why parseDeadline rejected the input.
+func deadlineError(t time.Time, err error, loc *time.Location) string {
+	if errors.Is(err, errDeadlinePast) {
+		return "Это " + formatDeadline(t, loc) + " — время уже прошло. Укажите дедлайн в будущем."
+	}
+	return "Не понял дату. " + deadlineHelp
+}
+
+var shortWeekdays = [...]string{"вс", "пн", "вт", "ср", "чт", "пт", "сб"}
+
+// formatDeadline renders a deadline with its weekday, for echoing back what
+// was understood, in loc.
+func formatDeadline(t time.Time, loc *time.Location) string {
+	t = t.In(loc)
+	return shortWeekdays[t.Weekday()] + ", " + t.Format("02.01.2006 15:04")
+}
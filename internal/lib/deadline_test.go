@@ -0,0 +1,130 @@
+package lib
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseDeadlineAt(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	// a Wednesday
+	now := time.Date(2025, 10, 15, 12, 0, 0, 0, msk)
+	day := func(y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, msk)
+	}
+
+	tests := []struct {
+		in   string
+		want time.Time // zero for a rejected input
+	}{
+		// relative days
+		{"сегодня", day(2025, 10, 15, 18, 0)},
+		{"завтра 18:00", day(2025, 10, 16, 18, 0)},
+		{"Завтра 18:00.", day(2025, 10, 16, 18, 0)},
+		{"послезавтра в 9:30", day(2025, 10, 17, 9, 30)},
+		{"today 3pm", day(2025, 10, 15, 15, 0)},
+		{"tomorrow 6pm", day(2025, 10, 16, 18, 0)},
+		{"day after tomorrow", day(2025, 10, 17, 18, 0)},
+
+		// offsets
+		{"+3д", day(2025, 10, 18, 18, 0)},
+		{"+3д 10:00", day(2025, 10, 18, 10, 0)},
+		{"+2ч", day(2025, 10, 15, 14, 0)},
+		{"через 2 часа", day(2025, 10, 15, 14, 0)},
+		{"через 30 минут", day(2025, 10, 15, 12, 30)},
+		{"через неделю", day(2025, 10, 22, 18, 0)},
+		{"in 3 days", day(2025, 10, 18, 18, 0)},
+		{"in an hour", day(2025, 10, 15, 13, 0)},
+		{"in a week", day(2025, 10, 22, 18, 0)},
+		{"+2ч 10:00", time.Time{}},
+		{"через 2 попугая", time.Time{}},
+
+		// weekdays
+		{"пятница", day(2025, 10, 17, 18, 0)},
+		{"в пятницу", day(2025, 10, 17, 18, 0)},
+		{"в пн 10:00", day(2025, 10, 20, 10, 0)},
+		{"среда", day(2025, 10, 15, 18, 0)},
+		{"в среду 9:00", day(2025, 10, 22, 9, 0)},
+		{"в следующую среду", day(2025, 10, 22, 18, 0)},
+		{"next wed", day(2025, 10, 22, 18, 0)},
+		{"on friday 10am", day(2025, 10, 17, 10, 0)},
+		{"во вторник", day(2025, 10, 21, 18, 0)},
+
+		// DD.MM rolls over to next year once passed
+		{"25.10", day(2025, 10, 25, 18, 0)},
+		{"15.10 18:00", day(2025, 10, 15, 18, 0)},
+		{"15.10 10:00", day(2026, 10, 15, 10, 0)},
+		{"01.03", day(2026, 3, 1, 18, 0)},
+		{"25.10.2025 18:00", day(2025, 10, 25, 18, 0)},
+		{"25.10.25", day(2025, 10, 25, 18, 0)},
+		{"10.10.2025", day(2025, 10, 10, 18, 0)},
+		{"29.02.2028", day(2028, 2, 29, 18, 0)},
+		{"29.02", time.Time{}},
+		{"31.02", time.Time{}},
+		{"32.01", time.Time{}},
+
+		// bare times and am/pm
+		{"13:00", day(2025, 10, 15, 13, 0)},
+		{"11:00", day(2025, 10, 16, 11, 0)},
+		{"9am", day(2025, 10, 16, 9, 0)},
+		{"11:59 pm", day(2025, 10, 15, 23, 59)},
+		{"12pm", day(2025, 10, 16, 12, 0)},
+		{"12am", day(2025, 10, 16, 0, 0)},
+		{"к 17:30", day(2025, 10, 15, 17, 30)},
+		{"13pm", time.Time{}},
+		{"25:00", time.Time{}},
+		{"10:60", time.Time{}},
+
+		// ISO 8601
+		{"2025-10-25", day(2025, 10, 25, 18, 0)},
+		{"2025-10-25T09:15", day(2025, 10, 25, 9, 15)},
+		{"2025-10-25 09:15", day(2025, 10, 25, 9, 15)},
+		{"2025-10-25T09:15:00Z", day(2025, 10, 25, 12, 15)},
+
+		{"", time.Time{}},
+		{"когда-нибудь", time.Time{}},
+	}
+	for _, tt := range tests {
+		got, err := parseDeadlineAt(tt.in, now)
+		if tt.want.IsZero() {
+			if !errors.Is(err, errDeadlineFormat) {
+				t.Errorf("parseDeadlineAt(%q) = %v, %v, want errDeadlineFormat", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseDeadlineAt(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		} else if got.Location() != msk {
+			t.Errorf("parseDeadlineAt(%q) is in %v, want now's zone", tt.in, got.Location())
+		}
+	}
+}
+
+func TestParseDeadline(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	tests := []struct {
+		in   string
+		past bool
+	}{
+		{"+1д", false},
+		{"через 2 часа", false},
+		{"01.01.2020", true},
+		{"2020-01-01T10:00", true},
+		{"2020-01-01T10:00:00+03:00", true},
+	}
+	for _, tt := range tests {
+		got, err := parseDeadline(tt.in, msk)
+		switch {
+		case tt.past && !errors.Is(err, errDeadlinePast):
+			t.Errorf("parseDeadline(%q) = %v, %v, want errDeadlinePast", tt.in, got, err)
+		case tt.past && got.IsZero():
+			t.Errorf("parseDeadline(%q) dropped the past deadline", tt.in)
+		case !tt.past && (err != nil || !got.After(time.Now())):
+			t.Errorf("parseDeadline(%q) = %v, %v, want a future deadline", tt.in, got, err)
+		}
+	}
+	if _, err := parseDeadline("вчера", msk); !errors.Is(err, errDeadlineFormat) {
+		t.Errorf("parseDeadline(вчера): %v, want errDeadlineFormat", err)
+	}
+}
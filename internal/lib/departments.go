@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (b *Bot) cmdDeptRename(m *tgbotapi.Message) {
+	ctx := context.Background()
+	args := strings.Fields(m.CommandArguments())
+	if len(args) < 2 {
+		b.reply(m.Chat.ID, "Переименование отдела:\n/dept_rename <id> <новое название>\nСписок id: /dept_list")
+		return
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.reply(m.Chat.ID, "id должен быть числом")
+		return
+	}
+	name := strings.Join(args[1:], " ")
+
+	dep, err := b.DB.GetDepartmentByID(ctx, id)
+	if err != nil {
+		b.reply(m.Chat.ID, "Отдел не найден. Список: /dept_list")
+		return
+	}
+	deps, _ := b.DB.ListDepartments(ctx)
+	for _, d := range deps {
+		if d.ID != id && strings.EqualFold(d.Name, name) {
+			b.reply(m.Chat.ID, fmt.Sprintf("Отдел «%s» уже есть (id %d).", d.Name, d.ID))
+			return
+		}
+	}
+	if err := b.DB.RenameDepartment(ctx, id, name); err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	b.reply(m.Chat.ID, fmt.Sprintf("Отдел переименован: «%s» → «%s».", dep.Name, name))
+}
+
+// deleteDepartment removes an empty department. For a department with
+// members it offers to move them to another one first.
+func (b *Bot) deleteDepartment(chatID, id int64) {
+	ctx := context.Background()
+	dep, err := b.DB.GetDepartmentByID(ctx, id)
+	if err != nil {
+		b.reply(chatID, "Отдел не найден. Список: /dept_list")
+		return
+	}
+	err = b.DB.DeleteDepartment(ctx, id)
+	if err == nil {
+		b.reply(chatID, "Отдел удалён.")
+		return
+	}
+	if !errors.Is(err, storage.ErrDepartmentNotEmpty) {
+		b.reply(chatID, "Ошибка: "+err.Error())
+		return
+	}
+
+	members, _ := b.DB.ListWorkersByDepartment(ctx, id)
+	var names []string
+	for _, u := range members {
+		names = append(names, b.userLabel(u))
+	}
+	text := fmt.Sprintf("В отделе «%s» есть сотрудники (%d): %s.\n", dep.Name, len(members), strings.Join(names, ", "))
+
+	deps, _ := b.DB.ListDepartments(ctx)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, d := range deps {
+		if d.ID == id {
+			continue
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Перевести в «"+d.Name+"»", fmt.Sprintf("dept_move:%d:%d", id, d.ID)),
+		))
+	}
+	if len(rows) == 0 {
+		b.reply(chatID, text+"Других отделов нет: создайте новый (/dept_add) или удалите сотрудников (/del).")
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text+"Куда перевести сотрудников перед удалением?")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	b.API.Send(msg)
+}
+
+// onDeptMove handles "dept_move:<from>:<to>": moves the members and deletes
+// the emptied department.
+func (b *Bot) onDeptMove(cq *tgbotapi.CallbackQuery) {
+	ctx := context.Background()
+	parts := strings.Split(strings.TrimPrefix(cq.Data, "dept_move:"), ":")
+	if len(parts) != 2 || cq.Message == nil {
+		return
+	}
+	fromID, err1 := strconv.ParseInt(parts[0], 10, 64)
+	toID, err2 := strconv.ParseInt(parts[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	from, err := b.DB.GetDepartmentByID(ctx, fromID)
+	if err != nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Отдел уже удалён"))
+		return
+	}
+	to, err := b.DB.GetDepartmentByID(ctx, toID)
+	if err != nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Отдел не найден"))
+		return
+	}
+
+	members, _ := b.DB.ListWorkersByDepartment(ctx, fromID)
+	n, err := b.DB.MoveDepartmentMembers(ctx, fromID, toID)
+	if err != nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Ошибка: "+err.Error()))
+		return
+	}
+	for _, u := range members {
+		b.API.Send(tgbotapi.NewMessage(u.TgID, "Ваш отдел изменён: «"+to.Name+"»."))
+	}
+
+	text := fmt.Sprintf("Сотрудники (%d) переведены из «%s» в «%s». Отдел «%s» удалён.", n, from.Name, to.Name, from.Name)
+	if err := b.DB.DeleteDepartment(ctx, fromID); err != nil {
+		text = fmt.Sprintf("Сотрудники (%d) переведены в «%s», но удалить «%s» не удалось: %v", n, to.Name, from.Name, err)
+	}
+	b.API.Send(tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, text))
+	b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+}
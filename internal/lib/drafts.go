@@ -0,0 +1,193 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func isNewTaskState(s string) bool {
+	switch s {
+	case StateNewTaskTitle, StateNewTaskBody, StateNewTaskAssignees, StateNewTaskDeadline, StateNewTaskReminders, StateNewTaskConfirm, StateNewTaskRepeat:
+		return true
+	}
+	return false
+}
+
+// newTaskSteps names the /newtask steps in the order they are asked.
+var newTaskSteps = map[string]string{
+	StateNewTaskTitle:     "название",
+	StateNewTaskBody:      "содержание",
+	StateNewTaskAssignees: "исполнители",
+	StateNewTaskDeadline:  "дедлайн",
+	StateNewTaskReminders: "напоминания",
+	StateNewTaskConfirm:   "подтверждение",
+	StateNewTaskRepeat:    "повтор",
+}
+
+// shelveDraft moves an unfinished /newtask of tgID from user_states to the
+// saved drafts and reports its title. Drafts without a title are dropped.
+func (b *Bot) shelveDraft(ctx context.Context, tgID int64) (string, bool) {
+	d := &NewTaskDraft{}
+	state, err := b.DB.LoadState(ctx, tgID, d)
+	if err != nil || !isNewTaskState(state) {
+		return "", false
+	}
+	_ = b.DB.ClearState(ctx, tgID)
+	if strings.TrimSpace(d.Title) == "" {
+		return "", false
+	}
+	if _, err := b.DB.SaveDraft(ctx, tgID, state, d); err != nil {
+		log.Println("save draft:", err)
+		return "", false
+	}
+	return d.Title, true
+}
+
+// startStateSweeper clears conversations left untouched for StateTTL and
+// drops saved drafts older than DraftRetention.
+func (b *Bot) startStateSweeper() {
+	if b.StateTTL <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(time.Minute) {
+			ctx := context.Background()
+			expired, err := b.DB.ExpireStates(ctx, time.Now().Add(-b.StateTTL))
+			if err != nil {
+				log.Println("expire states:", err)
+			}
+			for _, st := range expired {
+				b.onStateExpired(ctx, st)
+			}
+			if b.DraftRetention > 0 {
+				if _, err := b.DB.PurgeDrafts(ctx, time.Now().Add(-b.DraftRetention)); err != nil {
+					log.Println("purge drafts:", err)
+				}
+			}
+		}
+	}()
+}
+
+// onStateExpired tells the user their conversation timed out. An unfinished
+// /newtask with a title is kept as a draft.
+func (b *Bot) onStateExpired(ctx context.Context, st *storage.State) {
+	var text string
+	switch {
+	case isNewTaskState(st.State):
+		d := &NewTaskDraft{}
+		_ = json.Unmarshal(st.Payload, d)
+		text = "⌛ Создание задачи прервано: долго не было ответа. Начните заново: /newtask"
+		if strings.TrimSpace(d.Title) != "" {
+			if _, err := b.DB.SaveDraft(ctx, st.UserID, st.State, json.RawMessage(st.Payload)); err != nil {
+				log.Println("save draft:", err)
+			} else {
+				text = "⌛ Черновик задачи «" + d.Title + "» отложен: долго не было ответа. Продолжить: /drafts"
+			}
+		}
+	case st.State == StateRegName || st.State == StateRegTeam:
+		text = "⌛ Регистрация не завершена. Начните заново: /register"
+	case st.State == StateAwaitResult:
+		text = "⌛ Ожидание результата отменено. Чтобы отправить результат, нажмите «📎 Отправить результат» в задаче ещё раз."
+	case st.State == StateErrorReport:
+		text = "⌛ Описание ошибки так и не пришло. Отправьте его снова: /error"
+	case st.State == StateImportConfirm:
+		text = "⌛ Импорт задач не подтверждён, ничего не создано. Пришлите файл снова."
+	default:
+		return
+	}
+	b.reply(st.UserID, text)
+}
+
+func (b *Bot) cmdDrafts(m *tgbotapi.Message) {
+	drafts, err := b.DB.ListDrafts(context.Background(), m.From.ID)
+	if err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	if len(drafts) == 0 {
+		b.reply(m.Chat.ID, "Отложенных черновиков нет.")
+		return
+	}
+
+	var sb strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	loc := b.zone(m.From.ID)
+	sb.WriteString("Отложенные черновики:\n")
+	for _, dr := range drafts {
+		d := &NewTaskDraft{}
+		_ = json.Unmarshal(dr.Payload, d)
+		sb.WriteString(fmt.Sprintf("- «%s» — шаг: %s, отложен %s\n",
+			d.Title, newTaskSteps[dr.State], dr.CreatedAt.In(loc).Format("02.01 15:04")))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("▶ "+d.Title, fmt.Sprintf("draft:resume:%d", dr.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("🗑", fmt.Sprintf("draft:del:%d", dr.ID)),
+		))
+	}
+	if b.DraftRetention > 0 {
+		sb.WriteString(fmt.Sprintf("\nЧерновики хранятся %d дн.", int(b.DraftRetention.Hours()/24)))
+	}
+	msg := tgbotapi.NewMessage(m.Chat.ID, sb.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	b.API.Send(msg)
+}
+
+// onDraftCallback handles "draft:<resume|del>:<id>". Resuming puts aside a
+// /newtask already in progress, then asks the step the draft stopped at.
+func (b *Bot) onDraftCallback(cq *tgbotapi.CallbackQuery) {
+	ctx := context.Background()
+	parts := strings.Split(cq.Data, ":")
+	if len(parts) != 3 || cq.Message == nil {
+		return
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return
+	}
+	if !b.isBoss(cq.From.ID) {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Только для боссов."))
+		return
+	}
+	defer b.lockUser(cq.From.ID)()
+
+	dr, err := b.DB.TakeDraft(ctx, id, cq.From.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Черновик уже удалён или продолжен"))
+		return
+	}
+	if err != nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Ошибка: "+err.Error()))
+		return
+	}
+	d := &NewTaskDraft{}
+	_ = json.Unmarshal(dr.Payload, d)
+
+	chatID := cq.Message.Chat.ID
+	if parts[1] == "del" {
+		b.API.Send(tgbotapi.NewEditMessageText(chatID, cq.Message.MessageID, "Черновик «"+d.Title+"» удалён."))
+		b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+		return
+	}
+
+	shelved, _ := b.shelveDraft(ctx, cq.From.ID)
+	if err := b.DB.SaveState(ctx, cq.From.ID, dr.State, json.RawMessage(dr.Payload)); err != nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Ошибка: "+err.Error()))
+		return
+	}
+	text := "Продолжаем черновик «" + d.Title + "»."
+	if shelved != "" {
+		text += " Незавершённая задача «" + shelved + "» отложена в /drafts."
+	}
+	b.API.Send(tgbotapi.NewEditMessageText(chatID, cq.Message.MessageID, text))
+	b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+	b.askNewTaskStep(chatID, dr.State, d)
+}
@@ -0,0 +1,302 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// Escalation of overdue assignments. Every step of the policy fires once per
+// occurrence — After the deadline, then every Repeat — while the assignment
+// is new or in progress, and is recorded in the task history. Steps missed
+// while the bot was down or outside working hours fire once, not per missed
+// occurrence. A new deadline starts the policy over.
+
+// Recipients of a step as the config names them; escalationTargets has
+// the names shown to bosses.
+const (
+	escalateAssignee = "assignee"
+	escalateCreator  = "creator"
+	escalateHead     = "head"
+	escalateBosses   = "bosses"
+)
+
+var escalationTargets = map[string]string{
+	escalateAssignee: "исполнитель",
+	escalateCreator:  "автор задачи",
+	escalateHead:     "руководитель отдела",
+	escalateBosses:   "боссы",
+}
+
+// EscalationStep notifies To After the deadline and, if Repeat is set,
+// again every Repeat until the assignment is done.
+type EscalationStep struct {
+	After  time.Duration
+	To     []string
+	Repeat time.Duration
+}
+
+// CheckEscalation reports the first invalid step of a policy.
+func CheckEscalation(steps []EscalationStep) error {
+	for i, s := range steps {
+		switch {
+		case s.After < 0:
+			return fmt.Errorf("step %d: negative after", i+1)
+		case s.Repeat < 0 || s.Repeat > 0 && s.Repeat < time.Minute:
+			return fmt.Errorf("step %d: repeat must be at least a minute", i+1)
+		case len(s.To) == 0:
+			return fmt.Errorf("step %d: no recipients", i+1)
+		}
+		for _, to := range s.To {
+			if _, ok := escalationTargets[to]; !ok {
+				return fmt.Errorf("step %d: unknown recipient %q", i+1, to)
+			}
+		}
+	}
+	return nil
+}
+
+// last is the latest occurrence of s not after now for a deadline due.
+func (s EscalationStep) last(due, now time.Time) (time.Time, bool) {
+	at := due.Add(s.After)
+	if at.After(now) {
+		return time.Time{}, false
+	}
+	if s.Repeat > 0 {
+		at = at.Add(now.Sub(at) / s.Repeat * s.Repeat)
+	}
+	return at, true
+}
+
+// label names s in the history, e.g. "+1 д, каждые 1 д".
+func (s EscalationStep) label() string {
+	l := "+" + formatSpan(s.After)
+	if s.Repeat > 0 {
+		l += ", каждые " + formatSpan(s.Repeat)
+	}
+	return l
+}
+
+// formatSpan writes d to the minute as "1 д 2 ч 30 мин"; parseSpan reads it
+// back.
+func formatSpan(d time.Duration) string {
+	var parts []string
+	for _, u := range []struct {
+		d    time.Duration
+		name string
+	}{{24 * time.Hour, "д"}, {time.Hour, "ч"}, {time.Minute, "мин"}} {
+		if n := d / u.d; n > 0 {
+			parts = append(parts, strconv.Itoa(int(n))+" "+u.name)
+			d -= n * u.d
+		}
+	}
+	if len(parts) == 0 {
+		return "0 мин"
+	}
+	return strings.Join(parts, " ")
+}
+
+// escalationSummary describes a policy in one line for the task summary.
+func escalationSummary(steps []EscalationStep) string {
+	var parts []string
+	for _, s := range steps {
+		var to []string
+		for _, t := range s.To {
+			to = append(to, escalationTargets[t])
+		}
+		parts = append(parts, s.label()+" — "+strings.Join(to, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (b *Bot) dispatchEscalations() {
+	if len(b.Escalation) == 0 {
+		return
+	}
+	ctx := context.Background()
+	now := storage.Now()
+	overdue, err := b.DB.ListOverdueAssignees(ctx, now)
+	if err != nil {
+		log.Println("escalation:", err)
+		return
+	}
+	for _, o := range overdue {
+		var pending []EscalationStep
+		for _, s := range b.Escalation {
+			if at, ok := s.last(o.DueAt, now); ok && (!o.EscalatedAt.Valid || at.After(o.EscalatedAt.Time)) {
+				pending = append(pending, s)
+			}
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		// a result waits for the boss, not for the assignee
+		if has, _ := b.DB.HasResult(ctx, o.TaskID, o.UserID); has {
+			continue
+		}
+		u, err := b.DB.GetUserByID(ctx, o.UserID)
+		if err != nil {
+			continue
+		}
+		if !slices.Contains(b.UrgentReminders, "overdue") {
+			w, _ := b.workHours(ctx, u)
+			if w.nextStart(now.In(b.userZone(u))).After(now) {
+				continue
+			}
+		}
+		t, err := b.DB.GetTask(ctx, o.TaskID)
+		if err != nil {
+			continue
+		}
+		for _, s := range pending {
+			b.escalate(ctx, t, u, s, now)
+		}
+	}
+}
+
+// escalate sends step s for the overdue assignment of u on t and records it.
+func (b *Bot) escalate(ctx context.Context, t *storage.Task, u *storage.User, s EscalationStep, now time.Time) {
+	title := nullStr(t.Title)
+	sent := map[int64]bool{}
+	var names []string
+	for _, to := range s.To {
+		for _, r := range b.escalationRecipients(ctx, t, u, to) {
+			if sent[r.TgID] {
+				continue
+			}
+			sent[r.TgID] = true
+			names = append(names, b.userLabel(r))
+			due := t.DueAt.Time.In(b.userZone(r)).Format("02.01.2006 15:04")
+			if r.ID == u.ID {
+				msg := tgbotapi.NewMessage(r.TgID, "❗ Просрочено: задача «"+title+"», дедлайн был "+due+". Обновите статус или отправьте результат.")
+				msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(taskActionsRow(t.ID))
+				b.API.Send(msg)
+				continue
+			}
+			b.API.Send(tgbotapi.NewMessage(r.TgID, fmt.Sprintf("❗ Эскалация: задача [%d] «%s» просрочена у %s (дедлайн %s).",
+				t.ID, title, b.userLabel(u), due)))
+		}
+	}
+	err := b.DB.RecordEscalation(ctx, &storage.TaskEvent{
+		TaskID: t.ID, UserID: sql.NullInt64{Int64: u.ID, Valid: true}, Kind: storage.EventEscalated,
+		OldValue:  sql.NullString{String: s.label(), Valid: true},
+		NewValue:  sql.NullString{String: strings.Join(names, ", "), Valid: len(names) > 0},
+		CreatedAt: now,
+	})
+	if err != nil {
+		log.Println("record escalation:", err)
+	}
+}
+
+// escalationRecipients resolves one recipient of a step. A department
+// without a head escalates to the bosses.
+func (b *Bot) escalationRecipients(ctx context.Context, t *storage.Task, u *storage.User, to string) []*storage.User {
+	switch to {
+	case escalateAssignee:
+		return []*storage.User{u}
+	case escalateCreator:
+		if c, err := b.DB.GetUserByID(ctx, t.CreatorID); err == nil {
+			return []*storage.User{c}
+		}
+		return nil
+	case escalateHead:
+		if u.DepartmentID.Valid {
+			if dep, err := b.DB.GetDepartmentByID(ctx, u.DepartmentID.Int64); err == nil && dep.HeadID.Valid && dep.HeadID.Int64 != u.ID {
+				if h, err := b.DB.GetUserByID(ctx, dep.HeadID.Int64); err == nil {
+					return []*storage.User{h}
+				}
+			}
+		}
+	case escalateBosses:
+	default:
+		return nil
+	}
+	var ids []int64
+	for id := range b.BossIDs {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	var bosses []*storage.User
+	for _, id := range ids {
+		boss, err := b.DB.GetUserByTgID(ctx, id)
+		if err != nil {
+			boss = &storage.User{TgID: id}
+		}
+		bosses = append(bosses, boss)
+	}
+	return bosses
+}
+
+func (b *Bot) cmdDeptHead(m *tgbotapi.Message) {
+	ctx := context.Background()
+	args := strings.Fields(m.CommandArguments())
+	if len(args) == 0 {
+		b.reply(m.Chat.ID, "Руководитель отдела:\n/dept_head <id> <tg_id|@username> или /dept_head <id> reset\n"+
+			"Ему уходят эскалации по просроченным задачам сотрудников отдела; без руководителя — боссам. Список id: /dept_list")
+		return
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.reply(m.Chat.ID, "id должен быть числом")
+		return
+	}
+	dep, err := b.DB.GetDepartmentByID(ctx, id)
+	if err != nil {
+		b.reply(m.Chat.ID, "Отдел не найден. Список: /dept_list")
+		return
+	}
+	if len(args) == 1 {
+		b.reply(m.Chat.ID, fmt.Sprintf("Руководитель отдела «%s»: %s.", dep.Name, b.headLabel(ctx, dep)))
+		return
+	}
+	if strings.EqualFold(args[1], "reset") {
+		if err := b.DB.SetDepartmentHead(ctx, id, 0); err != nil {
+			b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+			return
+		}
+		b.reply(m.Chat.ID, fmt.Sprintf("У отдела «%s» больше нет руководителя, эскалации уходят боссам.", dep.Name))
+		return
+	}
+	var head *storage.User
+	if tg, perr := strconv.ParseInt(args[1], 10, 64); perr == nil {
+		head, err = b.DB.GetUserByTgID(ctx, tg)
+	} else {
+		head, err = b.DB.FindWorkerByUsername(ctx, strings.TrimPrefix(args[1], "@"))
+	}
+	// the SQL stores report a missing tg_id as sql.ErrNoRows
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, sql.ErrNoRows) {
+		b.reply(m.Chat.ID, "Пользователь не найден: он должен хотя бы раз написать боту.")
+		return
+	}
+	if err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	if err := b.DB.SetDepartmentHead(ctx, id, head.ID); err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	b.reply(m.Chat.ID, fmt.Sprintf("Руководитель отдела «%s»: %s.", dep.Name, b.userLabel(head)))
+}
+
+// headLabel names the head of dep for lists.
+func (b *Bot) headLabel(ctx context.Context, dep *storage.Department) string {
+	if !dep.HeadID.Valid {
+		return "не назначен"
+	}
+	h, err := b.DB.GetUserByID(ctx, dep.HeadID.Int64)
+	if err != nil {
+		return "не назначен"
+	}
+	return b.userLabel(h)
+}
@@ -0,0 +1,146 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+	"github.com/hihikaAAa/task-manager/internal/storage/memory"
+	"github.com/hihikaAAa/task-manager/internal/storage/sqlite"
+)
+
+// fakeTelegram answers Bot API calls and keeps the texts of sent messages.
+type fakeTelegram struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (f *fakeTelegram) Do(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	form, _ := url.ParseQuery(string(body))
+	result := `{"message_id":1,"date":0,"chat":{"id":1}}`
+	if strings.HasSuffix(req.URL.Path, "/getMe") {
+		result = `{"id":1,"is_bot":true,"first_name":"bot","username":"bot"}`
+	} else if text := form.Get("text"); text != "" {
+		f.mu.Lock()
+		f.texts = append(f.texts, text)
+		f.mu.Unlock()
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`{"ok":true,"result":` + result + `}`)),
+		Header:     http.Header{"Content-Type": {"application/json"}},
+	}, nil
+}
+
+// last returns the text of the last message sent.
+func (f *fakeTelegram) last() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.texts) == 0 {
+		return ""
+	}
+	return f.texts[len(f.texts)-1]
+}
+
+func newTestAPI(t *testing.T) (*tgbotapi.BotAPI, *fakeTelegram) {
+	t.Helper()
+	fake := &fakeTelegram{}
+	api, err := tgbotapi.NewBotAPIWithClient("token", "https://telegram.invalid/bot%s/%s", fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api, fake
+}
+
+// command builds a message with a bot command, as Telegram sends it.
+func command(fromID int64, text string) *tgbotapi.Message {
+	name, _, _ := strings.Cut(text, " ")
+	return &tgbotapi.Message{
+		From:     &tgbotapi.User{ID: fromID},
+		Chat:     &tgbotapi.Chat{ID: fromID},
+		Text:     text,
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(name)}},
+	}
+}
+
+func TestCmdDeptHead(t *testing.T) {
+	stores := map[string]func(t *testing.T) storage.Store{
+		"memory": func(t *testing.T) storage.Store { return memory.New() },
+		// the SQL stores report a missing user differently
+		"sqlite": func(t *testing.T) storage.Store {
+			db, err := sqlite.Open(filepath.Join(t.TempDir(), "tasks.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.SQL.Close() })
+			return db
+		},
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			db := open(t)
+			api, fake := newTestAPI(t)
+			b := &Bot{API: api, DB: db, BossIDs: map[int64]bool{1: true}, TZ: time.UTC}
+			if _, err := db.UpsertUser(ctx, 1, nil, "boss"); err != nil {
+				t.Fatal(err)
+			}
+			dep, err := db.CreateDepartment(ctx, "Склад", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			username := "anna"
+			if _, err := db.UpsertUser(ctx, 11, &username, "worker"); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.SetWorkerProfile(ctx, 11, "Анна", dep); err != nil {
+				t.Fatal(err)
+			}
+			id := strconv.FormatInt(dep, 10)
+			head := func() sql.NullInt64 {
+				t.Helper()
+				d, err := db.GetDepartmentByID(ctx, dep)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return d.HeadID
+			}
+
+			for _, who := range []string{"999", "@nobody"} {
+				b.cmdDeptHead(command(1, "/dept_head "+id+" "+who))
+				if got := fake.last(); !strings.HasPrefix(got, "Пользователь не найден") {
+					t.Errorf("/dept_head %s %s replied %q", id, who, got)
+				}
+				if h := head(); h.Valid {
+					t.Errorf("/dept_head %s %s set head %v", id, who, h)
+				}
+			}
+
+			for _, who := range []string{"@anna", "11"} {
+				b.cmdDeptHead(command(1, "/dept_head "+id+" "+who))
+				if got, want := fake.last(), "Руководитель отдела «Склад»: Анна."; got != want {
+					t.Errorf("/dept_head %s %s replied %q, want %q", id, who, got, want)
+				}
+				if h := head(); !h.Valid {
+					t.Errorf("/dept_head %s %s left no head", id, who)
+				}
+				b.cmdDeptHead(command(1, "/dept_head "+id+" reset"))
+				if h := head(); h.Valid {
+					t.Errorf("/dept_head %s reset left head %v", id, h)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/xuri/excelize/v2"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// /export sends a spreadsheet with a row per task and assignee, filtered by
+// the words after the command: a format, then "период", "отдел", "статус"
+// and "автор", each followed by its value.
+
+const exportUsage = "Использование: /export [xlsx|csv] [период …] [отдел …] [статус …] [автор …]\n" +
+	"период — сегодня, неделя, месяц (последние 7 или 30 дней), 01.10.2026 или 01.10.2026-31.10.2026;\n" +
+	"отдел — id или название;\n" +
+	"статус — новая, в работе, выполнена, не выполнена или просрочена;\n" +
+	"автор — tg_id или «я».\n" +
+	"Пример: /export csv период 01.10-31.10 отдел Продажи статус просрочена\n" +
+	"Без условий — все задачи в XLSX. Период считается по дате создания задачи."
+
+// statusOverdue is the "просрочена" filter: not a stored status but
+// assignments finished late or still open past the deadline.
+const statusOverdue = "overdue"
+
+var exportKeys = map[string]string{
+	"период": "period", "period": "period",
+	"отдел": "dept", "dept": "dept", "department": "dept",
+	"статус": "status", "status": "status",
+	"автор": "creator", "creator": "creator",
+}
+
+var exportStatuses = map[string]string{
+	"new": "new", "новая": "new", "новые": "new",
+	"in_progress": "in_progress", "в работе": "in_progress",
+	"done": "done", "выполнена": "done", "выполнено": "done", "выполненные": "done", "готово": "done",
+	"failed": "failed", "не выполнена": "failed", "не выполнено": "failed",
+	statusOverdue: statusOverdue, "просрочена": statusOverdue, "просрочено": statusOverdue, "просроченные": statusOverdue,
+}
+
+// exportStatusNames are the stored statuses as the report writes them.
+var exportStatusNames = map[string]string{
+	"new": "новая", "in_progress": "в работе", "done": "выполнена", "failed": "не выполнена",
+}
+
+var exportHeader = []string{"ID задачи", "Название", "Автор", "Исполнитель", "Отдел", "Статус", "Создана", "Дедлайн", "Выполнена", "Просрочена", "Результатов"}
+
+// exportRequest is a parsed /export.
+type exportRequest struct {
+	xlsx    bool
+	filter  storage.ExportFilter
+	overdue bool
+	// conds describes the filters for the caption.
+	conds []string
+}
+
+// parseExport reads the arguments of /export for boss, with dates in loc.
+func (b *Bot) parseExport(ctx context.Context, args string, boss *storage.User, loc *time.Location) (*exportRequest, error) {
+	req := &exportRequest{xlsx: true}
+	values := map[string][]string{}
+	key := ""
+	for _, w := range strings.Fields(args) {
+		lw := strings.ToLower(w)
+		if k, ok := exportKeys[lw]; ok {
+			key = k
+			if _, dup := values[k]; dup {
+				return nil, fmt.Errorf("%s указан дважды", w)
+			}
+			values[k] = nil
+			continue
+		}
+		if key == "" {
+			switch lw {
+			case "xlsx":
+				req.xlsx = true
+			case "csv":
+				req.xlsx = false
+			default:
+				return nil, fmt.Errorf("не понял «%s»", w)
+			}
+			continue
+		}
+		values[key] = append(values[key], w)
+	}
+	for _, k := range []string{"period", "dept", "status", "creator"} {
+		v, ok := values[k]
+		if !ok {
+			continue
+		}
+		if len(v) == 0 {
+			return nil, errors.New("не указано значение условия")
+		}
+		s := strings.Join(v, " ")
+		switch k {
+		case "period":
+			from, to, err := parsePeriod(s, time.Now().In(loc))
+			if err != nil {
+				return nil, err
+			}
+			req.filter.From, req.filter.To = from, to
+			req.conds = append(req.conds, "период "+from.Format("02.01.2006")+"–"+to.Add(-time.Nanosecond).Format("02.01.2006"))
+		case "dept":
+			dep, err := b.findDepartment(ctx, s)
+			if err != nil {
+				return nil, fmt.Errorf("отдел «%s» не найден", s)
+			}
+			req.filter.DepartmentID = dep.ID
+			req.conds = append(req.conds, "отдел «"+dep.Name+"»")
+		case "status":
+			st, ok := exportStatuses[strings.ToLower(s)]
+			if !ok {
+				return nil, fmt.Errorf("нет статуса «%s»", s)
+			}
+			if st == statusOverdue {
+				req.overdue = true
+			} else {
+				req.filter.Status = st
+			}
+			req.conds = append(req.conds, "статус «"+s+"»")
+		case "creator":
+			creator := boss
+			if ls := strings.ToLower(s); ls != "я" && ls != "me" {
+				tg, err := strconv.ParseInt(s, 10, 64)
+				if err != nil {
+					return nil, errors.New("автор — tg_id или «я»")
+				}
+				if creator, err = b.DB.GetUserByTgID(ctx, tg); err != nil {
+					return nil, fmt.Errorf("пользователь %d не найден", tg)
+				}
+			}
+			req.filter.CreatorID = creator.ID
+			req.conds = append(req.conds, "автор "+b.userLabel(creator))
+		}
+	}
+	return req, nil
+}
+
+// parsePeriod reads "сегодня", "неделя", "месяц", a date or a range of
+// dates into [from, to) in the zone of now.
+func parsePeriod(s string, now time.Time) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	tomorrow := today.AddDate(0, 0, 1)
+	switch strings.ToLower(s) {
+	case "сегодня", "today":
+		return today, tomorrow, nil
+	case "неделя", "week":
+		return today.AddDate(0, 0, -6), tomorrow, nil
+	case "месяц", "month":
+		return today.AddDate(0, 0, -29), tomorrow, nil
+	}
+	first, last, isRange := strings.Cut(dashRx.ReplaceAllString(s, "-"), "-")
+	from, ok := parseDay(first, today)
+	if !ok {
+		return time.Time{}, time.Time{}, fmt.Errorf("не понял период «%s»", s)
+	}
+	to := from
+	if isRange {
+		if to, ok = parseDay(last, today); !ok || to.Before(from) {
+			return time.Time{}, time.Time{}, fmt.Errorf("не понял период «%s»", s)
+		}
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+// parseDay reads DD.MM[.YYYY]; the year defaults to that of today.
+func parseDay(s string, today time.Time) (time.Time, bool) {
+	m := dateRx.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return time.Time{}, false
+	}
+	day, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	year := today.Year()
+	if m[3] != "" {
+		year, _ = strconv.Atoi(m[3])
+		if year < 100 {
+			year += 2000
+		}
+	}
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, today.Location())
+	if t.Day() != day || int(t.Month()) != month {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// findDepartment finds a department by id or by name, ignoring case.
+func (b *Bot) findDepartment(ctx context.Context, s string) (*storage.Department, error) {
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return b.DB.GetDepartmentByID(ctx, id)
+	}
+	deps, err := b.DB.ListDepartments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, dep := range deps {
+		if strings.EqualFold(dep.Name, s) {
+			return dep, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+// overdue reports whether r was finished after its deadline or is still
+// open past it.
+func overdue(r *storage.AssignmentRow, now time.Time) bool {
+	if !r.DueAt.Valid {
+		return false
+	}
+	if r.DoneAt.Valid {
+		return r.DoneAt.Time.After(r.DueAt.Time)
+	}
+	return now.After(r.DueAt.Time)
+}
+
+// filterOverdue keeps the rows overdue at now, for the "просрочена"
+// filter. It reuses the backing array of rows.
+func filterOverdue(rows []*storage.AssignmentRow, now time.Time) []*storage.AssignmentRow {
+	late := rows[:0]
+	for _, r := range rows {
+		if overdue(r, now) {
+			late = append(late, r)
+		}
+	}
+	return late
+}
+
+func (b *Bot) cmdExport(m *tgbotapi.Message) {
+	ctx := context.Background()
+	loc := b.zone(m.From.ID)
+	boss, err := b.DB.GetUserByTgID(ctx, m.From.ID)
+	if err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	req, err := b.parseExport(ctx, m.CommandArguments(), boss, loc)
+	if err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error()+".\n\n"+exportUsage)
+		return
+	}
+	rows, err := b.DB.ListAssignmentsForExport(ctx, req.filter)
+	if err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	now := time.Now()
+	if req.overdue {
+		rows = filterOverdue(rows, now)
+	}
+	if len(rows) == 0 {
+		b.reply(m.Chat.ID, "По этим условиям задач нет.")
+		return
+	}
+
+	var data []byte
+	ext := "csv"
+	if req.xlsx {
+		ext = "xlsx"
+		data, err = b.exportXLSX(rows, now, loc)
+	} else {
+		data, err = b.exportCSV(rows, now, loc)
+	}
+	if err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	if len(data) > maxUploadSize {
+		b.reply(m.Chat.ID, "Отчёт слишком большой для отправки — сузьте условия.")
+		return
+	}
+
+	tasks := map[int64]bool{}
+	for _, r := range rows {
+		tasks[r.TaskID] = true
+	}
+	name := "tasks_" + now.In(loc).Format("2006-01-02_1504") + "." + ext
+	doc := tgbotapi.NewDocument(m.Chat.ID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	doc.Caption = fmt.Sprintf("Задач: %d, строк: %d.", len(tasks), len(rows))
+	if len(req.conds) > 0 {
+		doc.Caption += "\nУсловия: " + strings.Join(req.conds, ", ") + "."
+	}
+	if _, err := b.API.Send(doc); err != nil {
+		b.reply(m.Chat.ID, "Не удалось отправить отчёт: "+err.Error())
+	}
+}
+
+// exportName names a creator or assignee in a report; a user deleted
+// since has no tg_id.
+func (b *Bot) exportName(tgID sql.NullInt64, name, username sql.NullString) string {
+	if !tgID.Valid {
+		return "удалённый сотрудник"
+	}
+	return b.userLabel(&storage.User{TgID: tgID.Int64, Name: name, Username: username})
+}
+
+func (b *Bot) exportCSV(rows []*storage.AssignmentRow, now time.Time, loc *time.Location) ([]byte, error) {
+	var buf bytes.Buffer
+	// the BOM lets Excel read the file as UTF-8
+	buf.WriteString("\xef\xbb\xbf")
+	w := csv.NewWriter(&buf)
+	w.Write(exportHeader)
+	when := func(t sql.NullTime) string {
+		if !t.Valid {
+			return ""
+		}
+		return t.Time.In(loc).Format("02.01.2006 15:04")
+	}
+	for _, r := range rows {
+		late := "нет"
+		if overdue(r, now) {
+			late = "да"
+		}
+		w.Write([]string{
+			strconv.FormatInt(r.TaskID, 10), nullStr(r.Title),
+			b.exportName(r.CreatorTgID, r.CreatorName, r.CreatorUsername), b.exportName(r.AssigneeTgID, r.AssigneeName, r.AssigneeUsername),
+			nullStr(r.Department), ifEmpty(exportStatusNames[r.Status], r.Status),
+			r.CreatedAt.In(loc).Format("02.01.2006 15:04"), when(r.DueAt), when(r.DoneAt),
+			late, strconv.Itoa(r.Results),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (b *Bot) exportXLSX(rows []*storage.AssignmentRow, now time.Time, loc *time.Location) ([]byte, error) {
+	const sheet = "Задачи"
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return nil, err
+	}
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return nil, err
+	}
+	dateFmt := "dd.mm.yyyy hh:mm"
+	date, err := f.NewStyle(&excelize.Style{CustomNumFmt: &dateFmt})
+	if err != nil {
+		return nil, err
+	}
+	// excelize writes the wall clock of a time, so it is moved to loc first
+	when := func(t time.Time) time.Time {
+		t = t.In(loc)
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	}
+
+	header := make([]any, len(exportHeader))
+	for i, h := range exportHeader {
+		header[i] = h
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return nil, err
+	}
+	for i, r := range rows {
+		row := []any{
+			r.TaskID, nullStr(r.Title),
+			b.exportName(r.CreatorTgID, r.CreatorName, r.CreatorUsername), b.exportName(r.AssigneeTgID, r.AssigneeName, r.AssigneeUsername),
+			nullStr(r.Department), ifEmpty(exportStatusNames[r.Status], r.Status),
+			when(r.CreatedAt), nil, nil, "нет", r.Results,
+		}
+		if r.DueAt.Valid {
+			row[7] = when(r.DueAt.Time)
+		}
+		if r.DoneAt.Valid {
+			row[8] = when(r.DoneAt.Time)
+		}
+		if overdue(r, now) {
+			row[9] = "да"
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return nil, err
+		}
+	}
+
+	last, _ := excelize.CoordinatesToCellName(len(exportHeader), len(rows)+1)
+	lastCol, _ := excelize.ColumnNumberToName(len(exportHeader))
+	steps := []error{
+		f.SetRowStyle(sheet, 1, 1, bold),
+		f.SetCellStyle(sheet, "G2", fmt.Sprintf("I%d", len(rows)+1), date),
+		f.SetColWidth(sheet, "A", "A", 10),
+		f.SetColWidth(sheet, "B", "B", 40),
+		f.SetColWidth(sheet, "C", "F", 20),
+		f.SetColWidth(sheet, "G", lastCol, 17),
+		f.AutoFilter(sheet, "A1:"+last, nil),
+		f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}),
+	}
+	if err := errors.Join(steps...); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,180 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+	"github.com/hihikaAAa/task-manager/internal/storage/memory"
+)
+
+func TestParsePeriod(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	now := time.Date(2025, 10, 15, 14, 30, 0, 0, msk)
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, msk) }
+
+	tests := []struct {
+		in       string
+		from, to time.Time // zero for a rejected period
+	}{
+		{"сегодня", day(2025, 10, 15), day(2025, 10, 16)},
+		{"today", day(2025, 10, 15), day(2025, 10, 16)},
+		{"Неделя", day(2025, 10, 9), day(2025, 10, 16)},
+		{"month", day(2025, 9, 16), day(2025, 10, 16)},
+		{"01.10", day(2025, 10, 1), day(2025, 10, 2)},
+		{"01.10-31.10", day(2025, 10, 1), day(2025, 11, 1)},
+		{"01.10.2024 — 31.12.2024", day(2024, 10, 1), day(2025, 1, 1)},
+		{"25.12.24-05.01.25", day(2024, 12, 25), day(2025, 1, 6)},
+		{"28.02.2025-28.02.2025", day(2025, 2, 28), day(2025, 3, 1)},
+		{"31.10-01.10", time.Time{}, time.Time{}},
+		{"29.02.2025", time.Time{}, time.Time{}},
+		{"01.10-", time.Time{}, time.Time{}},
+		{"вчера", time.Time{}, time.Time{}},
+		{"2025-10-01", time.Time{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		from, to, err := parsePeriod(tt.in, now)
+		if tt.from.IsZero() {
+			if err == nil {
+				t.Errorf("parsePeriod(%q) = %v – %v, want an error", tt.in, from, to)
+			}
+			continue
+		}
+		if err != nil || !from.Equal(tt.from) || !to.Equal(tt.to) {
+			t.Errorf("parsePeriod(%q) = %v – %v, %v, want %v – %v", tt.in, from, to, err, tt.from, tt.to)
+		}
+	}
+}
+
+func TestParseExport(t *testing.T) {
+	ctx := context.Background()
+	msk := time.FixedZone("MSK", 3*60*60)
+	db := memory.New()
+	b := &Bot{DB: db, TZ: msk}
+	name := "boss"
+	boss, err := db.UpsertUser(ctx, 1, &name, "boss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := db.UpsertUser(ctx, 2, nil, "boss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sales, err := db.CreateDepartment(ctx, "Продажи", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(m time.Month, d int) time.Time { return time.Date(2025, m, d, 0, 0, 0, 0, msk) }
+
+	tests := []struct {
+		args    string
+		xlsx    bool
+		filter  storage.ExportFilter
+		overdue bool
+		conds   []string
+	}{
+		{"", true, storage.ExportFilter{}, false, nil},
+		{"csv", false, storage.ExportFilter{}, false, nil},
+		{"XLSX", true, storage.ExportFilter{}, false, nil},
+		{"csv период 01.10.2025 - 31.10.2025 отдел продажи статус просрочена автор я", false,
+			storage.ExportFilter{From: day(10, 1), To: day(11, 1), DepartmentID: sales, CreatorID: boss.ID}, true,
+			[]string{"период 01.10.2025–31.10.2025", "отдел «Продажи»", "статус «просрочена»", "автор @boss"}},
+		// conditions are listed in a fixed order whatever the order typed
+		{"автор 2 период 05.10.2025", true,
+			storage.ExportFilter{From: day(10, 5), To: day(10, 6), CreatorID: other.ID}, false,
+			[]string{"период 05.10.2025–05.10.2025", "автор 2"}},
+		{"отдел " + strconv.FormatInt(sales, 10), true, storage.ExportFilter{DepartmentID: sales}, false, []string{"отдел «Продажи»"}},
+
+		// status aliases
+		{"статус новые", true, storage.ExportFilter{Status: "new"}, false, []string{"статус «новые»"}},
+		{"статус В работе", true, storage.ExportFilter{Status: "in_progress"}, false, []string{"статус «В работе»"}},
+		{"status done", true, storage.ExportFilter{Status: "done"}, false, []string{"статус «done»"}},
+		{"статус готово", true, storage.ExportFilter{Status: "done"}, false, []string{"статус «готово»"}},
+		{"статус не выполнена", true, storage.ExportFilter{Status: "failed"}, false, []string{"статус «не выполнена»"}},
+		{"статус просроченные", true, storage.ExportFilter{}, true, []string{"статус «просроченные»"}},
+		{"status overdue", true, storage.ExportFilter{}, true, []string{"статус «overdue»"}},
+	}
+	for _, tt := range tests {
+		req, err := b.parseExport(ctx, tt.args, boss, msk)
+		if err != nil {
+			t.Errorf("parseExport(%q): %v", tt.args, err)
+			continue
+		}
+		if req.xlsx != tt.xlsx || req.filter != tt.filter || req.overdue != tt.overdue || !reflect.DeepEqual(req.conds, tt.conds) {
+			t.Errorf("parseExport(%q) = %+v, want xlsx %v, %+v, overdue %v, %q", tt.args, req, tt.xlsx, tt.filter, tt.overdue, tt.conds)
+		}
+	}
+
+	for _, args := range []string{
+		"pdf",
+		"статус",
+		"статус готов",
+		"статус новая статус done",
+		"отдел Цех",
+		"отдел 999",
+		"автор кто-то",
+		"автор 99",
+		"период вчера",
+		"csv период",
+	} {
+		if req, err := b.parseExport(ctx, args, boss, msk); err == nil {
+			t.Errorf("parseExport(%q) = %+v, want an error", args, req)
+		}
+	}
+}
+
+func TestOverdue(t *testing.T) {
+	due := time.Date(2025, 10, 15, 18, 0, 0, 0, time.UTC)
+	null := sql.NullTime{}
+	at := func(t time.Time) sql.NullTime { return sql.NullTime{Time: t, Valid: true} }
+
+	tests := []struct {
+		name      string
+		due, done sql.NullTime
+		status    string
+		now       time.Time
+		want      bool
+	}{
+		{"no deadline", null, null, "new", due.Add(time.Hour), false},
+		{"open before the deadline", at(due), null, "in_progress", due.Add(-time.Hour), false},
+		{"open at the deadline", at(due), null, "new", due, false},
+		{"open past the deadline", at(due), null, "in_progress", due.Add(time.Minute), true},
+		{"failed past the deadline", at(due), null, "failed", due.Add(time.Hour), true},
+		{"done in time", at(due), at(due.Add(-time.Hour)), "done", due.Add(time.Hour), false},
+		{"done at the deadline", at(due), at(due), "done", due.Add(time.Hour), false},
+		{"done late", at(due), at(due.Add(time.Minute)), "done", due.Add(time.Hour), true},
+	}
+	for _, tt := range tests {
+		r := &storage.AssignmentRow{DueAt: tt.due, DoneAt: tt.done, Status: tt.status}
+		if got := overdue(r, tt.now); got != tt.want {
+			t.Errorf("%s: overdue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterOverdue(t *testing.T) {
+	now := time.Date(2025, 10, 15, 18, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) sql.NullTime { return sql.NullTime{Time: now.Add(d), Valid: true} }
+	rows := []*storage.AssignmentRow{
+		{TaskID: 1, Status: "new"},
+		{TaskID: 2, Status: "new", DueAt: at(-time.Hour)},
+		{TaskID: 3, Status: "in_progress", DueAt: at(time.Hour)},
+		{TaskID: 4, Status: "done", DueAt: at(-2 * time.Hour), DoneAt: at(-time.Hour)},
+		{TaskID: 5, Status: "done", DueAt: at(-time.Hour), DoneAt: at(-2 * time.Hour)},
+		{TaskID: 6, Status: "failed", DueAt: at(-time.Minute)},
+	}
+	var got []int64
+	for _, r := range filterOverdue(rows, now) {
+		got = append(got, r.TaskID)
+	}
+	if want := []int64{2, 4, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("filterOverdue kept tasks %v, want %v", got, want)
+	}
+	if late := filterOverdue(nil, now); len(late) != 0 {
+		t.Errorf("filterOverdue(nil) = %v", late)
+	}
+}
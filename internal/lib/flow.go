@@ -0,0 +1,214 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// The /newtask steps in order. "⬅ Назад" returns to prevStep; "Оставить ▶"
+// keeps the value already entered and moves to nextStep.
+var (
+	prevStep = map[string]string{
+		StateNewTaskBody:      StateNewTaskTitle,
+		StateNewTaskAssignees: StateNewTaskBody,
+		StateNewTaskDeadline:  StateNewTaskAssignees,
+		StateNewTaskReminders: StateNewTaskDeadline,
+		StateNewTaskRepeat:    StateNewTaskConfirm,
+	}
+	nextStep = map[string]string{
+		StateNewTaskTitle:    StateNewTaskBody,
+		StateNewTaskDeadline: StateNewTaskReminders,
+	}
+)
+
+// flowRow is the row under every conversation prompt: "❌ Отмена", preceded
+// by "⬅ Назад" for /newtask steps that have a previous one.
+func flowRow(state string) []tgbotapi.InlineKeyboardButton {
+	var row []tgbotapi.InlineKeyboardButton
+	if _, ok := prevStep[state]; ok {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("⬅ Назад", "flow:back"))
+	}
+	return append(row, tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "flow:cancel"))
+}
+
+// prompt asks for the input of state, with the given rows above flowRow.
+func (b *Bot) prompt(chatID int64, state, text string, rows ...[]tgbotapi.InlineKeyboardButton) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(append(rows, flowRow(state))...)
+	b.API.Send(msg)
+}
+
+// askNewTaskStep sends the prompt of a /newtask step, showing what the draft
+// already holds for it.
+func (b *Bot) askNewTaskStep(chatID int64, state string, d *NewTaskDraft) {
+	keep := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Оставить ▶", "flow:keep"))
+	switch state {
+	case StateNewTaskTitle:
+		if d.Title == "" {
+			b.prompt(chatID, state, "Введите НАЗВАНИЕ задачи (только текстом):")
+			return
+		}
+		b.prompt(chatID, state, "Название: «"+d.Title+"». Введите новое или оставьте как есть.", keep)
+	case StateNewTaskBody:
+		text := "Теперь отправьте содержание задачи: текст, документы, фото, видео или голосовые. Когда всё отправлено — нажмите «Готово» или напишите «готово»."
+		var have []string
+		if d.Description != "" {
+			have = append(have, "текст")
+		}
+		if len(d.Attachments) > 0 {
+			have = append(have, fmt.Sprintf("вложений: %d", len(d.Attachments)))
+		}
+		if len(have) > 0 {
+			text = "Уже добавлено: " + strings.Join(have, ", ") + ". Пришлите ещё или нажмите «Готово»."
+		}
+		b.prompt(chatID, state, text,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("✅ Готово", "body_done")))
+	case StateNewTaskAssignees:
+		b.askAssignees(chatID, d)
+	case StateNewTaskDeadline:
+		loc := b.zone(chatID)
+		text := "Выберите дату в календаре или введите дедлайн текстом (время по " + zoneLabel(loc) + "). " + deadlineHelp
+		if due := draftDue(d); due.Valid {
+			text = "Дедлайн: " + formatDeadline(due.Time, loc) + ". Выберите новый или оставьте как есть.\n" + deadlineHelp
+		}
+		b.sendCalendar(chatID, chatID, calNewTaskDeadline, 0, text)
+	case StateNewTaskReminders:
+		b.askReminders(chatID, d)
+	case StateNewTaskConfirm:
+		b.showConfirm(chatID, d)
+	case StateNewTaskRepeat:
+		b.askRepeat(chatID, d)
+	}
+}
+
+// cancelFlow drops whatever conversation tgID is in and names it, or
+// returns "" when there was none.
+func (b *Bot) cancelFlow(ctx context.Context, tgID int64) string {
+	state, err := b.DB.LoadState(ctx, tgID, nil)
+	if err != nil || state == "" || state == StateIdle {
+		return ""
+	}
+	_ = b.DB.ClearState(ctx, tgID)
+	switch {
+	case isNewTaskState(state):
+		return "Создание задачи отменено."
+	case state == StateRegName || state == StateRegTeam:
+		return "Регистрация отменена."
+	case state == StateAwaitResult:
+		return "Отправка результата отменена."
+	case state == StateErrorReport:
+		return "Сообщение об ошибке отменено."
+	case state == StateImportConfirm:
+		return "Импорт отменён, задачи не созданы."
+	}
+	return "Отменено."
+}
+
+func (b *Bot) cmdCancel(m *tgbotapi.Message) {
+	defer b.lockUser(m.From.ID)()
+	text := b.cancelFlow(context.Background(), m.From.ID)
+	if text == "" {
+		text = "Нечего отменять."
+	}
+	b.reply(m.Chat.ID, text)
+}
+
+// onFlowCallback handles "flow:<cancel|back|keep>" from the prompt buttons.
+// Back and keep act on the step the user is at now, so a button under an
+// older prompt behaves the same as under the latest one.
+func (b *Bot) onFlowCallback(cq *tgbotapi.CallbackQuery) {
+	ctx := context.Background()
+	if cq.Message == nil {
+		return
+	}
+	chatID := cq.Message.Chat.ID
+	defer b.lockUser(cq.From.ID)()
+
+	if cq.Data == "flow:cancel" {
+		text := b.cancelFlow(ctx, cq.From.ID)
+		if text == "" {
+			b.API.Request(tgbotapi.NewCallback(cq.ID, "Нечего отменять"))
+			return
+		}
+		b.API.Send(tgbotapi.NewEditMessageText(chatID, cq.Message.MessageID, text))
+		b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+		return
+	}
+
+	d := &NewTaskDraft{}
+	state, _ := b.DB.LoadState(ctx, cq.From.ID, d)
+	keep := cq.Data == "flow:keep"
+	steps := prevStep
+	if keep {
+		steps = nextStep
+	}
+	to, ok := steps[state]
+	if !ok {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Этот шаг уже пройден"))
+		return
+	}
+	if keep && (state == StateNewTaskTitle && d.Title == "" || state == StateNewTaskDeadline && d.DueAt == "") {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Сначала введите значение"))
+		return
+	}
+	d.LastGroupID = ""
+	b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+	if keep {
+		b.advance(chatID, cq.From.ID, d, to)
+		return
+	}
+	b.DB.SaveState(ctx, cq.From.ID, to, d)
+	b.askNewTaskStep(chatID, to, d)
+}
+
+// newTaskDeadlineFooter keeps the "Оставить ▶" and flow buttons under the
+// deadline picker.
+func (b *Bot) newTaskDeadlineFooter(tgID int64) [][]tgbotapi.InlineKeyboardButton {
+	d := &NewTaskDraft{}
+	b.DB.LoadState(context.Background(), tgID, d)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if d.DueAt != "" {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Оставить ▶", "flow:keep")))
+	}
+	return append(rows, flowRow(StateNewTaskDeadline))
+}
+
+func (b *Bot) onNewTaskDeadlinePicked(cq *tgbotapi.CallbackQuery, _ int64, at time.Time) {
+	ctx := context.Background()
+	defer b.lockUser(cq.From.ID)()
+	d := &NewTaskDraft{}
+	if state, _ := b.DB.LoadState(ctx, cq.From.ID, d); state != StateNewTaskDeadline {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Этот шаг уже пройден"))
+		return
+	}
+	d.DueAt = at.Format(time.RFC3339)
+	b.API.Send(tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, "Дедлайн: "+formatDeadline(at, at.Location())+"."))
+	b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+	b.advance(cq.Message.Chat.ID, cq.From.ID, d, StateNewTaskReminders)
+}
+
+// advance moves the draft on to the next step, or straight back to the
+// confirmation card when the step was opened from "✏️ Изменить поле".
+func (b *Bot) advance(chatID, tgID int64, d *NewTaskDraft, next string) {
+	if d.Editing {
+		next, d.Editing = StateNewTaskConfirm, false
+	}
+	b.DB.SaveState(context.Background(), tgID, next, d)
+	b.askNewTaskStep(chatID, next, d)
+}
+
+// stepDraft loads the /newtask draft for a button of step state. A button
+// left from an earlier step or a finished task is only answered; callers
+// hold the user's lock.
+func (b *Bot) stepDraft(ctx context.Context, cq *tgbotapi.CallbackQuery, state string) (*NewTaskDraft, bool) {
+	d := &NewTaskDraft{}
+	if st, _ := b.DB.LoadState(ctx, cq.From.ID, d); st != state || cq.Message == nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Этот шаг уже пройден"))
+		return nil, false
+	}
+	return d, true
+}
@@ -0,0 +1,163 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (b *Bot) cmdTaskDeadline(m *tgbotapi.Message) {
+	ctx := context.Background()
+	usage := "Использование: /task_deadline <id> <дедлайн>\nБез даты — снять дедлайн.\n" + deadlineHelp
+	args := strings.Fields(m.CommandArguments())
+	if len(args) == 0 {
+		b.reply(m.Chat.ID, usage)
+		return
+	}
+	taskID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.reply(m.Chat.ID, "id должен быть числом")
+		return
+	}
+
+	loc := b.zone(m.From.ID)
+	var due sql.NullTime
+	if len(args) > 1 {
+		dl, err := parseDeadline(strings.Join(args[1:], " "), loc)
+		if errors.Is(err, errDeadlinePast) {
+			b.reply(m.Chat.ID, deadlineError(dl, err, loc))
+			return
+		}
+		if err != nil {
+			b.reply(m.Chat.ID, usage)
+			return
+		}
+		due = sql.NullTime{Time: dl, Valid: true}
+	}
+
+	t, err := b.DB.GetTask(ctx, taskID)
+	if err != nil {
+		b.reply(m.Chat.ID, "Задача не найдена.")
+		return
+	}
+	if t.DeletedAt.Valid {
+		b.reply(m.Chat.ID, fmt.Sprintf("Задача в корзине. Сначала восстановите её: /task_restore %d", taskID))
+		return
+	}
+	boss, _ := b.DB.GetUserByTgID(ctx, m.From.ID)
+	if err := b.DB.UpdateTaskDeadline(ctx, taskID, boss.ID, due); err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+
+	tgIDs, _ := b.DB.ListAssigneeTgIDsByTask(ctx, taskID)
+	for _, tg := range tgIDs {
+		note := "🕒 Дедлайн по задаче «" + nullStr(t.Title) + "» снят."
+		if due.Valid {
+			note = "🕒 Дедлайн по задаче «" + nullStr(t.Title) + "» перенесён на " + due.Time.In(b.zone(tg)).Format("02.01.2006 15:04") + "."
+		}
+		b.API.Send(tgbotapi.NewMessage(tg, note))
+	}
+	if due.Valid {
+		b.reply(m.Chat.ID, "Дедлайн обновлён: "+formatDeadline(due.Time, loc)+".")
+		return
+	}
+	b.reply(m.Chat.ID, "Дедлайн снят.")
+}
+
+func (b *Bot) cmdHistory(m *tgbotapi.Message) {
+	ctx := context.Background()
+	taskID, err := strconv.ParseInt(strings.TrimSpace(m.CommandArguments()), 10, 64)
+	if err != nil {
+		b.reply(m.Chat.ID, "Использование: /history <id задачи>")
+		return
+	}
+	evs, err := b.DB.ListTaskEvents(ctx, taskID)
+	if err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	if len(evs) == 0 {
+		b.reply(m.Chat.ID, "История по задаче не найдена.")
+		return
+	}
+
+	title := ""
+	if t, err := b.DB.GetTask(ctx, taskID); err == nil {
+		title = nullStr(t.Title)
+	} else if evs[0].Kind == storage.EventCreated {
+		title = nullStr(evs[0].NewValue)
+	}
+
+	loc := b.zone(m.From.ID)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("История задачи [%d] «%s»:\n", taskID, title))
+	snoozes := map[string]int{}
+	var snoozers []string
+	for _, e := range evs {
+		sb.WriteString(e.CreatedAt.In(loc).Format("02.01.2006 15:04") + " — " + describeEvent(e, loc) + "\n")
+		if e.Kind == storage.EventSnoozed {
+			name := ifEmpty(nullStr(e.UserName), "удалённый сотрудник")
+			if snoozes[name] == 0 {
+				snoozers = append(snoozers, name)
+			}
+			snoozes[name]++
+		}
+	}
+	if len(snoozers) > 0 {
+		sb.WriteString("\nОткладывали напоминания:")
+		for _, name := range snoozers {
+			sb.WriteString(fmt.Sprintf("\n- %s — %d раз(а)", name, snoozes[name]))
+		}
+	}
+	b.reply(m.Chat.ID, sb.String())
+}
+
+func describeEvent(e *storage.TaskEvent, loc *time.Location) string {
+	actor := ifEmpty(nullStr(e.ActorName), "—")
+	user := ifEmpty(nullStr(e.UserName), "удалённый сотрудник")
+	switch e.Kind {
+	case storage.EventCreated:
+		return actor + ": задача создана"
+	case storage.EventAssigned:
+		return actor + ": назначен " + user
+	case storage.EventUnassigned:
+		return ifEmpty(nullStr(e.OldValue), user) + " снят с задачи"
+	case storage.EventStatus:
+		return user + ": " + mapStatus(nullStr(e.OldValue)) + " → " + mapStatus(nullStr(e.NewValue))
+	case storage.EventResult:
+		return user + " прислал результат: " + nullStr(e.NewValue)
+	case storage.EventDeadline:
+		return actor + ": дедлайн " + formatDue(e.OldValue, loc) + " → " + formatDue(e.NewValue, loc)
+	case storage.EventDeleted:
+		return actor + ": задача удалена"
+	case storage.EventRestored:
+		return actor + ": задача восстановлена"
+	case storage.EventEscalated:
+		return "эскалация " + nullStr(e.OldValue) + " по просрочке у " + user + ": " + ifEmpty(nullStr(e.NewValue), "получателей нет")
+	case storage.EventSnoozed:
+		return user + " отложил напоминание до " + formatDue(e.NewValue, loc)
+	default:
+		return actor + ": " + e.Kind
+	}
+}
+
+// formatDue renders a deadline stored by storage.FormatDue in loc.
+func formatDue(v sql.NullString, loc *time.Location) string {
+	if !v.Valid {
+		return "нет"
+	}
+	t, err := time.Parse(time.RFC3339, v.String)
+	if err != nil {
+		return v.String
+	}
+	return t.In(loc).Format("02.01.2006 15:04")
+}
@@ -0,0 +1,386 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/xuri/excelize/v2"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// Bulk creation: a boss sends a CSV or XLSX file with one task per row.
+// Every row is checked first; only a file without errors can be confirmed,
+// and then all its tasks are created in one transaction.
+
+const (
+	maxImportRows  = 200
+	maxImportBytes = 2 << 20
+)
+
+// importColumns maps the header names a file may use to its fields.
+var importColumns = map[string]string{
+	"название": "title", "задача": "title", "title": "title",
+	"описание": "description", "description": "description",
+	"исполнители": "assignees", "исполнитель": "assignees", "assignees": "assignees",
+	"отдел": "departments", "отделы": "departments", "department": "departments", "departments": "departments",
+	"дедлайн": "deadline", "срок": "deadline", "deadline": "deadline",
+	"напоминания": "reminders", "reminders": "reminders",
+}
+
+const importHelp = "Массовое создание задач: пришлите файл CSV или XLSX, по задаче в строке. Первая строка — заголовки:\n" +
+	"название — обязательно;\n" +
+	"описание — обязательно;\n" +
+	"исполнители — @username или tg_id через запятую;\n" +
+	"отдел — названия отделов через запятую (задача уйдёт всем их сотрудникам);\n" +
+	"дедлайн — как в /newtask, например 25.10 18:00 или +3д;\n" +
+	"напоминания — часы до дедлайна через запятую, например 48,24.\n" +
+	"Нужен хотя бы один исполнитель или отдел. Сначала бот проверит все строки и покажет ошибки; задачи создаются только после подтверждения. " +
+	"В CSV разделитель — запятая или точка с запятой."
+
+// importBatch is the state payload of a checked file awaiting confirmation.
+type importBatch struct {
+	File   string         `json:"file"`
+	Drafts []NewTaskDraft `json:"drafts"`
+}
+
+func isImportFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".xlsx":
+		return true
+	}
+	return false
+}
+
+func (b *Bot) cmdImport(m *tgbotapi.Message) {
+	b.reply(m.Chat.ID, importHelp)
+}
+
+// onImportFile checks an uploaded file and either reports its errors row by
+// row or asks to confirm the tasks.
+func (b *Bot) onImportFile(m *tgbotapi.Message) {
+	ctx := context.Background()
+	doc := m.Document
+	if doc.FileSize > maxImportBytes {
+		b.reply(m.Chat.ID, "Файл слишком большой для импорта.")
+		return
+	}
+	data, err := b.download(doc.FileID)
+	if err != nil {
+		// the error carries the file URL, and with it the bot token
+		log.Println("download import:", err)
+		b.reply(m.Chat.ID, "Не удалось скачать файл, попробуйте ещё раз.")
+		return
+	}
+	if len(data) > maxImportBytes {
+		b.reply(m.Chat.ID, "Файл слишком большой для импорта.")
+		return
+	}
+	var rows [][]string
+	if strings.EqualFold(filepath.Ext(doc.FileName), ".xlsx") {
+		rows, err = readXLSX(data)
+	} else {
+		rows, err = readCSV(data)
+	}
+	if err != nil {
+		b.reply(m.Chat.ID, "Не удалось прочитать файл: "+err.Error())
+		return
+	}
+
+	drafts, problems, err := b.checkImport(ctx, rows, b.zone(m.From.ID))
+	if err != nil {
+		b.reply(m.Chat.ID, err.Error()+"\n\n"+importHelp)
+		return
+	}
+	if len(problems) > 0 {
+		text := fmt.Sprintf("Файл «%s»: ошибок — %d, задачи не созданы. Исправьте и пришлите файл снова.\n\n%s",
+			doc.FileName, len(problems), strings.Join(problems, "\n"))
+		b.reply(m.Chat.ID, truncateText(text, maxPageText))
+		return
+	}
+
+	defer b.lockUser(m.From.ID)()
+	if err := b.DB.SaveState(ctx, m.From.ID, StateImportConfirm, &importBatch{File: doc.FileName, Drafts: drafts}); err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	loc := b.zone(m.From.ID)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Файл «%s» проверен, задач: %d.\n\n", doc.FileName, len(drafts))
+	for i := range drafts {
+		d := &drafts[i]
+		due := "без дедлайна"
+		if t := draftDue(d); t.Valid {
+			due = "до " + t.Time.In(loc).Format("02.01 15:04")
+		}
+		fmt.Fprintf(&sb, "%d. «%s» — исполнителей: %d, %s\n", i+1, d.Title, len(b.draftAssignees(ctx, d)), due)
+	}
+	msg := tgbotapi.NewMessage(m.Chat.ID, truncateText(sb.String(), maxPageText))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ Создать задачи (%d)", len(drafts)), "import:create")),
+		flowRow(StateImportConfirm),
+	)
+	b.API.Send(msg)
+}
+
+// download fetches a file sent to the bot.
+func (b *Bot) download(fileID string) ([]byte, error) {
+	url, err := b.API.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxImportBytes+1))
+}
+
+// readCSV reads a CSV file, comma, semicolon or tab separated as guessed
+// from its first line.
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	for _, sep := range []rune{';', '\t'} {
+		if bytes.Count(first, []byte(string(sep))) > bytes.Count(first, []byte(string(r.Comma))) {
+			r.Comma = sep
+		}
+	}
+	return r.ReadAll()
+}
+
+// readXLSX reads the first sheet. Dates come as serial numbers and are
+// written back the way a deadline is typed.
+func readXLSX(data []byte) ([][]string, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("в книге нет листов")
+	}
+	rows, err := f.GetRows(sheets[0], excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+	deadline := -1
+	for i, row := range rows {
+		if i == 0 {
+			for j, h := range row {
+				if deadline < 0 && importColumns[strings.ToLower(strings.TrimSpace(h))] == "deadline" {
+					deadline = j
+				}
+			}
+			continue
+		}
+		if deadline < 0 || deadline >= len(row) || dateRx.MatchString(row[deadline]) {
+			continue
+		}
+		if serial, err := strconv.ParseFloat(row[deadline], 64); err == nil && serial > 1 {
+			if t, err := excelize.ExcelDateToTime(serial, false); err == nil {
+				row[deadline] = t.Round(time.Minute).Format("02.01.2006 15:04")
+			}
+		}
+	}
+	return rows, nil
+}
+
+// checkImport turns the rows of a file into drafts, with deadlines read in
+// loc. problems lists what is wrong with each row; err means the file as a
+// whole cannot be read.
+func (b *Bot) checkImport(ctx context.Context, rows [][]string, loc *time.Location) (drafts []NewTaskDraft, problems []string, err error) {
+	if len(rows) == 0 {
+		return nil, nil, errors.New("Файл пустой.")
+	}
+	col := map[string]int{}
+	for i, h := range rows[0] {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if f, ok := importColumns[h]; ok {
+			if _, dup := col[f]; !dup {
+				col[f] = i
+			}
+		}
+	}
+	if _, ok := col["title"]; !ok {
+		return nil, nil, errors.New("В первой строке нет колонки «название».")
+	}
+	if len(rows)-1 > maxImportRows {
+		return nil, nil, fmt.Errorf("Слишком много строк: не больше %d задач за раз.", maxImportRows)
+	}
+
+	deps, err := b.DB.ListDepartments(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	depIDs := map[string]int64{}
+	for _, dep := range deps {
+		depIDs[strings.ToLower(dep.Name)] = dep.ID
+	}
+
+	for n, row := range rows[1:] {
+		cell := func(field string) string {
+			if i, ok := col[field]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		var errs []string
+		d := NewTaskDraft{Title: cell("title"), Description: cell("description")}
+		if d.Title == "" {
+			errs = append(errs, "нет названия")
+		}
+		if d.Description == "" {
+			errs = append(errs, "нет описания")
+		}
+
+		for _, name := range splitImportList(cell("assignees")) {
+			tg, err := b.findImportAssignee(ctx, name)
+			if err != nil {
+				errs = append(errs, "исполнитель "+name+" не найден")
+				continue
+			}
+			d.AssigneeIDs = uniqAppend(d.AssigneeIDs, tg)
+		}
+		for _, name := range strings.FieldsFunc(cell("departments"), func(r rune) bool { return r == ',' || r == ';' }) {
+			name = strings.TrimSpace(name)
+			id, ok := depIDs[strings.ToLower(name)]
+			if !ok {
+				errs = append(errs, "отдел «"+name+"» не найден")
+				continue
+			}
+			d.DeptIDs = uniqAppend(d.DeptIDs, id)
+		}
+		if cell("assignees") == "" && cell("departments") == "" {
+			errs = append(errs, "не указаны исполнители или отдел")
+		}
+
+		if s := cell("deadline"); s != "" {
+			due, err := parseDeadline(s, loc)
+			switch {
+			case errors.Is(err, errDeadlinePast):
+				errs = append(errs, "дедлайн "+formatDeadline(due, loc)+" уже прошёл")
+			case err != nil:
+				errs = append(errs, "не понял дедлайн «"+s+"»")
+			default:
+				d.DueAt = due.Format(time.RFC3339)
+			}
+		}
+		if s := cell("reminders"); s != "" {
+			hours, err := b.parseReminderHours(strings.ReplaceAll(s, ";", ","))
+			switch {
+			case err != nil:
+				errs = append(errs, "напоминания «"+s+"»: нужны часы через запятую")
+			case cell("deadline") == "":
+				errs = append(errs, "напоминания без дедлайна")
+			default:
+				d.RemindHours = hours
+			}
+		}
+
+		if len(errs) > 0 {
+			// the header is row 1, as in a spreadsheet
+			problems = append(problems, fmt.Sprintf("строка %d: %s", n+2, strings.Join(errs, "; ")))
+			continue
+		}
+		drafts = append(drafts, d)
+	}
+	if len(drafts) == 0 && len(problems) == 0 {
+		return nil, nil, errors.New("В файле нет задач.")
+	}
+	return drafts, problems, nil
+}
+
+// splitImportList splits a cell of assignees on commas, semicolons and
+// spaces.
+func splitImportList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == ' ' || r == '\n' })
+}
+
+// findImportAssignee resolves "@username", "username" or a tg_id to a
+// Telegram id.
+func (b *Bot) findImportAssignee(ctx context.Context, name string) (int64, error) {
+	if tg, err := strconv.ParseInt(name, 10, 64); err == nil {
+		u, err := b.DB.GetUserByTgID(ctx, tg)
+		if err != nil {
+			return 0, err
+		}
+		return u.TgID, nil
+	}
+	u, err := b.DB.FindWorkerByUsername(ctx, strings.TrimPrefix(name, "@"))
+	if err != nil {
+		return 0, err
+	}
+	return u.TgID, nil
+}
+
+// onImportCallback handles "import:create" under a checked file.
+func (b *Bot) onImportCallback(cq *tgbotapi.CallbackQuery) {
+	ctx := context.Background()
+	if cq.Data != "import:create" || cq.Message == nil {
+		return
+	}
+	chatID, msgID := cq.Message.Chat.ID, cq.Message.MessageID
+	defer b.lockUser(cq.From.ID)()
+
+	batch := &importBatch{}
+	if state, _ := b.DB.LoadState(ctx, cq.From.ID, batch); state != StateImportConfirm {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Импорт уже выполнен или отменён"))
+		return
+	}
+	// cleared first, so a second tap cannot create the tasks twice
+	_ = b.DB.ClearState(ctx, cq.From.ID)
+	b.API.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, msgID, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+	b.API.Request(tgbotapi.NewCallback(cq.ID, "Создаю задачи"))
+
+	boss, err := b.DB.GetUserByTgID(ctx, cq.From.ID)
+	if err != nil {
+		b.reply(chatID, "Ошибка: "+err.Error())
+		return
+	}
+	now := time.Now().In(b.userZone(boss))
+	var tasks []storage.NewTask
+	var recipients [][]int64
+	for i := range batch.Drafts {
+		d := &batch.Drafts[i]
+		expandDraft(d, now)
+		nt, tgIDs := b.draftNewTask(ctx, boss.ID, d)
+		tasks = append(tasks, nt)
+		recipients = append(recipients, tgIDs)
+	}
+	created, err := b.DB.CreateTasks(ctx, tasks)
+	if err != nil {
+		b.reply(chatID, "Ошибка, ни одна задача не создана: "+err.Error())
+		return
+	}
+	sent := 0
+	for i, c := range created {
+		for _, tg := range recipients[i] {
+			b.sendTaskToAssignee(tg, c.Task.ID, c.Task)
+			sent++
+		}
+	}
+	b.reply(chatID, fmt.Sprintf("Из файла «%s» создано задач: %d, отправлено исполнителям: %d.", batch.File, len(created), sent))
+}
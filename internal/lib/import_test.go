@@ -0,0 +1,182 @@
+package lib
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/hihikaAAa/task-manager/internal/storage/memory"
+)
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want [][]string
+	}{
+		{"comma", "название,описание\nОтчёт,Собрать\n",
+			[][]string{{"название", "описание"}, {"Отчёт", "Собрать"}}},
+		{"semicolon with a BOM", "\xef\xbb\xbfназвание;описание;исполнители\nОтчёт;Собрать, проверить;@anna,@boris\n",
+			[][]string{{"название", "описание", "исполнители"}, {"Отчёт", "Собрать, проверить", "@anna,@boris"}}},
+		{"tab", "название\tописание\r\nОтчёт\tСобрать; проверить\r\n",
+			[][]string{{"название", "описание"}, {"Отчёт", "Собрать; проверить"}}},
+		{"quotes", "название,описание\n\"Отчёт, квартал\",\"скажи \"\"да\"\"\"\n",
+			[][]string{{"название", "описание"}, {"Отчёт, квартал", `скажи "да"`}}},
+		{"ragged rows", "название;описание;срок\nОтчёт\n",
+			[][]string{{"название", "описание", "срок"}, {"Отчёт"}}},
+		{"one column", "название\nОтчёт\n",
+			[][]string{{"название"}, {"Отчёт"}}},
+	}
+	for _, tt := range tests {
+		got, err := readCSV([]byte(tt.data))
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: readCSV = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestReadXLSX(t *testing.T) {
+	f := excelize.NewFile()
+	rows := [][]any{
+		{"Название", "Описание", "Исполнители", "Срок"},
+		{"Отчёт", "Собрать", "@anna", time.Date(2099, 10, 25, 18, 0, 0, 0, time.UTC)},
+		{"Сверка", "Сверить", "@anna", time.Date(2099, 10, 25, 17, 59, 40, 0, time.UTC)},
+		{"План", "Составить", "@anna", "25.10.2099"},
+		{"Звонок", "Позвонить", "@anna", "+3д"},
+		{"Без срока", "Когда-нибудь", "@anna"},
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// only the first sheet is read
+	if _, err := f.NewSheet("Другое"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetCellValue("Другое", "A1", "не задачи"); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readXLSX(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Название", "Описание", "Исполнители", "Срок"},
+		{"Отчёт", "Собрать", "@anna", "25.10.2099 18:00"},
+		{"Сверка", "Сверить", "@anna", "25.10.2099 18:00"},
+		{"План", "Составить", "@anna", "25.10.2099"},
+		{"Звонок", "Позвонить", "@anna", "+3д"},
+		{"Без срока", "Когда-нибудь", "@anna"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readXLSX = %q, want %q", got, want)
+	}
+
+	if _, err := readXLSX([]byte("название,описание\n")); err == nil {
+		t.Error("readXLSX of a CSV file succeeded")
+	}
+}
+
+func TestCheckImport(t *testing.T) {
+	ctx := context.Background()
+	msk := time.FixedZone("MSK", 3*60*60)
+	db := memory.New()
+	b := &Bot{DB: db, TZ: msk}
+	dep, err := db.CreateDepartment(ctx, "Склад", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []struct {
+		tg   int64
+		name string
+	}{{11, "anna"}, {12, "boris"}} {
+		if _, err := db.UpsertUser(ctx, w.tg, &w.name, "worker"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SetWorkerProfile(ctx, w.tg, w.name, dep); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows := [][]string{
+		{"Задача", "Описание", "Исполнители", "Отдел", "Дедлайн", "Напоминания", "Заметки"},
+		{"Отчёт", "Собрать", "@anna, 12 anna", "", "+3д", "48; 24", "для себя"},
+		{"", "", "", "", "", "", ""},
+		{"Инвентаризация", "Пересчитать", "", " склад ", "", "", ""},
+		{"", "", "@nobody", "Цех", "вчера", "часто", ""},
+		{"Старое", "Было", "@anna", "", "01.01.2020", "", ""},
+		{"Без исполнителей", "Есть", "", "", "", "24", ""},
+		{"Короткая"},
+	}
+	drafts, problems, err := b.checkImport(ctx, rows, msk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(drafts) != 2 {
+		t.Fatalf("checkImport = %d drafts, want 2: %+v", len(drafts), drafts)
+	}
+	d := drafts[0]
+	if d.Title != "Отчёт" || d.Description != "Собрать" || !slices.Equal(d.AssigneeIDs, []int64{11, 12}) || !slices.Equal(d.RemindHours, []int{24, 48}) {
+		t.Errorf("first draft = %+v", d)
+	}
+	due, err := time.Parse(time.RFC3339, d.DueAt)
+	if err != nil {
+		t.Fatalf("due_at %q: %v", d.DueAt, err)
+	}
+	if inMSK := due.In(msk); inMSK.Hour() != workdayEndHour || inMSK.Sub(time.Now()) < 2*24*time.Hour || inMSK.Sub(time.Now()) > 4*24*time.Hour {
+		t.Errorf("due_at = %v, want in 3 days at %d:00", inMSK, workdayEndHour)
+	}
+	if d = drafts[1]; d.Title != "Инвентаризация" || !slices.Equal(d.DeptIDs, []int64{dep}) || len(d.AssigneeIDs) != 0 || d.DueAt != "" {
+		t.Errorf("second draft = %+v", d)
+	}
+
+	// rows are numbered as in a spreadsheet, the header being row 1; the
+	// blank row 3 is neither a task nor a problem
+	want := []string{
+		"строка 5: нет названия; нет описания; исполнитель @nobody не найден; отдел «Цех» не найден; не понял дедлайн «вчера»; напоминания «часто»: нужны часы через запятую",
+		"строка 6: дедлайн ср, 01.01.2020 18:00 уже прошёл",
+		"строка 7: не указаны исполнители или отдел; напоминания без дедлайна",
+		"строка 8: нет описания; не указаны исполнители или отдел",
+	}
+	if !slices.Equal(problems, want) {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(problems, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCheckImportFile(t *testing.T) {
+	ctx := context.Background()
+	b := &Bot{DB: memory.New(), TZ: time.UTC}
+	many := [][]string{{"название"}}
+	for range maxImportRows + 1 {
+		many = append(many, []string{"задача"})
+	}
+	tests := []struct {
+		name string
+		rows [][]string
+		want string
+	}{
+		{"empty", nil, "Файл пустой."},
+		{"no title column", [][]string{{"описание", "исполнители"}, {"Собрать", "@anna"}}, "В первой строке нет колонки «название»."},
+		{"too many rows", many, "Слишком много строк"},
+		{"header only", [][]string{{"название"}, {""}, {" "}}, "В файле нет задач."},
+	}
+	for _, tt := range tests {
+		drafts, problems, err := b.checkImport(ctx, tt.rows, time.UTC)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%s: checkImport = %d drafts, %q, %v, want %q", tt.name, len(drafts), problems, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package lib
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// mediaFile is the file carried by a message.
+type mediaFile struct {
+	Kind     string
+	FileID   string
+	FileName string
+	MimeType string
+	FileSize int
+}
+
+// messageMedia returns the document, voice, audio, photo or video in m, or
+// nil. Of a photo's sizes the largest is taken.
+func messageMedia(m *tgbotapi.Message) *mediaFile {
+	switch {
+	case m.Document != nil:
+		return &mediaFile{storage.MediaDocument, m.Document.FileID, m.Document.FileName, m.Document.MimeType, m.Document.FileSize}
+	case m.Voice != nil:
+		return &mediaFile{storage.MediaVoice, m.Voice.FileID, "", m.Voice.MimeType, m.Voice.FileSize}
+	case m.Audio != nil:
+		return &mediaFile{storage.MediaAudio, m.Audio.FileID, m.Audio.FileName, m.Audio.MimeType, m.Audio.FileSize}
+	case len(m.Photo) > 0:
+		p := m.Photo[len(m.Photo)-1]
+		return &mediaFile{storage.MediaPhoto, p.FileID, "", "", p.FileSize}
+	case m.Video != nil:
+		return &mediaFile{storage.MediaVideo, m.Video.FileID, m.Video.FileName, m.Video.MimeType, m.Video.FileSize}
+	}
+	return nil
+}
+
+// mediaMessage sends a file by id with the constructor for its kind.
+func mediaMessage(chatID int64, kind, fileID, caption string) tgbotapi.Chattable {
+	f := tgbotapi.FileID(fileID)
+	switch kind {
+	case storage.MediaPhoto:
+		m := tgbotapi.NewPhoto(chatID, f)
+		m.Caption = caption
+		return m
+	case storage.MediaVoice:
+		m := tgbotapi.NewVoice(chatID, f)
+		m.Caption = caption
+		return m
+	case storage.MediaAudio:
+		m := tgbotapi.NewAudio(chatID, f)
+		m.Caption = caption
+		return m
+	case storage.MediaVideo:
+		m := tgbotapi.NewVideo(chatID, f)
+		m.Caption = caption
+		return m
+	default:
+		m := tgbotapi.NewDocument(chatID, f)
+		m.Caption = caption
+		return m
+	}
+}
+
+// maxAlbum is the most items Telegram accepts in one media group.
+const maxAlbum = 10
+
+// sendAttachments delivers a task's attachments. Photos and videos go out
+// as one album, documents and audio as albums of their own (Telegram does
+// not mix those with other kinds); voice notes cannot be grouped and are
+// sent one by one.
+func (b *Bot) sendAttachments(chatID int64, atts []*storage.Attachment) {
+	var visual, docs, audio []*storage.Attachment
+	for _, a := range atts {
+		switch a.Kind {
+		case storage.MediaPhoto, storage.MediaVideo:
+			visual = append(visual, a)
+		case storage.MediaDocument:
+			docs = append(docs, a)
+		case storage.MediaAudio:
+			audio = append(audio, a)
+		default:
+			b.API.Send(mediaMessage(chatID, a.Kind, a.FileID, a.Caption.String))
+		}
+	}
+	for _, group := range [][]*storage.Attachment{visual, docs, audio} {
+		for len(group) > 0 {
+			n := min(len(group), maxAlbum)
+			b.sendAlbum(chatID, group[:n])
+			group = group[n:]
+		}
+	}
+}
+
+func (b *Bot) sendAlbum(chatID int64, atts []*storage.Attachment) {
+	if len(atts) == 1 {
+		a := atts[0]
+		b.API.Send(mediaMessage(chatID, a.Kind, a.FileID, a.Caption.String))
+		return
+	}
+	var media []interface{}
+	for _, a := range atts {
+		f := tgbotapi.FileID(a.FileID)
+		switch a.Kind {
+		case storage.MediaPhoto:
+			im := tgbotapi.NewInputMediaPhoto(f)
+			im.Caption = a.Caption.String
+			media = append(media, im)
+		case storage.MediaVideo:
+			im := tgbotapi.NewInputMediaVideo(f)
+			im.Caption = a.Caption.String
+			media = append(media, im)
+		case storage.MediaAudio:
+			im := tgbotapi.NewInputMediaAudio(f)
+			im.Caption = a.Caption.String
+			media = append(media, im)
+		default:
+			im := tgbotapi.NewInputMediaDocument(f)
+			im.Caption = a.Caption.String
+			media = append(media, im)
+		}
+	}
+	b.API.SendMediaGroup(tgbotapi.NewMediaGroup(chatID, media))
+}
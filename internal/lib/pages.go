@@ -0,0 +1,172 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// Paginated task lists. The list name travels in the "page:" callback data
+// together with the cursor, so navigation keeps working after a restart.
+const (
+	listMyTasks   = "my"
+	listTeamTasks = "team"
+	listAllActive = "all"
+	listDone      = "done"
+	listMyDone    = "mydone"
+)
+
+// maxPageText keeps a rendered page below Telegram's 4096 character limit.
+const maxPageText = 4000
+
+// truncateText cuts s to at most n characters, marking the cut with "…".
+func truncateText(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "…"
+	}
+	return s
+}
+
+// sendTaskPage renders one page of a task list. With msgID 0 it sends a new
+// message, otherwise it edits msgID in place.
+func (b *Bot) sendTaskPage(chatID int64, msgID int, fromTgID int64, list string, p storage.PageRequest) {
+	ctx := context.Background()
+	u, err := b.DB.GetUserByTgID(ctx, fromTgID)
+	if err != nil {
+		b.reply(chatID, "Сначала зарегистрируйтесь: /register")
+		return
+	}
+
+	page, empty, err := b.loadTaskPage(ctx, u, list, p)
+	if err == nil && len(page.Tasks) == 0 && !p.Cursor.IsZero() {
+		// the neighbouring rows are gone (done or deleted), start over
+		page, empty, err = b.loadTaskPage(ctx, u, list, storage.PageRequest{})
+	}
+	var text string
+	switch {
+	case err != nil:
+		text = "Ошибка: " + err.Error()
+	case len(page.Tasks) == 0:
+		text = empty
+	default:
+		text, page = b.fitTaskPage(list, page, b.userZone(u))
+	}
+	text = truncateText(text, maxPageText)
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if err == nil && len(page.Tasks) > 0 {
+		var nav []tgbotapi.InlineKeyboardButton
+		if page.HasPrev {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀", pageData(list, "p", page.First())))
+		}
+		if page.HasNext {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶", pageData(list, "n", page.Last())))
+		}
+		if len(nav) > 0 {
+			rows = append(rows, nav)
+		}
+	}
+
+	if msgID == 0 {
+		msg := tgbotapi.NewMessage(chatID, text)
+		if len(rows) > 0 {
+			msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		}
+		b.API.Send(msg)
+		return
+	}
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, text,
+		tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows})
+	if len(rows) == 0 {
+		edit.ReplyMarkup = nil
+	}
+	b.API.Send(edit)
+}
+
+// loadTaskPage fetches a page of list for u and the text to show when it is
+// empty.
+func (b *Bot) loadTaskPage(ctx context.Context, u *storage.User, list string, p storage.PageRequest) (*storage.TaskPage, string, error) {
+	switch list {
+	case listMyTasks:
+		page, err := b.DB.ListActiveTasksForUser(ctx, u.ID, p)
+		return page, "Нет активных задач.", err
+	case listTeamTasks:
+		if !u.DepartmentID.Valid {
+			return &storage.TaskPage{}, "В вашем профиле не указана команда. Используйте /register.", nil
+		}
+		page, err := b.DB.ListActiveTasksForDepartment(ctx, u.DepartmentID.Int64, p)
+		return page, "Нет активных задач по вашей команде.", err
+	case listAllActive:
+		page, err := b.DB.ListActiveTasksForBoss(ctx, p)
+		return page, "Нет активных задач.", err
+	case listDone:
+		page, err := b.DB.ListDoneTasksForBoss(ctx, u.ID, p)
+		return page, "Выполненных задач пока нет.", err
+	case listMyDone:
+		page, err := b.DB.ListDoneTasksForUser(ctx, u.ID, p)
+		return page, "У вас пока нет выполненных задач.", err
+	}
+	return nil, "", fmt.Errorf("unknown list %q", list)
+}
+
+// fitTaskPage renders page, leaving tasks off its end until the text fits
+// in maxPageText. The returned page holds the tasks shown, so ▶ continues
+// right after the last of them.
+func (b *Bot) fitTaskPage(list string, page *storage.TaskPage, loc *time.Location) (string, *storage.TaskPage) {
+	text := b.renderTaskPage(list, page, loc)
+	for n := len(page.Tasks) - 1; n > 0 && utf8.RuneCountInString(text) > maxPageText; n-- {
+		page = &storage.TaskPage{Tasks: page.Tasks[:n], Keys: page.Keys[:n], HasPrev: page.HasPrev, HasNext: true}
+		text = b.renderTaskPage(list, page, loc)
+	}
+	return text, page
+}
+
+func (b *Bot) renderTaskPage(list string, page *storage.TaskPage, loc *time.Location) string {
+	switch list {
+	case listAllActive:
+		return b.formatAllActive(page.Tasks, loc)
+	case listDone:
+		return b.formatDone(page.Tasks, page.Keys, loc)
+	case listMyDone:
+		return b.formatMyDone(page.Tasks, page.Keys, loc)
+	default:
+		return b.formatTasks(page.Tasks, false, loc)
+	}
+}
+
+// pageData encodes "page:<list>:<p|n>:<unix nanos>:<id>", well under the 64
+// byte callback data limit.
+func pageData(list, dir string, c storage.Cursor) string {
+	return fmt.Sprintf("page:%s:%s:%d:%d", list, dir, c.At.UnixNano(), c.ID)
+}
+
+func (b *Bot) onPageCallback(cq *tgbotapi.CallbackQuery) {
+	parts := strings.Split(cq.Data, ":")
+	if len(parts) != 5 || cq.Message == nil {
+		return
+	}
+	list, dir := parts[1], parts[2]
+	nanos, err1 := strconv.ParseInt(parts[3], 10, 64)
+	id, err2 := strconv.ParseInt(parts[4], 10, 64)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	if (list == listAllActive || list == listDone) && !b.isBoss(cq.From.ID) {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Только для боссов."))
+		return
+	}
+
+	p := storage.PageRequest{
+		Cursor:   storage.Cursor{At: time.Unix(0, nanos).UTC(), ID: id},
+		Backward: dir == "p",
+	}
+	b.sendTaskPage(cq.Message.Chat.ID, cq.Message.MessageID, cq.From.ID, list, p)
+	b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+}
@@ -0,0 +1,607 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// Recurring tasks are a /newtask draft with a schedule. On every occurrence
+// the scheduler makes an ordinary task of the draft — departments are
+// expanded anew, the deadline is the occurrence plus the schedule's "срок",
+// and reminders are counted from it — and sends it like any other. Times of
+// a schedule are read in the zone of its creator.
+
+var errSchedule = errors.New("bad schedule")
+
+const recurringHelp = "Примеры: ежедневно 09:00; еженедельно пн,чт 10:00; ежемесячно 1 09:00; cron 0 9 * * 1-5.\n" +
+	"Можно добавить «срок 2д» — дедлайн каждой задачи после её создания, и «до 31.12.2026» или «10 раз» — когда остановиться."
+
+// Kinds of schedule, as they start the canonical form.
+const (
+	scheduleDaily   = "ежедневно"
+	scheduleWeekly  = "еженедельно"
+	scheduleMonthly = "ежемесячно"
+	scheduleCron    = "cron"
+)
+
+var scheduleKinds = map[string]string{
+	scheduleDaily: scheduleDaily, "daily": scheduleDaily,
+	scheduleWeekly: scheduleWeekly, "weekly": scheduleWeekly,
+	scheduleMonthly: scheduleMonthly, "monthly": scheduleMonthly,
+	scheduleCron: scheduleCron,
+}
+
+var (
+	scheduleCountRx = regexp.MustCompile(`(?:^|\s)(\d+)\s*раза?(?:\s|$)`)
+	scheduleUntilRx = regexp.MustCompile(`(?:^|\s)до\s+(\d{1,2}\.\d{1,2}(?:\.\d{4}|\.\d{2})?)(?:\s|$)`)
+	scheduleDueRx   = regexp.MustCompile(`(?:^|\s)срок\s+\+?((?:\d+\s*[a-zа-я]+\s*)+)`)
+	spanRx          = regexp.MustCompile(`(\d+)\s*([a-zа-я]+)`)
+	scheduleClockRx = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+)
+
+// schedule is a parsed recurrence. Its String is what recurring_tasks keeps.
+type schedule struct {
+	kind  string
+	days  [7]bool // scheduleWeekly
+	day   int     // scheduleMonthly; past the end of a month means its last day
+	clock int     // minutes since midnight
+	cron  *cronSpec
+	// dueIn is the deadline of each task after its occurrence, 0 for none.
+	dueIn time.Duration
+	// until is the last date as UTC midnight, count the number of tasks;
+	// zero for no limit.
+	until time.Time
+	count int
+}
+
+// parseSchedule reads a schedule typed by a user; today resolves a "до"
+// date written without the year.
+func parseSchedule(s string, today time.Time) (*schedule, error) {
+	sc := &schedule{clock: 9 * 60}
+	s = strings.ToLower(strings.TrimSpace(s))
+	if mm := scheduleCountRx.FindStringSubmatch(s); mm != nil {
+		sc.count, _ = strconv.Atoi(mm[1])
+		if sc.count == 0 {
+			return nil, errSchedule
+		}
+		s = strings.Replace(s, mm[0], " ", 1)
+	}
+	if mm := scheduleUntilRx.FindStringSubmatch(s); mm != nil {
+		dm := dateRx.FindStringSubmatch(mm[1])
+		day, _ := strconv.Atoi(dm[1])
+		month, _ := strconv.Atoi(dm[2])
+		year := today.Year()
+		if dm[3] != "" {
+			year, _ = strconv.Atoi(dm[3])
+			if year < 100 {
+				year += 2000
+			}
+		}
+		sc.until = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		if sc.until.Day() != day || sc.until.Month() != time.Month(month) {
+			return nil, errSchedule
+		}
+		if dm[3] == "" && sc.until.Before(civilDate(today)) {
+			sc.until = sc.until.AddDate(1, 0, 0)
+		}
+		s = strings.Replace(s, mm[0], " ", 1)
+	}
+	if mm := scheduleDueRx.FindStringSubmatch(s); mm != nil {
+		d, ok := parseSpan(mm[1])
+		if !ok || d < time.Minute {
+			return nil, errSchedule
+		}
+		sc.dueIn = d
+		s = strings.Replace(s, mm[0], " ", 1)
+	}
+
+	f := strings.Fields(commaRx.ReplaceAllString(dashRx.ReplaceAllString(s, "-"), ","))
+	if len(f) == 0 {
+		return nil, errSchedule
+	}
+	kind, ok := scheduleKinds[f[0]]
+	if !ok {
+		return nil, errSchedule
+	}
+	sc.kind, f = kind, f[1:]
+	switch kind {
+	case scheduleCron:
+		if sc.cron, ok = parseCron(f); !ok {
+			return nil, errSchedule
+		}
+		return sc, nil
+	case scheduleWeekly:
+		if len(f) == 0 {
+			return nil, errSchedule
+		}
+		if sc.days, ok = parseDays(f[0]); !ok {
+			return nil, errSchedule
+		}
+		f = f[1:]
+	case scheduleMonthly:
+		if len(f) == 0 {
+			return nil, errSchedule
+		}
+		sc.day, _ = strconv.Atoi(f[0])
+		if sc.day < 1 || sc.day > 31 {
+			return nil, errSchedule
+		}
+		f = f[1:]
+	}
+	switch len(f) {
+	case 0:
+	case 1:
+		mm := scheduleClockRx.FindStringSubmatch(f[0])
+		if mm == nil {
+			return nil, errSchedule
+		}
+		if sc.clock, ok = clockMinutes(mm[1], mm[2]); !ok || sc.clock >= 24*60 {
+			return nil, errSchedule
+		}
+	default:
+		return nil, errSchedule
+	}
+	return sc, nil
+}
+
+// parseSpan reads a duration written as formatSpan writes it, or in the
+// units of "+3д": "2д", "1 д 4 ч", "90 мин".
+func parseSpan(s string) (time.Duration, bool) {
+	var d time.Duration
+	rest := spanRx.ReplaceAllStringFunc(s, func(part string) string {
+		mm := spanRx.FindStringSubmatch(part)
+		n, _ := strconv.Atoi(mm[1])
+		unit, ok := relativeUnits[mm[2]]
+		if !ok {
+			return part
+		}
+		d += time.Duration(n) * unit
+		return ""
+	})
+	return d, strings.TrimSpace(rest) == "" && d > 0
+}
+
+func (s *schedule) String() string {
+	var sb strings.Builder
+	sb.WriteString(s.kind)
+	switch s.kind {
+	case scheduleCron:
+		sb.WriteString(" " + s.cron.expr)
+	case scheduleWeekly:
+		sb.WriteString(" " + formatDays(s.days))
+	case scheduleMonthly:
+		sb.WriteString(" " + strconv.Itoa(s.day))
+	}
+	if s.kind != scheduleCron {
+		fmt.Fprintf(&sb, " %02d:%02d", s.clock/60, s.clock%60)
+	}
+	if s.dueIn > 0 {
+		sb.WriteString(" срок " + formatSpan(s.dueIn))
+	}
+	if !s.until.IsZero() {
+		sb.WriteString(" до " + s.until.Format("02.01.2006"))
+	}
+	if s.count > 0 {
+		fmt.Fprintf(&sb, " %d раз", s.count)
+	}
+	return sb.String()
+}
+
+// civilDate is the calendar date of t as UTC midnight, for comparing dates
+// across zones.
+func civilDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// next is the first occurrence after after, in after's zone.
+func (s *schedule) next(after time.Time) (time.Time, bool) {
+	day := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, after.Location())
+	horizon := 400
+	if s.kind == scheduleCron {
+		// "29 февраля в понедельник" comes round within 28 years
+		horizon = 28 * 366
+	}
+	for i := 0; i < horizon; i++ {
+		d := day.AddDate(0, 0, i)
+		for _, at := range s.times(d) {
+			if at.After(after) {
+				return at, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// times lists the occurrences on the day starting at midnight d, in order.
+func (s *schedule) times(d time.Time) []time.Time {
+	at := func(minutes int) time.Time {
+		return time.Date(d.Year(), d.Month(), d.Day(), minutes/60, minutes%60, 0, 0, d.Location())
+	}
+	switch s.kind {
+	case scheduleWeekly:
+		if !s.days[d.Weekday()] {
+			return nil
+		}
+	case scheduleMonthly:
+		last := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		if d.Day() != min(s.day, last) {
+			return nil
+		}
+	case scheduleCron:
+		if !s.cron.matchDay(d) {
+			return nil
+		}
+		var out []time.Time
+		for h := 0; h < 24; h++ {
+			for m := 0; m < 60; m++ {
+				if s.cron.hour[h] && s.cron.minute[m] {
+					out = append(out, at(h*60+m))
+				}
+			}
+		}
+		return out
+	}
+	return []time.Time{at(s.clock)}
+}
+
+// upcoming is the occurrence after after, or NULL once the schedule has
+// ended with created tasks made.
+func (s *schedule) upcoming(created int, after time.Time) sql.NullTime {
+	if s.count > 0 && created >= s.count {
+		return sql.NullTime{}
+	}
+	next, ok := s.next(after)
+	if !ok || !s.until.IsZero() && civilDate(next).After(s.until) {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: next, Valid: true}
+}
+
+// cronSpec is a five-field cron expression: minute, hour, day of month,
+// month and day of week (0 or 7 is Sunday), with *, lists, ranges and steps.
+type cronSpec struct {
+	expr                          string
+	minute, hour, dom, month, dow []bool
+	anyDom, anyDow                bool
+}
+
+func parseCron(f []string) (*cronSpec, bool) {
+	if len(f) != 5 {
+		return nil, false
+	}
+	// "*/2" counts as unrestricted for the day rule, as in cron
+	c := &cronSpec{expr: strings.Join(f, " "), anyDom: strings.HasPrefix(f[2], "*"), anyDow: strings.HasPrefix(f[4], "*")}
+	var ok [5]bool
+	c.minute, ok[0] = cronField(f[0], 0, 59)
+	c.hour, ok[1] = cronField(f[1], 0, 23)
+	c.dom, ok[2] = cronField(f[2], 1, 31)
+	c.month, ok[3] = cronField(f[3], 1, 12)
+	c.dow, ok[4] = cronField(f[4], 0, 7)
+	if ok != [5]bool{true, true, true, true, true} {
+		return nil, false
+	}
+	c.dow[0] = c.dow[0] || c.dow[7]
+	return c, true
+}
+
+// cronField reads one field into flags indexed by value.
+func cronField(s string, lo, hi int) ([]bool, bool) {
+	set := make([]bool, hi+1)
+	for _, item := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return nil, false
+			}
+		}
+		from, to := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err1, err2 error
+			from, err1 = strconv.Atoi(a)
+			to, err2 = from, nil
+			if isRange {
+				to, err2 = strconv.Atoi(b)
+			} else if hasStep {
+				to = hi
+			}
+			if err1 != nil || err2 != nil || from < lo || to > hi || from > to {
+				return nil, false
+			}
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return set, true
+}
+
+// matchDay applies the day fields as cron does: when both day of month and
+// day of week are restricted, either one matching is enough.
+func (c *cronSpec) matchDay(d time.Time) bool {
+	if !c.month[d.Month()] {
+		return false
+	}
+	dom, dow := c.dom[d.Day()], c.dow[d.Weekday()]
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	}
+	return dom || dow
+}
+
+// askRepeat prompts for the schedule of a /newtask draft.
+func (b *Bot) askRepeat(chatID int64, d *NewTaskDraft) {
+	text := "Как повторять задачу? Время — по вашему часовому поясу (" + zoneLabel(b.zone(chatID)) + ").\n" + recurringHelp
+	if d.Repeat != "" {
+		text = "Повтор: " + d.Repeat + ". Введите новое расписание или «нет», чтобы создать задачу один раз.\n" + recurringHelp
+	}
+	b.prompt(chatID, StateNewTaskRepeat, text)
+}
+
+func (b *Bot) onRepeatInput(m *tgbotapi.Message) {
+	ctx := context.Background()
+	d := &NewTaskDraft{}
+	b.DB.LoadState(ctx, m.From.ID, d)
+	text := strings.TrimSpace(m.Text)
+	switch strings.ToLower(text) {
+	case "нет", "no", "не повторять":
+		d.Repeat = ""
+		b.advance(m.Chat.ID, m.From.ID, d, StateNewTaskConfirm)
+		return
+	}
+	now := time.Now().In(b.zone(m.From.ID))
+	s, err := parseSchedule(text, now)
+	if err != nil {
+		b.reply(m.Chat.ID, "Не понял расписание. "+recurringHelp)
+		return
+	}
+	first := s.upcoming(0, now)
+	if !first.Valid {
+		b.reply(m.Chat.ID, "По такому расписанию не будет ни одной задачи.")
+		return
+	}
+	// the deadline picked earlier becomes the term of every task
+	if due := draftDue(d); s.dueIn == 0 && due.Valid && due.Time.After(first.Time) {
+		s.dueIn = due.Time.Sub(first.Time).Truncate(time.Minute)
+	}
+	d.Repeat = s.String()
+	b.advance(m.Chat.ID, m.From.ID, d, StateNewTaskConfirm)
+}
+
+// repeatSummary is the schedule part of the confirmation card.
+func (b *Bot) repeatSummary(chatID int64, d *NewTaskDraft) string {
+	loc := b.zone(chatID)
+	s, err := parseSchedule(d.Repeat, time.Now().In(loc))
+	if err != nil {
+		return "Повтор: не удалось разобрать расписание\n"
+	}
+	var sb strings.Builder
+	sb.WriteString("Повтор: " + s.String() + "\n")
+	if first := s.upcoming(0, time.Now().In(loc)); first.Valid {
+		sb.WriteString("Первая задача: " + formatDeadline(first.Time, loc) + "\n")
+	}
+	if s.dueIn == 0 {
+		sb.WriteString("Дедлайн: нет\n")
+		return sb.String()
+	}
+	sb.WriteString("Дедлайн: через " + formatSpan(s.dueIn) + " после создания каждой задачи\n")
+	if len(d.RemindHours) > 0 {
+		var hs []string
+		for _, h := range d.RemindHours {
+			hs = append(hs, strconv.Itoa(h))
+		}
+		sb.WriteString("Напоминания: за " + strings.Join(hs, ", ") + " ч до дедлайна и в дедлайн\n")
+	}
+	if len(b.Escalation) > 0 {
+		sb.WriteString("При просрочке: " + escalationSummary(b.Escalation) + "\n")
+	}
+	return sb.String()
+}
+
+func (b *Bot) createRecurringFromDraft(chatID int64, boss *storage.User, d *NewTaskDraft) {
+	ctx := context.Background()
+	loc := b.userZone(boss)
+	now := time.Now().In(loc)
+	s, err := parseSchedule(d.Repeat, now)
+	if err != nil {
+		b.reply(chatID, "Не удалось разобрать расписание: "+d.Repeat)
+		return
+	}
+	payload, err := templatePayload(d)
+	if err != nil {
+		b.reply(chatID, "Ошибка: "+err.Error())
+		return
+	}
+	r := &storage.Recurring{CreatorID: boss.ID, Title: d.Title, Schedule: s.String(), Payload: payload, NextAt: s.upcoming(0, now)}
+	if !r.NextAt.Valid {
+		b.reply(chatID, "По такому расписанию не будет ни одной задачи.")
+		return
+	}
+	id, err := b.DB.CreateRecurring(ctx, r)
+	if err != nil {
+		b.reply(chatID, "Ошибка: "+err.Error())
+		return
+	}
+	b.reply(chatID, fmt.Sprintf("🔁 Повторяющаяся задача [%d] «%s»: %s.\nПервая будет создана %s. Управление: /recurring",
+		id, d.Title, r.Schedule, formatDeadline(r.NextAt.Time, loc)))
+}
+
+// dispatchRecurring makes the tasks of the definitions due by now. An
+// occurrence missed while the bot was down is made once, late; the next
+// one is counted from now.
+func (b *Bot) dispatchRecurring() {
+	ctx := context.Background()
+	now := storage.Now()
+	rs, err := b.DB.ListDueRecurring(ctx, now)
+	if err != nil {
+		log.Println("recurring:", err)
+		return
+	}
+	for _, r := range rs {
+		creator, err := b.DB.GetUserByID(ctx, r.CreatorID)
+		if err != nil {
+			continue
+		}
+		loc := b.userZone(creator)
+		at := r.NextAt.Time
+		s, err := parseSchedule(r.Schedule, now.In(loc))
+		if err != nil {
+			log.Printf("recurring %d: %q: %v", r.ID, r.Schedule, err)
+			r.NextAt = sql.NullTime{}
+			_ = b.DB.SaveRecurring(ctx, r)
+			continue
+		}
+		// moved on before the task is made, so a failing one is not
+		// retried on every tick
+		r.Created++
+		r.NextAt = s.upcoming(r.Created, now.In(loc))
+		if err := b.DB.SaveRecurring(ctx, r); err != nil {
+			log.Printf("recurring %d: %v", r.ID, err)
+			continue
+		}
+
+		d := &NewTaskDraft{}
+		if err := json.Unmarshal(r.Payload, d); err != nil {
+			log.Printf("recurring %d: %v", r.ID, err)
+			continue
+		}
+		if s.dueIn > 0 {
+			d.DueAt = at.Add(s.dueIn).Format(time.RFC3339)
+		}
+		expandDraft(d, at.In(loc))
+		created, tgIDs, err := b.createDraftTask(ctx, creator.ID, d)
+		if err != nil {
+			log.Printf("recurring %d: create task: %v", r.ID, err)
+			continue
+		}
+		text := fmt.Sprintf("🔁 По расписанию создана задача [%d] «%s», исполнителей: %d.", created.Task.ID, d.Title, len(tgIDs))
+		if !r.NextAt.Valid {
+			text += " Расписание завершено."
+		}
+		b.API.Send(tgbotapi.NewMessage(creator.TgID, text))
+	}
+}
+
+func (b *Bot) cmdRecurring(m *tgbotapi.Message) {
+	text, markup := b.recurringList(m.From.ID)
+	msg := tgbotapi.NewMessage(m.Chat.ID, text)
+	if markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	b.API.Send(msg)
+}
+
+// recurringList renders every definition with pause, resume and delete
+// buttons, times in the zone of tgID.
+func (b *Bot) recurringList(tgID int64) (string, *tgbotapi.InlineKeyboardMarkup) {
+	ctx := context.Background()
+	rs, err := b.DB.ListRecurring(ctx)
+	if err != nil {
+		return "Ошибка: " + err.Error(), nil
+	}
+	if len(rs) == 0 {
+		return "Повторяющихся задач нет. Создайте задачу через /newtask и на карточке нажмите «🔁 Повторять».", nil
+	}
+	loc := b.zone(tgID)
+	var sb strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	sb.WriteString("Повторяющиеся задачи:\n")
+	for _, r := range rs {
+		state := "следующая " + r.NextAt.Time.In(loc).Format("02.01.2006 15:04")
+		switch {
+		case !r.NextAt.Valid:
+			state = "расписание завершено"
+		case r.Paused:
+			state = "на паузе"
+		}
+		fmt.Fprintf(&sb, "\n[%d] «%s» — %s\n%s, создано задач: %d\n", r.ID, r.Title, r.Schedule, state, r.Created)
+
+		del := tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑 %d", r.ID), fmt.Sprintf("rec:del:%d", r.ID))
+		switch {
+		case !r.NextAt.Valid:
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(del))
+		case r.Paused:
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("▶ %d", r.ID), fmt.Sprintf("rec:resume:%d", r.ID)), del))
+		default:
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⏸ %d", r.ID), fmt.Sprintf("rec:pause:%d", r.ID)), del))
+		}
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return truncateText(sb.String(), maxPageText), &markup
+}
+
+// onRecurringCallback handles "rec:<pause|resume|del>:<id>" from the list.
+func (b *Bot) onRecurringCallback(cq *tgbotapi.CallbackQuery) {
+	ctx := context.Background()
+	parts := strings.Split(cq.Data, ":")
+	if len(parts) != 3 || cq.Message == nil {
+		return
+	}
+	if !b.isBoss(cq.From.ID) {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Только для боссов"))
+		return
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return
+	}
+	r, err := b.DB.GetRecurring(ctx, id)
+	if err != nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Уже удалена"))
+		return
+	}
+
+	var note string
+	switch parts[1] {
+	case "pause":
+		r.Paused, note = true, "Поставлена на паузу"
+		err = b.DB.SaveRecurring(ctx, r)
+	case "resume":
+		r.Paused, note = false, "Возобновлена"
+		// occurrences missed while paused are skipped
+		if r.NextAt.Valid && r.NextAt.Time.Before(time.Now()) {
+			if creator, err := b.DB.GetUserByID(ctx, r.CreatorID); err == nil {
+				now := time.Now().In(b.userZone(creator))
+				if s, err := parseSchedule(r.Schedule, now); err == nil {
+					r.NextAt = s.upcoming(r.Created, now)
+				}
+			}
+		}
+		err = b.DB.SaveRecurring(ctx, r)
+	case "del":
+		note = "Удалена; созданные задачи остались"
+		err = b.DB.DeleteRecurring(ctx, id)
+	default:
+		return
+	}
+	if err != nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Ошибка: "+err.Error()))
+		return
+	}
+	text, markup := b.recurringList(cq.From.ID)
+	edit := tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, text)
+	edit.ReplyMarkup = markup
+	b.API.Send(edit)
+	b.API.Request(tgbotapi.NewCallback(cq.ID, note))
+}
@@ -0,0 +1,193 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	today := time.Date(2025, 10, 15, 12, 0, 0, 0, msk)
+
+	tests := []struct {
+		in, want string // want is the canonical form
+	}{
+		{"ежедневно 09:00", "ежедневно 09:00"},
+		{"Daily", "ежедневно 09:00"},
+		{"ежедневно 7:05", "ежедневно 07:05"},
+		{"еженедельно пн, чт 10:00", "еженедельно пн,чт 10:00"},
+		{"weekly пн — пт 8:30", "еженедельно пн-пт 08:30"},
+		{"еженедельно сб-пн", "еженедельно пн,сб,вс 09:00"},
+		{"ежемесячно 31 18:00", "ежемесячно 31 18:00"},
+		{"monthly 1", "ежемесячно 1 09:00"},
+		{"cron 0 9 * * 1-5", "cron 0 9 * * 1-5"},
+		{"cron */15 9-18 1,15 * 7", "cron */15 9-18 1,15 * 7"},
+
+		// «срок», «до» and «раз», in any order
+		{"ежедневно 09:00 срок 2д", "ежедневно 09:00 срок 2 д"},
+		{"ежедневно срок +1 д 4 ч", "ежедневно 09:00 срок 1 д 4 ч"},
+		{"ежедневно срок 90 мин", "ежедневно 09:00 срок 1 ч 30 мин"},
+		{"ежедневно до 31.12", "ежедневно 09:00 до 31.12.2025"},
+		{"ежедневно до 15.10", "ежедневно 09:00 до 15.10.2025"},
+		{"ежедневно до 01.03", "ежедневно 09:00 до 01.03.2026"},
+		{"ежедневно до 01.03.27", "ежедневно 09:00 до 01.03.2027"},
+		{"еженедельно пн 10 раз", "еженедельно пн 09:00 10 раз"},
+		{"еженедельно пн 2 раза", "еженедельно пн 09:00 2 раз"},
+		{"12 раз до 31.12.2026 срок 1д ежемесячно 1 10:00", "ежемесячно 1 10:00 срок 1 д до 31.12.2026 12 раз"},
+		{"cron 0 9 * * 1 срок 4ч 5 раз", "cron 0 9 * * 1 срок 4 ч 5 раз"},
+	}
+	for _, tt := range tests {
+		sc, err := parseSchedule(tt.in, today)
+		if err != nil {
+			t.Errorf("parseSchedule(%q): %v", tt.in, err)
+			continue
+		}
+		if got := sc.String(); got != tt.want {
+			t.Errorf("parseSchedule(%q).String() = %q, want %q", tt.in, got, tt.want)
+			continue
+		}
+		// recurring_tasks keeps String, which must read back the same
+		// however far from today it is read
+		again, err := parseSchedule(sc.String(), today.AddDate(2, 0, 0))
+		if err != nil {
+			t.Errorf("parseSchedule(%q) of String: %v", sc.String(), err)
+			continue
+		}
+		if again.String() != sc.String() || again.dueIn != sc.dueIn || !again.until.Equal(sc.until) || again.count != sc.count {
+			t.Errorf("parseSchedule(%q) = %+v, want %+v", sc.String(), again, sc)
+		}
+	}
+
+	for _, in := range []string{
+		"",
+		"ежечасно",
+		"еженедельно",
+		"еженедельно xx 10:00",
+		"ежемесячно",
+		"ежемесячно 0",
+		"ежемесячно 32",
+		"ежедневно 24:00",
+		"ежедневно 9",
+		"ежедневно 09:00 10:00",
+		"ежедневно 0 раз",
+		"ежедневно до 31.02",
+		"ежедневно срок 0д",
+		"ежедневно срок 30 сек",
+		"cron 0 9 * *",
+		"cron 60 * * * *",
+		"cron */0 * * * *",
+		"cron 0 9 5-1 * *",
+		"cron 0 9 * * 8",
+	} {
+		if sc, err := parseSchedule(in, today); err == nil {
+			t.Errorf("parseSchedule(%q) = %q, want an error", in, sc)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	today := time.Date(2025, 10, 15, 12, 0, 0, 0, msk)
+	at := func(y int, m time.Month, d, h, min int) time.Time { return time.Date(y, m, d, h, min, 0, 0, msk) }
+
+	tests := []struct {
+		schedule    string
+		after, want time.Time // want zero for never
+	}{
+		{"ежедневно 09:00", at(2025, 10, 15, 8, 0), at(2025, 10, 15, 9, 0)},
+		{"ежедневно 09:00", at(2025, 10, 15, 9, 0), at(2025, 10, 16, 9, 0)},
+		{"еженедельно пн,чт 10:00", at(2025, 10, 15, 12, 0), at(2025, 10, 16, 10, 0)},
+		{"еженедельно пн,чт 10:00", at(2025, 10, 16, 10, 0), at(2025, 10, 20, 10, 0)},
+
+		// day 31 falls on the last day of shorter months
+		{"ежемесячно 31 18:00", at(2025, 10, 15, 12, 0), at(2025, 10, 31, 18, 0)},
+		{"ежемесячно 31 18:00", at(2025, 10, 31, 18, 0), at(2025, 11, 30, 18, 0)},
+		{"ежемесячно 31 18:00", at(2025, 11, 30, 18, 0), at(2025, 12, 31, 18, 0)},
+		{"ежемесячно 31 18:00", at(2026, 1, 31, 18, 0), at(2026, 2, 28, 18, 0)},
+		{"ежемесячно 30", at(2028, 1, 30, 9, 0), at(2028, 2, 29, 9, 0)},
+		{"ежемесячно 29", at(2028, 2, 29, 9, 0), at(2028, 3, 29, 9, 0)},
+
+		{"cron 0 9 * * 1-5", at(2025, 10, 17, 10, 0), at(2025, 10, 20, 9, 0)},
+		{"cron */30 9-10 * * *", at(2025, 10, 15, 9, 0), at(2025, 10, 15, 9, 30)},
+		{"cron */30 9-10 * * *", at(2025, 10, 15, 10, 30), at(2025, 10, 16, 9, 0)},
+		// the 13th or any Friday
+		{"cron 0 12 13 * 5", at(2025, 10, 15, 13, 0), at(2025, 10, 17, 12, 0)},
+		{"cron 0 12 13 * 5", at(2025, 11, 7, 12, 0), at(2025, 11, 13, 12, 0)},
+		{"cron 0 12 13 * 5", at(2025, 11, 13, 12, 0), at(2025, 11, 14, 12, 0)},
+		{"cron 0 0 29 2 *", at(2025, 10, 15, 12, 0), at(2028, 2, 29, 0, 0)},
+		{"cron 0 0 30 2 *", at(2025, 10, 15, 12, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		sc, err := parseSchedule(tt.schedule, today)
+		if err != nil {
+			t.Fatalf("parseSchedule(%q): %v", tt.schedule, err)
+		}
+		got, ok := sc.next(tt.after)
+		if ok != !tt.want.IsZero() || ok && !got.Equal(tt.want) {
+			t.Errorf("%q: next(%v) = %v, %v, want %v", tt.schedule, tt.after, got, ok, tt.want)
+		}
+	}
+}
+
+func TestScheduleUpcoming(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	at := func(d, h int) time.Time { return time.Date(2025, 10, d, h, 0, 0, 0, msk) }
+	sc, err := parseSchedule("ежедневно 09:00 до 17.10 3 раз", at(15, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		created int
+		after   time.Time
+		want    time.Time // zero once ended
+	}{
+		{0, at(15, 12), at(16, 9)},
+		{1, at(16, 9), at(17, 9)},
+		{2, at(17, 9), time.Time{}},  // past the last date
+		{3, at(15, 12), time.Time{}}, // all made
+	}
+	for _, tt := range tests {
+		got := sc.upcoming(tt.created, tt.after)
+		if got.Valid != !tt.want.IsZero() || got.Valid && !got.Time.Equal(tt.want) {
+			t.Errorf("upcoming(%d, %v) = %v, want %v", tt.created, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestCronMatchDay(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2025, m, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		expr string
+		day  time.Time
+		want bool
+	}{
+		// both day fields restricted: either matches
+		{"0 0 13 * 5", day(10, 13), true},  // Monday the 13th
+		{"0 0 13 * 5", day(10, 17), true},  // Friday
+		{"0 0 13 * 5", day(10, 15), false}, // neither
+		// one restricted: only it counts
+		{"0 0 13 * *", day(10, 17), false},
+		{"0 0 * * 5", day(10, 13), false},
+		{"0 0 * * *", day(10, 15), true},
+		// a stepped "*" still counts as unrestricted
+		{"0 0 */2 * 1", day(10, 15), false}, // odd day, but a Wednesday
+		{"0 0 */2 * 1", day(10, 13), true},
+		{"0 0 1 * */2", day(10, 1), true},
+		// the month applies either way
+		{"0 0 13 1 5", day(10, 13), false},
+		{"0 0 * 10 *", day(10, 15), true},
+		// Sunday is 0 or 7
+		{"0 0 * * 0", day(10, 19), true},
+		{"0 0 * * 7", day(10, 19), true},
+		{"0 0 * * 6-7", day(10, 20), false},
+	}
+	for _, tt := range tests {
+		sc, err := parseSchedule("cron "+tt.expr, tt.day)
+		if err != nil {
+			t.Fatalf("parseSchedule(cron %s): %v", tt.expr, err)
+		}
+		if got := sc.cron.matchDay(tt.day); got != tt.want {
+			t.Errorf("cron %s: matchDay(%s) = %v, want %v", tt.expr, tt.day.Format("Mon 02.01"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// resultFromMessage reads a task result out of m: its text, or its file with
+// the caption and file metadata. It returns nil when m carries neither.
+func resultFromMessage(m *tgbotapi.Message, taskID, userID int64) *storage.TaskResult {
+	r := &storage.TaskResult{TaskID: taskID, UserID: userID}
+	if f := messageMedia(m); f != nil {
+		r.Kind = sql.NullString{String: f.Kind, Valid: true}
+		r.FileID = sql.NullString{String: f.FileID, Valid: true}
+		r.FileName = sql.NullString{String: f.FileName, Valid: f.FileName != ""}
+		r.MimeType = sql.NullString{String: f.MimeType, Valid: f.MimeType != ""}
+		r.FileSize = sql.NullInt64{Int64: int64(f.FileSize), Valid: f.FileSize > 0}
+	}
+	if m.Text != "" {
+		r.Text = sql.NullString{String: m.Text, Valid: true}
+	}
+	if m.Caption != "" {
+		r.Caption = sql.NullString{String: m.Caption, Valid: true}
+	}
+	if !r.Text.Valid && !r.FileID.Valid {
+		return nil
+	}
+	return r
+}
+
+// resultKinds is the order tried for results saved without a kind.
+var resultKinds = []string{storage.MediaDocument, storage.MediaPhoto, storage.MediaVideo, storage.MediaAudio, storage.MediaVoice}
+
+// sendResult re-sends r to chatID: text as a message, a file with the
+// constructor for its kind and its caption.
+func (b *Bot) sendResult(chatID int64, r *storage.TaskResult) error {
+	if !r.FileID.Valid {
+		_, err := b.API.Send(tgbotapi.NewMessage(chatID, r.Text.String))
+		return err
+	}
+	if r.Kind.Valid {
+		_, err := b.API.Send(mediaMessage(chatID, r.Kind.String, r.FileID.String, r.Caption.String))
+		return err
+	}
+	var err error
+	for _, kind := range resultKinds {
+		if _, err = b.API.Send(mediaMessage(chatID, kind, r.FileID.String, r.Caption.String)); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (b *Bot) cmdResults(m *tgbotapi.Message) {
+	ctx := context.Background()
+	taskID, err := strconv.ParseInt(strings.TrimSpace(m.CommandArguments()), 10, 64)
+	if err != nil {
+		b.reply(m.Chat.ID, "Использование: /results <id задачи>")
+		return
+	}
+	t, err := b.DB.GetTask(ctx, taskID)
+	if err != nil {
+		b.reply(m.Chat.ID, "Задача не найдена.")
+		return
+	}
+	rs, err := b.DB.ListResults(ctx, taskID)
+	if err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	if len(rs) == 0 {
+		b.reply(m.Chat.ID, "По задаче «"+nullStr(t.Title)+"» результатов пока нет.")
+		return
+	}
+
+	b.reply(m.Chat.ID, fmt.Sprintf("Результаты по задаче [%d] «%s» (%d):", taskID, nullStr(t.Title), len(rs)))
+	loc := b.zone(m.From.ID)
+	names := map[int64]string{}
+	for _, r := range rs {
+		name, ok := names[r.UserID]
+		if !ok {
+			name = "удалённый сотрудник"
+			if u, err := b.DB.GetUserByID(ctx, r.UserID); err == nil {
+				name = b.userLabel(u)
+			}
+			names[r.UserID] = name
+		}
+		head := name + ", " + r.CreatedAt.In(loc).Format("02.01.2006 15:04")
+		if r.FileName.Valid {
+			head += " — " + r.FileName.String
+		}
+		if r.FileSize.Valid {
+			head += fmt.Sprintf(" (%s)", formatSize(r.FileSize.Int64))
+		}
+		b.reply(m.Chat.ID, head)
+		if err := b.sendResult(m.Chat.ID, r); err != nil {
+			b.reply(m.Chat.ID, "Не удалось отправить файл: "+err.Error())
+		}
+	}
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f МБ", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%d КБ", n>>10)
+	}
+	return fmt.Sprintf("%d Б", n)
+}
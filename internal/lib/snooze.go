@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// Reminder messages carry "snooze:<reminder id>:<1h|3h|tm>" buttons next to
+// the task actions. Snoozing repeats the reminder as a new row, and the
+// history of the task shows who postponed it and how often.
+
+const snoozeTomorrow = "tm"
+
+var snoozeSteps = map[string]time.Duration{"1h": time.Hour, "3h": 3 * time.Hour}
+
+func reminderMarkup(r *storage.Reminder) tgbotapi.InlineKeyboardMarkup {
+	snooze := func(text, step string) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(text, fmt.Sprintf("snooze:%d:%s", r.ID, step))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(snooze("🔕 Отложить на 1 ч", "1h"), snooze("🔕 На 3 ч", "3h"), snooze("🔕 До завтра", snoozeTomorrow)),
+		taskActionsRow(r.TaskID),
+	)
+}
+
+func taskActionsRow(taskID int64) []tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🚀 В работу", fmt.Sprintf("task_action:accept:%d", taskID)),
+		tgbotapi.NewInlineKeyboardButtonData("📎 Отправить результат", fmt.Sprintf("task_action:upload:%d", taskID)),
+	)
+}
+
+// sendReminder sends the text of r to the assignee with its buttons.
+func (b *Bot) sendReminder(chatID int64, text string, r *storage.Reminder) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = reminderMarkup(r)
+	b.API.Send(msg)
+}
+
+// snoozeUntil is when a reminder snoozed with step comes back for u.
+// "До завтра" means the start of u's working day tomorrow.
+func (b *Bot) snoozeUntil(ctx context.Context, u *storage.User, step string, now time.Time) (time.Time, bool) {
+	if d, ok := snoozeSteps[step]; ok {
+		return now.Add(d), true
+	}
+	if step != snoozeTomorrow {
+		return time.Time{}, false
+	}
+	now = now.In(b.userZone(u))
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	w, _ := b.workHours(ctx, u)
+	if w.IsZero() {
+		return tomorrow.Add(9 * time.Hour), true
+	}
+	return w.nextStart(tomorrow), true
+}
+
+func (b *Bot) onSnoozeCallback(cq *tgbotapi.CallbackQuery) {
+	ctx := context.Background()
+	parts := strings.Split(cq.Data, ":")
+	if len(parts) != 3 || cq.Message == nil {
+		return
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return
+	}
+	u, err := b.DB.GetUserByTgID(ctx, cq.From.ID)
+	if err != nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Профиль не найден"))
+		return
+	}
+	at, ok := b.snoozeUntil(ctx, u, parts[2], time.Now())
+	if !ok {
+		return
+	}
+	r, err := b.DB.SnoozeReminder(ctx, id, u.ID, at)
+	if errors.Is(err, storage.ErrNotFound) {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Напоминание не найдено"))
+		return
+	}
+	if err != nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Ошибка: "+err.Error()))
+		return
+	}
+
+	when := at.In(b.userZone(u)).Format("02.01 15:04")
+	edit := tgbotapi.NewEditMessageTextAndMarkup(cq.Message.Chat.ID, cq.Message.MessageID,
+		cq.Message.Text+"\n\n🔕 Отложено до "+when+".",
+		tgbotapi.NewInlineKeyboardMarkup(taskActionsRow(r.TaskID)))
+	b.API.Send(edit)
+	b.API.Request(tgbotapi.NewCallback(cq.ID, "Напомню "+when))
+}
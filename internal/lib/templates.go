@@ -0,0 +1,250 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// Templates are /newtask drafts without a deadline, saved from a task or
+// from the draft in progress. Starting from one fills the draft and asks
+// only for the deadline before the confirmation card. Placeholders in the
+// title and description are kept as typed and filled in when a task is made.
+
+// expandPlaceholders fills {date}, {week}, {month} and {year} in s for t.
+func expandPlaceholders(s string, t time.Time) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+	_, week := t.ISOWeek()
+	return strings.NewReplacer(
+		"{date}", t.Format("02.01.2006"),
+		"{week}", strconv.Itoa(week),
+		"{month}", monthNames[t.Month()-1],
+		"{year}", strconv.Itoa(t.Year()),
+	).Replace(s)
+}
+
+// expandDraft fills the placeholders of the title and description of d.
+func expandDraft(d *NewTaskDraft, t time.Time) {
+	d.Title = expandPlaceholders(d.Title, t)
+	d.Description = expandPlaceholders(d.Description, t)
+}
+
+// templatePayload is d without what belongs to a single task.
+func templatePayload(d *NewTaskDraft) ([]byte, error) {
+	tpl := *d
+	tpl.Repeat, tpl.DueAt, tpl.Editing, tpl.LastGroupID, tpl.TaskID = "", "", false, "", 0
+	return json.Marshal(&tpl)
+}
+
+func (b *Bot) saveTemplate(ctx context.Context, bossTgID int64, name string, d *NewTaskDraft) (int64, error) {
+	boss, err := b.DB.GetUserByTgID(ctx, bossTgID)
+	if err != nil {
+		return 0, err
+	}
+	payload, err := templatePayload(d)
+	if err != nil {
+		return 0, err
+	}
+	return b.DB.CreateTemplate(ctx, &storage.Template{Name: name, CreatorID: boss.ID, Payload: payload})
+}
+
+// draftFromTask rebuilds the draft a task could have been made from.
+// A department counts as picked when all its current workers are on the
+// task. Reminder hours are read back from the reminders before the
+// deadline, so those counted in working hours come out approximate.
+func (b *Bot) draftFromTask(ctx context.Context, t *storage.Task) *NewTaskDraft {
+	d := &NewTaskDraft{Title: nullStr(t.Title), Description: nullStr(t.Description)}
+
+	atts, _ := b.DB.ListAttachments(ctx, t.ID)
+	for _, a := range atts {
+		d.Attachments = append(d.Attachments, draftAttachment{
+			Kind: a.Kind, FileID: a.FileID, FileName: nullStr(a.FileName), Caption: nullStr(a.Caption),
+		})
+	}
+
+	tgIDs, _ := b.DB.ListAssigneeTgIDsByTask(ctx, t.ID)
+	covered := map[int64]bool{}
+	deps, _ := b.DB.ListDepartments(ctx)
+	for _, dep := range deps {
+		workers, _ := b.DB.ListWorkersByDepartment(ctx, dep.ID)
+		whole := len(workers) > 0
+		for _, w := range workers {
+			if !slices.Contains(tgIDs, w.TgID) {
+				whole = false
+				break
+			}
+		}
+		if !whole {
+			continue
+		}
+		d.DeptIDs = append(d.DeptIDs, dep.ID)
+		for _, w := range workers {
+			covered[w.TgID] = true
+		}
+	}
+	for _, tg := range tgIDs {
+		if !covered[tg] {
+			d.AssigneeIDs = append(d.AssigneeIDs, tg)
+		}
+	}
+
+	if t.DueAt.Valid {
+		rs, _ := b.DB.ListTaskReminders(ctx, t.ID)
+		for _, r := range rs {
+			if r.Kind != "before" {
+				continue
+			}
+			// snoozed copies are off the hour
+			left := t.DueAt.Time.Sub(r.At)
+			if h := int(left / time.Hour); left > 0 && left%time.Hour == 0 && !slices.Contains(d.RemindHours, h) {
+				d.RemindHours = append(d.RemindHours, h)
+			}
+		}
+		slices.Sort(d.RemindHours)
+	}
+	return d
+}
+
+// cmdTemplateSave handles "/template_save <id задачи> [имя]" and, while a
+// task is being made, "/template_save [имя]" for the draft.
+func (b *Bot) cmdTemplateSave(m *tgbotapi.Message) {
+	ctx := context.Background()
+	args := strings.TrimSpace(m.CommandArguments())
+	first, rest, _ := strings.Cut(args, " ")
+	rest = strings.TrimSpace(rest)
+
+	var d *NewTaskDraft
+	name := args
+	if taskID, err := strconv.ParseInt(first, 10, 64); err == nil {
+		t, err := b.DB.GetTask(ctx, taskID)
+		if err != nil {
+			b.reply(m.Chat.ID, "Задача не найдена.")
+			return
+		}
+		d = b.draftFromTask(ctx, t)
+		name = rest
+	} else {
+		d = &NewTaskDraft{}
+		if state, _ := b.DB.LoadState(ctx, m.From.ID, d); !isNewTaskState(state) {
+			b.reply(m.Chat.ID, "Использование: /template_save <id задачи> [имя]\n"+
+				"Во время /newtask без id сохраняет текущий черновик. В названии и описании можно писать {date}, {week}, {month}, {year}.")
+			return
+		}
+	}
+	if strings.TrimSpace(d.Title) == "" {
+		b.reply(m.Chat.ID, "У задачи нет названия — сначала введите его.")
+		return
+	}
+	name = ifEmpty(name, d.Title)
+	if _, err := b.saveTemplate(ctx, m.From.ID, name, d); err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	b.reply(m.Chat.ID, "💾 Шаблон «"+name+"» сохранён. Все шаблоны: /templates")
+}
+
+func (b *Bot) cmdTemplates(m *tgbotapi.Message) {
+	tpls, err := b.DB.ListTemplates(context.Background())
+	if err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	if len(tpls) == 0 {
+		b.reply(m.Chat.ID, "Шаблонов нет. Сохраните задачу: /template_save <id задачи> [имя] или «💾 В шаблоны» на карточке /newtask.")
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, t := range tpls {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📄 "+t.Name, fmt.Sprintf("tpl:use:%d", t.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("🗑", fmt.Sprintf("tpl:del:%d", t.ID)),
+		))
+	}
+	msg := tgbotapi.NewMessage(m.Chat.ID, "Шаблоны задач — выберите, чтобы создать задачу:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	b.API.Send(msg)
+}
+
+// onTemplateCallback handles "tpl:<use|del>:<id>". Using a template puts
+// aside a task in progress, like resuming a draft.
+func (b *Bot) onTemplateCallback(cq *tgbotapi.CallbackQuery) {
+	ctx := context.Background()
+	parts := strings.Split(cq.Data, ":")
+	if len(parts) != 3 || cq.Message == nil {
+		return
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return
+	}
+	if !b.isBoss(cq.From.ID) {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Только для боссов."))
+		return
+	}
+	defer b.lockUser(cq.From.ID)()
+
+	t, err := b.DB.GetTemplate(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Шаблон уже удалён"))
+		return
+	}
+	if err != nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Ошибка: "+err.Error()))
+		return
+	}
+	chatID := cq.Message.Chat.ID
+
+	switch parts[1] {
+	case "del":
+		if err := b.DB.DeleteTemplate(ctx, id); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			b.API.Request(tgbotapi.NewCallback(cq.ID, "Ошибка: "+err.Error()))
+			return
+		}
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Шаблон «"+t.Name+"» удалён"))
+		b.reply(chatID, "Шаблон «"+t.Name+"» удалён.")
+	case "use":
+		d := &NewTaskDraft{}
+		if err := json.Unmarshal(t.Payload, d); err != nil {
+			log.Printf("template %d: %v", t.ID, err)
+			b.API.Request(tgbotapi.NewCallback(cq.ID, "Шаблон повреждён"))
+			return
+		}
+		// the rest of the template is ready, so the deadline leads to the card
+		d.Editing = true
+		shelved, _ := b.shelveDraft(ctx, cq.From.ID)
+		if err := b.DB.SaveState(ctx, cq.From.ID, StateNewTaskDeadline, d); err != nil {
+			b.API.Request(tgbotapi.NewCallback(cq.ID, "Ошибка: "+err.Error()))
+			return
+		}
+		text := "Задача по шаблону «" + t.Name + "»."
+		if shelved != "" {
+			text += " Незавершённая задача «" + shelved + "» отложена в /drafts."
+		}
+		b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+		b.reply(chatID, text)
+		b.askNewTaskStep(chatID, StateNewTaskDeadline, d)
+	}
+}
+
+// onConfirmTemplate saves the draft on the confirmation card under its
+// title; the card stays as it is.
+func (b *Bot) onConfirmTemplate(ctx context.Context, cq *tgbotapi.CallbackQuery, d *NewTaskDraft) {
+	if _, err := b.saveTemplate(ctx, cq.From.ID, d.Title, d); err != nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Ошибка: "+err.Error()))
+		return
+	}
+	b.API.Request(tgbotapi.NewCallback(cq.ID, "Шаблон «"+d.Title+"» сохранён"))
+}
@@ -0,0 +1,199 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// Per-user time zones. Times are stored as instants; a user's zone only
+// decides how they are shown to that user and how the times they type are
+// read. Users without a zone get TZ.
+
+// commonZones are offered as buttons by /timezone and after /register.
+var commonZones = []struct{ label, name string }{
+	{"Калининград", "Europe/Kaliningrad"},
+	{"Москва", "Europe/Moscow"},
+	{"Самара", "Europe/Samara"},
+	{"Екатеринбург", "Asia/Yekaterinburg"},
+	{"Омск", "Asia/Omsk"},
+	{"Новосибирск", "Asia/Novosibirsk"},
+	{"Красноярск", "Asia/Krasnoyarsk"},
+	{"Иркутск", "Asia/Irkutsk"},
+	{"Якутск", "Asia/Yakutsk"},
+	{"Владивосток", "Asia/Vladivostok"},
+	{"Магадан", "Asia/Magadan"},
+	{"Камчатка", "Asia/Kamchatka"},
+}
+
+var errZoneName = errors.New("unknown time zone")
+
+// zoneOffsetRx matches fixed offsets: "UTC+3", "GMT-03:30", "+0530".
+var zoneOffsetRx = regexp.MustCompile(`(?i)^(?:utc|gmt)?\s*([+-])(\d{1,2})(?::?(\d{2}))?$`)
+
+// zoneCache keeps loaded locations by stored name, so rendering a list does
+// not read the zoneinfo database for every line.
+var zoneCache sync.Map
+
+// loadZone resolves a zone as stored in users.timezone: an IANA name or a
+// fixed offset in the form produced by parseZone.
+func loadZone(name string) (*time.Location, error) {
+	if v, ok := zoneCache.Load(name); ok {
+		return v.(*time.Location), nil
+	}
+	_, loc, err := parseZone(name)
+	if err != nil {
+		return nil, err
+	}
+	zoneCache.Store(name, loc)
+	return loc, nil
+}
+
+// parseZone reads a zone typed by a user and returns the name to store
+// along with the location.
+func parseZone(s string) (string, *time.Location, error) {
+	s = strings.TrimSpace(s)
+	if mm := zoneOffsetRx.FindStringSubmatch(s); mm != nil {
+		h, _ := strconv.Atoi(mm[2])
+		m, _ := strconv.Atoi(mm[3])
+		if h > 14 || m > 59 {
+			return "", nil, errZoneName
+		}
+		name := "UTC" + mm[1] + strconv.Itoa(h)
+		if m > 0 {
+			name += fmt.Sprintf(":%02d", m)
+		}
+		off := h*3600 + m*60
+		if mm[1] == "-" {
+			off = -off
+		}
+		return name, time.FixedZone(name, off), nil
+	}
+	// "" and "Local" would silently mean the server's zone
+	if s == "" || strings.EqualFold(s, "local") {
+		return "", nil, errZoneName
+	}
+	loc, err := time.LoadLocation(s)
+	if err != nil {
+		return "", nil, errZoneName
+	}
+	return loc.String(), loc, nil
+}
+
+// zone is the time zone tgID reads and types times in.
+func (b *Bot) zone(tgID int64) *time.Location {
+	u, err := b.DB.GetUserByTgID(context.Background(), tgID)
+	if err != nil {
+		return b.TZ
+	}
+	return b.userZone(u)
+}
+
+func (b *Bot) userZone(u *storage.User) *time.Location {
+	if !u.Timezone.Valid {
+		return b.TZ
+	}
+	loc, err := loadZone(u.Timezone.String)
+	if err != nil {
+		return b.TZ
+	}
+	return loc
+}
+
+// zoneLabel names loc with its current UTC offset, e.g. "Asia/Omsk, UTC+6".
+func zoneLabel(loc *time.Location) string {
+	_, off := time.Now().In(loc).Zone()
+	label := "UTC"
+	if off != 0 {
+		label += formatOffset(off)
+	}
+	if name := loc.String(); name != label {
+		label = name + ", " + label
+	}
+	return label
+}
+
+func formatOffset(off int) string {
+	sign := "+"
+	if off < 0 {
+		sign, off = "-", -off
+	}
+	s := sign + strconv.Itoa(off/3600)
+	if m := off % 3600 / 60; m > 0 {
+		s += fmt.Sprintf(":%02d", m)
+	}
+	return s
+}
+
+// askTimezone offers the common zones as buttons under text.
+func (b *Bot) askTimezone(chatID int64, text string) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, z := range commonZones {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(z.label, "tz:"+z.name))
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	b.API.Send(msg)
+}
+
+func (b *Bot) cmdTimezone(m *tgbotapi.Message) {
+	ctx := context.Background()
+	arg := strings.TrimSpace(m.CommandArguments())
+	if arg == "" {
+		b.askTimezone(m.Chat.ID, "Ваш часовой пояс: "+zoneLabel(b.zone(m.From.ID))+
+			". В нём показываются дедлайны и читается введённое время.\n"+
+			"Выберите город или укажите пояс: /timezone Europe/Moscow, /timezone UTC+5. Сбросить: /timezone reset")
+		return
+	}
+	if strings.EqualFold(arg, "reset") {
+		if err := b.DB.SetUserTimezone(ctx, m.From.ID, ""); err != nil {
+			b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+			return
+		}
+		b.reply(m.Chat.ID, "Часовой пояс сброшен: "+zoneLabel(b.TZ)+".")
+		return
+	}
+	name, loc, err := parseZone(arg)
+	if err != nil {
+		b.reply(m.Chat.ID, "Не знаю такой часовой пояс. Примеры: Europe/Moscow, Asia/Novosibirsk, UTC+3.")
+		return
+	}
+	if err := b.DB.SetUserTimezone(ctx, m.From.ID, name); err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	b.reply(m.Chat.ID, "Часовой пояс: "+zoneLabel(loc)+". Сейчас у вас "+time.Now().In(loc).Format("15:04")+".")
+}
+
+// onTimezoneCallback handles "tz:<name>" from askTimezone.
+func (b *Bot) onTimezoneCallback(cq *tgbotapi.CallbackQuery) {
+	name, loc, err := parseZone(strings.TrimPrefix(cq.Data, "tz:"))
+	if err != nil || cq.Message == nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Неизвестный часовой пояс"))
+		return
+	}
+	if err := b.DB.SetUserTimezone(context.Background(), cq.From.ID, name); err != nil {
+		b.API.Request(tgbotapi.NewCallback(cq.ID, "Ошибка: "+err.Error()))
+		return
+	}
+	b.API.Send(tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID,
+		"Часовой пояс: "+zoneLabel(loc)+". Сейчас у вас "+time.Now().In(loc).Format("15:04")+".\nИзменить: /timezone"))
+	b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+}
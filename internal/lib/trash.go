@@ -0,0 +1,102 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (b *Bot) cmdTrash(m *tgbotapi.Message) {
+	ctx := context.Background()
+	ts, err := b.DB.ListDeletedTasks(ctx)
+	if err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	if len(ts) == 0 {
+		b.reply(m.Chat.ID, "Корзина пуста.")
+		return
+	}
+
+	loc := b.zone(m.From.ID)
+	var sb strings.Builder
+	sb.WriteString("Корзина:\n")
+	for _, t := range ts {
+		who := ""
+		if t.DeletedBy.Valid {
+			if u, err := b.DB.GetUserByID(ctx, t.DeletedBy.Int64); err == nil {
+				who = ", " + b.userLabel(u)
+			}
+		}
+		sb.WriteString(fmt.Sprintf("- [%d] «%s» (удалена %s%s)\n",
+			t.ID, nullStr(t.Title), t.DeletedAt.Time.In(loc).Format("02.01 15:04"), who))
+	}
+	sb.WriteString("\nВосстановить: /task_restore <id>")
+	if b.TrashRetention > 0 {
+		sb.WriteString(fmt.Sprintf("\nЗадачи удаляются навсегда через %d дн.", int(b.TrashRetention.Hours()/24)))
+	}
+	b.reply(m.Chat.ID, sb.String())
+}
+
+func (b *Bot) cmdTaskRestore(m *tgbotapi.Message) {
+	ctx := context.Background()
+	taskID, err := strconv.ParseInt(strings.TrimSpace(m.CommandArguments()), 10, 64)
+	if err != nil {
+		b.reply(m.Chat.ID, "Использование: /task_restore <id>\nСписок: /trash")
+		return
+	}
+
+	boss, _ := b.DB.GetUserByTgID(ctx, m.From.ID)
+	if err := b.DB.RestoreTask(ctx, taskID, boss.ID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			b.reply(m.Chat.ID, "В корзине нет такой задачи.")
+			return
+		}
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+
+	t, err := b.DB.GetTask(ctx, taskID)
+	if err != nil {
+		b.reply(m.Chat.ID, "Восстановлено.")
+		return
+	}
+	tgIDs, _ := b.DB.ListAssigneeTgIDsByTask(ctx, taskID)
+	for _, tg := range tgIDs {
+		b.API.Send(tgbotapi.NewMessage(tg, "♻️ Задача «"+nullStr(t.Title)+"» восстановлена боссом и снова активна."))
+	}
+	b.reply(m.Chat.ID, "Восстановлено: «"+nullStr(t.Title)+"».")
+}
+
+// startTrashPurge permanently removes expired trash once a day at hour.
+func (b *Bot) startTrashPurge(hour int) {
+	if b.TrashRetention <= 0 {
+		return
+	}
+	go func() {
+		for {
+			now := time.Now().In(b.TZ)
+			next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, b.TZ)
+			if !now.Before(next) {
+				next = next.Add(24 * time.Hour)
+			}
+			time.Sleep(next.Sub(now))
+			n, err := b.DB.PurgeDeletedTasks(context.Background(), time.Now().Add(-b.TrashRetention))
+			if err != nil {
+				log.Println("purge trash:", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("purged %d task(s) from trash", n)
+			}
+		}
+	}()
+}
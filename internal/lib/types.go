@@ -12,15 +12,35 @@ const (
     StateNewTaskTitle   = "newtask_title"   
     StateNewTaskBody    = "newtask_body"    
     StateErrorReport    = "error_report"    
+    StateNewTaskConfirm = "newtask_confirm"
+    StateNewTaskRepeat  = "newtask_repeat"
+    StateImportConfirm  = "import_confirm"
 )
 
 type NewTaskDraft struct {
     Title       string   `json:"title"`
     Description string   `json:"description"`
-    VoiceFileID string   `json:"voice_file_id"`
+    Attachments []draftAttachment `json:"attachments"`
+    // LastGroupID is the media group of the last received attachment, so an
+    // album is acknowledged once rather than per file.
+    LastGroupID string   `json:"last_group_id,omitempty"`
     AssigneeIDs []int64  `json:"assignee_ids"`
     DeptIDs []int64      `json:"dept_ids"`
     DueAt       string   `json:"due_at"`
     RemindHours []int    `json:"remind_hours"`
+    // BusinessHours counts RemindHours in each assignee's working hours.
+    BusinessHours bool   `json:"business_hours,omitempty"`
+    // Repeat is the schedule of a recurring task, empty for a one-off.
+    Repeat      string   `json:"repeat,omitempty"`
     TaskID      int64    `json:"task_id"`
+    // Editing is set while a step is reopened from the confirmation card;
+    // finishing it returns to the card.
+    Editing     bool     `json:"editing,omitempty"`
+}
+
+type draftAttachment struct {
+    Kind     string `json:"kind"`
+    FileID   string `json:"file_id"`
+    FileName string `json:"file_name,omitempty"`
+    Caption  string `json:"caption,omitempty"`
 }
@@ -0,0 +1,361 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// WorkHours is a daily working window on some weekdays, read in the zone of
+// whoever it applies to. Time outside it is quiet: reminders wait for the
+// next window unless their kind is urgent. The zero value has no window and
+// counts every moment as working time.
+type WorkHours struct {
+	// Start and End are minutes since midnight, Start < End.
+	Start, End int
+	// Days is indexed by time.Weekday.
+	Days [7]bool
+}
+
+var errWorkHours = errors.New("bad working hours")
+
+// workHoursOff disables quiet time, for a user, a department or the default.
+const workHoursOff = "off"
+
+const workHoursHelp = "Формат: 09:00-18:00 пн-пт. Дни можно перечислить (пн,ср,пт) или не указывать — тогда пн-пт; «off» — без ограничений."
+
+var (
+	workRangeRx = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?-(\d{1,2})(?::(\d{2}))?$`)
+	dashRx      = regexp.MustCompile(`\s*[-–—]\s*`)
+	commaRx     = regexp.MustCompile(`\s*,\s*`)
+)
+
+// ParseWorkHours reads "09:00-18:00 пн-пт", "9-18", "10:00-19:00 пн,вт,чт"
+// or "off". The days default to Monday to Friday.
+func ParseWorkHours(s string) (WorkHours, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == workHoursOff {
+		return WorkHours{}, nil
+	}
+	s = commaRx.ReplaceAllString(dashRx.ReplaceAllString(s, "-"), ",")
+
+	var w WorkHours
+	var days []string
+	found := false
+	for _, f := range strings.Fields(s) {
+		mm := workRangeRx.FindStringSubmatch(f)
+		if mm == nil || found {
+			days = append(days, f)
+			continue
+		}
+		start, ok1 := clockMinutes(mm[1], mm[2])
+		end, ok2 := clockMinutes(mm[3], mm[4])
+		if !ok1 || !ok2 || start >= end {
+			return WorkHours{}, errWorkHours
+		}
+		w.Start, w.End, found = start, end, true
+	}
+	if !found {
+		return WorkHours{}, errWorkHours
+	}
+	if len(days) == 0 {
+		days = []string{"пн-пт"}
+	}
+	var ok bool
+	if w.Days, ok = parseDays(strings.Join(days, ",")); !ok {
+		return WorkHours{}, errWorkHours
+	}
+	return w, nil
+}
+
+// parseDays reads a set of weekdays: "пн-пт", "пн,ср,пт", "ежедневно".
+// Dashes and commas must already be normalised, as ParseWorkHours does.
+func parseDays(s string) ([7]bool, bool) {
+	var days [7]bool
+	for _, item := range strings.Split(s, ",") {
+		if item == "ежедневно" || item == "daily" {
+			item = "пн-вс"
+		}
+		from, to, isRange := strings.Cut(item, "-")
+		a, ok := weekdays[from]
+		if !ok {
+			return days, false
+		}
+		z := a
+		if isRange {
+			if z, ok = weekdays[to]; !ok {
+				return days, false
+			}
+		}
+		for d := a; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == z {
+				break
+			}
+		}
+	}
+	return days, true
+}
+
+// formatDays is the form parseDays reads back, in runs from Monday, so
+// "пн-пт" rather than "вс...".
+func formatDays(days [7]bool) string {
+	var parts []string
+	for i := 0; i < 7; {
+		d := time.Weekday((i + 1) % 7)
+		if !days[d] {
+			i++
+			continue
+		}
+		j := i
+		for j+1 < 7 && days[time.Weekday((j+2)%7)] {
+			j++
+		}
+		first, last := shortWeekdays[d], shortWeekdays[(j+1)%7]
+		switch j - i {
+		case 0:
+			parts = append(parts, first)
+		case 1:
+			parts = append(parts, first, last)
+		default:
+			parts = append(parts, first+"-"+last)
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+func clockMinutes(h, m string) (int, bool) {
+	hh, _ := strconv.Atoi(h)
+	mm := 0
+	if m != "" {
+		mm, _ = strconv.Atoi(m)
+	}
+	if hh > 24 || mm > 59 || hh == 24 && mm > 0 {
+		return 0, false
+	}
+	return hh*60 + mm, true
+}
+
+func (w WorkHours) IsZero() bool { return w.Days == [7]bool{} }
+
+// String gives the canonical form ParseWorkHours reads back.
+func (w WorkHours) String() string {
+	if w.IsZero() {
+		return workHoursOff
+	}
+	return fmt.Sprintf("%02d:%02d-%02d:%02d %s", w.Start/60, w.Start%60, w.End/60, w.End%60, formatDays(w.Days))
+}
+
+// label is String for people.
+func (w WorkHours) label() string {
+	if w.IsZero() {
+		return "без ограничений"
+	}
+	return w.String()
+}
+
+// window is the working window on the calendar day of day, in its zone.
+func (w WorkHours) window(day time.Time) (from, to time.Time, ok bool) {
+	if !w.Days[day.Weekday()] {
+		return time.Time{}, time.Time{}, false
+	}
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	return midnight.Add(time.Duration(w.Start) * time.Minute), midnight.Add(time.Duration(w.End) * time.Minute), true
+}
+
+// nextStart is t when it falls in working time, otherwise the start of the
+// next window. t must be in the zone the hours are read in.
+func (w WorkHours) nextStart(t time.Time) time.Time {
+	if w.IsZero() {
+		return t
+	}
+	for i := 0; i < 8; i++ {
+		from, to, ok := w.window(t.AddDate(0, 0, i))
+		if !ok || !to.After(t) {
+			continue
+		}
+		if from.After(t) {
+			return from
+		}
+		return t
+	}
+	return t
+}
+
+// before goes back d of working time from t, e.g. "24 working hours before
+// the deadline". Without a window it is plain t.Add(-d).
+func (w WorkHours) before(t time.Time, d time.Duration) time.Time {
+	if w.IsZero() {
+		return t.Add(-d)
+	}
+	// at most a year back: d is a reminder offset, not a career
+	for i := 0; i < 366; i++ {
+		from, to, ok := w.window(t.AddDate(0, 0, -i))
+		if !ok || !from.Before(t) {
+			continue
+		}
+		if to.After(t) {
+			to = t
+		}
+		avail := to.Sub(from)
+		if d <= avail {
+			return to.Add(-d)
+		}
+		d -= avail
+	}
+	return t.Add(-d)
+}
+
+// workHours resolves the hours that apply to u: their own, else their
+// department's, else WorkHours. The second result names the source.
+func (b *Bot) workHours(ctx context.Context, u *storage.User) (WorkHours, string) {
+	if u.WorkHours.Valid {
+		if w, err := ParseWorkHours(u.WorkHours.String); err == nil {
+			return w, "личные"
+		}
+	}
+	if u.DepartmentID.Valid {
+		if dep, err := b.DB.GetDepartmentByID(ctx, u.DepartmentID.Int64); err == nil && dep.WorkHours.Valid {
+			if w, err := ParseWorkHours(dep.WorkHours.String); err == nil {
+				return w, "отдела «" + dep.Name + "»"
+			}
+		}
+	}
+	return b.WorkHours, "по умолчанию"
+}
+
+// deferReminder postpones r, due now, to the recipient's next working
+// window. It reports whether r was dealt with: moved, or dropped because
+// waiting would carry an advance reminder past the deadline, where the
+// deadline reminder and escalation take over. A deadline reminder itself is
+// always moved, so a deadline set after hours is still announced.
+func (b *Bot) deferReminder(ctx context.Context, r *storage.Reminder, t *storage.Task, now time.Time) bool {
+	if !r.UserID.Valid || slices.Contains(b.UrgentReminders, r.Kind) {
+		return false
+	}
+	u, err := b.DB.GetUserByID(ctx, r.UserID.Int64)
+	if err != nil {
+		return false
+	}
+	w, _ := b.workHours(ctx, u)
+	next := w.nextStart(now.In(b.userZone(u)))
+	if !next.After(now) {
+		return false
+	}
+	if r.Kind != "deadline" && t.DueAt.Valid && next.After(t.DueAt.Time) {
+		_ = b.DB.MarkReminderSent(ctx, r.ID)
+		return true
+	}
+	_ = b.DB.DeferReminder(ctx, r.ID, next)
+	return true
+}
+
+func (b *Bot) cmdWorkHours(m *tgbotapi.Message) {
+	ctx := context.Background()
+	arg := strings.TrimSpace(m.CommandArguments())
+	u, err := b.DB.GetUserByTgID(ctx, m.From.ID)
+	if err != nil {
+		b.reply(m.Chat.ID, "Сначала зарегистрируйтесь: /register")
+		return
+	}
+	switch {
+	case arg == "":
+		w, src := b.workHours(ctx, u)
+		b.reply(m.Chat.ID, "Рабочее время ("+src+"): "+w.label()+", пояс "+zoneLabel(b.userZone(u))+".\n"+
+			"Вне его напоминания приходят в начале следующего рабочего окна.\n"+
+			"Изменить: /workhours 10:00-19:00 пн-пт. Вернуть время отдела: /workhours reset\n"+workHoursHelp)
+	case strings.EqualFold(arg, "reset"):
+		if err := b.DB.SetUserWorkHours(ctx, m.From.ID, ""); err != nil {
+			b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+			return
+		}
+		u.WorkHours.Valid = false
+		w, src := b.workHours(ctx, u)
+		b.reply(m.Chat.ID, "Рабочее время ("+src+"): "+w.label()+".")
+	default:
+		w, err := ParseWorkHours(arg)
+		if err != nil {
+			b.reply(m.Chat.ID, "Не понял рабочее время. "+workHoursHelp)
+			return
+		}
+		if err := b.DB.SetUserWorkHours(ctx, m.From.ID, w.String()); err != nil {
+			b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+			return
+		}
+		b.reply(m.Chat.ID, "Рабочее время: "+w.label()+".")
+	}
+}
+
+func (b *Bot) cmdDeptHours(m *tgbotapi.Message) {
+	ctx := context.Background()
+	args := strings.Fields(m.CommandArguments())
+	if len(args) == 0 {
+		b.reply(m.Chat.ID, "Рабочее время отдела:\n/dept_hours <id> <время> или /dept_hours <id> reset\n"+workHoursHelp+
+			"\nПо умолчанию: "+b.WorkHours.label()+". Список id: /dept_list")
+		return
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.reply(m.Chat.ID, "id должен быть числом")
+		return
+	}
+	dep, err := b.DB.GetDepartmentByID(ctx, id)
+	if err != nil {
+		b.reply(m.Chat.ID, "Отдел не найден. Список: /dept_list")
+		return
+	}
+	spec := strings.Join(args[1:], " ")
+	switch {
+	case spec == "":
+		w := b.WorkHours
+		if dep.WorkHours.Valid {
+			w, _ = ParseWorkHours(dep.WorkHours.String)
+		}
+		b.reply(m.Chat.ID, fmt.Sprintf("Рабочее время отдела «%s»: %s.", dep.Name, w.label()))
+		return
+	case strings.EqualFold(spec, "reset"):
+		spec = ""
+	default:
+		w, err := ParseWorkHours(spec)
+		if err != nil {
+			b.reply(m.Chat.ID, "Не понял рабочее время. "+workHoursHelp)
+			return
+		}
+		spec = w.String()
+	}
+	if err := b.DB.SetDepartmentWorkHours(ctx, id, spec); err != nil {
+		b.reply(m.Chat.ID, "Ошибка: "+err.Error())
+		return
+	}
+	if spec == "" {
+		b.reply(m.Chat.ID, fmt.Sprintf("Отдел «%s»: рабочее время по умолчанию (%s).", dep.Name, b.WorkHours.label()))
+		return
+	}
+	w, _ := ParseWorkHours(spec)
+	b.reply(m.Chat.ID, fmt.Sprintf("Отдел «%s»: рабочее время %s. Личное время сотрудников (/workhours) важнее.", dep.Name, w.label()))
+}
+
+// onRemBusinessToggle switches the draft between counting reminder hours
+// on the clock and in each assignee's working hours.
+func (b *Bot) onRemBusinessToggle(cq *tgbotapi.CallbackQuery) {
+	ctx := context.Background()
+	defer b.lockUser(cq.From.ID)()
+	d, ok := b.stepDraft(ctx, cq, StateNewTaskReminders)
+	if !ok {
+		return
+	}
+	d.BusinessHours = !d.BusinessHours
+	b.DB.SaveState(ctx, cq.From.ID, StateNewTaskReminders, d)
+	b.API.Send(tgbotapi.NewEditMessageReplyMarkup(cq.Message.Chat.ID, cq.Message.MessageID, remindersMarkup(d)))
+	b.API.Request(tgbotapi.NewCallback(cq.ID, ""))
+}
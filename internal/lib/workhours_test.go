@@ -0,0 +1,218 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+	"github.com/hihikaAAa/task-manager/internal/storage/memory"
+)
+
+var weekdaysOnly = [7]bool{time.Monday: true, time.Tuesday: true, time.Wednesday: true, time.Thursday: true, time.Friday: true}
+
+func TestParseWorkHours(t *testing.T) {
+	tests := []struct {
+		in   string
+		want WorkHours
+		str  string // String(), which must parse back to want
+	}{
+		{"09:00-18:00 пн-пт", WorkHours{540, 1080, weekdaysOnly}, "09:00-18:00 пн-пт"},
+		{"9-18", WorkHours{540, 1080, weekdaysOnly}, "09:00-18:00 пн-пт"},
+		{"пн-пт 9:30 — 18", WorkHours{570, 1080, weekdaysOnly}, "09:30-18:00 пн-пт"},
+		{"10:00 - 19:00 пн, ср, пт", WorkHours{600, 1140, [7]bool{time.Monday: true, time.Wednesday: true, time.Friday: true}}, "10:00-19:00 пн,ср,пт"},
+		{"00:00-24:00 ежедневно", WorkHours{0, 1440, [7]bool{true, true, true, true, true, true, true}}, "00:00-24:00 пн-вс"},
+		{"20:00-24:00 сб-вт", WorkHours{1200, 1440, [7]bool{time.Saturday: true, time.Sunday: true, time.Monday: true, time.Tuesday: true}}, "20:00-24:00 пн,вт,сб,вс"},
+		{"OFF", WorkHours{}, "off"},
+	}
+	for _, tt := range tests {
+		w, err := ParseWorkHours(tt.in)
+		if err != nil || w != tt.want {
+			t.Errorf("ParseWorkHours(%q) = %+v, %v, want %+v", tt.in, w, err, tt.want)
+			continue
+		}
+		if s := w.String(); s != tt.str {
+			t.Errorf("ParseWorkHours(%q).String() = %q, want %q", tt.in, s, tt.str)
+		}
+		if again, err := ParseWorkHours(w.String()); err != nil || again != w {
+			t.Errorf("ParseWorkHours(%q) = %+v, %v, want %+v", w.String(), again, err, w)
+		}
+	}
+
+	for _, in := range []string{
+		"",
+		"22:00-06:00", // overnight windows are not supported
+		"09:00-09:00",
+		"09:00-24:30",
+		"25-26",
+		"9:60-18",
+		"9-18 пн-xx",
+		"9-18 10-19",
+		"пн-пт",
+	} {
+		if w, err := ParseWorkHours(in); err == nil {
+			t.Errorf("ParseWorkHours(%q) = %+v, want an error", in, w)
+		}
+	}
+}
+
+func TestWorkHoursNextStart(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	// 15.10.2025 is a Wednesday
+	at := func(d, h, m int) time.Time { return time.Date(2025, 10, d, h, m, 0, 0, msk) }
+	office := WorkHours{540, 1080, weekdaysOnly}
+	// an evening shift that ends at midnight
+	evening := WorkHours{1200, 1440, [7]bool{true, true, true, true, true, true, true}}
+
+	tests := []struct {
+		name string
+		w    WorkHours
+		t    time.Time
+		want time.Time
+	}{
+		{"inside", office, at(15, 12, 0), at(15, 12, 0)},
+		{"at the start", office, at(15, 9, 0), at(15, 9, 0)},
+		{"early morning", office, at(15, 8, 0), at(15, 9, 0)},
+		{"at the end", office, at(15, 18, 0), at(16, 9, 0)},
+		{"overnight", office, at(15, 23, 30), at(16, 9, 0)},
+		{"friday evening", office, at(17, 19, 0), at(20, 9, 0)},
+		{"saturday", office, at(18, 10, 0), at(20, 9, 0)},
+		{"sunday night", office, at(19, 23, 59), at(20, 9, 0)},
+		{"before 24:00", evening, at(15, 23, 59), at(15, 23, 59)},
+		{"after midnight", evening, at(16, 0, 0), at(16, 20, 0)},
+		{"afternoon", evening, at(15, 19, 0), at(15, 20, 0)},
+		{"no window", WorkHours{}, at(18, 3, 0), at(18, 3, 0)},
+	}
+	for _, tt := range tests {
+		if got := tt.w.nextStart(tt.t); !got.Equal(tt.want) {
+			t.Errorf("%s: nextStart(%v) = %v, want %v", tt.name, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestWorkHoursBefore(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	at := func(d, h, m int) time.Time { return time.Date(2025, 10, d, h, m, 0, 0, msk) }
+	office := WorkHours{540, 1080, weekdaysOnly}
+	evening := WorkHours{1200, 1440, [7]bool{true, true, true, true, true, true, true}}
+
+	tests := []struct {
+		name string
+		w    WorkHours
+		t    time.Time
+		d    time.Duration
+		want time.Time
+	}{
+		{"same day", office, at(15, 12, 0), 2 * time.Hour, at(15, 10, 0)},
+		{"into yesterday", office, at(15, 12, 0), 4 * time.Hour, at(14, 17, 0)},
+		{"over the weekend", office, at(20, 10, 0), 2 * time.Hour, at(17, 17, 0)},
+		{"from the evening", office, at(15, 20, 0), time.Hour, at(15, 17, 0)},
+		{"from the morning", office, at(15, 8, 0), time.Hour, at(14, 17, 0)},
+		{"24 working hours", office, at(15, 18, 0), 24 * time.Hour, at(13, 12, 0)},
+		{"across midnight", evening, at(16, 1, 0), time.Hour, at(15, 23, 0)},
+		{"no window", WorkHours{}, at(18, 10, 0), 24 * time.Hour, at(17, 10, 0)},
+	}
+	for _, tt := range tests {
+		if got := tt.w.before(tt.t, tt.d); !got.Equal(tt.want) {
+			t.Errorf("%s: before(%v, %v) = %v, want %v", tt.name, tt.t, tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestDeferReminder(t *testing.T) {
+	ctx := context.Background()
+	msk := time.FixedZone("MSK", 3*60*60)
+	at := func(d, h, m int) time.Time { return time.Date(2025, 10, d, h, m, 0, 0, msk) }
+	db := memory.New()
+	b := &Bot{DB: db, TZ: msk, WorkHours: WorkHours{540, 1080, weekdaysOnly}, UrgentReminders: []string{"urgent"}}
+
+	boss, err := db.UpsertUser(ctx, 1, nil, "boss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker := func(tgID int64, deptID int64) *storage.User {
+		t.Helper()
+		if _, err := db.UpsertUser(ctx, tgID, nil, "worker"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SetWorkerProfile(ctx, tgID, "Анна", deptID); err != nil {
+			t.Fatal(err)
+		}
+		u, err := db.GetUserByTgID(ctx, tgID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	office, err := db.CreateDepartment(ctx, "Офис", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shop, err := db.CreateDepartment(ctx, "Магазин", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetDepartmentWorkHours(ctx, shop, "10:00-20:00 ежедневно"); err != nil {
+		t.Fatal(err)
+	}
+	anna, seller, owl := worker(11, office), worker(12, shop), worker(13, office)
+	if err := db.SetUserWorkHours(ctx, 13, workHoursOff); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		u     *storage.User
+		kind  string
+		due   time.Time
+		now   time.Time
+		dealt bool
+		want  time.Time // when the reminder is due afterwards; zero once dropped
+	}{
+		{"working time", anna, "before", at(17, 18, 0), at(15, 12, 0), false, at(15, 12, 0)},
+		{"evening", anna, "before", at(17, 18, 0), at(15, 20, 0), true, at(16, 9, 0)},
+		{"weekend", anna, "before", at(22, 18, 0), at(18, 11, 0), true, at(20, 9, 0)},
+		{"past the deadline", anna, "before", at(18, 12, 0), at(17, 19, 0), true, time.Time{}},
+		{"deadline after hours", anna, "deadline", at(17, 20, 0), at(17, 20, 0), true, at(20, 9, 0)},
+		{"urgent", anna, "urgent", at(17, 20, 0), at(17, 19, 0), false, at(17, 19, 0)},
+		{"department hours", seller, "before", at(22, 18, 0), at(18, 9, 30), true, at(18, 10, 0)},
+		{"hours off", owl, "before", at(22, 18, 0), at(18, 3, 0), false, at(18, 3, 0)},
+	}
+	for _, tt := range tests {
+		ct, err := db.CreateTask(ctx, storage.NewTask{
+			Task:        &storage.Task{CreatorID: boss.ID, Title: sql.NullString{String: tt.name, Valid: true}, DueAt: sql.NullTime{Time: tt.due, Valid: true}},
+			AssigneeIDs: []int64{tt.u.ID},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.CreateReminders(ctx, ct.Task.ID, []int64{tt.u.ID}, []time.Time{tt.now}, tt.kind); err != nil {
+			t.Fatal(err)
+		}
+		rs, err := db.ListTaskReminders(ctx, ct.Task.ID)
+		if err != nil || len(rs) != 1 {
+			t.Fatalf("%s: reminders = %v, %v", tt.name, rs, err)
+		}
+		if dealt := b.deferReminder(ctx, rs[0], ct.Task, tt.now); dealt != tt.dealt {
+			t.Errorf("%s: deferReminder = %v, want %v", tt.name, dealt, tt.dealt)
+		}
+
+		due, _ := db.ListDueReminders(ctx, at(31, 0, 0))
+		i := slices.IndexFunc(due, func(r *storage.Reminder) bool { return r.TaskID == ct.Task.ID })
+		switch {
+		case tt.want.IsZero() && i >= 0:
+			t.Errorf("%s: reminder kept for %v, want it dropped", tt.name, due[i].At)
+		case !tt.want.IsZero() && i < 0:
+			t.Errorf("%s: reminder dropped, want it at %v", tt.name, tt.want)
+		case !tt.want.IsZero() && !due[i].At.Equal(tt.want):
+			t.Errorf("%s: reminder at %v, want %v", tt.name, due[i].At, tt.want)
+		}
+	}
+
+	// a reminder for all assignees has nobody's hours to wait for
+	if b.deferReminder(ctx, &storage.Reminder{Kind: "before"}, &storage.Task{}, at(18, 3, 0)) {
+		t.Error("deferReminder moved a reminder without a recipient")
+	}
+}
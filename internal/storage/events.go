@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ResultSummary is the new_value recorded for an EventResult: the start of
+// the text or caption, or a marker for a bare file.
+func ResultSummary(r *TaskResult) sql.NullString {
+	text := r.Text.String
+	if text == "" {
+		text = r.Caption.String
+	}
+	if text != "" {
+		rs := []rune(text)
+		if len(rs) > 80 {
+			return sql.NullString{String: string(rs[:80]) + "…", Valid: true}
+		}
+		return sql.NullString{String: text, Valid: true}
+	}
+	if r.FileName.Valid {
+		return r.FileName
+	}
+	if r.FileID.Valid {
+		return sql.NullString{String: "файл", Valid: true}
+	}
+	return sql.NullString{}
+}
+
+// FormatDue is how deadlines are stored in EventDeadline values.
+func FormatDue(t sql.NullTime) sql.NullString {
+	if !t.Valid {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.Time.Format(time.RFC3339), Valid: true}
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (s *Store) CreateDepartment(ctx context.Context, name string, createdBy *int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.departments {
+		if d.Name == name {
+			return 0, errors.New("department already exists")
+		}
+	}
+	id := s.nextID("departments")
+	s.departments[id] = &storage.Department{ID: id, Name: name}
+	return id, nil
+}
+
+func (s *Store) ListDepartments(ctx context.Context) ([]*storage.Department, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.Department
+	for _, d := range s.departments {
+		cp := *d
+		out = append(out, &cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (s *Store) GetDepartmentByID(ctx context.Context, id int64) (*storage.Department, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.departments[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	cp := *d
+	return &cp, nil
+}
+
+func (s *Store) RenameDepartment(ctx context.Context, id int64, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.departments[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	for _, o := range s.departments {
+		if o.ID != id && o.Name == name {
+			return errors.New("department already exists")
+		}
+	}
+	d.Name = name
+	return nil
+}
+
+func (s *Store) SetDepartmentWorkHours(ctx context.Context, id int64, spec string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.departments[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	d.WorkHours = sql.NullString{String: spec, Valid: spec != ""}
+	return nil
+}
+
+func (s *Store) SetDepartmentHead(ctx context.Context, id, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.departments[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	d.HeadID = nullID(userID)
+	return nil
+}
+
+func (s *Store) DeleteDepartment(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.departments[id]; !ok {
+		return storage.ErrNotFound
+	}
+	for _, u := range s.users {
+		if u.DepartmentID.Valid && u.DepartmentID.Int64 == id {
+			return storage.ErrDepartmentNotEmpty
+		}
+	}
+	delete(s.departments, id)
+	return nil
+}
+
+func (s *Store) MoveDepartmentMembers(ctx context.Context, fromID, toID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.departments[toID]; !ok {
+		return 0, storage.ErrNotFound
+	}
+	var n int64
+	for _, u := range s.users {
+		if u.DepartmentID.Valid && u.DepartmentID.Int64 == fromID {
+			u.DepartmentID.Int64 = toID
+			n++
+		}
+	}
+	return n, nil
+}
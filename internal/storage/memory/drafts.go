@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (s *Store) SaveDraft(ctx context.Context, userID int64, state string, payload any) (int64, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dr := &storage.Draft{ID: s.nextID("task_drafts"), UserID: userID, State: state, Payload: b, CreatedAt: storage.Now()}
+	s.drafts = append(s.drafts, dr)
+	return dr.ID, nil
+}
+
+func (s *Store) ListDrafts(ctx context.Context, userID int64) ([]*storage.Draft, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.Draft
+	for i := len(s.drafts) - 1; i >= 0; i-- {
+		if dr := s.drafts[i]; dr.UserID == userID {
+			cp := *dr
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) TakeDraft(ctx context.Context, id, userID int64) (*storage.Draft, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, dr := range s.drafts {
+		if dr.ID == id && dr.UserID == userID {
+			s.drafts = append(s.drafts[:i], s.drafts[i+1:]...)
+			return dr, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (s *Store) PurgeDrafts(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.drafts[:0]
+	for _, dr := range s.drafts {
+		if !dr.CreatedAt.Before(before) {
+			kept = append(kept, dr)
+		}
+	}
+	n := int64(len(s.drafts) - len(kept))
+	s.drafts = kept
+	return n, nil
+}
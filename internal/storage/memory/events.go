@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// addEvent appends a copy of e to the log; callers hold s.mu.
+func (s *Store) addEvent(e storage.TaskEvent) {
+	e.ID = s.nextID("task_events")
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = storage.Now()
+	}
+	e.ActorName, e.UserName = sql.NullString{}, sql.NullString{}
+	s.events = append(s.events, &e)
+}
+
+func (s *Store) AddTaskEvent(ctx context.Context, e *storage.TaskEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addEvent(*e)
+	return nil
+}
+
+func (s *Store) ListTaskEvents(ctx context.Context, taskID int64) ([]*storage.TaskEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.TaskEvent
+	for _, e := range s.events {
+		if e.TaskID != taskID {
+			continue
+		}
+		cp := *e
+		cp.ActorName = s.displayName(cp.ActorID)
+		cp.UserName = s.displayName(cp.UserID)
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+func (s *Store) displayName(id sql.NullInt64) sql.NullString {
+	u, ok := s.users[id.Int64]
+	if !id.Valid || !ok {
+		return sql.NullString{}
+	}
+	if u.Name.Valid {
+		return u.Name
+	}
+	if u.Username.Valid {
+		return sql.NullString{String: "@" + u.Username.String, Valid: true}
+	}
+	return sql.NullString{}
+}
+
+func nullID(id int64) sql.NullInt64 { return sql.NullInt64{Int64: id, Valid: id != 0} }
+
+func nullText(s string) sql.NullString { return sql.NullString{String: s, Valid: s != ""} }
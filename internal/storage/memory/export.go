@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (s *Store) ListAssignmentsForExport(ctx context.Context, f storage.ExportFilter) ([]*storage.AssignmentRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	type keyed struct {
+		row   *storage.AssignmentRow
+		label sql.NullString
+		id    int64
+	}
+	var rows []keyed
+	for _, a := range s.assignees {
+		t, ok := s.tasks[a.taskID]
+		switch {
+		case !ok || t.DeletedAt.Valid:
+			continue
+		case !f.From.IsZero() && t.CreatedAt.Before(f.From), !f.To.IsZero() && !t.CreatedAt.Before(f.To):
+			continue
+		case f.Status != "" && a.status != f.Status, f.CreatorID != 0 && t.CreatorID != f.CreatorID:
+			continue
+		}
+		r := &storage.AssignmentRow{TaskID: t.ID, Title: t.Title, CreatedAt: t.CreatedAt, DueAt: t.DueAt, Status: a.status}
+		if c, ok := s.users[t.CreatorID]; ok {
+			r.CreatorTgID = sql.NullInt64{Int64: c.TgID, Valid: true}
+			r.CreatorName, r.CreatorUsername = c.Name, c.Username
+		}
+		var u *storage.User
+		if a.userID != nil {
+			u = s.users[*a.userID]
+		}
+		if u != nil {
+			r.AssigneeTgID = sql.NullInt64{Int64: u.TgID, Valid: true}
+			r.AssigneeName, r.AssigneeUsername, r.Department = u.Name, u.Username, s.deptName(u.DepartmentID)
+		}
+		if f.DepartmentID != 0 && (u == nil || !u.DepartmentID.Valid || u.DepartmentID.Int64 != f.DepartmentID) {
+			continue
+		}
+		if a.status == "done" {
+			r.DoneAt = sql.NullTime{Time: a.updatedAt, Valid: true}
+		}
+		for _, res := range s.results {
+			if a.userID != nil && res.TaskID == t.ID && res.UserID == *a.userID {
+				r.Results++
+			}
+		}
+		label := r.AssigneeName
+		if !label.Valid {
+			label = r.AssigneeUsername
+		}
+		rows = append(rows, keyed{r, label, a.id})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		switch {
+		case !a.row.CreatedAt.Equal(b.row.CreatedAt):
+			return a.row.CreatedAt.Before(b.row.CreatedAt)
+		case a.row.TaskID != b.row.TaskID:
+			return a.row.TaskID < b.row.TaskID
+		case a.label != b.label:
+			return lessNull(a.label, b.label)
+		}
+		return a.id < b.id
+	})
+	out := make([]*storage.AssignmentRow, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, r.row)
+	}
+	return out, nil
+}
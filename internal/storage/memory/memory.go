@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// Store is a process-local storage.Store. It mirrors the semantics of the
+// sqlite backend, including cascades, and is meant for tests and local runs.
+type Store struct {
+	mu sync.Mutex
+
+	seq map[string]int64
+
+	users       map[int64]*storage.User
+	departments map[int64]*storage.Department
+	tasks       map[int64]*storage.Task
+	assignees   []*assignee
+	reminders   []*reminder
+	results     []*storage.TaskResult
+	attachments []*storage.Attachment
+	events      []*storage.TaskEvent
+	states      map[int64]*storage.State
+	drafts      []*storage.Draft
+	recurring   map[int64]*storage.Recurring
+	templates   map[int64]*storage.Template
+}
+
+var _ storage.Store = (*Store)(nil)
+
+type assignee struct {
+	id        int64
+	taskID    int64
+	userID    *int64
+	status    string
+	updatedAt time.Time
+	// escalatedAt is zero before the first escalation step.
+	escalatedAt time.Time
+}
+
+type reminder struct {
+	storage.Reminder
+	sent bool
+}
+
+func New() *Store {
+	return &Store{
+		seq:         map[string]int64{},
+		users:       map[int64]*storage.User{},
+		departments: map[int64]*storage.Department{},
+		tasks:       map[int64]*storage.Task{},
+		states:      map[int64]*storage.State{},
+		recurring:   map[int64]*storage.Recurring{},
+		templates:   map[int64]*storage.Template{},
+	}
+}
+
+func (s *Store) nextID(table string) int64 {
+	s.seq[table]++
+	return s.seq[table]
+}
@@ -0,0 +1,12 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+	"github.com/hihikaAAa/task-manager/internal/storage/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storage.Store { return New() })
+}
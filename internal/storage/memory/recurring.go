@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (s *Store) CreateRecurring(ctx context.Context, r *storage.Recurring) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *r
+	cp.ID = s.nextID("recurring_tasks")
+	cp.CreatedAt = storage.Now()
+	s.recurring[cp.ID] = &cp
+	return cp.ID, nil
+}
+
+func (s *Store) GetRecurring(ctx context.Context, id int64) (*storage.Recurring, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.recurring[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	cp := *r
+	return &cp, nil
+}
+
+func (s *Store) ListRecurring(ctx context.Context) ([]*storage.Recurring, error) {
+	return s.listRecurring(func(*storage.Recurring) bool { return true }), nil
+}
+
+func (s *Store) ListDueRecurring(ctx context.Context, now time.Time) ([]*storage.Recurring, error) {
+	out := s.listRecurring(func(r *storage.Recurring) bool {
+		return !r.Paused && r.NextAt.Valid && !r.NextAt.Time.After(now)
+	})
+	sort.SliceStable(out, func(i, j int) bool { return out[i].NextAt.Time.Before(out[j].NextAt.Time) })
+	return out, nil
+}
+
+func (s *Store) listRecurring(keep func(*storage.Recurring) bool) []*storage.Recurring {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.Recurring
+	for _, r := range s.recurring {
+		if keep(r) {
+			cp := *r
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (s *Store) SaveRecurring(ctx context.Context, r *storage.Recurring) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.recurring[r.ID]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	cur.NextAt, cur.Created, cur.Paused = r.NextAt, r.Created, r.Paused
+	return nil
+}
+
+func (s *Store) DeleteRecurring(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.recurring[id]; !ok {
+		return storage.ErrNotFound
+	}
+	delete(s.recurring, id)
+	return nil
+}
@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (s *Store) CreateReminders(ctx context.Context, taskID int64, userIDs []int64, reminderTimes []time.Time, kind string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[taskID]; !ok {
+		return storage.ErrNotFound
+	}
+	for _, uid := range userIDs {
+		for _, at := range reminderTimes {
+			s.reminders = append(s.reminders, &reminder{Reminder: storage.Reminder{
+				ID:     s.nextID("reminders"),
+				TaskID: taskID,
+				UserID: sql.NullInt64{Int64: uid, Valid: true},
+				At:     at,
+				Kind:   kind,
+			}})
+		}
+	}
+	return nil
+}
+
+func (s *Store) ListDueReminders(ctx context.Context, until time.Time) ([]*storage.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.Reminder
+	for _, r := range s.reminders {
+		if t, ok := s.tasks[r.TaskID]; !ok || t.DeletedAt.Valid {
+			continue
+		}
+		if !r.sent && !r.At.After(until) {
+			cp := r.Reminder
+			out = append(out, &cp)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out, nil
+}
+
+func (s *Store) ListTaskReminders(ctx context.Context, taskID int64) ([]*storage.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.Reminder
+	for _, r := range s.reminders {
+		if r.TaskID == taskID {
+			cp := r.Reminder
+			out = append(out, &cp)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out, nil
+}
+
+func (s *Store) MarkReminderSent(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.reminders {
+		if r.ID == id {
+			r.sent = true
+		}
+	}
+	return nil
+}
+
+func (s *Store) DeferReminder(ctx context.Context, id int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.reminders {
+		if r.ID == id && !r.sent {
+			r.At = at
+		}
+	}
+	return nil
+}
+
+func (s *Store) SnoozeReminder(ctx context.Context, id, userID int64, at time.Time) (*storage.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.reminders {
+		if r.ID != id || r.UserID.Int64 != userID {
+			continue
+		}
+		cp := reminder{Reminder: r.Reminder}
+		cp.ID, cp.At = s.nextID("reminders"), at
+		s.reminders = append(s.reminders, &cp)
+		s.addEvent(storage.TaskEvent{
+			TaskID: r.TaskID, ActorID: r.UserID, UserID: r.UserID, Kind: storage.EventSnoozed,
+			NewValue: storage.FormatDue(sql.NullTime{Time: at, Valid: true}),
+		})
+		out := cp.Reminder
+		return &out, nil
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (s *Store) MarkAllRemindersSentFor(ctx context.Context, taskID, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.reminders {
+		if r.TaskID == taskID && r.UserID.Int64 == userID {
+			r.sent = true
+		}
+	}
+	return nil
+}
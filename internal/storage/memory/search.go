@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// SearchTasks scores tasks by weighted word-prefix hits of every term; it is
+// a rough stand-in for the ranking the SQL backends do.
+func (s *Store) SearchTasks(ctx context.Context, q string, assigneeID int64, limit int) ([]*storage.SearchHit, error) {
+	terms := storage.SearchTerms(q)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type scored struct {
+		hit   *storage.SearchHit
+		score int
+	}
+	var found []scored
+	for _, t := range s.selectTasks(func(t *storage.Task) bool { return assigneeID == 0 || s.isAssignee(t.ID, assigneeID) }) {
+		fields := []struct {
+			text   string
+			weight int
+		}{{t.Title.String, 10}, {t.Description.String, 4}, {s.resultsText(t.ID), 1}}
+
+		score, best := 0, ""
+		for _, term := range terms {
+			hit := false
+			for _, f := range fields {
+				if hasWordPrefix(f.text, term) {
+					score += f.weight
+					if best == "" {
+						best = f.text
+					}
+					hit = true
+				}
+			}
+			if !hit {
+				score = 0
+				break
+			}
+		}
+		if score > 0 {
+			found = append(found, scored{&storage.SearchHit{Task: t, Snippet: markTerms(best, terms)}, score})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].score > found[j].score })
+
+	var out []*storage.SearchHit
+	for _, f := range found {
+		if len(out) == limit {
+			break
+		}
+		out = append(out, f.hit)
+	}
+	return out, nil
+}
+
+func (s *Store) isAssignee(taskID, userID int64) bool {
+	for _, a := range s.assigneesOf(taskID) {
+		if a.userID != nil && *a.userID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) resultsText(taskID int64) string {
+	var parts []string
+	for _, r := range s.results {
+		if r.TaskID == taskID && r.Text.Valid {
+			parts = append(parts, r.Text.String)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+const snippetWords = 24
+
+func hasWordPrefix(text, term string) bool {
+	for _, w := range strings.FieldsFunc(storage.FoldYo(strings.ToLower(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if strings.HasPrefix(w, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// markTerms wraps the words of text that start with one of terms, keeping
+// at most snippetWords words.
+func markTerms(text string, terms []string) string {
+	words := strings.Fields(text)
+	if len(words) > snippetWords {
+		words = append(words[:snippetWords], "…")
+	}
+	for i, w := range words {
+		lw := storage.FoldYo(strings.ToLower(strings.TrimLeftFunc(w, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })))
+		for _, t := range terms {
+			if strings.HasPrefix(lw, t) {
+				words[i] = storage.SnippetOpen + w + storage.SnippetClose
+				break
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (s *Store) SaveState(ctx context.Context, userID int64, state string, payload any) error {
+	var b []byte
+	var err error
+	if payload != nil {
+		b, err = json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[userID] = &storage.State{UserID: userID, State: state, Payload: b, UpdatedAt: storage.Now()}
+	return nil
+}
+
+func (s *Store) LoadState(ctx context.Context, userID int64, dst any) (string, error) {
+	s.mu.Lock()
+	st, ok := s.states[userID]
+	s.mu.Unlock()
+	if !ok {
+		return "", storage.ErrNotFound
+	}
+	if dst != nil && len(st.Payload) > 0 {
+		_ = json.Unmarshal(st.Payload, dst)
+	}
+	return st.State, nil
+}
+
+func (s *Store) ClearState(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, userID)
+	return nil
+}
+
+func (s *Store) ExpireStates(ctx context.Context, before time.Time) ([]*storage.State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.State
+	for id, st := range s.states {
+		if st.UpdatedAt.Before(before) {
+			out = append(out, st)
+			delete(s.states, id)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,636 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (s *Store) CreateTask(ctx context.Context, in storage.NewTask) (*storage.CreatedTask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkNewTask(in); err != nil {
+		return nil, err
+	}
+	return s.createTask(in), nil
+}
+
+func (s *Store) CreateTasks(ctx context.Context, in []storage.NewTask) ([]*storage.CreatedTask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, nt := range in {
+		if err := s.checkNewTask(nt); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]*storage.CreatedTask, 0, len(in))
+	for _, nt := range in {
+		out = append(out, s.createTask(nt))
+	}
+	return out, nil
+}
+
+// checkNewTask fails where the SQL stores would; callers hold s.mu.
+func (s *Store) checkNewTask(in storage.NewTask) error {
+	if _, ok := s.users[in.Task.CreatorID]; !ok {
+		return storage.ErrNotFound
+	}
+	seen := map[int64]bool{}
+	for _, uid := range in.AssigneeIDs {
+		if _, ok := s.users[uid]; !ok {
+			return storage.ErrNotFound
+		}
+		if seen[uid] {
+			return errors.New("duplicate assignee")
+		}
+		seen[uid] = true
+	}
+	return nil
+}
+
+// createTask writes a validated NewTask; callers hold s.mu.
+func (s *Store) createTask(in storage.NewTask) *storage.CreatedTask {
+	now := storage.Now()
+	t := *in.Task
+	t.ID = s.nextID("tasks")
+	t.CreatedAt, t.UpdatedAt = now, now
+	stored := t
+	s.tasks[t.ID] = &stored
+	s.addEvent(storage.TaskEvent{TaskID: t.ID, ActorID: nullID(t.CreatorID), Kind: storage.EventCreated, NewValue: t.Title, CreatedAt: now})
+	for _, uid := range in.AssigneeIDs {
+		uid := uid
+		s.assignees = append(s.assignees, &assignee{id: s.nextID("task_assignees"), taskID: t.ID, userID: &uid, status: "new", updatedAt: now})
+		s.addEvent(storage.TaskEvent{TaskID: t.ID, ActorID: nullID(t.CreatorID), UserID: nullID(uid), Kind: storage.EventAssigned, CreatedAt: now})
+	}
+	for _, a := range in.Attachments {
+		cp := *a
+		cp.ID, cp.TaskID = s.nextID("task_attachments"), t.ID
+		s.attachments = append(s.attachments, &cp)
+	}
+	n := 0
+	for _, uid := range in.AssigneeIDs {
+		for _, r := range in.Reminders {
+			if r.UserID != 0 && r.UserID != uid {
+				continue
+			}
+			s.reminders = append(s.reminders, &reminder{Reminder: storage.Reminder{
+				ID:     s.nextID("reminders"),
+				TaskID: t.ID,
+				UserID: sql.NullInt64{Int64: uid, Valid: true},
+				At:     r.At,
+				Kind:   r.Kind,
+			}})
+			n++
+		}
+	}
+	return &storage.CreatedTask{Task: &t, AssigneeIDs: in.AssigneeIDs, Reminders: n}
+}
+
+func (s *Store) GetTask(ctx context.Context, id int64) (*storage.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	cp := *t
+	return &cp, nil
+}
+
+func (s *Store) DueAtForTask(ctx context.Context, taskID int64) (time.Time, bool, error) {
+	t, err := s.GetTask(ctx, taskID)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t.DueAt.Time, t.DueAt.Valid, nil
+}
+
+// selectTasks returns copies of the live tasks matching keep, newest first.
+func (s *Store) selectTasks(keep func(t *storage.Task) bool) []*storage.Task {
+	var out []*storage.Task
+	for _, t := range s.tasks {
+		if !t.DeletedAt.Valid && keep(t) {
+			cp := *t
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out
+}
+
+func (s *Store) assigneesOf(taskID int64) []*assignee {
+	var out []*assignee
+	for _, a := range s.assignees {
+		if a.taskID == taskID {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func (s *Store) ListAllTasks(ctx context.Context) ([]*storage.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := s.selectTasks(func(*storage.Task) bool { return true })
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (s *Store) ListActiveTasksForBoss(ctx context.Context, p storage.PageRequest) (*storage.TaskPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pageByCreation(p, s.selectTasks(func(t *storage.Task) bool {
+		as := s.assigneesOf(t.ID)
+		if len(as) == 0 {
+			return true
+		}
+		for _, a := range as {
+			if a.status != "done" {
+				return true
+			}
+		}
+		return false
+	})), nil
+}
+
+func (s *Store) ListActiveTasksForUser(ctx context.Context, userID int64, p storage.PageRequest) (*storage.TaskPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pageByCreation(p, s.selectTasks(func(t *storage.Task) bool {
+		for _, a := range s.assigneesOf(t.ID) {
+			if a.userID != nil && *a.userID == userID && a.status != "done" {
+				return true
+			}
+		}
+		return false
+	})), nil
+}
+
+func (s *Store) ListActiveTasksForDepartment(ctx context.Context, deptID int64, p storage.PageRequest) (*storage.TaskPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pageByCreation(p, s.selectTasks(func(t *storage.Task) bool {
+		for _, a := range s.assigneesOf(t.ID) {
+			if a.userID == nil || a.status == "done" {
+				continue
+			}
+			if u, ok := s.users[*a.userID]; ok && u.DepartmentID.Valid && u.DepartmentID.Int64 == deptID {
+				return true
+			}
+		}
+		return false
+	})), nil
+}
+
+func (s *Store) listDone(match func(a *assignee) bool, creatorID *int64) ([]*storage.Task, []time.Time) {
+	type row struct {
+		t    *storage.Task
+		comp time.Time
+	}
+	var rows []row
+	for _, t := range s.tasks {
+		if t.DeletedAt.Valid || (creatorID != nil && t.CreatorID != *creatorID) {
+			continue
+		}
+		for _, a := range s.assigneesOf(t.ID) {
+			if a.status != "done" || !match(a) {
+				continue
+			}
+			if creatorID != nil && len(rows) > 0 && rows[len(rows)-1].t.ID == t.ID {
+				if a.updatedAt.After(rows[len(rows)-1].comp) {
+					rows[len(rows)-1].comp = a.updatedAt
+				}
+				continue
+			}
+			cp := *t
+			rows = append(rows, row{&cp, a.updatedAt})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].comp.Equal(rows[j].comp) {
+			return rows[i].comp.After(rows[j].comp)
+		}
+		return rows[i].t.ID > rows[j].t.ID
+	})
+	var ts []*storage.Task
+	var comps []time.Time
+	for _, r := range rows {
+		ts = append(ts, r.t)
+		comps = append(comps, r.comp)
+	}
+	return ts, comps
+}
+
+func (s *Store) ListDoneTasksForBoss(ctx context.Context, creatorID int64, p storage.PageRequest) (*storage.TaskPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts, comps := s.listDone(func(*assignee) bool { return true }, &creatorID)
+	return page(p, ts, comps), nil
+}
+
+func (s *Store) ListDoneTasksForUser(ctx context.Context, userID int64, p storage.PageRequest) (*storage.TaskPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts, comps := s.listDone(func(a *assignee) bool { return a.userID != nil && *a.userID == userID }, nil)
+	return page(p, ts, comps), nil
+}
+
+func pageByCreation(p storage.PageRequest, ts []*storage.Task) *storage.TaskPage {
+	keys := make([]time.Time, len(ts))
+	for i, t := range ts {
+		keys[i] = t.CreatedAt
+	}
+	return page(p, ts, keys)
+}
+
+// page cuts the requested page out of ts, which is sorted by (keys, id)
+// descending, the way the SQL backends read it.
+func page(p storage.PageRequest, ts []*storage.Task, keys []time.Time) *storage.TaskPage {
+	var pts []*storage.Task
+	var pkeys []time.Time
+	take := func(i int) bool {
+		pts, pkeys = append(pts, ts[i]), append(pkeys, keys[i])
+		return len(pts) > p.Size()
+	}
+	c := p.Cursor
+	if p.Backward {
+		for i := len(ts) - 1; i >= 0; i-- {
+			if (c.IsZero() || c.Before(keys[i], ts[i].ID)) && take(i) {
+				break
+			}
+		}
+	} else {
+		for i := range ts {
+			if (c.IsZero() || c.After(keys[i], ts[i].ID)) && take(i) {
+				break
+			}
+		}
+	}
+	return storage.NewTaskPage(p, pts, pkeys)
+}
+
+func (s *Store) ListTasksWithoutAssignees(ctx context.Context) ([]*storage.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.selectTasks(func(t *storage.Task) bool { return len(s.assigneesOf(t.ID)) == 0 }), nil
+}
+
+func (s *Store) FindTasksByTitleLike(ctx context.Context, q string, limit int) ([]*storage.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q = strings.ToLower(q)
+	out := s.selectTasks(func(t *storage.Task) bool {
+		return t.Title.Valid && strings.Contains(strings.ToLower(t.Title.String), q)
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *Store) deleteTask(id int64) bool {
+	if _, ok := s.tasks[id]; !ok {
+		return false
+	}
+	delete(s.tasks, id)
+	s.assignees = filter(s.assignees, func(a *assignee) bool { return a.taskID != id })
+	s.reminders = filter(s.reminders, func(r *reminder) bool { return r.TaskID != id })
+	s.results = filter(s.results, func(r *storage.TaskResult) bool { return r.TaskID != id })
+	s.attachments = filter(s.attachments, func(a *storage.Attachment) bool { return a.TaskID != id })
+	return true
+}
+
+// deleteTasks moves the live tasks matching match to the trash.
+func (s *Store) deleteTasks(actorID int64, match func(t *storage.Task) bool) int64 {
+	now := storage.Now()
+	var n int64
+	for id, t := range s.tasks {
+		if t.DeletedAt.Valid || !match(t) {
+			continue
+		}
+		t.DeletedAt = sql.NullTime{Time: now, Valid: true}
+		t.DeletedBy = nullID(actorID)
+		s.addEvent(storage.TaskEvent{TaskID: id, ActorID: nullID(actorID), Kind: storage.EventDeleted, OldValue: t.Title, CreatedAt: now})
+		n++
+	}
+	return n
+}
+
+func (s *Store) DeleteTask(ctx context.Context, taskID, actorID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deleteTasks(actorID, func(t *storage.Task) bool { return t.ID == taskID }), nil
+}
+
+func (s *Store) DeleteAllTasks(ctx context.Context, actorID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deleteTasks(actorID, func(*storage.Task) bool { return true }), nil
+}
+
+func (s *Store) DeleteTasksByExactTitle(ctx context.Context, title string, actorID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deleteTasks(actorID, func(t *storage.Task) bool { return t.Title.Valid && t.Title.String == title }), nil
+}
+
+func (s *Store) ListDeletedTasks(ctx context.Context) ([]*storage.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.Task
+	for _, t := range s.tasks {
+		if t.DeletedAt.Valid {
+			cp := *t
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DeletedAt.Time.After(out[j].DeletedAt.Time) })
+	return out, nil
+}
+
+func (s *Store) RestoreTask(ctx context.Context, taskID, actorID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[taskID]
+	if !ok || !t.DeletedAt.Valid {
+		return storage.ErrNotFound
+	}
+	now := storage.Now()
+	t.DeletedAt, t.DeletedBy, t.UpdatedAt = sql.NullTime{}, sql.NullInt64{}, now
+	for _, r := range s.reminders {
+		if r.TaskID == taskID && !r.At.After(now) {
+			r.sent = true
+		}
+	}
+	s.addEvent(storage.TaskEvent{TaskID: taskID, ActorID: nullID(actorID), Kind: storage.EventRestored, CreatedAt: now})
+	return nil
+}
+
+func (s *Store) PurgeDeletedTasks(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for id, t := range s.tasks {
+		if t.DeletedAt.Valid && t.DeletedAt.Time.Before(before) && s.deleteTask(id) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) UpdateTaskDeadline(ctx context.Context, taskID, actorID int64, due sql.NullTime) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[taskID]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	old := t.DueAt
+	now := storage.Now()
+	t.DueAt, t.UpdatedAt = due, now
+	for _, a := range s.assigneesOf(taskID) {
+		a.escalatedAt = time.Time{}
+	}
+
+	switch {
+	case !due.Valid:
+		s.reminders = filter(s.reminders, func(r *reminder) bool { return r.TaskID != taskID || r.sent })
+	case old.Valid:
+		delta := due.Time.Sub(old.Time)
+		for _, r := range s.reminders {
+			if r.TaskID == taskID && !r.sent {
+				r.At = r.At.Add(delta)
+			}
+		}
+	}
+
+	s.addEvent(storage.TaskEvent{
+		TaskID: taskID, ActorID: nullID(actorID), Kind: storage.EventDeadline,
+		OldValue: storage.FormatDue(old), NewValue: storage.FormatDue(due), CreatedAt: now,
+	})
+	return nil
+}
+
+func (s *Store) AddResult(ctx context.Context, r *storage.TaskResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[r.TaskID]; !ok {
+		return storage.ErrNotFound
+	}
+	if _, ok := s.users[r.UserID]; !ok {
+		return storage.ErrNotFound
+	}
+	r.ID, r.CreatedAt = s.nextID("task_results"), storage.Now()
+	cp := *r
+	s.results = append(s.results, &cp)
+	s.addEvent(storage.TaskEvent{
+		TaskID: r.TaskID, ActorID: nullID(r.UserID), UserID: nullID(r.UserID), Kind: storage.EventResult,
+		NewValue: storage.ResultSummary(r), CreatedAt: r.CreatedAt,
+	})
+	return nil
+}
+
+func (s *Store) ListResults(ctx context.Context, taskID int64) ([]*storage.TaskResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.TaskResult
+	for _, r := range s.results {
+		if r.TaskID == taskID {
+			cp := *r
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) ListAttachments(ctx context.Context, taskID int64) ([]*storage.Attachment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.Attachment
+	for _, a := range s.attachments {
+		if a.TaskID == taskID {
+			cp := *a
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) HasResult(ctx context.Context, taskID, userID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.results {
+		if r.TaskID == taskID && r.UserID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) UpdateAssigneeStatus(ctx context.Context, taskID, userID int64, status string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := false
+	for _, a := range s.assigneesOf(taskID) {
+		if a.userID != nil && *a.userID == userID && a.status != status {
+			s.addEvent(storage.TaskEvent{
+				TaskID: taskID, ActorID: nullID(userID), UserID: nullID(userID), Kind: storage.EventStatus,
+				OldValue: nullText(a.status), NewValue: nullText(status),
+			})
+			a.status = status
+			a.updatedAt = storage.Now()
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+func (s *Store) IsAssigneeDone(ctx context.Context, taskID, userID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.assigneesOf(taskID) {
+		if a.userID != nil && *a.userID == userID {
+			return a.status == "done", nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) GetAssignees(ctx context.Context, taskID int64) ([]*storage.TaskAssignee, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.TaskAssignee
+	for _, a := range s.assigneesOf(taskID) {
+		if a.userID == nil {
+			return nil, errors.New("assignee without user")
+		}
+		out = append(out, &storage.TaskAssignee{ID: a.id, TaskID: a.taskID, UserID: *a.userID, Status: a.status, UpdatedAt: a.updatedAt})
+	}
+	return out, nil
+}
+
+func (s *Store) ListAssigneesWithUsers(ctx context.Context, taskID int64) ([]*storage.AssigneeRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.AssigneeRow
+	for _, a := range s.assigneesOf(taskID) {
+		if a.userID == nil {
+			continue
+		}
+		u, ok := s.users[*a.userID]
+		if !ok {
+			continue
+		}
+		out = append(out, &storage.AssigneeRow{TgID: u.TgID, Name: u.Name, Username: u.Username, Team: s.deptName(u.DepartmentID), Status: a.status})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Team != out[j].Team {
+			return lessNull(out[i].Team, out[j].Team)
+		}
+		return lessNull(out[i].Name, out[j].Name)
+	})
+	return out, nil
+}
+
+func (s *Store) ListAssigneesWithUsersAny(ctx context.Context, taskID int64) ([]*storage.AssigneeWithUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.AssigneeWithUser
+	for _, a := range s.assigneesOf(taskID) {
+		r := &storage.AssigneeWithUser{Status: a.status}
+		if a.userID != nil {
+			r.UserID = sql.NullInt64{Int64: *a.userID, Valid: true}
+			if u, ok := s.users[*a.userID]; ok {
+				r.Name, r.Username, r.Team = u.Name, u.Username, s.deptName(u.DepartmentID)
+				r.TgID = sql.NullInt64{Int64: u.TgID, Valid: true}
+			}
+		}
+		out = append(out, r)
+	}
+	label := func(r *storage.AssigneeWithUser) sql.NullString {
+		if r.Name.Valid {
+			return r.Name
+		}
+		return r.Username
+	}
+	sort.SliceStable(out, func(i, j int) bool { return lessNull(label(out[i]), label(out[j])) })
+	return out, nil
+}
+
+func (s *Store) ListAssigneeTgIDsByTask(ctx context.Context, taskID int64) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []int64
+	for _, a := range s.assigneesOf(taskID) {
+		if a.userID == nil {
+			continue
+		}
+		if u, ok := s.users[*a.userID]; ok {
+			ids = append(ids, u.TgID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *Store) ListDoneExecutorsForTask(ctx context.Context, taskID int64) ([]*storage.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var done []*assignee
+	for _, a := range s.assigneesOf(taskID) {
+		if a.userID != nil && a.status == "done" {
+			done = append(done, a)
+		}
+	}
+	sort.SliceStable(done, func(i, j int) bool { return done[i].updatedAt.After(done[j].updatedAt) })
+	var out []*storage.User
+	for _, a := range done {
+		if u, ok := s.users[*a.userID]; ok {
+			out = append(out, s.userCopy(u))
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) ListOverdueAssignees(ctx context.Context, now time.Time) ([]*storage.OverdueAssignee, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.OverdueAssignee
+	for _, a := range s.assignees {
+		t, ok := s.tasks[a.taskID]
+		if !ok || t.DeletedAt.Valid || !t.DueAt.Valid || !t.DueAt.Time.Before(now) || a.userID == nil {
+			continue
+		}
+		if a.status != "new" && a.status != "in_progress" {
+			continue
+		}
+		o := &storage.OverdueAssignee{TaskID: a.taskID, UserID: *a.userID, DueAt: t.DueAt.Time}
+		if !a.escalatedAt.IsZero() {
+			o.EscalatedAt = sql.NullTime{Time: a.escalatedAt, Valid: true}
+		}
+		out = append(out, o)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].DueAt.Before(out[j].DueAt) })
+	return out, nil
+}
+
+func (s *Store) RecordEscalation(ctx context.Context, e *storage.TaskEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.assigneesOf(e.TaskID) {
+		if a.userID != nil && *a.userID == e.UserID.Int64 {
+			a.escalatedAt = e.CreatedAt
+		}
+	}
+	s.addEvent(*e)
+	return nil
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (s *Store) CreateTemplate(ctx context.Context, t *storage.Template) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *t
+	cp.ID = s.nextID("task_templates")
+	cp.CreatedAt = storage.Now()
+	s.templates[cp.ID] = &cp
+	return cp.ID, nil
+}
+
+func (s *Store) GetTemplate(ctx context.Context, id int64) (*storage.Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.templates[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	cp := *t
+	return &cp, nil
+}
+
+func (s *Store) ListTemplates(ctx context.Context) ([]*storage.Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*storage.Template
+	for _, t := range s.templates {
+		cp := *t
+		out = append(out, &cp)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := strings.ToLower(out[i].Name), strings.ToLower(out[j].Name)
+		if a != b {
+			return a < b
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+func (s *Store) DeleteTemplate(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.templates[id]; !ok {
+		return storage.ErrNotFound
+	}
+	delete(s.templates, id)
+	return nil
+}
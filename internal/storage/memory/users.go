@@ -0,0 +1,213 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (s *Store) userByTgID(tgID int64) *storage.User {
+	for _, u := range s.users {
+		if u.TgID == tgID {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *Store) UpsertUser(ctx context.Context, tgID int64, username *string, role string) (*storage.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var uname sql.NullString
+	if username != nil {
+		uname = sql.NullString{String: *username, Valid: true}
+	}
+	u := s.userByTgID(tgID)
+	if u == nil {
+		u = &storage.User{ID: s.nextID("users"), TgID: tgID, Role: role, CreatedAt: storage.Now()}
+		s.users[u.ID] = u
+	}
+	u.Username = uname
+	return s.userCopy(u), nil
+}
+
+func (s *Store) GetUserByTgID(ctx context.Context, tgID int64) (*storage.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.userByTgID(tgID)
+	if u == nil {
+		return nil, storage.ErrNotFound
+	}
+	return s.userCopy(u), nil
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id int64) (*storage.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return s.userCopy(u), nil
+}
+
+// userCopy returns a copy of u with Team read from its department, as the
+// SQL backends join it.
+func (s *Store) userCopy(u *storage.User) *storage.User {
+	cp := *u
+	cp.Team = s.deptName(u.DepartmentID)
+	return &cp
+}
+
+func (s *Store) deptName(id sql.NullInt64) sql.NullString {
+	if d, ok := s.departments[id.Int64]; ok && id.Valid {
+		return sql.NullString{String: d.Name, Valid: true}
+	}
+	return sql.NullString{}
+}
+
+func (s *Store) SetUserTimezone(ctx context.Context, tgID int64, tz string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u := s.userByTgID(tgID); u != nil {
+		u.Timezone = sql.NullString{String: tz, Valid: tz != ""}
+	}
+	return nil
+}
+
+func (s *Store) SetUserWorkHours(ctx context.Context, tgID int64, spec string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u := s.userByTgID(tgID); u != nil {
+		u.WorkHours = sql.NullString{String: spec, Valid: spec != ""}
+	}
+	return nil
+}
+
+func (s *Store) SetWorkerProfile(ctx context.Context, tgID int64, name string, deptID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.departments[deptID]; !ok {
+		return storage.ErrNotFound
+	}
+	if u := s.userByTgID(tgID); u != nil {
+		u.Name = sql.NullString{String: name, Valid: true}
+		u.DepartmentID = sql.NullInt64{Int64: deptID, Valid: true}
+	}
+	return nil
+}
+
+func (s *Store) workers(keep func(u *storage.User) bool) []*storage.User {
+	var out []*storage.User
+	for _, u := range s.users {
+		if u.Role == "worker" && keep(u) {
+			out = append(out, s.userCopy(u))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Team != out[j].Team {
+			return lessNull(out[i].Team, out[j].Team)
+		}
+		return lessNull(out[i].Name, out[j].Name)
+	})
+	return out
+}
+
+func (s *Store) ListWorkersByDepartment(ctx context.Context, deptID int64) ([]*storage.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.workers(func(u *storage.User) bool { return u.DepartmentID.Valid && u.DepartmentID.Int64 == deptID }), nil
+}
+
+func (s *Store) ListAllWorkers(ctx context.Context) ([]*storage.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.workers(func(*storage.User) bool { return true }), nil
+}
+
+func (s *Store) SearchWorkers(ctx context.Context, q string) ([]*storage.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q = strings.ToLower(q)
+	return s.workers(func(u *storage.User) bool {
+		return strings.Contains(strings.ToLower(u.Username.String), q) ||
+			strings.Contains(strings.ToLower(u.Name.String), q) ||
+			strings.Contains(strings.ToLower(s.deptName(u.DepartmentID).String), q)
+	}), nil
+}
+
+func (s *Store) FindWorkerByUsername(ctx context.Context, username string) (*storage.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws := s.workers(func(u *storage.User) bool {
+		return u.Username.Valid && strings.EqualFold(u.Username.String, username)
+	})
+	if len(ws) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	return ws[0], nil
+}
+
+func (s *Store) DeleteWorkerByTgID(ctx context.Context, tgID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.userByTgID(tgID)
+	if u == nil {
+		return 0, storage.ErrNotFound
+	}
+	name := s.displayName(nullID(u.ID))
+	for _, a := range s.assignees {
+		if a.userID != nil && *a.userID == u.ID {
+			s.addEvent(storage.TaskEvent{TaskID: a.taskID, UserID: nullID(u.ID), Kind: storage.EventUnassigned, OldValue: name})
+			a.userID = nil
+		}
+	}
+	s.reminders = filter(s.reminders, func(r *reminder) bool { return r.UserID.Int64 != u.ID })
+	if u.Role != "worker" {
+		return 0, nil
+	}
+	// head_id is ON DELETE SET NULL in the SQL stores
+	for _, d := range s.departments {
+		if d.HeadID.Int64 == u.ID {
+			d.HeadID = sql.NullInt64{}
+		}
+	}
+	s.results = filter(s.results, func(r *storage.TaskResult) bool { return r.UserID != u.ID })
+	for id, r := range s.recurring {
+		if r.CreatorID == u.ID {
+			delete(s.recurring, id)
+		}
+	}
+	for id, t := range s.templates {
+		if t.CreatorID == u.ID {
+			delete(s.templates, id)
+		}
+	}
+	for id, t := range s.tasks {
+		if t.CreatorID == u.ID {
+			s.deleteTask(id)
+		}
+	}
+	delete(s.users, u.ID)
+	return 1, nil
+}
+
+func lessNull(a, b sql.NullString) bool {
+	if a.Valid != b.Valid {
+		return !a.Valid
+	}
+	return a.String < b.String
+}
+
+func filter[T any](in []T, keep func(T) bool) []T {
+	out := in[:0]
+	for _, v := range in {
+		if keep(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
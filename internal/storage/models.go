@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+type User struct {
+	ID       int64
+	TgID     int64
+	Username sql.NullString
+	Role     string
+	Name     sql.NullString
+	// Team is the name of the user's department, read through DepartmentID.
+	Team         sql.NullString
+	DepartmentID sql.NullInt64
+	// Timezone is an IANA zone name; NULL means the bot's default zone.
+	Timezone sql.NullString
+	// WorkHours overrides the department's working hours; the format is
+	// up to the bot.
+	WorkHours sql.NullString
+	CreatedAt time.Time
+}
+
+type Department struct {
+	ID   int64
+	Name string
+	// WorkHours are the members' working hours, NULL for the bot's default.
+	WorkHours sql.NullString
+	// HeadID is the users.id overdue tasks of members escalate to.
+	HeadID sql.NullInt64
+}
+
+type Task struct {
+	ID          int64
+	CreatorID   int64
+	Title       sql.NullString
+	Description sql.NullString
+	// VoiceFileID is only set on tasks created before attachments; their
+	// voice note was copied into task_attachments.
+	VoiceFileID sql.NullString
+	DueAt       sql.NullTime
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	// DeletedAt is set while the task sits in the trash.
+	DeletedAt sql.NullTime
+	DeletedBy sql.NullInt64
+}
+
+// NewTask is everything CreateTask writes in one transaction. Each reminder
+// is created for every assignee.
+type NewTask struct {
+	Task        *Task
+	AssigneeIDs []int64
+	Reminders   []NewReminder
+	Attachments []*Attachment
+}
+
+type NewReminder struct {
+	At   time.Time
+	Kind string
+	// UserID limits the reminder to one assignee (users.id); 0 means all.
+	UserID int64
+}
+
+type CreatedTask struct {
+	Task        *Task
+	AssigneeIDs []int64
+	Reminders   int
+}
+
+type TaskAssignee struct {
+	ID        int64
+	TaskID    int64
+	UserID    int64
+	Status    string
+	UpdatedAt time.Time
+}
+
+// OverdueAssignee is an unfinished assignment of a task past its deadline.
+type OverdueAssignee struct {
+	TaskID int64
+	UserID int64
+	DueAt  time.Time
+	// EscalatedAt is when escalation last fired, NULL before the first step.
+	EscalatedAt sql.NullTime
+}
+
+// ExportFilter narrows ListAssignmentsForExport; zero fields match all.
+type ExportFilter struct {
+	// From and To bound the creation time of the task, [From, To).
+	From, To time.Time
+	// DepartmentID is the current department of the assignee.
+	DepartmentID int64
+	Status       string
+	CreatorID    int64
+}
+
+// AssignmentRow is one task–assignee pair of a report. The assignee fields
+// are NULL once the user is deleted.
+type AssignmentRow struct {
+	TaskID           int64
+	Title            sql.NullString
+	CreatedAt        time.Time
+	DueAt            sql.NullTime
+	CreatorTgID      sql.NullInt64
+	CreatorName      sql.NullString
+	CreatorUsername  sql.NullString
+	AssigneeTgID     sql.NullInt64
+	AssigneeName     sql.NullString
+	AssigneeUsername sql.NullString
+	Department       sql.NullString
+	Status           string
+	// DoneAt is when the assignment was marked done.
+	DoneAt  sql.NullTime
+	Results int
+}
+
+type AssigneeRow struct {
+	TgID     int64
+	Name     sql.NullString
+	Username sql.NullString
+	Team     sql.NullString
+	Status   string
+}
+
+type AssigneeWithUser struct {
+	UserID   sql.NullInt64
+	Status   string
+	Name     sql.NullString
+	Username sql.NullString
+	Team     sql.NullString
+	TgID     sql.NullInt64
+}
+
+type Reminder struct {
+	ID     int64
+	TaskID int64
+	UserID sql.NullInt64
+	At     time.Time
+	Kind   string
+}
+
+// Media kinds name the Telegram media type of a result or attachment file.
+const (
+	MediaDocument = "document"
+	MediaPhoto    = "photo"
+	MediaVoice    = "voice"
+	MediaAudio    = "audio"
+	MediaVideo    = "video"
+)
+
+// Attachment is a file the boss attached to a task when creating it.
+type Attachment struct {
+	ID       int64
+	TaskID   int64
+	Kind     string
+	FileID   string
+	FileName sql.NullString
+	Caption  sql.NullString
+}
+
+// TaskResult is what an assignee sent for a task: text, or a file of Kind
+// with an optional caption. Results saved before kinds were recorded have a
+// FileID but no Kind.
+type TaskResult struct {
+	ID        int64
+	TaskID    int64
+	UserID    int64
+	Text      sql.NullString
+	Kind      sql.NullString
+	FileID    sql.NullString
+	Caption   sql.NullString
+	FileName  sql.NullString
+	FileSize  sql.NullInt64
+	MimeType  sql.NullString
+	CreatedAt time.Time
+}
+
+// SearchHit is a SearchTasks result. Snippet is a fragment of the best
+// matching field with the matched words between SnippetOpen and SnippetClose.
+type SearchHit struct {
+	Task    *Task
+	Snippet string
+}
+
+type State struct {
+	UserID    int64
+	State     string
+	Payload   []byte
+	UpdatedAt time.Time
+}
+
+// Draft is a conversation state put aside to be resumed later. UserID is the
+// Telegram id, as in State.
+type Draft struct {
+	ID        int64
+	UserID    int64
+	State     string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// Recurring is a task definition the scheduler creates a task from on
+// every occurrence of Schedule.
+type Recurring struct {
+	ID        int64
+	CreatorID int64
+	Title     string
+	Schedule  string
+	// Payload is the /newtask draft every task is made from.
+	Payload []byte
+	// NextAt is the next occurrence, NULL once the schedule has ended.
+	NextAt sql.NullTime
+	// Created counts the tasks made so far.
+	Created   int
+	Paused    bool
+	CreatedAt time.Time
+}
+
+// Template is a saved /newtask draft new tasks can start from.
+type Template struct {
+	ID        int64
+	Name      string
+	CreatorID int64
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+const (
+	EventCreated    = "created"
+	EventAssigned   = "assigned"
+	EventUnassigned = "unassigned"
+	EventStatus     = "status"
+	EventResult     = "result"
+	EventDeadline   = "deadline"
+	EventDeleted    = "deleted"
+	EventRestored   = "restored"
+	// EventSnoozed is a worker postponing a reminder; NewValue is the new
+	// time as FormatDue writes it.
+	EventSnoozed = "snoozed"
+	// EventEscalated is an overdue assignment of UserID escalated: OldValue
+	// names the step, NewValue who was notified.
+	EventEscalated = "escalated"
+)
+
+// TaskEvent is one entry of a task's history. ActorID is who caused the
+// change (NULL for the system), UserID the assignee it concerns, if any.
+// ActorName and UserName are filled in by ListTaskEvents.
+type TaskEvent struct {
+	ID        int64
+	TaskID    int64
+	ActorID   sql.NullInt64
+	UserID    sql.NullInt64
+	Kind      string
+	OldValue  sql.NullString
+	NewValue  sql.NullString
+	CreatedAt time.Time
+
+	ActorName sql.NullString
+	UserName  sql.NullString
+}
@@ -0,0 +1,75 @@
+package storage
+
+import "time"
+
+// DefaultPageSize is used when a PageRequest has no Limit.
+const DefaultPageSize = 10
+
+// Cursor is a position in a list sorted by (At, ID) descending: the sort key
+// and id of a row. The zero Cursor is the start of the list.
+type Cursor struct {
+	At time.Time
+	ID int64
+}
+
+func (c Cursor) IsZero() bool { return c.ID == 0 }
+
+// PageRequest asks for up to Limit rows right after Cursor, or with Backward
+// set, the rows right before it.
+type PageRequest struct {
+	Cursor   Cursor
+	Backward bool
+	Limit    int
+}
+
+func (p PageRequest) Size() int {
+	if p.Limit <= 0 {
+		return DefaultPageSize
+	}
+	return p.Limit
+}
+
+// TaskPage is one page of a task list. Keys holds each task's sort key:
+// creation time for the active lists, completion time for the done lists.
+type TaskPage struct {
+	Tasks            []*Task
+	Keys             []time.Time
+	HasPrev, HasNext bool
+}
+
+// First and Last are the cursors to page backward and forward from.
+func (p *TaskPage) First() Cursor { return Cursor{At: p.Keys[0], ID: p.Tasks[0].ID} }
+func (p *TaskPage) Last() Cursor {
+	n := len(p.Tasks) - 1
+	return Cursor{At: p.Keys[n], ID: p.Tasks[n].ID}
+}
+
+// NewTaskPage builds a page from up to Size()+1 rows read in the request's
+// direction: descending going forward, ascending going backward.
+func NewTaskPage(p PageRequest, ts []*Task, keys []time.Time) *TaskPage {
+	more := len(ts) > p.Size()
+	if more {
+		ts, keys = ts[:p.Size()], keys[:p.Size()]
+	}
+	page := &TaskPage{Tasks: ts, Keys: keys}
+	if p.Backward {
+		for i, j := 0, len(ts)-1; i < j; i, j = i+1, j-1 {
+			ts[i], ts[j] = ts[j], ts[i]
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+		page.HasPrev, page.HasNext = more, !p.Cursor.IsZero()
+	} else {
+		page.HasPrev, page.HasNext = !p.Cursor.IsZero(), more
+	}
+	return page
+}
+
+// After and Before report on which side of c the row (at, id) falls in
+// descending order.
+func (c Cursor) After(at time.Time, id int64) bool {
+	return at.Before(c.At) || (at.Equal(c.At) && id < c.ID)
+}
+
+func (c Cursor) Before(at time.Time, id int64) bool {
+	return at.After(c.At) || (at.Equal(c.At) && id > c.ID)
+}
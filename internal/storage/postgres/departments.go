@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (d *DB) CreateDepartment(ctx context.Context, name string, createdBy *int64) (int64, error) {
+	var id int64
+	err := d.SQL.QueryRowContext(ctx,
+		`INSERT INTO departments (name, created_at, created_by) VALUES ($1, $2, $3) RETURNING id`,
+		name, storage.Now(), createdBy).Scan(&id)
+	return id, err
+}
+
+func (d *DB) ListDepartments(ctx context.Context) ([]*storage.Department, error) {
+	rows, err := d.SQL.QueryContext(ctx, `SELECT id, name, work_hours, head_id FROM departments ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.Department
+	for rows.Next() {
+		dep := &storage.Department{}
+		if err := rows.Scan(&dep.ID, &dep.Name, &dep.WorkHours, &dep.HeadID); err != nil {
+			return nil, err
+		}
+		out = append(out, dep)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) GetDepartmentByID(ctx context.Context, id int64) (*storage.Department, error) {
+	dep := &storage.Department{}
+	if err := d.SQL.QueryRowContext(ctx, `SELECT id, name, work_hours, head_id FROM departments WHERE id=$1`, id).Scan(&dep.ID, &dep.Name, &dep.WorkHours, &dep.HeadID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrNotFound
+		}
+		return nil, err
+	}
+	return dep, nil
+}
+
+func (d *DB) RenameDepartment(ctx context.Context, id int64, name string) error {
+	res, err := d.SQL.ExecContext(ctx, `UPDATE departments SET name=$1 WHERE id=$2`, name, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (d *DB) SetDepartmentWorkHours(ctx context.Context, id int64, spec string) error {
+	res, err := d.SQL.ExecContext(ctx, `UPDATE departments SET work_hours=$1 WHERE id=$2`, sql.NullString{String: spec, Valid: spec != ""}, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (d *DB) SetDepartmentHead(ctx context.Context, id, userID int64) error {
+	res, err := d.SQL.ExecContext(ctx, `UPDATE departments SET head_id=$1 WHERE id=$2`, nullID(userID), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (d *DB) DeleteDepartment(ctx context.Context, id int64) error {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// lock the row so no one joins the department while it is checked
+	var locked int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM departments WHERE id=$1 FOR UPDATE`, id).Scan(&locked); err != nil {
+		if err == sql.ErrNoRows {
+			return storage.ErrNotFound
+		}
+		return err
+	}
+	var members bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE department_id=$1)`, id).Scan(&members); err != nil {
+		return err
+	}
+	if members {
+		return storage.ErrDepartmentNotEmpty
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM departments WHERE id=$1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *DB) MoveDepartmentMembers(ctx context.Context, fromID, toID int64) (int64, error) {
+	if _, err := d.GetDepartmentByID(ctx, toID); err != nil {
+		return 0, err
+	}
+	res, err := d.SQL.ExecContext(ctx, `UPDATE users SET department_id=$1 WHERE department_id=$2`, toID, fromID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (d *DB) SaveDraft(ctx context.Context, userID int64, state string, payload any) (int64, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = d.SQL.QueryRowContext(ctx,
+		`INSERT INTO task_drafts (user_id, state, payload, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+		userID, state, string(b), storage.Now()).Scan(&id)
+	return id, err
+}
+
+func (d *DB) ListDrafts(ctx context.Context, userID int64) ([]*storage.Draft, error) {
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT id, user_id, state, payload, created_at FROM task_drafts
+		WHERE user_id = $1 ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.Draft
+	for rows.Next() {
+		dr := &storage.Draft{}
+		if err := rows.Scan(&dr.ID, &dr.UserID, &dr.State, &dr.Payload, &dr.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, dr)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) TakeDraft(ctx context.Context, id, userID int64) (*storage.Draft, error) {
+	dr := &storage.Draft{}
+	err := d.SQL.QueryRowContext(ctx, `
+		DELETE FROM task_drafts WHERE id = $1 AND user_id = $2
+		RETURNING id, user_id, state, payload, created_at`, id, userID).
+		Scan(&dr.ID, &dr.UserID, &dr.State, &dr.Payload, &dr.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dr, nil
+}
+
+func (d *DB) PurgeDrafts(ctx context.Context, before time.Time) (int64, error) {
+	res, err := d.SQL.ExecContext(ctx, `DELETE FROM task_drafts WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
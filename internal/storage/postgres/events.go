@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func addEvent(ctx context.Context, ex execer, e *storage.TaskEvent) error {
+	at := e.CreatedAt
+	if at.IsZero() {
+		at = storage.Now()
+	}
+	_, err := ex.ExecContext(ctx, `
+		INSERT INTO task_events (task_id, actor_id, user_id, kind, old_value, new_value, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		e.TaskID, e.ActorID, e.UserID, e.Kind, e.OldValue, e.NewValue, at)
+	return err
+}
+
+func (d *DB) AddTaskEvent(ctx context.Context, e *storage.TaskEvent) error {
+	return addEvent(ctx, d.SQL, e)
+}
+
+func (d *DB) ListTaskEvents(ctx context.Context, taskID int64) ([]*storage.TaskEvent, error) {
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT e.id, e.task_id, e.actor_id, e.user_id, e.kind, e.old_value, e.new_value, e.created_at,
+		       COALESCE(a.name, '@' || a.username), COALESCE(u.name, '@' || u.username)
+		FROM task_events e
+		LEFT JOIN users a ON a.id = e.actor_id
+		LEFT JOIN users u ON u.id = e.user_id
+		WHERE e.task_id = $1
+		ORDER BY e.id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*storage.TaskEvent
+	for rows.Next() {
+		e := &storage.TaskEvent{}
+		if err := rows.Scan(&e.ID, &e.TaskID, &e.ActorID, &e.UserID, &e.Kind, &e.OldValue, &e.NewValue, &e.CreatedAt,
+			&e.ActorName, &e.UserName); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func nullID(id int64) sql.NullInt64 { return sql.NullInt64{Int64: id, Valid: id != 0} }
+
+func nullText(s string) sql.NullString { return sql.NullString{String: s, Valid: s != ""} }
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (d *DB) ListAssignmentsForExport(ctx context.Context, f storage.ExportFilter) ([]*storage.AssignmentRow, error) {
+	conds := []string{"t.deleted_at IS NULL"}
+	var args []any
+	where := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if !f.From.IsZero() {
+		where("t.created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		where("t.created_at < $%d", f.To)
+	}
+	if f.DepartmentID != 0 {
+		where("u.department_id = $%d", f.DepartmentID)
+	}
+	if f.Status != "" {
+		where("ta.status = $%d", f.Status)
+	}
+	if f.CreatorID != 0 {
+		where("t.creator_id = $%d", f.CreatorID)
+	}
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT t.id, t.title, t.created_at, t.due_at, c.tg_id, c.name, c.username, u.tg_id, u.name, u.username, dp.name,
+		       ta.status, ta.updated_at,
+		       (SELECT COUNT(*) FROM task_results r WHERE r.task_id = t.id AND r.user_id = ta.user_id)
+		FROM task_assignees ta
+		JOIN tasks t ON t.id = ta.task_id
+		LEFT JOIN users c ON c.id = t.creator_id
+		LEFT JOIN users u ON u.id = ta.user_id
+		LEFT JOIN departments dp ON dp.id = u.department_id
+		WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY t.created_at, t.id, COALESCE(u.name, u.username), ta.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*storage.AssignmentRow
+	for rows.Next() {
+		r := &storage.AssignmentRow{}
+		var updated time.Time
+		if err := rows.Scan(&r.TaskID, &r.Title, &r.CreatedAt, &r.DueAt, &r.CreatorTgID, &r.CreatorName, &r.CreatorUsername,
+			&r.AssigneeTgID, &r.AssigneeName, &r.AssigneeUsername, &r.Department, &r.Status, &updated, &r.Results); err != nil {
+			return nil, err
+		}
+		if r.Status == "done" {
+			r.DoneAt.Time, r.DoneAt.Valid = updated, true
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,368 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// Migration is a single forward-only schema step, applied in its own
+// transaction and recorded in schema_migrations.
+type Migration struct {
+	Version int
+	Name    string
+	up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// migrateLockID serialises concurrent migrators via pg_advisory_lock.
+const migrateLockID = 7_242_025_001
+
+var migrations = []Migration{
+	{Version: 1, Name: "initial schema", up: migrateInitialSchema},
+	{Version: 2, Name: "task_events history", up: migrateTaskEvents},
+	{Version: 3, Name: "soft-deleted tasks", up: migrateTaskSoftDelete},
+	{Version: 4, Name: "full-text search index", up: migrateTasksSearch},
+	{Version: 5, Name: "users.department_id", up: migrateUserDepartments},
+	{Version: 6, Name: "structured task results", up: migrateResultFiles},
+	{Version: 7, Name: "task_attachments", up: migrateTaskAttachments},
+	{Version: 8, Name: "task_drafts", up: migrateTaskDrafts},
+	{Version: 9, Name: "users.timezone", up: migrateUserTimezone},
+	{Version: 10, Name: "work_hours", up: migrateWorkHours},
+	{Version: 11, Name: "escalation", up: migrateEscalation},
+	{Version: 12, Name: "recurring_tasks", up: migrateRecurringTasks},
+	{Version: 13, Name: "task_templates", up: migrateTaskTemplates},
+}
+
+func LatestVersion() int { return migrations[len(migrations)-1].Version }
+
+// Migrate brings the schema up to LatestVersion. With dryRun set nothing is
+// written; the returned slice lists the steps that would be applied.
+func Migrate(ctx context.Context, db *sql.DB, dryRun bool) ([]Migration, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrateLockID); err != nil {
+		return nil, err
+	}
+	defer func() { _, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrateLockID) }()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL
+		)`); err != nil {
+		return nil, err
+	}
+
+	var current sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&current); err != nil {
+		return nil, err
+	}
+	if int(current.Int64) > LatestVersion() {
+		return nil, fmt.Errorf("%w: db is at v%d, binary knows up to v%d", ErrSchemaTooNew, current.Int64, LatestVersion())
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if int64(m.Version) > current.Int64 {
+			pending = append(pending, m)
+		}
+	}
+	if dryRun {
+		return pending, nil
+	}
+
+	for _, m := range pending {
+		if err := applyMigration(ctx, conn, m); err != nil {
+			return nil, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return pending, nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, m Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := m.up(ctx, tx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+		m.Version, m.Name, storage.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func execAll(ctx context.Context, tx *sql.Tx, stmts ...string) error {
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateInitialSchema(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE users (
+			id BIGSERIAL PRIMARY KEY,
+			tg_id BIGINT UNIQUE NOT NULL,
+			username TEXT,
+			role TEXT NOT NULL,
+			name TEXT,
+			team TEXT,
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+
+		`CREATE TABLE tasks (
+			id BIGSERIAL PRIMARY KEY,
+			creator_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			title TEXT,
+			description TEXT,
+			voice_file_id TEXT,
+			due_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL
+		)`,
+
+		`CREATE TABLE task_assignees (
+			id BIGSERIAL PRIMARY KEY,
+			task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+			status TEXT NOT NULL DEFAULT 'new',
+			updated_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE UNIQUE INDEX idx_task_assignees_unique
+			ON task_assignees(task_id, user_id)
+			WHERE user_id IS NOT NULL`,
+
+		`CREATE TABLE reminders (
+			id BIGSERIAL PRIMARY KEY,
+			task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			at TIMESTAMPTZ NOT NULL,
+			kind TEXT NOT NULL,
+			sent BOOLEAN NOT NULL DEFAULT FALSE
+		)`,
+		`CREATE INDEX idx_reminders_due ON reminders(at) WHERE NOT sent`,
+
+		`CREATE TABLE task_results (
+			id BIGSERIAL PRIMARY KEY,
+			task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			text TEXT,
+			file_id TEXT,
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+
+		`CREATE TABLE user_states (
+			user_id BIGINT PRIMARY KEY,
+			state TEXT NOT NULL,
+			payload JSONB,
+			updated_at TIMESTAMPTZ NOT NULL
+		)`,
+
+		`CREATE TABLE departments (
+			id BIGSERIAL PRIMARY KEY,
+			name TEXT UNIQUE NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			created_by BIGINT
+		)`,
+	)
+}
+
+func migrateTaskEvents(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE task_events (
+			id BIGSERIAL PRIMARY KEY,
+			task_id BIGINT NOT NULL,
+			actor_id BIGINT,
+			user_id BIGINT,
+			kind TEXT NOT NULL,
+			old_value TEXT,
+			new_value TEXT,
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE INDEX idx_task_events_task ON task_events(task_id, id)`,
+	)
+}
+
+func migrateTaskSoftDelete(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`ALTER TABLE tasks
+			ADD COLUMN deleted_at TIMESTAMPTZ,
+			ADD COLUMN deleted_by BIGINT REFERENCES users(id) ON DELETE SET NULL`,
+		`CREATE INDEX idx_tasks_deleted ON tasks(deleted_at) WHERE deleted_at IS NOT NULL`,
+	)
+}
+
+// migrateTasksSearch keeps the text of a task's results in tasks.results_text
+// and indexes title, description and results in a generated tsvector, with ё
+// folded to е as storage.SearchTerms does.
+func migrateTasksSearch(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`ALTER TABLE tasks ADD COLUMN results_text TEXT NOT NULL DEFAULT ''`,
+		`UPDATE tasks t SET results_text = r.txt
+			FROM (SELECT task_id, string_agg(text, ' ' ORDER BY id) AS txt
+			      FROM task_results WHERE text IS NOT NULL GROUP BY task_id) r
+			WHERE r.task_id = t.id`,
+		`ALTER TABLE tasks ADD COLUMN search tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('russian', translate(coalesce(title, ''), 'ёЁ', 'еЕ')), 'A') ||
+			setweight(to_tsvector('russian', translate(coalesce(description, ''), 'ёЁ', 'еЕ')), 'B') ||
+			setweight(to_tsvector('russian', translate(results_text, 'ёЁ', 'еЕ')), 'C')
+		) STORED`,
+		`CREATE INDEX idx_tasks_search ON tasks USING GIN (search)`,
+		`CREATE FUNCTION task_results_search() RETURNS trigger AS $$
+		DECLARE
+			tid BIGINT := CASE WHEN TG_OP = 'DELETE' THEN OLD.task_id ELSE NEW.task_id END;
+		BEGIN
+			UPDATE tasks SET results_text = coalesce(
+				(SELECT string_agg(text, ' ' ORDER BY id) FROM task_results WHERE task_id = tid AND text IS NOT NULL), '')
+			WHERE id = tid;
+			RETURN NULL;
+		END $$ LANGUAGE plpgsql`,
+		`CREATE TRIGGER task_results_search AFTER INSERT OR DELETE ON task_results
+			FOR EACH ROW EXECUTE FUNCTION task_results_search()`,
+	)
+}
+
+// migrateUserDepartments replaces the free-text users.team with a foreign key
+// to departments, creating departments for team names that had none.
+func migrateUserDepartments(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO departments (name, created_at)
+		SELECT DISTINCT team, $1::timestamptz FROM users WHERE team IS NOT NULL AND team <> ''
+		ON CONFLICT (name) DO NOTHING`, storage.Now()); err != nil {
+		return err
+	}
+	return execAll(ctx, tx,
+		`ALTER TABLE users ADD COLUMN department_id BIGINT REFERENCES departments(id)`,
+		`UPDATE users u SET department_id = dp.id FROM departments dp WHERE dp.name = u.team`,
+		`ALTER TABLE users DROP COLUMN team`,
+		`CREATE INDEX idx_users_department ON users(department_id)`,
+	)
+}
+
+// migrateResultFiles records what kind of file a result is; older rows keep
+// a NULL kind.
+func migrateResultFiles(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`ALTER TABLE task_results
+			ADD COLUMN kind TEXT,
+			ADD COLUMN caption TEXT,
+			ADD COLUMN file_name TEXT,
+			ADD COLUMN file_size BIGINT,
+			ADD COLUMN mime_type TEXT`,
+	)
+}
+
+// migrateTaskAttachments adds task_attachments and copies the single voice
+// note of older tasks into it.
+func migrateTaskAttachments(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE task_attachments (
+			id BIGSERIAL PRIMARY KEY,
+			task_id BIGINT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			kind TEXT NOT NULL,
+			file_id TEXT NOT NULL,
+			file_name TEXT,
+			caption TEXT
+		)`,
+		`CREATE INDEX idx_task_attachments_task ON task_attachments(task_id)`,
+		`INSERT INTO task_attachments (task_id, kind, file_id)
+			SELECT id, 'voice', voice_file_id FROM tasks WHERE voice_file_id IS NOT NULL AND voice_file_id <> ''`,
+	)
+}
+
+// migrateTaskDrafts adds task_drafts for conversations put aside by the
+// state sweeper or by switching to another command.
+func migrateTaskDrafts(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE task_drafts (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			state TEXT NOT NULL,
+			payload JSONB,
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE INDEX idx_task_drafts_user ON task_drafts(user_id)`,
+		`CREATE INDEX idx_user_states_updated ON user_states(updated_at)`,
+	)
+}
+
+// migrateUserTimezone adds the IANA zone a user reads times in. NULL means
+// the bot's configured zone.
+func migrateUserTimezone(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx, `ALTER TABLE users ADD COLUMN timezone TEXT`)
+}
+
+// migrateWorkHours adds working hours to departments and, as an override,
+// to users. NULL falls back to the department, then to the bot's default.
+func migrateWorkHours(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`ALTER TABLE departments ADD COLUMN work_hours TEXT`,
+		`ALTER TABLE users ADD COLUMN work_hours TEXT`,
+	)
+}
+
+// migrateEscalation adds department heads and the time escalation of an
+// assignment last fired. Assignments already overdue count as escalated so
+// the upgrade does not flood every recipient at once, and the fixed overdue
+// reminders give way to the escalation policy.
+func migrateEscalation(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`ALTER TABLE departments ADD COLUMN head_id BIGINT REFERENCES users(id) ON DELETE SET NULL`,
+		`ALTER TABLE task_assignees ADD COLUMN escalated_at TIMESTAMPTZ`,
+		`UPDATE task_assignees SET escalated_at = CURRENT_TIMESTAMP
+			WHERE status IN ('new', 'in_progress')
+			  AND task_id IN (SELECT id FROM tasks WHERE due_at < CURRENT_TIMESTAMP)`,
+		`DELETE FROM reminders WHERE kind = 'overdue' AND NOT sent`,
+	)
+}
+
+// migrateRecurringTasks adds recurring_tasks: /newtask drafts the scheduler
+// turns into a task on every occurrence of their schedule.
+func migrateRecurringTasks(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE recurring_tasks (
+			id BIGSERIAL PRIMARY KEY,
+			creator_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			title TEXT NOT NULL,
+			schedule TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			next_at TIMESTAMPTZ,
+			created_count INTEGER NOT NULL DEFAULT 0,
+			paused BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE INDEX idx_recurring_tasks_next ON recurring_tasks(next_at) WHERE NOT paused`,
+	)
+}
+
+// migrateTaskTemplates adds task_templates: /newtask drafts saved to start
+// similar tasks from.
+func migrateTaskTemplates(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE task_templates (
+			id BIGSERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			creator_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			payload JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL
+		)`,
+	)
+}
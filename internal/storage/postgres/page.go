@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// keyset returns the condition selecting the rows on the requested side of
+// p.Cursor for a list ordered by (key, id) descending, and the ORDER BY and
+// LIMIT to read them with. Placeholders are numbered from $n; the returned
+// args (limit included) follow the query's own.
+func keyset(key, id string, p storage.PageRequest, n int) (cond, order string, args []any) {
+	cmp, dir := "<", "DESC"
+	if p.Backward {
+		cmp, dir = ">", "ASC"
+	}
+	cond = `TRUE`
+	if !p.Cursor.IsZero() {
+		cond = fmt.Sprintf(`(%s, %s) %s ($%d, $%d)`, key, id, cmp, n, n+1)
+		args = []any{p.Cursor.At, p.Cursor.ID}
+		n += 2
+	}
+	order = fmt.Sprintf(` ORDER BY %s %s, %s %s LIMIT $%d`, key, dir, id, dir, n)
+	return cond, order, append(args, p.Size()+1)
+}
+
+func (d *DB) queryTaskPage(ctx context.Context, p storage.PageRequest, query string, args ...any) (*storage.TaskPage, error) {
+	ts, err := d.queryTasks(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]time.Time, len(ts))
+	for i, t := range ts {
+		keys[i] = t.CreatedAt
+	}
+	return storage.NewTaskPage(p, ts, keys), nil
+}
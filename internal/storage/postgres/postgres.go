@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+type DB struct {
+	SQL *sql.DB
+}
+
+var _ storage.Store = (*DB)(nil)
+
+// Open connects to the database described by dsn (a libpq URL or key=value
+// string) and applies pending migrations.
+func Open(dsn string) (*DB, error) {
+	s, err := open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := Migrate(context.Background(), s, false); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return &DB{SQL: s}, nil
+}
+
+// PendingMigrations reports the migrations Open would apply, without
+// changing anything.
+func PendingMigrations(dsn string) ([]Migration, error) {
+	s, err := open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	return Migrate(context.Background(), s, true)
+}
+
+func open(dsn string) (*sql.DB, error) {
+	s, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	s.SetMaxOpenConns(10)
+	s.SetMaxIdleConns(5)
+	s.SetConnMaxIdleTime(5 * time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := s.PingContext(ctx); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
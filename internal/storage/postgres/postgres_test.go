@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+	"github.com/hihikaAAa/task-manager/internal/storage/storetest"
+)
+
+// The tests need a server and are skipped unless TEST_DB_DSN points at a
+// database they may create schemas in, e.g.
+//
+//	TEST_DB_DSN=postgres://postgres@localhost/postgres?sslmode=disable go test ./internal/storage/postgres
+const testDSNEnv = "TEST_DB_DSN"
+
+// testDSN creates an empty schema for t, dropped when t ends, and returns
+// the DSN with it as the search path.
+func testDSN(t *testing.T) string {
+	t.Helper()
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skip(testDSNEnv + " is not set")
+	}
+	admin, err := open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("storetest_%d_%d", os.Getpid(), time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	if strings.Contains(dsn, "://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "search_path=" + schema
+	}
+	return dsn + " search_path=" + schema
+}
+
+func openTest(t *testing.T, dsn string) *DB {
+	t.Helper()
+	db, err := Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.SQL.Close() })
+	return db
+}
+
+func TestStore(t *testing.T) {
+	if os.Getenv(testDSNEnv) == "" {
+		t.Skip(testDSNEnv + " is not set")
+	}
+	storetest.Run(t, func(t *testing.T) storage.Store { return openTest(t, testDSN(t)) })
+}
+
+func TestMigrate(t *testing.T) {
+	dsn := testDSN(t)
+
+	pending, err := PendingMigrations(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != LatestVersion() {
+		t.Fatalf("empty schema has %d pending migrations, want %d", len(pending), LatestVersion())
+	}
+
+	db := openTest(t, dsn)
+	var versions []int
+	rows, err := db.SQL.Query(`SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, v)
+	}
+	rows.Close()
+	for i, v := range versions {
+		if v != i+1 {
+			t.Fatalf("applied versions = %v", versions)
+		}
+	}
+	if len(versions) != LatestVersion() {
+		t.Fatalf("applied versions = %v, want 1..%d", versions, LatestVersion())
+	}
+
+	if pending, err = PendingMigrations(dsn); err != nil || len(pending) != 0 {
+		t.Fatalf("pending after Open = %v, %v", pending, err)
+	}
+	// reopening an up-to-date schema changes nothing
+	openTest(t, dsn)
+
+	if _, err := db.SQL.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, 'from the future', now())`, LatestVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dsn); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("Open of a newer schema: %v, want ErrSchemaTooNew", err)
+	}
+}
+
+// TestMigrateConcurrently starts several migrators on one empty schema; the
+// advisory lock lets one apply everything and the rest find nothing to do.
+func TestMigrateConcurrently(t *testing.T) {
+	dsn := testDSN(t)
+	const n = 4
+	dbs := make([]*sql.DB, n)
+	applied := make([]int, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		s, err := open(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbs[i] = s
+		t.Cleanup(func() { s.Close() })
+	}
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var ms []Migration
+			ms, errs[i] = Migrate(context.Background(), dbs[i], false)
+			applied[i] = len(ms)
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range n {
+		if errs[i] != nil {
+			t.Fatalf("migrator %d: %v", i, errs[i])
+		}
+		total += applied[i]
+	}
+	if total != LatestVersion() {
+		t.Fatalf("migrators applied %v, want %d in all", applied, LatestVersion())
+	}
+	var rows int
+	if err := dbs[0].QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != LatestVersion() {
+		t.Fatalf("schema_migrations has %d rows, want %d", rows, LatestVersion())
+	}
+}
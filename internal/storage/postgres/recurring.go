@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+const recurringColumns = `id, creator_id, title, schedule, payload, next_at, created_count, paused, created_at`
+
+func scanRecurring(row interface{ Scan(...any) error }) (*storage.Recurring, error) {
+	r := &storage.Recurring{}
+	err := row.Scan(&r.ID, &r.CreatorID, &r.Title, &r.Schedule, &r.Payload, &r.NextAt, &r.Created, &r.Paused, &r.CreatedAt)
+	return r, err
+}
+
+func (d *DB) CreateRecurring(ctx context.Context, r *storage.Recurring) (int64, error) {
+	var id int64
+	err := d.SQL.QueryRowContext(ctx, `
+		INSERT INTO recurring_tasks (creator_id, title, schedule, payload, next_at, created_count, paused, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		r.CreatorID, r.Title, r.Schedule, string(r.Payload), r.NextAt, r.Created, r.Paused, storage.Now()).Scan(&id)
+	return id, err
+}
+
+func (d *DB) GetRecurring(ctx context.Context, id int64) (*storage.Recurring, error) {
+	r, err := scanRecurring(d.SQL.QueryRowContext(ctx, `SELECT `+recurringColumns+` FROM recurring_tasks WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	return r, err
+}
+
+func (d *DB) ListRecurring(ctx context.Context) ([]*storage.Recurring, error) {
+	return d.queryRecurring(ctx, `SELECT `+recurringColumns+` FROM recurring_tasks ORDER BY id`)
+}
+
+func (d *DB) ListDueRecurring(ctx context.Context, now time.Time) ([]*storage.Recurring, error) {
+	return d.queryRecurring(ctx, `SELECT `+recurringColumns+` FROM recurring_tasks
+		WHERE NOT paused AND next_at <= $1 ORDER BY next_at, id`, now)
+}
+
+func (d *DB) queryRecurring(ctx context.Context, q string, args ...any) ([]*storage.Recurring, error) {
+	rows, err := d.SQL.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.Recurring
+	for rows.Next() {
+		r, err := scanRecurring(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) SaveRecurring(ctx context.Context, r *storage.Recurring) error {
+	res, err := d.SQL.ExecContext(ctx, `UPDATE recurring_tasks SET next_at = $1, created_count = $2, paused = $3 WHERE id = $4`,
+		r.NextAt, r.Created, r.Paused, r.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func (d *DB) DeleteRecurring(ctx context.Context, id int64) error {
+	res, err := d.SQL.ExecContext(ctx, `DELETE FROM recurring_tasks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
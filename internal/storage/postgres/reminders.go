@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (d *DB) ListDueReminders(ctx context.Context, until time.Time) ([]*storage.Reminder, error) {
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT r.id, r.task_id, r.user_id, r.at, r.kind
+		FROM reminders r
+		JOIN tasks t ON t.id = r.task_id
+		WHERE NOT r.sent AND r.at<=$1 AND t.deleted_at IS NULL
+		ORDER BY r.at`, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*storage.Reminder
+	for rows.Next() {
+		r := &storage.Reminder{}
+		if err := rows.Scan(&r.ID, &r.TaskID, &r.UserID, &r.At, &r.Kind); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) ListTaskReminders(ctx context.Context, taskID int64) ([]*storage.Reminder, error) {
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT id, task_id, user_id, at, kind FROM reminders WHERE task_id=$1 ORDER BY at, id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*storage.Reminder
+	for rows.Next() {
+		r := &storage.Reminder{}
+		if err := rows.Scan(&r.ID, &r.TaskID, &r.UserID, &r.At, &r.Kind); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) MarkReminderSent(ctx context.Context, id int64) error {
+	_, err := d.SQL.ExecContext(ctx, `UPDATE reminders SET sent=TRUE WHERE id=$1`, id)
+	return err
+}
+
+func (d *DB) DeferReminder(ctx context.Context, id int64, at time.Time) error {
+	_, err := d.SQL.ExecContext(ctx, `UPDATE reminders SET at=$1 WHERE id=$2 AND sent=FALSE`, at, id)
+	return err
+}
+
+func (d *DB) SnoozeReminder(ctx context.Context, id, userID int64, at time.Time) (*storage.Reminder, error) {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	r := &storage.Reminder{At: at}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO reminders (task_id, user_id, at, kind, sent)
+		SELECT task_id, user_id, $1, kind, FALSE FROM reminders WHERE id=$2 AND user_id=$3
+		RETURNING id, task_id, user_id, kind`, at, id, userID).Scan(&r.ID, &r.TaskID, &r.UserID, &r.Kind)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := addEvent(ctx, tx, &storage.TaskEvent{
+		TaskID: r.TaskID, ActorID: nullID(userID), UserID: nullID(userID), Kind: storage.EventSnoozed,
+		NewValue: storage.FormatDue(sql.NullTime{Time: at, Valid: true}),
+	}); err != nil {
+		return nil, err
+	}
+	return r, tx.Commit()
+}
+
+func (d *DB) MarkAllRemindersSentFor(ctx context.Context, taskID, userID int64) error {
+	_, err := d.SQL.ExecContext(ctx, `
+		UPDATE reminders SET sent=TRUE
+		WHERE task_id=$1 AND user_id=$2 AND NOT sent`, taskID, userID)
+	return err
+}
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// tsQuery turns search terms into a to_tsquery expression matching all of
+// them as prefixes. Terms are letters and digits only, so no escaping.
+func tsQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = t + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+func (d *DB) SearchTasks(ctx context.Context, q string, assigneeID int64, limit int) ([]*storage.SearchHit, error) {
+	terms := storage.SearchTerms(q)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT `+taskColumns+`,
+		       ts_headline('russian', translate(concat_ws(' ', t.title, t.description, t.results_text), 'ёЁ', 'еЕ'), q,
+		                   'StartSel='||$2||', StopSel='||$3||', MaxWords=14, MinWords=5')
+		FROM tasks t, to_tsquery('russian', $1) q
+		WHERE t.search @@ q AND t.deleted_at IS NULL
+		  AND ($4 = 0 OR EXISTS (SELECT 1 FROM task_assignees ta WHERE ta.task_id = t.id AND ta.user_id = $4))
+		ORDER BY ts_rank(t.search, q) DESC, t.created_at DESC
+		LIMIT $5`,
+		tsQuery(terms), storage.SnippetOpen, storage.SnippetClose, assigneeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*storage.SearchHit
+	for rows.Next() {
+		h := &storage.SearchHit{}
+		if h.Task, err = scanTask(rows, &h.Snippet); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (d *DB) SaveState(ctx context.Context, userID int64, state string, payload any) error {
+	var p *string
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		s := string(b)
+		p = &s
+	}
+	_, err := d.SQL.ExecContext(ctx, `
+		INSERT INTO user_states (user_id, state, payload, updated_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET state=excluded.state, payload=excluded.payload, updated_at=excluded.updated_at`,
+		userID, state, p, storage.Now())
+	return err
+}
+
+func (d *DB) LoadState(ctx context.Context, userID int64, dst any) (string, error) {
+	var state string
+	var payload []byte
+	if err := d.SQL.QueryRowContext(ctx, `SELECT state, payload FROM user_states WHERE user_id=$1`, userID).Scan(&state, &payload); err != nil {
+		return "", err
+	}
+	if dst != nil && len(payload) > 0 {
+		_ = json.Unmarshal(payload, dst)
+	}
+	return state, nil
+}
+
+func (d *DB) ClearState(ctx context.Context, userID int64) error {
+	_, err := d.SQL.ExecContext(ctx, `DELETE FROM user_states WHERE user_id=$1`, userID)
+	return err
+}
+
+func (d *DB) ExpireStates(ctx context.Context, before time.Time) ([]*storage.State, error) {
+	rows, err := d.SQL.QueryContext(ctx,
+		`DELETE FROM user_states WHERE updated_at < $1 RETURNING user_id, state, payload, updated_at`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.State
+	for rows.Next() {
+		st := &storage.State{}
+		if err := rows.Scan(&st.UserID, &st.State, &st.Payload, &st.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,615 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+const taskColumns = `t.id, t.creator_id, t.title, t.description, t.voice_file_id, t.due_at, t.created_at, t.updated_at, t.deleted_at, t.deleted_by`
+
+func scanTask(row interface{ Scan(...any) error }, extra ...any) (*storage.Task, error) {
+	t := &storage.Task{}
+	dst := append([]any{&t.ID, &t.CreatorID, &t.Title, &t.Description, &t.VoiceFileID, &t.DueAt, &t.CreatedAt, &t.UpdatedAt, &t.DeletedAt, &t.DeletedBy}, extra...)
+	if err := row.Scan(dst...); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (d *DB) queryTasks(ctx context.Context, query string, args ...any) ([]*storage.Task, error) {
+	rows, err := d.SQL.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.Task
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) queryDoneTasks(ctx context.Context, p storage.PageRequest, query string, args ...any) (*storage.TaskPage, error) {
+	rows, err := d.SQL.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ts []*storage.Task
+	var comps []time.Time
+	for rows.Next() {
+		var comp time.Time
+		t, err := scanTask(rows, &comp)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+		comps = append(comps, comp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return storage.NewTaskPage(p, ts, comps), nil
+}
+
+func (d *DB) CreateTask(ctx context.Context, in storage.NewTask) (*storage.CreatedTask, error) {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	out, err := createTaskTx(ctx, tx, in)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (d *DB) CreateTasks(ctx context.Context, in []storage.NewTask) ([]*storage.CreatedTask, error) {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	out := make([]*storage.CreatedTask, 0, len(in))
+	for _, nt := range in {
+		c, err := createTaskTx(ctx, tx, nt)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func createTaskTx(ctx context.Context, tx *sql.Tx, in storage.NewTask) (*storage.CreatedTask, error) {
+	now := storage.Now()
+	t := *in.Task
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO tasks (creator_id, title, description, voice_file_id, due_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		t.CreatorID, t.Title, t.Description, t.VoiceFileID, t.DueAt, now, now).Scan(&t.ID)
+	if err != nil {
+		return nil, err
+	}
+	t.CreatedAt, t.UpdatedAt = now, now
+
+	if err := addEvent(ctx, tx, &storage.TaskEvent{TaskID: t.ID, ActorID: nullID(t.CreatorID), Kind: storage.EventCreated, NewValue: t.Title, CreatedAt: now}); err != nil {
+		return nil, err
+	}
+	for _, uid := range in.AssigneeIDs {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO task_assignees (task_id, user_id, status, updated_at) VALUES ($1, $2, 'new', $3)`,
+			t.ID, uid, now); err != nil {
+			return nil, err
+		}
+		if err := addEvent(ctx, tx, &storage.TaskEvent{TaskID: t.ID, ActorID: nullID(t.CreatorID), UserID: nullID(uid), Kind: storage.EventAssigned, CreatedAt: now}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, a := range in.Attachments {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO task_attachments (task_id, kind, file_id, file_name, caption) VALUES ($1, $2, $3, $4, $5)`,
+			t.ID, a.Kind, a.FileID, a.FileName, a.Caption); err != nil {
+			return nil, err
+		}
+	}
+
+	n := 0
+	for _, uid := range in.AssigneeIDs {
+		for _, r := range in.Reminders {
+			if r.UserID != 0 && r.UserID != uid {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO reminders (task_id, user_id, at, kind, sent) VALUES ($1, $2, $3, $4, FALSE)`,
+				t.ID, uid, r.At, r.Kind); err != nil {
+				return nil, err
+			}
+			n++
+		}
+	}
+	return &storage.CreatedTask{Task: &t, AssigneeIDs: in.AssigneeIDs, Reminders: n}, nil
+}
+
+func (d *DB) UpdateAssigneeStatus(ctx context.Context, taskID, userID int64, status string) (bool, error) {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var old string
+	err = tx.QueryRowContext(ctx,
+		`SELECT status FROM task_assignees WHERE task_id=$1 AND user_id=$2 FOR UPDATE`, taskID, userID).Scan(&old)
+	if err == sql.ErrNoRows || (err == nil && old == status) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	now := storage.Now()
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE task_assignees SET status=$1, updated_at=$2 WHERE task_id=$3 AND user_id=$4`,
+		status, now, taskID, userID); err != nil {
+		return false, err
+	}
+	if err := addEvent(ctx, tx, &storage.TaskEvent{
+		TaskID: taskID, ActorID: nullID(userID), UserID: nullID(userID), Kind: storage.EventStatus,
+		OldValue: nullText(old), NewValue: nullText(status), CreatedAt: now,
+	}); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (d *DB) UpdateTaskDeadline(ctx context.Context, taskID, actorID int64, due sql.NullTime) error {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var old sql.NullTime
+	if err := tx.QueryRowContext(ctx, `SELECT due_at FROM tasks WHERE id=$1 FOR UPDATE`, taskID).Scan(&old); err != nil {
+		return err
+	}
+
+	now := storage.Now()
+	if _, err := tx.ExecContext(ctx, `UPDATE tasks SET due_at=$1, updated_at=$2 WHERE id=$3`, due, now, taskID); err != nil {
+		return err
+	}
+
+	// a new deadline starts escalation over
+	if _, err := tx.ExecContext(ctx, `UPDATE task_assignees SET escalated_at=NULL WHERE task_id=$1`, taskID); err != nil {
+		return err
+	}
+
+	switch {
+	case !due.Valid:
+		_, err = tx.ExecContext(ctx, `DELETE FROM reminders WHERE task_id=$1 AND NOT sent`, taskID)
+	case old.Valid:
+		_, err = tx.ExecContext(ctx,
+			`UPDATE reminders SET at = at + $1 * INTERVAL '1 microsecond' WHERE task_id=$2 AND NOT sent`,
+			due.Time.Sub(old.Time).Microseconds(), taskID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := addEvent(ctx, tx, &storage.TaskEvent{
+		TaskID: taskID, ActorID: nullID(actorID), Kind: storage.EventDeadline,
+		OldValue: storage.FormatDue(old), NewValue: storage.FormatDue(due), CreatedAt: now,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *DB) GetTask(ctx context.Context, id int64) (*storage.Task, error) {
+	return scanTask(d.SQL.QueryRowContext(ctx, `SELECT `+taskColumns+` FROM tasks t WHERE t.id=$1`, id))
+}
+
+func (d *DB) ListActiveTasksForBoss(ctx context.Context, p storage.PageRequest) (*storage.TaskPage, error) {
+	cond, order, args := keyset("t.created_at", "t.id", p, 1)
+	return d.queryTaskPage(ctx, p, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		LEFT JOIN task_assignees ta ON ta.task_id = t.id
+		WHERE t.deleted_at IS NULL AND `+cond+`
+		GROUP BY t.id
+		HAVING COUNT(ta.id)=0
+		   OR SUM(CASE WHEN ta.status<>'done' THEN 1 ELSE 0 END) > 0`+order, args...)
+}
+
+func (d *DB) ListActiveTasksForUser(ctx context.Context, userID int64, p storage.PageRequest) (*storage.TaskPage, error) {
+	cond, order, args := keyset("t.created_at", "t.id", p, 2)
+	return d.queryTaskPage(ctx, p, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		JOIN task_assignees ta ON ta.task_id = t.id
+		WHERE ta.user_id=$1 AND ta.status <> 'done' AND t.deleted_at IS NULL AND `+cond+order,
+		append([]any{userID}, args...)...)
+}
+
+func (d *DB) ListActiveTasksForDepartment(ctx context.Context, deptID int64, p storage.PageRequest) (*storage.TaskPage, error) {
+	cond, order, args := keyset("t.created_at", "t.id", p, 2)
+	return d.queryTaskPage(ctx, p, `
+		SELECT DISTINCT `+taskColumns+`
+		FROM tasks t
+		JOIN task_assignees ta ON ta.task_id = t.id
+		JOIN users u ON u.id = ta.user_id
+		WHERE u.department_id = $1 AND ta.status <> 'done' AND t.deleted_at IS NULL AND `+cond+order,
+		append([]any{deptID}, args...)...)
+}
+
+func (d *DB) GetAssignees(ctx context.Context, taskID int64) ([]*storage.TaskAssignee, error) {
+	rows, err := d.SQL.QueryContext(ctx, `SELECT id, task_id, user_id, status, updated_at FROM task_assignees WHERE task_id=$1`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.TaskAssignee
+	for rows.Next() {
+		a := &storage.TaskAssignee{}
+		if err := rows.Scan(&a.ID, &a.TaskID, &a.UserID, &a.Status, &a.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) ListAssigneesWithUsers(ctx context.Context, taskID int64) ([]*storage.AssigneeRow, error) {
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT u.tg_id, u.name, u.username, dp.name, ta.status
+		FROM task_assignees ta
+		JOIN users u ON u.id = ta.user_id
+		LEFT JOIN departments dp ON dp.id = u.department_id
+		WHERE ta.task_id = $1
+		ORDER BY dp.name NULLS FIRST, u.name NULLS FIRST`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.AssigneeRow
+	for rows.Next() {
+		r := &storage.AssigneeRow{}
+		if err := rows.Scan(&r.TgID, &r.Name, &r.Username, &r.Team, &r.Status); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) CreateReminders(ctx context.Context, taskID int64, userIDs []int64, reminderTimes []time.Time, kind string) error {
+	for _, uid := range userIDs {
+		for _, at := range reminderTimes {
+			if _, err := d.SQL.ExecContext(ctx,
+				`INSERT INTO reminders (task_id, user_id, at, kind, sent) VALUES ($1, $2, $3, $4, FALSE)`,
+				taskID, uid, at, kind); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *DB) DueAtForTask(ctx context.Context, taskID int64) (time.Time, bool, error) {
+	var due sql.NullTime
+	if err := d.SQL.QueryRowContext(ctx, `SELECT due_at FROM tasks WHERE id=$1`, taskID).Scan(&due); err != nil {
+		return time.Time{}, false, err
+	}
+	return due.Time, due.Valid, nil
+}
+
+func (d *DB) AddResult(ctx context.Context, r *storage.TaskResult) error {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := storage.Now()
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO task_results (task_id, user_id, text, kind, file_id, caption, file_name, file_size, mime_type, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		r.TaskID, r.UserID, r.Text, r.Kind, r.FileID, r.Caption, r.FileName, r.FileSize, r.MimeType, now).Scan(&r.ID); err != nil {
+		return err
+	}
+	r.CreatedAt = now
+	if err := addEvent(ctx, tx, &storage.TaskEvent{
+		TaskID: r.TaskID, ActorID: nullID(r.UserID), UserID: nullID(r.UserID), Kind: storage.EventResult,
+		NewValue: storage.ResultSummary(r), CreatedAt: now,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *DB) ListResults(ctx context.Context, taskID int64) ([]*storage.TaskResult, error) {
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT id, task_id, user_id, text, kind, file_id, caption, file_name, file_size, mime_type, created_at
+		FROM task_results WHERE task_id=$1 ORDER BY created_at, id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.TaskResult
+	for rows.Next() {
+		r := &storage.TaskResult{}
+		if err := rows.Scan(&r.ID, &r.TaskID, &r.UserID, &r.Text, &r.Kind, &r.FileID, &r.Caption, &r.FileName, &r.FileSize, &r.MimeType, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) ListAttachments(ctx context.Context, taskID int64) ([]*storage.Attachment, error) {
+	rows, err := d.SQL.QueryContext(ctx,
+		`SELECT id, task_id, kind, file_id, file_name, caption FROM task_attachments WHERE task_id=$1 ORDER BY id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.Attachment
+	for rows.Next() {
+		a := &storage.Attachment{}
+		if err := rows.Scan(&a.ID, &a.TaskID, &a.Kind, &a.FileID, &a.FileName, &a.Caption); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) SearchWorkers(ctx context.Context, q string) ([]*storage.User, error) {
+	q = strings.ToLower(q)
+	return d.queryUsers(ctx, `
+		SELECT `+userColumns+`
+		FROM `+userTables+`
+		WHERE u.role='worker' AND (
+			lower(COALESCE(u.username,'')) LIKE '%' || $1 || '%'
+			OR lower(COALESCE(u.name,'')) LIKE '%' || $1 || '%'
+			OR lower(COALESCE(dp.name,'')) LIKE '%' || $1 || '%'
+		)
+		ORDER BY dp.name NULLS FIRST, u.name NULLS FIRST`, q)
+}
+
+func (d *DB) HasResult(ctx context.Context, taskID, userID int64) (bool, error) {
+	var one int
+	err := d.SQL.QueryRowContext(ctx, `SELECT 1 FROM task_results WHERE task_id=$1 AND user_id=$2 LIMIT 1`, taskID, userID).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *DB) ListDoneTasksForBoss(ctx context.Context, creatorID int64, p storage.PageRequest) (*storage.TaskPage, error) {
+	cond, order, args := keyset("MAX(ta.updated_at)", "t.id", p, 2)
+	return d.queryDoneTasks(ctx, p, `
+		SELECT `+taskColumns+`, MAX(ta.updated_at) AS completed_at
+		FROM tasks t
+		JOIN task_assignees ta ON ta.task_id = t.id AND ta.status='done'
+		WHERE t.creator_id = $1 AND t.deleted_at IS NULL
+		GROUP BY t.id
+		HAVING `+cond+order, append([]any{creatorID}, args...)...)
+}
+
+func (d *DB) ListDoneTasksForUser(ctx context.Context, userID int64, p storage.PageRequest) (*storage.TaskPage, error) {
+	cond, order, args := keyset("ta.updated_at", "t.id", p, 2)
+	return d.queryDoneTasks(ctx, p, `
+		SELECT `+taskColumns+`, ta.updated_at AS completed_at
+		FROM tasks t
+		JOIN task_assignees ta ON ta.task_id = t.id
+		WHERE ta.user_id = $1 AND ta.status='done' AND t.deleted_at IS NULL AND `+cond+order,
+		append([]any{userID}, args...)...)
+}
+
+func (d *DB) ListTasksWithoutAssignees(ctx context.Context) ([]*storage.Task, error) {
+	return d.queryTasks(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		LEFT JOIN task_assignees ta ON ta.task_id = t.id
+		WHERE t.deleted_at IS NULL
+		GROUP BY t.id
+		HAVING COUNT(ta.id)=0
+		ORDER BY t.created_at DESC`)
+}
+
+func (d *DB) ListAssigneesWithUsersAny(ctx context.Context, taskID int64) ([]*storage.AssigneeWithUser, error) {
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT ta.user_id, ta.status, u.name, u.username, dp.name, u.tg_id
+		FROM task_assignees ta
+		LEFT JOIN users u ON u.id = ta.user_id
+		LEFT JOIN departments dp ON dp.id = u.department_id
+		WHERE ta.task_id = $1
+		ORDER BY COALESCE(u.name, u.username) NULLS FIRST`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.AssigneeWithUser
+	for rows.Next() {
+		r := &storage.AssigneeWithUser{}
+		if err := rows.Scan(&r.UserID, &r.Status, &r.Name, &r.Username, &r.Team, &r.TgID); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) IsAssigneeDone(ctx context.Context, taskID, userID int64) (bool, error) {
+	var st string
+	err := d.SQL.QueryRowContext(ctx,
+		`SELECT status FROM task_assignees WHERE task_id=$1 AND user_id=$2`, taskID, userID).Scan(&st)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return st == "done", nil
+}
+
+func (d *DB) ListAllTasks(ctx context.Context) ([]*storage.Task, error) {
+	return d.queryTasks(ctx, `SELECT `+taskColumns+` FROM tasks t WHERE t.deleted_at IS NULL ORDER BY t.id`)
+}
+
+func (d *DB) DeleteTask(ctx context.Context, taskID, actorID int64) (int64, error) {
+	return d.deleteTasks(ctx, actorID, `id=$4`, taskID)
+}
+
+func (d *DB) DeleteAllTasks(ctx context.Context, actorID int64) (int64, error) {
+	return d.deleteTasks(ctx, actorID, `TRUE`)
+}
+
+func (d *DB) DeleteTasksByExactTitle(ctx context.Context, title string, actorID int64) (int64, error) {
+	return d.deleteTasks(ctx, actorID, `title = $4`, title)
+}
+
+// deleteTasks moves the live tasks matching where to the trash, leaving a
+// "deleted" event behind for each of them. where may refer to one extra
+// argument as $4.
+func (d *DB) deleteTasks(ctx context.Context, actorID int64, where string, args ...any) (int64, error) {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `
+		WITH gone AS (
+			UPDATE tasks SET deleted_at=$3, deleted_by=$1
+			WHERE deleted_at IS NULL AND (`+where+`)
+			RETURNING id, title
+		)
+		INSERT INTO task_events (task_id, actor_id, kind, old_value, created_at)
+		SELECT id, $1, $2, title, $3 FROM gone`,
+		append([]any{nullID(actorID), storage.EventDeleted, storage.Now()}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return n, tx.Commit()
+}
+
+func (d *DB) ListDeletedTasks(ctx context.Context) ([]*storage.Task, error) {
+	return d.queryTasks(ctx, `SELECT `+taskColumns+` FROM tasks t WHERE t.deleted_at IS NOT NULL ORDER BY t.deleted_at DESC`)
+}
+
+func (d *DB) RestoreTask(ctx context.Context, taskID, actorID int64) error {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := storage.Now()
+	res, err := tx.ExecContext(ctx,
+		`UPDATE tasks SET deleted_at=NULL, deleted_by=NULL, updated_at=$1 WHERE id=$2 AND deleted_at IS NOT NULL`, now, taskID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return storage.ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE reminders SET sent=TRUE WHERE task_id=$1 AND NOT sent AND at<=$2`, taskID, now); err != nil {
+		return err
+	}
+	if err := addEvent(ctx, tx, &storage.TaskEvent{TaskID: taskID, ActorID: nullID(actorID), Kind: storage.EventRestored, CreatedAt: now}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *DB) PurgeDeletedTasks(ctx context.Context, before time.Time) (int64, error) {
+	res, err := d.SQL.ExecContext(ctx, `DELETE FROM tasks WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (d *DB) FindTasksByTitleLike(ctx context.Context, q string, limit int) ([]*storage.Task, error) {
+	return d.queryTasks(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t
+		WHERE t.title ILIKE $1 AND t.deleted_at IS NULL
+		ORDER BY t.created_at DESC
+		LIMIT $2`, "%"+q+"%", limit)
+}
+
+func (d *DB) ListDoneExecutorsForTask(ctx context.Context, taskID int64) ([]*storage.User, error) {
+	return d.queryUsers(ctx, `
+		SELECT `+userColumns+`
+		FROM task_assignees ta
+		JOIN users u ON u.id = ta.user_id
+		LEFT JOIN departments dp ON dp.id = u.department_id
+		WHERE ta.task_id = $1 AND ta.status = 'done'
+		ORDER BY ta.updated_at DESC`, taskID)
+}
+
+func (d *DB) ListOverdueAssignees(ctx context.Context, now time.Time) ([]*storage.OverdueAssignee, error) {
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT a.task_id, a.user_id, t.due_at, a.escalated_at
+		FROM task_assignees a
+		JOIN tasks t ON t.id = a.task_id
+		WHERE a.user_id IS NOT NULL AND a.status IN ('new', 'in_progress')
+		  AND t.deleted_at IS NULL AND t.due_at < $1
+		ORDER BY t.due_at, a.id`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.OverdueAssignee
+	for rows.Next() {
+		o := &storage.OverdueAssignee{}
+		if err := rows.Scan(&o.TaskID, &o.UserID, &o.DueAt, &o.EscalatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) RecordEscalation(ctx context.Context, e *storage.TaskEvent) error {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `UPDATE task_assignees SET escalated_at=$1 WHERE task_id=$2 AND user_id=$3`,
+		e.CreatedAt, e.TaskID, e.UserID); err != nil {
+		return err
+	}
+	if err := addEvent(ctx, tx, e); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (d *DB) CreateTemplate(ctx context.Context, t *storage.Template) (int64, error) {
+	var id int64
+	err := d.SQL.QueryRowContext(ctx,
+		`INSERT INTO task_templates (name, creator_id, payload, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+		t.Name, t.CreatorID, string(t.Payload), storage.Now()).Scan(&id)
+	return id, err
+}
+
+func (d *DB) GetTemplate(ctx context.Context, id int64) (*storage.Template, error) {
+	t := &storage.Template{}
+	err := d.SQL.QueryRowContext(ctx, `SELECT id, name, creator_id, payload, created_at FROM task_templates WHERE id = $1`, id).
+		Scan(&t.ID, &t.Name, &t.CreatorID, &t.Payload, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	return t, err
+}
+
+func (d *DB) ListTemplates(ctx context.Context) ([]*storage.Template, error) {
+	rows, err := d.SQL.QueryContext(ctx, `SELECT id, name, creator_id, payload, created_at FROM task_templates ORDER BY lower(name), id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.Template
+	for rows.Next() {
+		t := &storage.Template{}
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatorID, &t.Payload, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) DeleteTemplate(ctx context.Context, id int64) error {
+	res, err := d.SQL.ExecContext(ctx, `DELETE FROM task_templates WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// userColumns reads a user from userTables, with the department name as Team.
+const (
+	userColumns = `u.id, u.tg_id, u.username, u.role, u.name, dp.name, u.department_id, u.timezone, u.work_hours, u.created_at`
+	userTables  = `users u LEFT JOIN departments dp ON dp.id = u.department_id`
+)
+
+func scanUser(row interface{ Scan(...any) error }) (*storage.User, error) {
+	u := &storage.User{}
+	if err := row.Scan(&u.ID, &u.TgID, &u.Username, &u.Role, &u.Name, &u.Team, &u.DepartmentID, &u.Timezone, &u.WorkHours, &u.CreatedAt); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (d *DB) queryUsers(ctx context.Context, query string, args ...any) ([]*storage.User, error) {
+	rows, err := d.SQL.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) UpsertUser(ctx context.Context, tgID int64, username *string, role string) (*storage.User, error) {
+	if _, err := d.SQL.ExecContext(ctx, `
+		INSERT INTO users (tg_id, username, role, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tg_id) DO UPDATE SET username=excluded.username`, tgID, username, role, storage.Now()); err != nil {
+		return nil, err
+	}
+	return d.GetUserByTgID(ctx, tgID)
+}
+
+func (d *DB) GetUserByTgID(ctx context.Context, tgID int64) (*storage.User, error) {
+	return scanUser(d.SQL.QueryRowContext(ctx, `SELECT `+userColumns+` FROM `+userTables+` WHERE u.tg_id=$1`, tgID))
+}
+
+func (d *DB) GetUserByID(ctx context.Context, id int64) (*storage.User, error) {
+	return scanUser(d.SQL.QueryRowContext(ctx, `SELECT `+userColumns+` FROM `+userTables+` WHERE u.id=$1`, id))
+}
+
+func (d *DB) SetWorkerProfile(ctx context.Context, tgID int64, name string, deptID int64) error {
+	if _, err := d.GetDepartmentByID(ctx, deptID); err != nil {
+		return err
+	}
+	_, err := d.SQL.ExecContext(ctx, `UPDATE users SET name=$1, department_id=$2 WHERE tg_id=$3`, name, deptID, tgID)
+	return err
+}
+
+func (d *DB) SetUserTimezone(ctx context.Context, tgID int64, tz string) error {
+	_, err := d.SQL.ExecContext(ctx, `UPDATE users SET timezone=$1 WHERE tg_id=$2`, sql.NullString{String: tz, Valid: tz != ""}, tgID)
+	return err
+}
+
+func (d *DB) SetUserWorkHours(ctx context.Context, tgID int64, spec string) error {
+	_, err := d.SQL.ExecContext(ctx, `UPDATE users SET work_hours=$1 WHERE tg_id=$2`, sql.NullString{String: spec, Valid: spec != ""}, tgID)
+	return err
+}
+
+func (d *DB) ListWorkersByDepartment(ctx context.Context, deptID int64) ([]*storage.User, error) {
+	return d.queryUsers(ctx, `SELECT `+userColumns+`
+		FROM `+userTables+` WHERE u.role='worker' AND u.department_id=$1 ORDER BY u.name NULLS FIRST`, deptID)
+}
+
+func (d *DB) ListAllWorkers(ctx context.Context) ([]*storage.User, error) {
+	return d.queryUsers(ctx, `SELECT `+userColumns+`
+		FROM `+userTables+` WHERE u.role='worker' ORDER BY dp.name NULLS FIRST, u.name NULLS FIRST`)
+}
+
+func (d *DB) FindWorkerByUsername(ctx context.Context, username string) (*storage.User, error) {
+	u, err := scanUser(d.SQL.QueryRowContext(ctx, `SELECT `+userColumns+`
+		FROM `+userTables+` WHERE u.role='worker' AND lower(u.username)=lower($1)`, username))
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrNotFound
+	}
+	return u, err
+}
+
+func (d *DB) DeleteWorkerByTgID(ctx context.Context, tgID int64) (int64, error) {
+	u, err := d.GetUserByTgID(ctx, tgID)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	name := u.Name
+	if !name.Valid && u.Username.Valid {
+		name = sql.NullString{String: "@" + u.Username.String, Valid: true}
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO task_events (task_id, user_id, kind, old_value, created_at)
+		SELECT task_id, $1, $2, $3, $4 FROM task_assignees WHERE user_id=$1`,
+		u.ID, storage.EventUnassigned, name, storage.Now()); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE task_assignees SET user_id=NULL WHERE user_id=$1`, u.ID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM reminders WHERE user_id=$1`, u.ID); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE tg_id=$1 AND role='worker'`, tgID)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return n, tx.Commit()
+}
+
+func (d *DB) ListAssigneeTgIDsByTask(ctx context.Context, taskID int64) ([]int64, error) {
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT u.tg_id
+		FROM task_assignees ta JOIN users u ON u.id = ta.user_id
+		WHERE ta.task_id = $1`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var tg int64
+		if err := rows.Scan(&tg); err != nil {
+			return nil, err
+		}
+		ids = append(ids, tg)
+	}
+	return ids, rows.Err()
+}
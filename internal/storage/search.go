@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SnippetOpen and SnippetClose surround matched words in SearchHit
+// snippets. Snippets go out as plain text, so the marks are ordinary
+// characters rather than markup, and not «», which the bot puts around
+// task titles.
+const (
+	SnippetOpen  = "›"
+	SnippetClose = "‹"
+)
+
+const maxSearchTerms = 8
+
+// SearchTerms splits a user query into lower-cased words with ё folded to е.
+// Backends match every term as a word prefix, so punctuation and query
+// syntax are dropped.
+func SearchTerms(q string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, w := range strings.FieldsFunc(FoldYo(strings.ToLower(q)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		out = append(out, w)
+		if len(out) == maxSearchTerms {
+			break
+		}
+	}
+	return out
+}
+
+// FoldYo replaces ё with е, which Russian text uses interchangeably.
+func FoldYo(s string) string {
+	return strings.NewReplacer("ё", "е", "Ё", "Е").Replace(s)
+}
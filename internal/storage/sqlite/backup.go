@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Backup writes a consistent copy of the live database to path with
+// VACUUM INTO. The copy is built next to path and renamed into place, so
+// path never holds a partial snapshot.
+func (d *DB) Backup(ctx context.Context, path string) error {
+	tmp := path + ".tmp"
+	_ = os.Remove(tmp)
+	if _, err := d.SQL.ExecContext(ctx, `VACUUM INTO ?`, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// SnapshotVersion checks that the file at path is an intact database of this
+// application and returns its schema version.
+func SnapshotVersion(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+	s, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	var check string
+	if err := s.QueryRowContext(ctx, `PRAGMA quick_check`).Scan(&check); err != nil {
+		return 0, fmt.Errorf("not a database: %w", err)
+	}
+	if check != "ok" {
+		return 0, fmt.Errorf("snapshot is corrupt: %s", check)
+	}
+	var v sql.NullInt64
+	if err := s.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&v); err != nil || !v.Valid {
+		return 0, errors.New("snapshot has no schema version")
+	}
+	return int(v.Int64), nil
+}
+
+// Restore replaces the database at dbPath with snapshot. The bot must not be
+// running. The snapshot's schema must not be newer than this binary; older
+// ones are migrated on the next Open. The replaced database is kept as
+// dbPath.before-restore-<time>.
+func Restore(snapshot, dbPath string) (kept string, err error) {
+	v, err := SnapshotVersion(snapshot)
+	if err != nil {
+		return "", err
+	}
+	if v > LatestVersion() {
+		return "", fmt.Errorf("%w: snapshot is at v%d, binary knows up to v%d", ErrSchemaTooNew, v, LatestVersion())
+	}
+
+	tmp := dbPath + ".restore"
+	if err := copyFile(snapshot, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if _, err := os.Stat(dbPath); err == nil {
+		// fold the WAL into the old file so the kept copy is complete
+		if old, err := open(dbPath); err == nil {
+			_, _ = old.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+			_ = old.Close()
+		}
+		kept = dbPath + ".before-restore-" + time.Now().UTC().Format("20060102-150405")
+		if err := os.Rename(dbPath, kept); err != nil {
+			_ = os.Remove(tmp)
+			return "", err
+		}
+	}
+	_ = os.Remove(dbPath + "-wal")
+	_ = os.Remove(dbPath + "-shm")
+	return kept, os.Rename(tmp, dbPath)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -2,12 +2,11 @@ package sqlite
 
 import (
     "context"
+    "database/sql"
+
+    "github.com/hihikaAAa/task-manager/internal/storage"
 )
 
-type Department struct {
-    ID   int64
-    Name string
-}
 
 
 func (d *DB) CreateDepartment(ctx context.Context, name string, createdBy *int64) (int64, error) {
@@ -16,7 +15,7 @@ func (d *DB) CreateDepartment(ctx context.Context, name string, createdBy *int64
         return 0, err
     }
 
-    now := Now()
+    now := now()
     var cb interface{}
     if createdBy != nil { cb = *createdBy }
 
@@ -28,28 +27,68 @@ func (d *DB) CreateDepartment(ctx context.Context, name string, createdBy *int64
     return nextID, nil
 }
 
-func (d *DB) ListDepartments(ctx context.Context) ([]*Department, error) {
-    rows, err := d.SQL.QueryContext(ctx, `SELECT id, name FROM departments ORDER BY name`)
+func (d *DB) ListDepartments(ctx context.Context) ([]*storage.Department, error) {
+    rows, err := d.SQL.QueryContext(ctx, `SELECT id, name, work_hours, head_id FROM departments ORDER BY name`)
     if err != nil { return nil, err }
     defer rows.Close()
-    var out []*Department
+    var out []*storage.Department
     for rows.Next() {
-        var dep Department
-        if err := rows.Scan(&dep.ID, &dep.Name); err != nil { return nil, err }
+        var dep storage.Department
+        if err := rows.Scan(&dep.ID, &dep.Name, &dep.WorkHours, &dep.HeadID); err != nil { return nil, err }
         out = append(out, &dep)
     }
     return out, nil
 }
 
-func (d *DB) GetDepartmentByID(ctx context.Context, id int64) (*Department, error) {
-    row := d.SQL.QueryRowContext(ctx, `SELECT id, name FROM departments WHERE id=?`, id)
-    dep := &Department{}
-    if err := row.Scan(&dep.ID, &dep.Name); err != nil { return nil, err }
+func (d *DB) GetDepartmentByID(ctx context.Context, id int64) (*storage.Department, error) {
+    row := d.SQL.QueryRowContext(ctx, `SELECT id, name, work_hours, head_id FROM departments WHERE id=?`, id)
+    dep := &storage.Department{}
+    if err := row.Scan(&dep.ID, &dep.Name, &dep.WorkHours, &dep.HeadID); err != nil {
+        if err == sql.ErrNoRows { return nil, ErrNotFound }
+        return nil, err
+    }
     return dep, nil
 }
 
+func (d *DB) RenameDepartment(ctx context.Context, id int64, name string) error {
+    res, err := d.SQL.ExecContext(ctx, `UPDATE departments SET name=? WHERE id=?`, name, id)
+    if err != nil { return err }
+    if n, _ := res.RowsAffected(); n == 0 { return ErrNotFound }
+    return nil
+}
+
+func (d *DB) SetDepartmentWorkHours(ctx context.Context, id int64, spec string) error {
+    res, err := d.SQL.ExecContext(ctx, `UPDATE departments SET work_hours=? WHERE id=?`, sql.NullString{String: spec, Valid: spec != ""}, id)
+    if err != nil { return err }
+    if n, _ := res.RowsAffected(); n == 0 { return ErrNotFound }
+    return nil
+}
+
+func (d *DB) SetDepartmentHead(ctx context.Context, id, userID int64) error {
+    res, err := d.SQL.ExecContext(ctx, `UPDATE departments SET head_id=? WHERE id=?`, nullID(userID), id)
+    if err != nil { return err }
+    if n, _ := res.RowsAffected(); n == 0 { return ErrNotFound }
+    return nil
+}
+
 func (d *DB) DeleteDepartment(ctx context.Context, id int64) error {
-    _, err := d.SQL.ExecContext(ctx, `DELETE FROM departments WHERE id=?`, id)
-    return err
+    tx, err := d.SQL.BeginTx(ctx, nil)
+    if err != nil { return err }
+    defer func() { _ = tx.Rollback() }()
+
+    var members int
+    if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE department_id=?`, id).Scan(&members); err != nil { return err }
+    if members > 0 { return storage.ErrDepartmentNotEmpty }
+    res, err := tx.ExecContext(ctx, `DELETE FROM departments WHERE id=?`, id)
+    if err != nil { return err }
+    if n, _ := res.RowsAffected(); n == 0 { return ErrNotFound }
+    return tx.Commit()
+}
+
+func (d *DB) MoveDepartmentMembers(ctx context.Context, fromID, toID int64) (int64, error) {
+    if _, err := d.GetDepartmentByID(ctx, toID); err != nil { return 0, err }
+    res, err := d.SQL.ExecContext(ctx, `UPDATE users SET department_id=? WHERE department_id=?`, toID, fromID)
+    if err != nil { return 0, err }
+    return res.RowsAffected()
 }
 
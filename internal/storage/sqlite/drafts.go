@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (d *DB) SaveDraft(ctx context.Context, userID int64, state string, payload any) (int64, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	res, err := d.SQL.ExecContext(ctx,
+		`INSERT INTO task_drafts (user_id, state, payload, created_at) VALUES (?, ?, ?, ?)`,
+		userID, state, b, now())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (d *DB) ListDrafts(ctx context.Context, userID int64) ([]*storage.Draft, error) {
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT id, user_id, state, payload, created_at FROM task_drafts
+		WHERE user_id = ? ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.Draft
+	for rows.Next() {
+		dr := &storage.Draft{}
+		if err := rows.Scan(&dr.ID, &dr.UserID, &dr.State, &dr.Payload, &dr.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, dr)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) TakeDraft(ctx context.Context, id, userID int64) (*storage.Draft, error) {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	dr := &storage.Draft{}
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, state, payload, created_at FROM task_drafts
+		WHERE id = ? AND user_id = ?`, id, userID).
+		Scan(&dr.ID, &dr.UserID, &dr.State, &dr.Payload, &dr.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM task_drafts WHERE id = ?`, id); err != nil {
+		return nil, err
+	}
+	return dr, tx.Commit()
+}
+
+func (d *DB) PurgeDrafts(ctx context.Context, before time.Time) (int64, error) {
+	res, err := d.SQL.ExecContext(ctx, `DELETE FROM task_drafts WHERE created_at < ?`, utc(before))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,523 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Migration is a single forward-only schema step. Steps are applied in
+// version order, each one in its own transaction, and recorded in
+// schema_migrations.
+type Migration struct {
+	Version int
+	Name    string
+	up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+var migrations = []Migration{
+	{Version: 1, Name: "initial schema", up: migrateInitialSchema},
+	{Version: 2, Name: "nullable task_assignees.user_id", up: migrateTaskAssigneesNullable},
+	{Version: 3, Name: "task_events history", up: migrateTaskEvents},
+	{Version: 4, Name: "soft-deleted tasks", up: migrateTaskSoftDelete},
+	{Version: 5, Name: "full-text search index", up: migrateTasksFTS},
+	{Version: 6, Name: "UTC timestamps", up: migrateUTCTimestamps},
+	{Version: 7, Name: "users.department_id", up: migrateUserDepartments},
+	{Version: 8, Name: "structured task results", up: migrateResultFiles},
+	{Version: 9, Name: "task_attachments", up: migrateTaskAttachments},
+	{Version: 10, Name: "task_drafts", up: migrateTaskDrafts},
+	{Version: 11, Name: "users.timezone", up: migrateUserTimezone},
+	{Version: 12, Name: "work_hours", up: migrateWorkHours},
+	{Version: 13, Name: "escalation", up: migrateEscalation},
+	{Version: 14, Name: "recurring_tasks", up: migrateRecurringTasks},
+	{Version: 15, Name: "task_templates", up: migrateTaskTemplates},
+}
+
+// LatestVersion is the schema version this binary expects.
+func LatestVersion() int { return migrations[len(migrations)-1].Version }
+
+// Migrate brings the schema up to LatestVersion. With dryRun set nothing is
+// written; the returned slice lists the steps that would be applied.
+func Migrate(ctx context.Context, db *sql.DB, dryRun bool) ([]Migration, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if dryRun {
+		// a dry run may be on a read-only connection; without the table
+		// nothing has been applied yet
+		var n int
+		if err := conn.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&n); err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return slices.Clone(migrations), nil
+		}
+	} else if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		);`); err != nil {
+		return nil, err
+	}
+
+	current, err := schemaVersion(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if current > LatestVersion() {
+		return nil, fmt.Errorf("%w: db is at v%d, binary knows up to v%d", ErrSchemaTooNew, current, LatestVersion())
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	if dryRun || len(pending) == 0 {
+		return pending, nil
+	}
+
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys=OFF;`); err != nil {
+		return nil, err
+	}
+	defer func() { _, _ = conn.ExecContext(context.Background(), `PRAGMA foreign_keys=ON;`) }()
+
+	for _, m := range pending {
+		if err := applyMigration(ctx, conn, m); err != nil {
+			return nil, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return pending, nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, m Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := m.up(ctx, tx); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		return err
+	}
+	broken := rows.Next()
+	rows.Close()
+	if broken {
+		return errors.New("foreign key check failed")
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Name, now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func schemaVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	var v sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&v); err != nil {
+		return 0, err
+	}
+	return int(v.Int64), nil
+}
+
+func execAll(ctx context.Context, tx *sql.Tx, stmts ...string) error {
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateInitialSchema(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE IF NOT EXISTS users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			tg_id INTEGER UNIQUE NOT NULL,
+			username TEXT,
+			role TEXT NOT NULL,
+			name TEXT,
+			team TEXT,
+			created_at DATETIME NOT NULL
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS tasks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			creator_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			title TEXT,
+			description TEXT,
+			voice_file_id TEXT,
+			due_at DATETIME,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS task_assignees (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+			status TEXT NOT NULL DEFAULT 'new',
+			updated_at DATETIME NOT NULL
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS reminders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			at DATETIME NOT NULL,
+			kind TEXT NOT NULL,
+			sent INTEGER NOT NULL DEFAULT 0
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS task_results (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			text TEXT,
+			file_id TEXT,
+			created_at DATETIME NOT NULL
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS user_states (
+			user_id INTEGER PRIMARY KEY,
+			state TEXT NOT NULL,
+			payload TEXT,
+			updated_at DATETIME NOT NULL
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS departments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT UNIQUE NOT NULL,
+			created_at DATETIME NOT NULL,
+			created_by INTEGER
+		);`,
+	)
+}
+
+// migrateTaskAssigneesNullable rebuilds task_assignees on databases created
+// before user_id became nullable with ON DELETE SET NULL.
+func migrateTaskAssigneesNullable(ctx context.Context, tx *sql.Tx) error {
+	userNotNull := 0
+	rows, err := tx.QueryContext(ctx, `PRAGMA table_info(task_assignees)`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var cid, notnull, pk int
+		var name, ctype string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		if name == "user_id" {
+			userNotNull = notnull
+		}
+	}
+	rows.Close()
+
+	onDelete := ""
+	fkRows, err := tx.QueryContext(ctx, `PRAGMA foreign_key_list(task_assignees)`)
+	if err != nil {
+		return err
+	}
+	for fkRows.Next() {
+		var id, seq int
+		var table, from, to, onUpdate, onDel, match string
+		if err := fkRows.Scan(&id, &seq, &table, &from, &to, &onUpdate, &onDel, &match); err != nil {
+			fkRows.Close()
+			return err
+		}
+		if from == "user_id" {
+			onDelete = onDel
+		}
+	}
+	fkRows.Close()
+
+	if userNotNull == 1 || strings.ToUpper(onDelete) != "SET NULL" {
+		if err := execAll(ctx, tx,
+			`CREATE TABLE task_assignees_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+				user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				status TEXT NOT NULL DEFAULT 'new',
+				updated_at DATETIME NOT NULL
+			);`,
+			`INSERT INTO task_assignees_new (id, task_id, user_id, status, updated_at)
+			 SELECT id, task_id, user_id, status, updated_at FROM task_assignees;`,
+			`DROP TABLE task_assignees;`,
+			`ALTER TABLE task_assignees_new RENAME TO task_assignees;`,
+		); err != nil {
+			return err
+		}
+	}
+
+	return execAll(ctx, tx, `
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_task_assignees_unique
+		ON task_assignees(task_id, user_id)
+		WHERE user_id IS NOT NULL;`)
+}
+
+// migrateTaskEvents adds the task history table. task_id deliberately has no
+// foreign key so the history of a deleted task stays readable.
+func migrateTaskEvents(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE task_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			actor_id INTEGER,
+			user_id INTEGER,
+			kind TEXT NOT NULL,
+			old_value TEXT,
+			new_value TEXT,
+			created_at DATETIME NOT NULL
+		);`,
+		`CREATE INDEX idx_task_events_task ON task_events(task_id, id);`,
+	)
+}
+
+func migrateTaskSoftDelete(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`ALTER TABLE tasks ADD COLUMN deleted_at DATETIME;`,
+		`ALTER TABLE tasks ADD COLUMN deleted_by INTEGER REFERENCES users(id) ON DELETE SET NULL;`,
+		`CREATE INDEX idx_tasks_deleted ON tasks(deleted_at);`,
+	)
+}
+
+// migrateTasksFTS indexes tasks in tasks_fts (rowid = task id); triggers keep
+// it in step with tasks and the text of task_results. The index folds ё to е,
+// as storage.SearchTerms does.
+func migrateTasksFTS(ctx context.Context, tx *sql.Tx) error {
+	fold := func(expr string) string { return `replace(replace(` + expr + `, 'ё', 'е'), 'Ё', 'Е')` }
+	results := func(taskID string) string {
+		return fold(`(SELECT group_concat(text, ' ') FROM task_results WHERE task_id = ` + taskID + ` AND text IS NOT NULL)`)
+	}
+	return execAll(ctx, tx,
+		`CREATE VIRTUAL TABLE tasks_fts USING fts5(title, description, results, tokenize='unicode61 remove_diacritics 2');`,
+		`INSERT INTO tasks_fts (rowid, title, description, results)
+			SELECT id, `+fold("title")+`, `+fold("description")+`, `+results("tasks.id")+` FROM tasks;`,
+		`CREATE TRIGGER tasks_fts_ai AFTER INSERT ON tasks BEGIN
+			INSERT INTO tasks_fts (rowid, title, description, results) VALUES (new.id, `+fold("new.title")+`, `+fold("new.description")+`, NULL);
+		END;`,
+		`CREATE TRIGGER tasks_fts_au AFTER UPDATE OF title, description ON tasks BEGIN
+			UPDATE tasks_fts SET title = `+fold("new.title")+`, description = `+fold("new.description")+` WHERE rowid = new.id;
+		END;`,
+		`CREATE TRIGGER tasks_fts_ad AFTER DELETE ON tasks BEGIN
+			DELETE FROM tasks_fts WHERE rowid = old.id;
+		END;`,
+		`CREATE TRIGGER task_results_fts_ai AFTER INSERT ON task_results BEGIN
+			UPDATE tasks_fts SET results = `+results("new.task_id")+` WHERE rowid = new.task_id;
+		END;`,
+		`CREATE TRIGGER task_results_fts_ad AFTER DELETE ON task_results BEGIN
+			UPDATE tasks_fts SET results = `+results("old.task_id")+` WHERE rowid = old.task_id;
+		END;`,
+	)
+}
+
+// migrateUTCTimestamps rewrites every timestamp in timeLayout at UTC. Older
+// rows hold time.Time.String() in whatever zone the bot ran in, which does
+// not sort as text.
+func migrateUTCTimestamps(ctx context.Context, tx *sql.Tx) error {
+	columns := map[string][]string{
+		"schema_migrations": {"applied_at"},
+		"users":             {"created_at"},
+		"departments":       {"created_at"},
+		"tasks":             {"due_at", "created_at", "updated_at", "deleted_at"},
+		"task_assignees":    {"updated_at"},
+		"reminders":         {"at"},
+		"task_results":      {"created_at"},
+		"user_states":       {"updated_at"},
+		"task_events":       {"created_at"},
+	}
+	for table, cols := range columns {
+		for _, col := range cols {
+			if err := rewriteUTC(ctx, tx, table, col); err != nil {
+				return fmt.Errorf("%s.%s: %w", table, col, err)
+			}
+		}
+	}
+	return nil
+}
+
+func rewriteUTC(ctx context.Context, tx *sql.Tx, table, col string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT rowid, `+col+` FROM `+table+` WHERE `+col+` IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	type row struct {
+		id int64
+		at time.Time
+	}
+	var rs []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.at); err != nil {
+			rows.Close()
+			return err
+		}
+		rs = append(rs, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, r := range rs {
+		if _, err := tx.ExecContext(ctx, `UPDATE `+table+` SET `+col+`=? WHERE rowid=?`, utc(r.at), r.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateUserDepartments replaces the free-text users.team with a foreign key
+// to departments. Team names without a department get one, so no worker
+// loses their team.
+func migrateUserDepartments(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO departments (name, created_at)
+		SELECT DISTINCT team, ? FROM users
+		WHERE team IS NOT NULL AND team <> '' AND team NOT IN (SELECT name FROM departments)`, now()); err != nil {
+		return err
+	}
+	return execAll(ctx, tx,
+		`ALTER TABLE users ADD COLUMN department_id INTEGER REFERENCES departments(id);`,
+		`UPDATE users SET department_id = (SELECT id FROM departments WHERE name = users.team);`,
+		`ALTER TABLE users DROP COLUMN team;`,
+		`CREATE INDEX IF NOT EXISTS idx_users_department ON users(department_id);`,
+	)
+}
+
+// migrateResultFiles records what kind of file a result is. Older results
+// keep a NULL kind; the bot probes the file type when re-sending them.
+func migrateResultFiles(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`ALTER TABLE task_results ADD COLUMN kind TEXT;`,
+		`ALTER TABLE task_results ADD COLUMN caption TEXT;`,
+		`ALTER TABLE task_results ADD COLUMN file_name TEXT;`,
+		`ALTER TABLE task_results ADD COLUMN file_size INTEGER;`,
+		`ALTER TABLE task_results ADD COLUMN mime_type TEXT;`,
+	)
+}
+
+// migrateTaskAttachments adds task_attachments and copies the single voice
+// note of older tasks into it.
+func migrateTaskAttachments(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE task_attachments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			kind TEXT NOT NULL,
+			file_id TEXT NOT NULL,
+			file_name TEXT,
+			caption TEXT
+		);`,
+		`CREATE INDEX idx_task_attachments_task ON task_attachments(task_id);`,
+		`INSERT INTO task_attachments (task_id, kind, file_id)
+			SELECT id, 'voice', voice_file_id FROM tasks WHERE voice_file_id IS NOT NULL AND voice_file_id <> '';`,
+	)
+}
+
+// migrateTaskDrafts adds task_drafts for conversations put aside by the
+// state sweeper or by switching to another command.
+func migrateTaskDrafts(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE task_drafts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			state TEXT NOT NULL,
+			payload TEXT,
+			created_at DATETIME NOT NULL
+		);`,
+		`CREATE INDEX idx_task_drafts_user ON task_drafts(user_id);`,
+		`CREATE INDEX idx_user_states_updated ON user_states(updated_at);`,
+	)
+}
+
+// migrateUserTimezone adds the IANA zone a user reads times in. NULL means
+// the bot's configured zone.
+func migrateUserTimezone(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx, `ALTER TABLE users ADD COLUMN timezone TEXT;`)
+}
+
+// migrateWorkHours adds working hours to departments and, as an override,
+// to users. NULL falls back to the department, then to the bot's default.
+func migrateWorkHours(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`ALTER TABLE departments ADD COLUMN work_hours TEXT;`,
+		`ALTER TABLE users ADD COLUMN work_hours TEXT;`,
+	)
+}
+
+// migrateEscalation adds department heads and the time escalation of an
+// assignment last fired. Assignments already overdue count as escalated so
+// the upgrade does not flood every recipient at once, and the fixed overdue
+// reminders give way to the escalation policy.
+func migrateEscalation(ctx context.Context, tx *sql.Tx) error {
+	if err := execAll(ctx, tx,
+		`ALTER TABLE departments ADD COLUMN head_id INTEGER REFERENCES users(id) ON DELETE SET NULL;`,
+		`ALTER TABLE task_assignees ADD COLUMN escalated_at DATETIME;`,
+	); err != nil {
+		return err
+	}
+	// CURRENT_TIMESTAMP would be "YYYY-MM-DD HH:MM:SS" without an offset,
+	// which neither compares with nor scans like timeLayout values.
+	t := utc(now())
+	if _, err := tx.ExecContext(ctx, `UPDATE task_assignees SET escalated_at = ?
+		WHERE status IN ('new', 'in_progress')
+		  AND task_id IN (SELECT id FROM tasks WHERE due_at < ?)`, t, t); err != nil {
+		return err
+	}
+	return execAll(ctx, tx, `DELETE FROM reminders WHERE kind = 'overdue' AND sent=0;`)
+}
+
+// migrateRecurringTasks adds recurring_tasks: /newtask drafts the scheduler
+// turns into a task on every occurrence of their schedule.
+func migrateRecurringTasks(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE recurring_tasks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			creator_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			title TEXT NOT NULL,
+			schedule TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			next_at DATETIME,
+			created_count INTEGER NOT NULL DEFAULT 0,
+			paused INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL
+		);`,
+		`CREATE INDEX idx_recurring_tasks_next ON recurring_tasks(next_at);`,
+	)
+}
+
+// migrateTaskTemplates adds task_templates: /newtask drafts saved to start
+// similar tasks from.
+func migrateTaskTemplates(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE task_templates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			creator_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			payload TEXT NOT NULL,
+			created_at DATETIME NOT NULL
+		);`,
+	)
+}
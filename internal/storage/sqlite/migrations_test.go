@@ -0,0 +1,210 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// migrateTo applies the migrations up to version to a new database at path.
+func migrateTo(t *testing.T, path string, version int) *sql.DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at DATETIME NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations[:version] {
+		if err := applyMigration(ctx, conn, m); err != nil {
+			t.Fatalf("migration %d: %v", m.Version, err)
+		}
+	}
+	return db
+}
+
+// schemaOf lists the schema objects and applied versions of db.
+func schemaOf(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	var out []string
+	for _, q := range []string{
+		`SELECT type || ' ' || name || ' ' || COALESCE(sql, '') FROM sqlite_master ORDER BY type, name`,
+		`SELECT 'v' || version FROM schema_migrations ORDER BY version`,
+	} {
+		rows, err := db.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var s string
+			if err := rows.Scan(&s); err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, s)
+		}
+		rows.Close()
+	}
+	return out
+}
+
+func versions(ms []Migration) []int {
+	vs := make([]int, len(ms))
+	for i, m := range ms {
+		vs[i] = m.Version
+	}
+	return vs
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// a missing file is all pending and is not created
+	missing := filepath.Join(dir, "missing.db")
+	pending, err := PendingMigrations(missing)
+	if err != nil || len(pending) != LatestVersion() {
+		t.Fatalf("PendingMigrations of a missing file = %v, %v", versions(pending), err)
+	}
+	if _, err := os.Stat(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("dry run created the file: %v", err)
+	}
+
+	path := filepath.Join(dir, "tasks.db")
+	db := migrateTo(t, path, 12)
+	before := schemaOf(t, db)
+	pending, err = PendingMigrations(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{13, 14, 15}
+	if got := versions(pending); !slices.Equal(got, want) {
+		t.Fatalf("PendingMigrations = %v, want %v", got, want)
+	}
+	if pending, err = Migrate(ctx, db, true); err != nil || !slices.Equal(versions(pending), want) {
+		t.Fatalf("Migrate dry run = %v, %v, want %v", versions(pending), err, want)
+	}
+	if after := schemaOf(t, db); !reflect.DeepEqual(after, before) {
+		t.Fatalf("dry run changed the schema:\n%q\nwant\n%q", after, before)
+	}
+
+	if _, err := Migrate(ctx, db, false); err != nil {
+		t.Fatal(err)
+	}
+	if pending, err = PendingMigrations(path); err != nil || len(pending) != 0 {
+		t.Fatalf("PendingMigrations after migrating = %v, %v", versions(pending), err)
+	}
+}
+
+func TestMigrateSchemaTooNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.SQL.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'from the future', ?)`, LatestVersion()+1, now())
+	db.SQL.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(path); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("Open of a newer schema: %v, want ErrSchemaTooNew", err)
+	}
+	if _, err := PendingMigrations(path); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("PendingMigrations of a newer schema: %v, want ErrSchemaTooNew", err)
+	}
+}
+
+// TestMigrateRollsBack appends a step that fails halfway: the steps before
+// it stay applied, and nothing of it is left behind.
+func TestMigrateRollsBack(t *testing.T) {
+	ctx := context.Background()
+	latest := LatestVersion()
+	db := migrateTo(t, filepath.Join(t.TempDir(), "tasks.db"), 12)
+
+	orig := migrations
+	t.Cleanup(func() { migrations = orig })
+	migrations = append(slices.Clone(orig), Migration{Version: latest + 1, Name: "broken", up: func(ctx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `CREATE TABLE half_done (id INTEGER)`); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}})
+
+	if _, err := Migrate(ctx, db, false); err == nil {
+		t.Fatal("Migrate with a failing step succeeded")
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatal("the failed step left its table behind")
+	}
+	var v int
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v != latest {
+		t.Fatalf("schema is at v%d, want v%d: the steps before the failed one stay", v, latest)
+	}
+	// and the next run retries only the failed step
+	if pending, err := Migrate(ctx, db, true); err != nil || !slices.Equal(versions(pending), []int{latest + 1}) {
+		t.Fatalf("pending after the failure = %v, %v", versions(pending), err)
+	}
+}
+
+func TestMigrateEscalation(t *testing.T) {
+	ctx := context.Background()
+	db := migrateTo(t, filepath.Join(t.TempDir(), "tasks.db"), 12)
+	start := now()
+	exec := func(q string, args ...any) {
+		t.Helper()
+		if _, err := db.ExecContext(ctx, q, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exec(`INSERT INTO users (id, tg_id, role, created_at) VALUES (1, 1, 'worker', ?), (2, 2, 'worker', ?)`, start, start)
+	exec(`INSERT INTO tasks (id, creator_id, title, due_at, created_at, updated_at) VALUES (1, 1, 'late', ?, ?, ?), (2, 1, 'later', ?, ?, ?)`,
+		start.Add(-time.Hour), start, start, start.Add(time.Hour), start, start)
+	exec(`INSERT INTO task_assignees (task_id, user_id, status, updated_at) VALUES (1, 1, 'new', ?), (2, 1, 'new', ?), (1, 2, 'done', ?)`, start, start, start)
+
+	if _, err := Migrate(ctx, db, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// escalated_at must be stored in timeLayout like every other column
+	// since v6, so that it compares with bound times
+	var raw string
+	if err := db.QueryRowContext(ctx, `SELECT CAST(escalated_at AS TEXT) FROM task_assignees WHERE task_id = 1 AND status = 'new'`).Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	at, err := time.Parse(timeLayout, raw)
+	if err != nil {
+		t.Fatalf("escalated_at %q is not in timeLayout: %v", raw, err)
+	}
+	if _, off := at.Zone(); off != 0 || at.Before(start) || at.After(now()) {
+		t.Fatalf("escalated_at = %v, want the time of the upgrade in UTC", at)
+	}
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM task_assignees WHERE escalated_at IS NOT NULL`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("%d assignments escalated, want only the overdue unfinished one", n)
+	}
+}
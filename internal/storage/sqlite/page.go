@@ -0,0 +1,36 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// keyset returns the condition selecting the rows on the requested side of
+// p.Cursor for a list ordered by (key, id) descending, its arguments, and the
+// ORDER BY and LIMIT to read them with.
+func keyset(key, id string, p storage.PageRequest) (cond string, args []any, order string) {
+	cmp, dir := "<", "DESC"
+	if p.Backward {
+		cmp, dir = ">", "ASC"
+	}
+	order = ` ORDER BY ` + key + ` ` + dir + `, ` + id + ` ` + dir + ` LIMIT ?`
+	if p.Cursor.IsZero() {
+		return `1=1`, nil, order
+	}
+	at := utc(p.Cursor.At)
+	return `(` + key + ` ` + cmp + ` ? OR (` + key + ` = ? AND ` + id + ` ` + cmp + ` ?))`, []any{at, at, p.Cursor.ID}, order
+}
+
+func (d *DB) queryTaskPage(ctx context.Context, p storage.PageRequest, query string, args ...any) (*storage.TaskPage, error) {
+	ts, err := d.queryTasks(ctx, query, append(args, p.Size()+1)...)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]time.Time, len(ts))
+	for i, t := range ts {
+		keys[i] = t.CreatedAt
+	}
+	return storage.NewTaskPage(p, ts, keys), nil
+}
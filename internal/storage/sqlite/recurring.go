@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+const recurringColumns = `id, creator_id, title, schedule, payload, next_at, created_count, paused, created_at`
+
+func scanRecurring(row interface{ Scan(...any) error }) (*storage.Recurring, error) {
+	r := &storage.Recurring{}
+	err := row.Scan(&r.ID, &r.CreatorID, &r.Title, &r.Schedule, &r.Payload, &r.NextAt, &r.Created, &r.Paused, &r.CreatedAt)
+	return r, err
+}
+
+func (d *DB) CreateRecurring(ctx context.Context, r *storage.Recurring) (int64, error) {
+	res, err := d.SQL.ExecContext(ctx, `
+		INSERT INTO recurring_tasks (creator_id, title, schedule, payload, next_at, created_count, paused, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.CreatorID, r.Title, r.Schedule, r.Payload, utcNull(r.NextAt), r.Created, r.Paused, now())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (d *DB) GetRecurring(ctx context.Context, id int64) (*storage.Recurring, error) {
+	r, err := scanRecurring(d.SQL.QueryRowContext(ctx, `SELECT `+recurringColumns+` FROM recurring_tasks WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return r, err
+}
+
+func (d *DB) ListRecurring(ctx context.Context) ([]*storage.Recurring, error) {
+	return d.queryRecurring(ctx, `SELECT `+recurringColumns+` FROM recurring_tasks ORDER BY id`)
+}
+
+func (d *DB) ListDueRecurring(ctx context.Context, now time.Time) ([]*storage.Recurring, error) {
+	return d.queryRecurring(ctx, `SELECT `+recurringColumns+` FROM recurring_tasks
+		WHERE paused = 0 AND next_at IS NOT NULL AND next_at <= ? ORDER BY next_at, id`, utc(now))
+}
+
+func (d *DB) queryRecurring(ctx context.Context, q string, args ...any) ([]*storage.Recurring, error) {
+	rows, err := d.SQL.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.Recurring
+	for rows.Next() {
+		r, err := scanRecurring(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) SaveRecurring(ctx context.Context, r *storage.Recurring) error {
+	res, err := d.SQL.ExecContext(ctx, `UPDATE recurring_tasks SET next_at = ?, created_count = ?, paused = ? WHERE id = ?`,
+		utcNull(r.NextAt), r.Created, r.Paused, r.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (d *DB) DeleteRecurring(ctx context.Context, id int64) error {
+	res, err := d.SQL.ExecContext(ctx, `DELETE FROM recurring_tasks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
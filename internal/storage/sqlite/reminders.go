@@ -3,33 +3,30 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
 )
 
 
-type Reminder struct {
-	ID     int64
-	TaskID int64
-	UserID sql.NullInt64
-	At     time.Time
-	Kind   string 
-}
 
 
-func (d *DB) ListDueReminders(ctx context.Context, until time.Time) ([]*Reminder, error) {
+func (d *DB) ListDueReminders(ctx context.Context, until time.Time) ([]*storage.Reminder, error) {
 	rows, err := d.SQL.QueryContext(ctx, `
-		SELECT id, task_id, user_id, at, kind
-		FROM reminders
-		WHERE sent=0 AND at<=?
-		ORDER BY at`, until)
+		SELECT r.id, r.task_id, r.user_id, r.at, r.kind
+		FROM reminders r
+		JOIN tasks t ON t.id = r.task_id
+		WHERE r.sent=0 AND r.at<=? AND t.deleted_at IS NULL
+		ORDER BY r.at`, utc(until))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var out []*Reminder
+	var out []*storage.Reminder
 	for rows.Next() {
-		r := &Reminder{}
+		r := &storage.Reminder{}
 		if err := rows.Scan(&r.ID, &r.TaskID, &r.UserID, &r.At, &r.Kind); err != nil {
 			return nil, err
 		}
@@ -39,10 +36,64 @@ func (d *DB) ListDueReminders(ctx context.Context, until time.Time) ([]*Reminder
 }
 
 
+func (d *DB) ListTaskReminders(ctx context.Context, taskID int64) ([]*storage.Reminder, error) {
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT id, task_id, user_id, at, kind FROM reminders WHERE task_id=? ORDER BY at, id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*storage.Reminder
+	for rows.Next() {
+		r := &storage.Reminder{}
+		if err := rows.Scan(&r.ID, &r.TaskID, &r.UserID, &r.At, &r.Kind); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
 func (d *DB) MarkReminderSent(ctx context.Context, id int64) error {
 	_, err := d.SQL.ExecContext(ctx, `UPDATE reminders SET sent=1 WHERE id=?`, id)
 	return err
 }
+func (d *DB) DeferReminder(ctx context.Context, id int64, at time.Time) error {
+	_, err := d.SQL.ExecContext(ctx, `UPDATE reminders SET at=? WHERE id=? AND sent=0`, utc(at), id)
+	return err
+}
+
+func (d *DB) SnoozeReminder(ctx context.Context, id, userID int64, at time.Time) (*storage.Reminder, error) {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	r := &storage.Reminder{}
+	err = tx.QueryRowContext(ctx, `SELECT task_id, user_id, kind FROM reminders WHERE id=? AND user_id=?`, id, userID).
+		Scan(&r.TaskID, &r.UserID, &r.Kind)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.At = utc(at)
+	res, err := tx.ExecContext(ctx, `INSERT INTO reminders (task_id, user_id, at, kind, sent) VALUES (?, ?, ?, ?, 0)`, r.TaskID, userID, r.At, r.Kind)
+	if err != nil {
+		return nil, err
+	}
+	if r.ID, err = res.LastInsertId(); err != nil {
+		return nil, err
+	}
+	return r, commitWith(tx, addEvent(ctx, tx, &storage.TaskEvent{
+		TaskID: r.TaskID, ActorID: nullID(userID), UserID: nullID(userID), Kind: storage.EventSnoozed,
+		NewValue: storage.FormatDue(sql.NullTime{Time: r.At, Valid: true}),
+	}))
+}
+
 func (d *DB) MarkAllRemindersSentFor(ctx context.Context, taskID, userID int64) error {
     _, err := d.SQL.ExecContext(ctx, `
         UPDATE reminders SET sent=1
@@ -0,0 +1,49 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// ftsQuery turns search terms into an FTS5 query matching all of them as
+// prefixes. Terms are letters and digits only, so quoting is enough.
+func ftsQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = `"` + t + `"*`
+	}
+	return strings.Join(parts, " ")
+}
+
+func (d *DB) SearchTasks(ctx context.Context, q string, assigneeID int64, limit int) ([]*storage.SearchHit, error) {
+	terms := storage.SearchTerms(q)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT `+taskColumns+`,
+		       snippet(tasks_fts, -1, ?, ?, '…', 12)
+		FROM tasks_fts f
+		JOIN tasks t ON t.id = f.rowid
+		WHERE tasks_fts MATCH ? AND t.deleted_at IS NULL
+		  AND (? = 0 OR EXISTS (SELECT 1 FROM task_assignees ta WHERE ta.task_id = t.id AND ta.user_id = ?))
+		ORDER BY bm25(tasks_fts, 10.0, 4.0, 1.0), t.created_at DESC
+		LIMIT ?`,
+		storage.SnippetOpen, storage.SnippetClose, ftsQuery(terms), assigneeID, assigneeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*storage.SearchHit
+	for rows.Next() {
+		h := &storage.SearchHit{}
+		if h.Task, err = scanTask(rows, &h.Snippet); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
@@ -3,9 +3,13 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/hihikaAAa/task-manager/internal/storage"
 	_ "modernc.org/sqlite"
 )
 
@@ -13,34 +17,16 @@ type DB struct {
 	SQL *sql.DB
 }
 
-func Open(path string) (*DB, error) {
-	dsn := path + "?_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(15000)"
-
-	var s *sql.DB
-	var err error
+var _ storage.Store = (*DB)(nil)
 
-	for i := 0; i < 6; i++ { 
-		s, err = sql.Open("sqlite", dsn)
-		if err == nil {
-			break
-		}
-		time.Sleep(time.Duration(100*(i+1)) * time.Millisecond)
-	}
+func Open(path string) (*DB, error) {
+	s, err := open(path)
 	if err != nil {
 		return nil, err
 	}
 
-	s.SetMaxOpenConns(2)
-	s.SetMaxIdleConns(2)
-
-
-	if err := s.Ping(); err != nil {
-		_ = s.Close()
-		return nil, err
-	}
-
 	for i := 0; i < 5; i++ {
-		err = migrate(context.Background(), s)
+		_, err = Migrate(context.Background(), s, false)
 		if err == nil {
 			break
 		}
@@ -63,184 +49,47 @@ func Open(path string) (*DB, error) {
 	return &DB{SQL: s}, nil
 }
 
-func migrate(ctx context.Context, db *sql.DB) error {
-	stmts := []string{
-		`PRAGMA foreign_keys = ON;`,
-
-		`CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			tg_id INTEGER UNIQUE NOT NULL,
-			username TEXT,
-			role TEXT NOT NULL,
-			name TEXT,
-			team TEXT,
-			created_at DATETIME NOT NULL
-		);`,
-
-		`CREATE TABLE IF NOT EXISTS tasks (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			creator_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-			title TEXT,
-			description TEXT,
-			voice_file_id TEXT,
-			due_at DATETIME,
-			created_at DATETIME NOT NULL,
-			updated_at DATETIME NOT NULL
-		);`,
-
-		`CREATE TABLE IF NOT EXISTS reminders (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
-			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-			at DATETIME NOT NULL,
-			kind TEXT NOT NULL,
-			sent INTEGER NOT NULL DEFAULT 0
-		);`,
-
-		`CREATE TABLE IF NOT EXISTS task_results (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
-			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-			text TEXT,
-			file_id TEXT,
-			created_at DATETIME NOT NULL
-		);`,
-
-		`CREATE TABLE IF NOT EXISTS user_states (
-			user_id INTEGER PRIMARY KEY,
-			state TEXT NOT NULL,
-			payload TEXT,
-			updated_at DATETIME NOT NULL
-		);`,
-
-		`CREATE TABLE IF NOT EXISTS departments (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT UNIQUE NOT NULL,
-			created_at DATETIME NOT NULL,
-			created_by INTEGER
-		);`,
-	}
-
-	for _, s := range stmts {
-		if _, err := db.ExecContext(ctx, s); err != nil {
-			return err
-		}
+// PendingMigrations opens the database at path read-only and reports the
+// migrations Open would apply, without changing anything. A missing file
+// is left missing and gets every migration.
+func PendingMigrations(path string) ([]Migration, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return slices.Clone(migrations), nil
 	}
-
-	if err := ensureTaskAssigneesSchema(ctx, db); err != nil {
-		return err
+	s, err := openDSN("file:" + path + "?mode=ro&_pragma=busy_timeout(15000)&_time_format=sqlite")
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	defer s.Close()
+	return Migrate(context.Background(), s, true)
 }
 
-func ensureTaskAssigneesSchema(ctx context.Context, db *sql.DB) error {
-	var cnt int
-	if err := db.QueryRowContext(ctx,
-		`SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='task_assignees'`,
-	).Scan(&cnt); err != nil {
-		return err
-	}
-
-	if cnt == 0 {
+func open(path string) (*sql.DB, error) {
+	return openDSN(path + "?_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(15000)&_pragma=foreign_keys(1)&_time_format=sqlite")
+}
 
-		conn, err := db.Conn(ctx)
-		if err != nil { return err }
-		defer conn.Close()
+func openDSN(dsn string) (*sql.DB, error) {
+	var s *sql.DB
+	var err error
 
-		if _, err := conn.ExecContext(ctx, `
-			CREATE TABLE task_assignees (
-				id INTEGER PRIMARY KEY AUTOINCREMENT,
-				task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
-				user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
-				status TEXT NOT NULL DEFAULT 'new',
-				updated_at DATETIME NOT NULL
-			);
-		`); err != nil {
-			return err
+	for i := 0; i < 6; i++ { 
+		s, err = sql.Open("sqlite", dsn)
+		if err == nil {
+			break
 		}
-		_, _ = conn.ExecContext(ctx, `
-			CREATE UNIQUE INDEX IF NOT EXISTS idx_task_assignees_unique
-			ON task_assignees(task_id, user_id)
-			WHERE user_id IS NOT NULL;
-		`)
-		return nil
-	}
-
-	type colInfo struct{ notnull int }
-	cols := map[string]colInfo{}
-	rows, err := db.QueryContext(ctx, `PRAGMA table_info(task_assignees)`)
-	if err != nil { return err }
-	defer rows.Close()
-	for rows.Next() {
-		var cid int
-		var name, ctype string
-		var notnull, pk int
-		var dflt sql.NullString
-		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil { return err }
-		cols[name] = colInfo{notnull: notnull}
-	}
-	userNotNull := 0
-	if c, ok := cols["user_id"]; ok { userNotNull = c.notnull }
-
-	onDelete := ""
-	fkRows, err := db.QueryContext(ctx, `PRAGMA foreign_key_list(task_assignees)`)
-	if err != nil { return err }
-	defer fkRows.Close()
-	for fkRows.Next() {
-		var id, seq int
-		var table, from, to, onUpdate, onDel, match string
-		if err := fkRows.Scan(&id, &seq, &table, &from, &to, &onUpdate, &onDel, &match); err != nil { return err }
-		if from == "user_id" { onDelete = onDel; break }
+		time.Sleep(time.Duration(100*(i+1)) * time.Millisecond)
 	}
-
-	needMigrate := (userNotNull == 1) || (strings.ToUpper(onDelete) != "SET NULL")
-	if !needMigrate {
-		_, _ = db.ExecContext(ctx, `
-			CREATE UNIQUE INDEX IF NOT EXISTS idx_task_assignees_unique
-			ON task_assignees(task_id, user_id)
-			WHERE user_id IS NOT NULL;
-		`)
-		return nil
+	if err != nil {
+		return nil, err
 	}
 
-	conn, err := db.Conn(ctx)
-	if err != nil { return err }
-
-	defer conn.Close()
-	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys=OFF;`); err != nil { return err }
-
-	tx, err := conn.BeginTx(ctx, nil)
-	if err != nil { return err }
-	defer func() { _ = tx.Rollback() }()
-
-	if _, err := tx.ExecContext(ctx, `
-		CREATE TABLE task_assignees_new (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
-			user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
-			status TEXT NOT NULL DEFAULT 'new',
-			updated_at DATETIME NOT NULL
-		);
-	`); err != nil { return err }
-
-	if _, err := tx.ExecContext(ctx, `
-		INSERT INTO task_assignees_new (id, task_id, user_id, status, updated_at)
-		SELECT id, task_id, user_id, status, updated_at FROM task_assignees;
-	`); err != nil { return err }
-
-	if _, err := tx.ExecContext(ctx, `DROP TABLE task_assignees;`); err != nil { return err }
-	if _, err := tx.ExecContext(ctx, `ALTER TABLE task_assignees_new RENAME TO task_assignees;`); err != nil { return err }
+	s.SetMaxOpenConns(2)
+	s.SetMaxIdleConns(2)
 
-	if _, err := tx.ExecContext(ctx, `
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_task_assignees_unique
-		ON task_assignees(task_id, user_id)
-		WHERE user_id IS NOT NULL;
-	`); err != nil { return err }
 
-	if err := tx.Commit(); err != nil { return err }
-	_, _ = conn.ExecContext(ctx, `PRAGMA foreign_keys=ON;`)
-	return nil
+	if err := s.Ping(); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
 }
-
-func Now() time.Time { return time.Now().In(time.Local) }
@@ -4,14 +4,10 @@ import (
     "context"
     "encoding/json"
     "time"
+
+    "github.com/hihikaAAa/task-manager/internal/storage"
 )
 
-type State struct {
-    UserID  int64
-    State   string
-    Payload []byte
-    UpdatedAt time.Time
-}
 
 func (d *DB) SaveState(ctx context.Context, userID int64, state string, payload any) error {
     var b []byte
@@ -20,7 +16,7 @@ func (d *DB) SaveState(ctx context.Context, userID int64, state string, payload
         b, err = json.Marshal(payload)
         if err != nil { return err }
     }
-    now := Now()
+    now := now()
     _, err = d.SQL.ExecContext(ctx, `
         INSERT INTO user_states (user_id, state, payload, updated_at) VALUES (?, ?, ?, ?)
         ON CONFLICT(user_id) DO UPDATE SET state=excluded.state, payload=excluded.payload, updated_at=excluded.updated_at
@@ -43,3 +39,24 @@ func (d *DB) ClearState(ctx context.Context, userID int64) error {
     _, err := d.SQL.ExecContext(ctx, `DELETE FROM user_states WHERE user_id=?`, userID)
     return err
 }
+
+func (d *DB) ExpireStates(ctx context.Context, before time.Time) ([]*storage.State, error) {
+    tx, err := d.SQL.BeginTx(ctx, nil)
+    if err != nil { return nil, err }
+    defer func() { _ = tx.Rollback() }()
+
+    rows, err := tx.QueryContext(ctx, `SELECT user_id, state, payload, updated_at FROM user_states WHERE updated_at < ?`, utc(before))
+    if err != nil { return nil, err }
+    var out []*storage.State
+    for rows.Next() {
+        st := &storage.State{}
+        if err := rows.Scan(&st.UserID, &st.State, &st.Payload, &st.UpdatedAt); err != nil { rows.Close(); return nil, err }
+        out = append(out, st)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil { return nil, err }
+    for _, st := range out {
+        if _, err := tx.ExecContext(ctx, `DELETE FROM user_states WHERE user_id=?`, st.UserID); err != nil { return nil, err }
+    }
+    return out, tx.Commit()
+}
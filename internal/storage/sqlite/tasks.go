@@ -5,149 +5,224 @@ import (
     "database/sql"
     "time"
     "strings"
+
+    "github.com/hihikaAAa/task-manager/internal/storage"
 )
 
-type Task struct {
-    ID          int64
-    CreatorID   int64
-    Title       sql.NullString
-    Description sql.NullString
-    VoiceFileID sql.NullString
-    DueAt       sql.NullTime
-    CreatedAt   time.Time
-    UpdatedAt   time.Time
-}
-
-type TaskAssignee struct {
-    ID        int64
-    TaskID    int64
-    UserID    int64
-    Status    string
-    UpdatedAt time.Time
-}
-
-type AssigneeRow struct {
-    TgID     int64
-    Name     sql.NullString
-    Username sql.NullString
-    Team     sql.NullString
-    Status   string
-}
-
-type AssigneeWithUser struct {
-	UserID   sql.NullInt64
-	Status   string
-	Name     sql.NullString
-	Username sql.NullString
-	Team     sql.NullString
-	TgID     sql.NullInt64
-}
-
-func (d *DB) CreateTask(ctx context.Context, t *Task, assigneeIDs []int64) (int64, error) {
-    now := Now()
-    res, err := d.SQL.ExecContext(ctx, `
+const taskColumns = `t.id, t.creator_id, t.title, t.description, t.voice_file_id, t.due_at, t.created_at, t.updated_at, t.deleted_at, t.deleted_by`
+
+func scanTask(row interface{ Scan(...any) error }, extra ...any) (*storage.Task, error) {
+	t := &storage.Task{}
+	dst := append([]any{&t.ID, &t.CreatorID, &t.Title, &t.Description, &t.VoiceFileID, &t.DueAt, &t.CreatedAt, &t.UpdatedAt, &t.DeletedAt, &t.DeletedBy}, extra...)
+	if err := row.Scan(dst...); err != nil { return nil, err }
+	return t, nil
+}
+
+func (d *DB) queryTasks(ctx context.Context, query string, args ...any) ([]*storage.Task, error) {
+	rows, err := d.SQL.QueryContext(ctx, query, args...)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	var out []*storage.Task
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil { return nil, err }
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) CreateTask(ctx context.Context, in storage.NewTask) (*storage.CreatedTask, error) {
+    tx, err := d.SQL.BeginTx(ctx, nil)
+    if err != nil { return nil, err }
+    defer func() { _ = tx.Rollback() }()
+
+    out, err := createTaskTx(ctx, tx, in)
+    if err != nil { return nil, err }
+    if err := tx.Commit(); err != nil { return nil, err }
+    return out, nil
+}
+
+func (d *DB) CreateTasks(ctx context.Context, in []storage.NewTask) ([]*storage.CreatedTask, error) {
+    tx, err := d.SQL.BeginTx(ctx, nil)
+    if err != nil { return nil, err }
+    defer func() { _ = tx.Rollback() }()
+
+    out := make([]*storage.CreatedTask, 0, len(in))
+    for _, nt := range in {
+        c, err := createTaskTx(ctx, tx, nt)
+        if err != nil { return nil, err }
+        out = append(out, c)
+    }
+    if err := tx.Commit(); err != nil { return nil, err }
+    return out, nil
+}
+
+func createTaskTx(ctx context.Context, tx *sql.Tx, in storage.NewTask) (*storage.CreatedTask, error) {
+    now := now()
+    t := *in.Task
+    res, err := tx.ExecContext(ctx, `
         INSERT INTO tasks (creator_id, title, description, voice_file_id, due_at, created_at, updated_at)
         VALUES (?, ?, ?, ?, ?, ?, ?)
-    `, t.CreatorID, t.Title, t.Description, t.VoiceFileID, t.DueAt, now, now)
-    if err != nil { return 0, err }
-    id, _ := res.LastInsertId()
-    for _, uid := range assigneeIDs {
-        _, err := d.SQL.ExecContext(ctx, `INSERT INTO task_assignees (task_id, user_id, status, updated_at) VALUES (?, ?, 'new', ?)`, id, uid, now)
-        if err != nil { return 0, err }
+    `, t.CreatorID, t.Title, t.Description, t.VoiceFileID, utcNull(t.DueAt), now, now)
+    if err != nil { return nil, err }
+    t.ID, err = res.LastInsertId()
+    if err != nil { return nil, err }
+    t.CreatedAt, t.UpdatedAt = now, now
+
+    if err := addEvent(ctx, tx, &storage.TaskEvent{TaskID: t.ID, ActorID: nullID(t.CreatorID), Kind: storage.EventCreated, NewValue: t.Title, CreatedAt: now}); err != nil {
+        return nil, err
     }
-    return id, nil
+    for _, uid := range in.AssigneeIDs {
+        _, err := tx.ExecContext(ctx, `INSERT INTO task_assignees (task_id, user_id, status, updated_at) VALUES (?, ?, 'new', ?)`, t.ID, uid, now)
+        if err != nil { return nil, err }
+        if err := addEvent(ctx, tx, &storage.TaskEvent{TaskID: t.ID, ActorID: nullID(t.CreatorID), UserID: nullID(uid), Kind: storage.EventAssigned, CreatedAt: now}); err != nil {
+            return nil, err
+        }
+    }
+
+    for _, a := range in.Attachments {
+        _, err := tx.ExecContext(ctx, `INSERT INTO task_attachments (task_id, kind, file_id, file_name, caption) VALUES (?, ?, ?, ?, ?)`,
+            t.ID, a.Kind, a.FileID, a.FileName, a.Caption)
+        if err != nil { return nil, err }
+    }
+
+    n := 0
+    for _, uid := range in.AssigneeIDs {
+        for _, r := range in.Reminders {
+            if r.UserID != 0 && r.UserID != uid { continue }
+            _, err := tx.ExecContext(ctx, `INSERT INTO reminders (task_id, user_id, at, kind, sent) VALUES (?, ?, ?, ?, 0)`, t.ID, uid, utc(r.At), r.Kind)
+            if err != nil { return nil, err }
+            n++
+        }
+    }
+    return &storage.CreatedTask{Task: &t, AssigneeIDs: in.AssigneeIDs, Reminders: n}, nil
 }
 
 func (d *DB) UpdateAssigneeStatus(ctx context.Context, taskID, userID int64, status string) (bool, error) {
-    now := Now()
-    res, err := d.SQL.ExecContext(ctx, `
+    tx, err := d.SQL.BeginTx(ctx, nil)
+    if err != nil { return false, err }
+    defer func() { _ = tx.Rollback() }()
+
+    var old string
+    err = tx.QueryRowContext(ctx, `SELECT status FROM task_assignees WHERE task_id=? AND user_id=?`, taskID, userID).Scan(&old)
+    if err == sql.ErrNoRows || (err == nil && old == status) { return false, nil }
+    if err != nil { return false, err }
+
+    now := now()
+    if _, err := tx.ExecContext(ctx, `
         UPDATE task_assignees
         SET status=?, updated_at=?
-        WHERE task_id=? AND user_id=? AND status<>?`,
-        status, now, taskID, userID, status)
-    if err != nil { return false, err }
-    n, _ := res.RowsAffected()
-    return n > 0, nil
+        WHERE task_id=? AND user_id=?`,
+        status, now, taskID, userID); err != nil {
+        return false, err
+    }
+    if err := addEvent(ctx, tx, &storage.TaskEvent{
+        TaskID: taskID, ActorID: nullID(userID), UserID: nullID(userID), Kind: storage.EventStatus,
+        OldValue: nullText(old), NewValue: nullText(status), CreatedAt: now,
+    }); err != nil {
+        return false, err
+    }
+    return true, tx.Commit()
 }
 
+func (d *DB) UpdateTaskDeadline(ctx context.Context, taskID, actorID int64, due sql.NullTime) error {
+    tx, err := d.SQL.BeginTx(ctx, nil)
+    if err != nil { return err }
+    defer func() { _ = tx.Rollback() }()
 
-func (d *DB) GetTask(ctx context.Context, id int64) (*Task, error) {
-    row := d.SQL.QueryRowContext(ctx, `SELECT id, creator_id, title, description, voice_file_id, due_at, created_at, updated_at FROM tasks WHERE id=?`, id)
-    t := &Task{}
-    if err := row.Scan(&t.ID, &t.CreatorID, &t.Title, &t.Description, &t.VoiceFileID, &t.DueAt, &t.CreatedAt, &t.UpdatedAt); err != nil { return nil, err }
-    return t, nil
+    var old sql.NullTime
+    if err := tx.QueryRowContext(ctx, `SELECT due_at FROM tasks WHERE id=?`, taskID).Scan(&old); err != nil { return err }
+
+    due = utcNull(due)
+    now := now()
+    if _, err := tx.ExecContext(ctx, `UPDATE tasks SET due_at=?, updated_at=? WHERE id=?`, due, now, taskID); err != nil { return err }
+
+    if err := shiftReminders(ctx, tx, taskID, old, due); err != nil { return err }
+    // a new deadline starts escalation over
+    if _, err := tx.ExecContext(ctx, `UPDATE task_assignees SET escalated_at=NULL WHERE task_id=?`, taskID); err != nil { return err }
+
+    return commitWith(tx, addEvent(ctx, tx, &storage.TaskEvent{
+        TaskID: taskID, ActorID: nullID(actorID), Kind: storage.EventDeadline,
+        OldValue: storage.FormatDue(old), NewValue: storage.FormatDue(due), CreatedAt: now,
+    }))
 }
 
-func (d *DB) ListActiveTasksForBoss(ctx context.Context) ([]*Task, error) {
-	rows, err := d.SQL.QueryContext(ctx, `
-		SELECT t.id, t.creator_id, t.title, t.description, t.voice_file_id, t.due_at, t.created_at, t.updated_at
+// shiftReminders moves the unsent reminders of a task along with its
+// deadline, keeping their offsets. Without a new deadline they are dropped.
+func shiftReminders(ctx context.Context, tx *sql.Tx, taskID int64, old, due sql.NullTime) error {
+    if !due.Valid {
+        _, err := tx.ExecContext(ctx, `DELETE FROM reminders WHERE task_id=? AND sent=0`, taskID)
+        return err
+    }
+    if !old.Valid { return nil }
+    delta := due.Time.Sub(old.Time)
+
+    rows, err := tx.QueryContext(ctx, `SELECT id, at FROM reminders WHERE task_id=? AND sent=0`, taskID)
+    if err != nil { return err }
+    type rem struct { id int64; at time.Time }
+    var rs []rem
+    for rows.Next() {
+        var r rem
+        if err := rows.Scan(&r.id, &r.at); err != nil { rows.Close(); return err }
+        rs = append(rs, r)
+    }
+    rows.Close()
+    for _, r := range rs {
+        if _, err := tx.ExecContext(ctx, `UPDATE reminders SET at=? WHERE id=?`, utc(r.at.Add(delta)), r.id); err != nil { return err }
+    }
+    return nil
+}
+
+func commitWith(tx *sql.Tx, err error) error {
+    if err != nil { return err }
+    return tx.Commit()
+}
+
+func (d *DB) GetTask(ctx context.Context, id int64) (*storage.Task, error) {
+    return scanTask(d.SQL.QueryRowContext(ctx, `SELECT `+taskColumns+` FROM tasks t WHERE t.id=?`, id))
+}
+
+func (d *DB) ListActiveTasksForBoss(ctx context.Context, p storage.PageRequest) (*storage.TaskPage, error) {
+	cond, args, order := keyset("t.created_at", "t.id", p)
+	return d.queryTaskPage(ctx, p, `
+		SELECT `+taskColumns+`
 		FROM tasks t
 		LEFT JOIN task_assignees ta ON ta.task_id = t.id
+		WHERE t.deleted_at IS NULL AND `+cond+`
 		GROUP BY t.id
 		HAVING COUNT(ta.id)=0
-		   OR SUM(CASE WHEN ta.status!='done' THEN 1 ELSE 0 END) > 0
-		ORDER BY t.created_at DESC`)
-	if err != nil { return nil, err }
-	defer rows.Close()
-
-	var out []*Task
-	for rows.Next() {
-		t := &Task{}
-		if err := rows.Scan(&t.ID,&t.CreatorID,&t.Title,&t.Description,&t.VoiceFileID,&t.DueAt,&t.CreatedAt,&t.UpdatedAt); err != nil {
-			return nil, err
-		}
-		out = append(out, t)
-	}
-	return out, nil
+		   OR SUM(CASE WHEN ta.status!='done' THEN 1 ELSE 0 END) > 0`+order, args...)
 }
 
 
-func (d *DB) ListActiveTasksForUser(ctx context.Context, userID int64) ([]*Task, error) {
-    rows, err := d.SQL.QueryContext(ctx, `
-        SELECT t.id, t.creator_id, t.title, t.description, t.voice_file_id, t.due_at, t.created_at, t.updated_at
+func (d *DB) ListActiveTasksForUser(ctx context.Context, userID int64, p storage.PageRequest) (*storage.TaskPage, error) {
+    cond, args, order := keyset("t.created_at", "t.id", p)
+    return d.queryTaskPage(ctx, p, `
+        SELECT `+taskColumns+`
         FROM tasks t
         JOIN task_assignees ta ON ta.task_id = t.id
-        WHERE ta.user_id=? AND ta.status != 'done'
-        ORDER BY t.created_at DESC
-    `, userID)
-    if err != nil { return nil, err }
-    defer rows.Close()
-    var out []*Task
-    for rows.Next() {
-        t := &Task{}
-        if err := rows.Scan(&t.ID, &t.CreatorID, &t.Title, &t.Description, &t.VoiceFileID, &t.DueAt, &t.CreatedAt, &t.UpdatedAt); err != nil { return nil, err }
-        out = append(out, t)
-    }
-    return out, nil
+        WHERE ta.user_id=? AND ta.status != 'done' AND t.deleted_at IS NULL AND `+cond+order,
+        append([]any{userID}, args...)...)
 }
 
-func (d *DB) ListActiveTasksForTeam(ctx context.Context, team string) ([]*Task, error) {
-    rows, err := d.SQL.QueryContext(ctx, `
-        SELECT DISTINCT t.id, t.creator_id, t.title, t.description, t.voice_file_id, t.due_at, t.created_at, t.updated_at
+func (d *DB) ListActiveTasksForDepartment(ctx context.Context, deptID int64, p storage.PageRequest) (*storage.TaskPage, error) {
+    cond, args, order := keyset("t.created_at", "t.id", p)
+    return d.queryTaskPage(ctx, p, `
+        SELECT DISTINCT `+taskColumns+`
         FROM tasks t
         JOIN task_assignees ta ON ta.task_id = t.id
         JOIN users u ON u.id = ta.user_id
-        WHERE u.team = ? AND ta.status != 'done'
-        ORDER BY t.created_at DESC
-    `, team)
-    if err != nil { return nil, err }
-    defer rows.Close()
-    var out []*Task
-    for rows.Next() {
-        t := &Task{}
-        if err := rows.Scan(&t.ID, &t.CreatorID, &t.Title, &t.Description, &t.VoiceFileID, &t.DueAt, &t.CreatedAt, &t.UpdatedAt); err != nil { return nil, err }
-        out = append(out, t)
-    }
-    return out, nil
+        WHERE u.department_id = ? AND ta.status != 'done' AND t.deleted_at IS NULL AND `+cond+order,
+        append([]any{deptID}, args...)...)
 }
 
-func (d *DB) GetAssignees(ctx context.Context, taskID int64) ([]*TaskAssignee, error) {
+func (d *DB) GetAssignees(ctx context.Context, taskID int64) ([]*storage.TaskAssignee, error) {
     rows, err := d.SQL.QueryContext(ctx, `SELECT id, task_id, user_id, status, updated_at FROM task_assignees WHERE task_id=?`, taskID)
     if err != nil { return nil, err }
     defer rows.Close()
-    var out []*TaskAssignee
+    var out []*storage.TaskAssignee
     for rows.Next() {
-        a := &TaskAssignee{}
+        a := &storage.TaskAssignee{}
         if err := rows.Scan(&a.ID, &a.TaskID, &a.UserID, &a.Status, &a.UpdatedAt); err != nil { return nil, err }
         out = append(out, a)
     }
@@ -155,19 +230,20 @@ func (d *DB) GetAssignees(ctx context.Context, taskID int64) ([]*TaskAssignee, e
 }
 
 
-func (d *DB) ListAssigneesWithUsers(ctx context.Context, taskID int64) ([]*AssigneeRow, error) {
+func (d *DB) ListAssigneesWithUsers(ctx context.Context, taskID int64) ([]*storage.AssigneeRow, error) {
     rows, err := d.SQL.QueryContext(ctx, `
-        SELECT u.tg_id, u.name, u.username, u.team, ta.status
+        SELECT u.tg_id, u.name, u.username, dp.name, ta.status
         FROM task_assignees ta
         JOIN users u ON u.id = ta.user_id
+        LEFT JOIN departments dp ON dp.id = u.department_id
         WHERE ta.task_id = ?
-        ORDER BY u.team, u.name
+        ORDER BY dp.name, u.name
     `, taskID)
     if err != nil { return nil, err }
     defer rows.Close()
-    var out []*AssigneeRow
+    var out []*storage.AssigneeRow
     for rows.Next() {
-        r := &AssigneeRow{}
+        r := &storage.AssigneeRow{}
         if err := rows.Scan(&r.TgID, &r.Name, &r.Username, &r.Team, &r.Status); err != nil { return nil, err }
         out = append(out, r)
     }
@@ -177,7 +253,7 @@ func (d *DB) ListAssigneesWithUsers(ctx context.Context, taskID int64) ([]*Assig
 func (d *DB) CreateReminders(ctx context.Context, taskID int64, userIDs []int64, reminderTimes []time.Time, kind string) error {
     for _, uid := range userIDs {
         for _, at := range reminderTimes {
-            _, err := d.SQL.ExecContext(ctx, `INSERT INTO reminders (task_id, user_id, at, kind, sent) VALUES (?, ?, ?, ?, 0)`, taskID, uid, at, kind)
+            _, err := d.SQL.ExecContext(ctx, `INSERT INTO reminders (task_id, user_id, at, kind, sent) VALUES (?, ?, ?, ?, 0)`, taskID, uid, utc(at), kind)
             if err != nil { return err }
         }
     }
@@ -191,43 +267,65 @@ func (d *DB) DueAtForTask(ctx context.Context, taskID int64) (time.Time, bool, e
     return due.Time, due.Valid, nil
 }
 
-func (d *DB) AddResult(ctx context.Context, taskID, userID int64, text, fileID *string) error {
-    now := Now()
-    _, err := d.SQL.ExecContext(ctx, `INSERT INTO task_results (task_id, user_id, text, file_id, created_at) VALUES (?, ?, ?, ?, ?)`,
-        taskID, userID, text, fileID, now)
-    return err
+func (d *DB) AddResult(ctx context.Context, r *storage.TaskResult) error {
+    now := now()
+    tx, err := d.SQL.BeginTx(ctx, nil)
+    if err != nil { return err }
+    defer func() { _ = tx.Rollback() }()
+
+    res, err := tx.ExecContext(ctx, `
+        INSERT INTO task_results (task_id, user_id, text, kind, file_id, caption, file_name, file_size, mime_type, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        r.TaskID, r.UserID, r.Text, r.Kind, r.FileID, r.Caption, r.FileName, r.FileSize, r.MimeType, now)
+    if err != nil { return err }
+    r.ID, _ = res.LastInsertId()
+    r.CreatedAt = now
+    return commitWith(tx, addEvent(ctx, tx, &storage.TaskEvent{
+        TaskID: r.TaskID, ActorID: nullID(r.UserID), UserID: nullID(r.UserID), Kind: storage.EventResult,
+        NewValue: storage.ResultSummary(r), CreatedAt: now,
+    }))
 }
 
-func (d *DB) ListResults(ctx context.Context, taskID int64) ([]string, error) {
-    rows, err := d.SQL.QueryContext(ctx, `SELECT coalesce(text,'') || coalesce(file_id,'') FROM task_results WHERE task_id=? ORDER BY created_at`, taskID)
+func (d *DB) ListResults(ctx context.Context, taskID int64) ([]*storage.TaskResult, error) {
+    rows, err := d.SQL.QueryContext(ctx, `
+        SELECT id, task_id, user_id, text, kind, file_id, caption, file_name, file_size, mime_type, created_at
+        FROM task_results WHERE task_id=? ORDER BY created_at, id`, taskID)
     if err != nil { return nil, err }
     defer rows.Close()
-    var out []string
-    for rows.Next() { var s string; if err := rows.Scan(&s); err != nil { return nil, err }; out = append(out, s) }
-    return out, nil
+    var out []*storage.TaskResult
+    for rows.Next() {
+        r := &storage.TaskResult{}
+        if err := rows.Scan(&r.ID, &r.TaskID, &r.UserID, &r.Text, &r.Kind, &r.FileID, &r.Caption, &r.FileName, &r.FileSize, &r.MimeType, &r.CreatedAt); err != nil { return nil, err }
+        out = append(out, r)
+    }
+    return out, rows.Err()
 }
 
-func (d *DB) SearchWorkers(ctx context.Context, q string) ([]*User, error) {
-    q = strings.ToLower(q)
-    rows, err := d.SQL.QueryContext(ctx, `
-        SELECT id, tg_id, username, role, name, team, created_at
-        FROM users
-        WHERE role='worker' AND (
-            lower(coalesce(username,'')) LIKE '%'||?||'%'
-            OR lower(coalesce(name,'')) LIKE '%'||?||'%'
-            OR lower(coalesce(team,'')) LIKE '%'||?||'%'
-        )
-        ORDER BY team, name
-    `, q, q, q)
+func (d *DB) ListAttachments(ctx context.Context, taskID int64) ([]*storage.Attachment, error) {
+    rows, err := d.SQL.QueryContext(ctx, `SELECT id, task_id, kind, file_id, file_name, caption FROM task_attachments WHERE task_id=? ORDER BY id`, taskID)
     if err != nil { return nil, err }
     defer rows.Close()
-    var out []*User
+    var out []*storage.Attachment
     for rows.Next() {
-        u := &User{}
-        if err := rows.Scan(&u.ID, &u.TgID, &u.Username, &u.Role, &u.Name, &u.Team, &u.CreatedAt); err != nil { return nil, err }
-        out = append(out, u)
+        a := &storage.Attachment{}
+        if err := rows.Scan(&a.ID, &a.TaskID, &a.Kind, &a.FileID, &a.FileName, &a.Caption); err != nil { return nil, err }
+        out = append(out, a)
     }
-    return out, nil
+    return out, rows.Err()
+}
+
+func (d *DB) SearchWorkers(ctx context.Context, q string) ([]*storage.User, error) {
+    q = strings.ToLower(q)
+    return d.queryUsers(ctx, `
+        SELECT `+userColumns+`
+        FROM `+userTables+`
+        WHERE u.role='worker' AND (
+            lower(coalesce(u.username,'')) LIKE '%'||?||'%'
+            OR lower(coalesce(u.name,'')) LIKE '%'||?||'%'
+            OR lower(coalesce(dp.name,'')) LIKE '%'||?||'%'
+        )
+        ORDER BY dp.name, u.name
+    `, q, q, q)
 }
 
 func (d *DB) HasResult(ctx context.Context, taskID, userID int64) (bool, error) {
@@ -240,94 +338,73 @@ func (d *DB) HasResult(ctx context.Context, taskID, userID int64) (bool, error)
     return true, nil
 }
 
-// sqlite/tasks.go
-func (d *DB) ListDoneTasksForBoss(ctx context.Context, creatorID int64, limit int) ([]*Task, []time.Time, error) {
-	rows, err := d.SQL.QueryContext(ctx, `
-		SELECT t.id, t.creator_id, t.title, t.description, t.voice_file_id, t.due_at,
-		       t.created_at, t.updated_at,
+func (d *DB) ListDoneTasksForBoss(ctx context.Context, creatorID int64, p storage.PageRequest) (*storage.TaskPage, error) {
+	cond, args, order := keyset("MAX(ta.updated_at)", "t.id", p)
+	return d.queryDoneTasks(ctx, p, `
+		SELECT `+taskColumns+`,
 		       MAX(ta.updated_at) AS completed_at
 		FROM tasks t
 		JOIN task_assignees ta ON ta.task_id = t.id AND ta.status='done'
-		WHERE t.creator_id = ?                         -- << ключевой фильтр по автору
+		WHERE t.creator_id = ? AND t.deleted_at IS NULL
 		GROUP BY t.id
-		ORDER BY completed_at DESC
-		LIMIT ?`, creatorID, limit)
-	if err != nil { return nil, nil, err }
-	defer rows.Close()
-
-	var ts []*Task
-	var comps []time.Time
-	for rows.Next() {
-		t := &Task{}
-		var comp time.Time
-		if err := rows.Scan(&t.ID,&t.CreatorID,&t.Title,&t.Description,&t.VoiceFileID,&t.DueAt,&t.CreatedAt,&t.UpdatedAt,&comp); err != nil {
-			return nil, nil, err
-		}
-		ts = append(ts, t)
-		comps = append(comps, comp)
-	}
-	return ts, comps, nil
+		HAVING `+cond+order, append([]any{creatorID}, args...)...)
 }
 
 
-func (d *DB) ListDoneTasksForUser(ctx context.Context, userID int64, limit int) ([]*Task, []time.Time, error) {
-	rows, err := d.SQL.QueryContext(ctx, `
-		SELECT t.id, t.creator_id, t.title, t.description, t.voice_file_id, t.due_at, t.created_at, t.updated_at,
+func (d *DB) ListDoneTasksForUser(ctx context.Context, userID int64, p storage.PageRequest) (*storage.TaskPage, error) {
+	cond, args, order := keyset("ta.updated_at", "t.id", p)
+	return d.queryDoneTasks(ctx, p, `
+		SELECT `+taskColumns+`,
 		       ta.updated_at AS completed_at
 		FROM tasks t
 		JOIN task_assignees ta ON ta.task_id = t.id
-		WHERE ta.user_id = ? AND ta.status='done'
-		ORDER BY ta.updated_at DESC
-		LIMIT ?`, userID, limit)
-	if err != nil { return nil, nil, err }
+		WHERE ta.user_id = ? AND ta.status='done' AND t.deleted_at IS NULL AND `+cond+order,
+		append([]any{userID}, args...)...)
+}
+
+func (d *DB) queryDoneTasks(ctx context.Context, p storage.PageRequest, query string, args ...any) (*storage.TaskPage, error) {
+	rows, err := d.SQL.QueryContext(ctx, query, append(args, p.Size()+1)...)
+	if err != nil { return nil, err }
 	defer rows.Close()
 
-	var ts []*Task; var comps []time.Time
+	var ts []*storage.Task
+	var comps []time.Time
 	for rows.Next() {
-		t := &Task{}; var comp time.Time
-		if err := rows.Scan(&t.ID,&t.CreatorID,&t.Title,&t.Description,&t.VoiceFileID,&t.DueAt,&t.CreatedAt,&t.UpdatedAt,&comp); err != nil {
-			return nil, nil, err
-		}
-		ts = append(ts, t); comps = append(comps, comp)
+		var comp textTime
+		t, err := scanTask(rows, &comp)
+		if err != nil { return nil, err }
+		ts = append(ts, t)
+		comps = append(comps, comp.Time)
 	}
-	return ts, comps, nil
+	if err := rows.Err(); err != nil { return nil, err }
+	return storage.NewTaskPage(p, ts, comps), nil
 }
 
-func (d *DB) ListTasksWithoutAssignees(ctx context.Context) ([]*Task, error) {
-    rows, err := d.SQL.QueryContext(ctx, `
-        SELECT t.id, t.creator_id, t.title, t.description, t.voice_file_id, t.due_at, t.created_at, t.updated_at
+func (d *DB) ListTasksWithoutAssignees(ctx context.Context) ([]*storage.Task, error) {
+    return d.queryTasks(ctx, `
+        SELECT `+taskColumns+`
         FROM tasks t
         LEFT JOIN task_assignees ta ON ta.task_id = t.id
+        WHERE t.deleted_at IS NULL
         GROUP BY t.id
         HAVING COUNT(ta.id)=0
         ORDER BY t.created_at DESC`)
-    if err != nil { return nil, err }
-    defer rows.Close()
-
-    var out []*Task
-    for rows.Next() {
-        t := &Task{}
-        if err := rows.Scan(&t.ID,&t.CreatorID,&t.Title,&t.Description,&t.VoiceFileID,&t.DueAt,&t.CreatedAt,&t.UpdatedAt); err != nil {
-            return nil, err
-        }
-        out = append(out, t)
-    }
-    return out, nil
 }
 
-func (d *DB) ListAssigneesWithUsersAny(ctx context.Context, taskID int64) ([]*AssigneeWithUser, error) {
+func (d *DB) ListAssigneesWithUsersAny(ctx context.Context, taskID int64) ([]*storage.AssigneeWithUser, error) {
 	rows, err := d.SQL.QueryContext(ctx, `
-		SELECT ta.user_id, ta.status, u.name, u.username, u.team, u.tg_id
+		SELECT ta.user_id, ta.status, u.name, u.username, dp.name, u.tg_id
 		FROM task_assignees ta
 		LEFT JOIN users u ON u.id = ta.user_id
+		LEFT JOIN departments dp ON dp.id = u.department_id
 		WHERE ta.task_id = ?
 		ORDER BY COALESCE(u.name, u.username)`, taskID)
 	if err != nil { return nil, err }
 	defer rows.Close()
 
-	var out []*AssigneeWithUser
+	var out []*storage.AssigneeWithUser
 	for rows.Next() {
-		r := &AssigneeWithUser{}
+		r := &storage.AssigneeWithUser{}
 		if err := rows.Scan(&r.UserID, &r.Status, &r.Name, &r.Username, &r.Team, &r.TgID); err != nil {
 			return nil, err
 		}
@@ -345,78 +422,120 @@ func (d *DB) IsAssigneeDone(ctx context.Context, taskID, userID int64) (bool, er
 	return st == "done", nil
 }
 
-func (d *DB) ListAllTasks(ctx context.Context) ([]*Task, error) {
-	rows, err := d.SQL.QueryContext(ctx, `
-		SELECT id, creator_id, title, description, voice_file_id, due_at, created_at, updated_at
-		FROM tasks ORDER BY id`)
-	if err != nil { return nil, err }
-	defer rows.Close()
-	var out []*Task
-	for rows.Next() {
-		t := &Task{}
-		if err := rows.Scan(&t.ID,&t.CreatorID,&t.Title,&t.Description,&t.VoiceFileID,&t.DueAt,&t.CreatedAt,&t.UpdatedAt); err != nil {
-			return nil, err
-		}
-		out = append(out, t)
-	}
-	return out, nil
+func (d *DB) ListAllTasks(ctx context.Context) ([]*storage.Task, error) {
+	return d.queryTasks(ctx, `SELECT `+taskColumns+` FROM tasks t WHERE t.deleted_at IS NULL ORDER BY t.id`)
 }
 
-func (d *DB) DeleteTask(ctx context.Context, taskID int64) (int64, error) {
-	res, err := d.SQL.ExecContext(ctx, `DELETE FROM tasks WHERE id=?`, taskID)
-	if err != nil { return 0, err }
-	return res.RowsAffected()
+func (d *DB) DeleteTask(ctx context.Context, taskID, actorID int64) (int64, error) {
+	return d.deleteTasks(ctx, actorID, `id=?`, taskID)
+}
+
+func (d *DB) DeleteAllTasks(ctx context.Context, actorID int64) (int64, error) {
+	return d.deleteTasks(ctx, actorID, `1=1`)
 }
 
-func (d *DB) DeleteAllTasks(ctx context.Context) (int64, error) {
-	res, err := d.SQL.ExecContext(ctx, `DELETE FROM tasks`)
+func (d *DB) DeleteTasksByExactTitle(ctx context.Context, title string, actorID int64) (int64, error) {
+	return d.deleteTasks(ctx, actorID, `title = ?`, title)
+}
+
+// deleteTasks moves the live tasks matching where to the trash, leaving a
+// "deleted" event behind for each of them.
+func (d *DB) deleteTasks(ctx context.Context, actorID int64, where string, args ...any) (int64, error) {
+	tx, err := d.SQL.BeginTx(ctx, nil)
 	if err != nil { return 0, err }
-	return res.RowsAffected()
+	defer func() { _ = tx.Rollback() }()
+
+	now := now()
+	where = `deleted_at IS NULL AND (` + where + `)`
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO task_events (task_id, actor_id, kind, old_value, created_at)
+		SELECT id, ?, ?, title, ? FROM tasks WHERE `+where,
+		append([]any{nullID(actorID), storage.EventDeleted, now}, args...)...); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `UPDATE tasks SET deleted_at=?, deleted_by=? WHERE `+where,
+		append([]any{now, nullID(actorID)}, args...)...)
+	if err != nil { return 0, err }
+	n, _ := res.RowsAffected()
+	return n, tx.Commit()
 }
 
-func (d *DB) DeleteTasksByExactTitle(ctx context.Context, title string) (int64, error) {
-    res, err := d.SQL.ExecContext(ctx, `DELETE FROM tasks WHERE title = ?`, title)
-    if err != nil { return 0, err }
-    return res.RowsAffected()
+func (d *DB) ListDeletedTasks(ctx context.Context) ([]*storage.Task, error) {
+	return d.queryTasks(ctx, `SELECT `+taskColumns+` FROM tasks t WHERE t.deleted_at IS NOT NULL ORDER BY t.deleted_at DESC`)
 }
 
-func (d *DB) FindTasksByTitleLike(ctx context.Context, q string, limit int) ([]*Task, error) {
-    rows, err := d.SQL.QueryContext(ctx, `
-        SELECT id, creator_id, title, description, voice_file_id, due_at, created_at, updated_at
-        FROM tasks
-        WHERE title LIKE ?
-        ORDER BY created_at DESC
+func (d *DB) RestoreTask(ctx context.Context, taskID, actorID int64) error {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil { return err }
+	defer func() { _ = tx.Rollback() }()
+
+	now := now()
+	res, err := tx.ExecContext(ctx, `UPDATE tasks SET deleted_at=NULL, deleted_by=NULL, updated_at=? WHERE id=? AND deleted_at IS NOT NULL`, now, taskID)
+	if err != nil { return err }
+	if n, _ := res.RowsAffected(); n == 0 { return storage.ErrNotFound }
+
+	if _, err := tx.ExecContext(ctx, `UPDATE reminders SET sent=1 WHERE task_id=? AND sent=0 AND at<=?`, taskID, now); err != nil {
+		return err
+	}
+	return commitWith(tx, addEvent(ctx, tx, &storage.TaskEvent{TaskID: taskID, ActorID: nullID(actorID), Kind: storage.EventRestored, CreatedAt: now}))
+}
+
+func (d *DB) PurgeDeletedTasks(ctx context.Context, before time.Time) (int64, error) {
+	res, err := d.SQL.ExecContext(ctx, `DELETE FROM tasks WHERE deleted_at IS NOT NULL AND deleted_at < ?`, utc(before))
+	if err != nil { return 0, err }
+	return res.RowsAffected()
+}
+
+func (d *DB) FindTasksByTitleLike(ctx context.Context, q string, limit int) ([]*storage.Task, error) {
+    return d.queryTasks(ctx, `
+        SELECT `+taskColumns+`
+        FROM tasks t
+        WHERE t.title LIKE ? AND t.deleted_at IS NULL
+        ORDER BY t.created_at DESC
         LIMIT ?`, "%"+q+"%", limit)
-    if err != nil { return nil, err }
-    defer rows.Close()
-    var out []*Task
-    for rows.Next() {
-        t := &Task{}
-        if err := rows.Scan(&t.ID,&t.CreatorID,&t.Title,&t.Description,&t.VoiceFileID,&t.DueAt,&t.CreatedAt,&t.UpdatedAt); err != nil {
-            return nil, err
-        }
-        out = append(out, t)
-    }
-    return out, nil
 }
 
-func (d *DB) ListDoneExecutorsForTask(ctx context.Context, taskID int64) ([]*User, error) {
-	rows, err := d.SQL.QueryContext(ctx, `
-		SELECT u.id, u.tg_id, u.username, u.role, u.name, u.team, u.created_at
+func (d *DB) ListDoneExecutorsForTask(ctx context.Context, taskID int64) ([]*storage.User, error) {
+	return d.queryUsers(ctx, `
+		SELECT `+userColumns+`
 		FROM task_assignees ta
 		JOIN users u ON u.id = ta.user_id
+		LEFT JOIN departments dp ON dp.id = u.department_id
 		WHERE ta.task_id = ? AND ta.status = 'done'
 		ORDER BY ta.updated_at DESC`, taskID)
-	if err != nil { return nil, err }
+}
+func (d *DB) ListOverdueAssignees(ctx context.Context, now time.Time) ([]*storage.OverdueAssignee, error) {
+	rows, err := d.SQL.QueryContext(ctx, `
+		SELECT a.task_id, a.user_id, t.due_at, a.escalated_at
+		FROM task_assignees a
+		JOIN tasks t ON t.id = a.task_id
+		WHERE a.user_id IS NOT NULL AND a.status IN ('new', 'in_progress')
+		  AND t.deleted_at IS NULL AND t.due_at IS NOT NULL AND t.due_at < ?
+		ORDER BY t.due_at, a.id`, utc(now))
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
-
-	var out []*User
+	var out []*storage.OverdueAssignee
 	for rows.Next() {
-		u := &User{}
-		if err := rows.Scan(&u.ID, &u.TgID, &u.Username, &u.Role, &u.Name, &u.Team, &u.CreatedAt); err != nil {
+		o := &storage.OverdueAssignee{}
+		if err := rows.Scan(&o.TaskID, &o.UserID, &o.DueAt, &o.EscalatedAt); err != nil {
 			return nil, err
 		}
-		out = append(out, u)
+		out = append(out, o)
 	}
-	return out, nil
-}
\ No newline at end of file
+	return out, rows.Err()
+}
+
+func (d *DB) RecordEscalation(ctx context.Context, e *storage.TaskEvent) error {
+	tx, err := d.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `UPDATE task_assignees SET escalated_at=? WHERE task_id=? AND user_id=?`,
+		utc(e.CreatedAt), e.TaskID, e.UserID); err != nil {
+		return err
+	}
+	return commitWith(tx, addEvent(ctx, tx, e))
+}
@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func (d *DB) CreateTemplate(ctx context.Context, t *storage.Template) (int64, error) {
+	res, err := d.SQL.ExecContext(ctx,
+		`INSERT INTO task_templates (name, creator_id, payload, created_at) VALUES (?, ?, ?, ?)`,
+		t.Name, t.CreatorID, t.Payload, now())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (d *DB) GetTemplate(ctx context.Context, id int64) (*storage.Template, error) {
+	t := &storage.Template{}
+	err := d.SQL.QueryRowContext(ctx, `SELECT id, name, creator_id, payload, created_at FROM task_templates WHERE id = ?`, id).
+		Scan(&t.ID, &t.Name, &t.CreatorID, &t.Payload, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+func (d *DB) ListTemplates(ctx context.Context) ([]*storage.Template, error) {
+	rows, err := d.SQL.QueryContext(ctx, `SELECT id, name, creator_id, payload, created_at FROM task_templates ORDER BY name COLLATE NOCASE, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*storage.Template
+	for rows.Next() {
+		t := &storage.Template{}
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatorID, &t.Payload, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) DeleteTemplate(ctx context.Context, id int64) error {
+	res, err := d.SQL.ExecContext(ctx, `DELETE FROM task_templates WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
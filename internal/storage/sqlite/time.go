@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// Timestamps are stored as UTC text in timeLayout (the driver's
+// _time_format=sqlite), so comparing and sorting them as strings follows
+// time order. Every time bound into a query must go through utc first.
+const timeLayout = "2006-01-02 15:04:05.999999999-07:00"
+
+func now() time.Time { return storage.Now().UTC() }
+
+func utc(t time.Time) time.Time { return t.UTC() }
+
+func utcNull(t sql.NullTime) sql.NullTime {
+	if t.Valid {
+		t.Time = t.Time.UTC()
+	}
+	return t
+}
+
+// textTime scans timestamps that may come back as plain text because the
+// column has no declared type, e.g. MAX(updated_at).
+type textTime struct{ time.Time }
+
+func (t *textTime) Scan(v any) (err error) {
+	switch x := v.(type) {
+	case time.Time:
+		t.Time = x
+	case string:
+		t.Time, err = time.Parse(timeLayout, x)
+	case []byte:
+		t.Time, err = time.Parse(timeLayout, string(x))
+	default:
+		err = fmt.Errorf("cannot scan %T into a timestamp", v)
+	}
+	return err
+}
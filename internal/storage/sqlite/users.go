@@ -3,22 +3,38 @@ package sqlite
 import (
     "context"
     "database/sql"
-    "errors"
-    "time"
+
+    "github.com/hihikaAAa/task-manager/internal/storage"
 )
 
-type User struct {
-    ID       int64
-    TgID     int64
-    Username sql.NullString
-    Role     string
-    Name     sql.NullString
-    Team     sql.NullString
-    CreatedAt time.Time
+
+// userColumns reads a user from userTables, with the department name as Team.
+const (
+    userColumns = `u.id, u.tg_id, u.username, u.role, u.name, dp.name, u.department_id, u.timezone, u.work_hours, u.created_at`
+    userTables  = `users u LEFT JOIN departments dp ON dp.id = u.department_id`
+)
+
+func scanUser(row interface{ Scan(...any) error }) (*storage.User, error) {
+    u := &storage.User{}
+    if err := row.Scan(&u.ID, &u.TgID, &u.Username, &u.Role, &u.Name, &u.Team, &u.DepartmentID, &u.Timezone, &u.WorkHours, &u.CreatedAt); err != nil { return nil, err }
+    return u, nil
+}
+
+func (d *DB) queryUsers(ctx context.Context, query string, args ...any) ([]*storage.User, error) {
+    rows, err := d.SQL.QueryContext(ctx, query, args...)
+    if err != nil { return nil, err }
+    defer rows.Close()
+    var out []*storage.User
+    for rows.Next() {
+        u, err := scanUser(rows)
+        if err != nil { return nil, err }
+        out = append(out, u)
+    }
+    return out, rows.Err()
 }
 
-func (d *DB) UpsertUser(ctx context.Context, tgID int64, username *string, role string) (*User, error) {
-    now := Now()
+func (d *DB) UpsertUser(ctx context.Context, tgID int64, username *string, role string) (*storage.User, error) {
+    now := now()
     var uname interface{} = nil
     if username != nil { uname = *username }
     _, err := d.SQL.ExecContext(ctx, `
@@ -30,102 +46,72 @@ func (d *DB) UpsertUser(ctx context.Context, tgID int64, username *string, role
     return d.GetUserByTgID(ctx, tgID)
 }
 
-func (d *DB) GetUserByTgID(ctx context.Context, tgID int64) (*User, error) {
-    row := d.SQL.QueryRowContext(ctx, `SELECT id, tg_id, username, role, name, team, created_at FROM users WHERE tg_id=?`, tgID)
-    u := &User{}
-    err := row.Scan(&u.ID, &u.TgID, &u.Username, &u.Role, &u.Name, &u.Team, &u.CreatedAt)
-    if err != nil { 
-		return nil, err }
-    return u, nil
+func (d *DB) GetUserByTgID(ctx context.Context, tgID int64) (*storage.User, error) {
+    return scanUser(d.SQL.QueryRowContext(ctx, `SELECT `+userColumns+` FROM `+userTables+` WHERE u.tg_id=?`, tgID))
 }
 
-func (d *DB) SetWorkerProfile(ctx context.Context, tgID int64, name, team string) error {
-    _, err := d.SQL.ExecContext(ctx, `UPDATE users SET name=?, team=? WHERE tg_id=?`, name, team, tgID)
+func (d *DB) SetWorkerProfile(ctx context.Context, tgID int64, name string, deptID int64) error {
+    if _, err := d.GetDepartmentByID(ctx, deptID); err != nil { return err }
+    _, err := d.SQL.ExecContext(ctx, `UPDATE users SET name=?, department_id=? WHERE tg_id=?`, name, deptID, tgID)
     return err
 }
 
-func (d *DB) ListTeams(ctx context.Context) ([]string, error) {
-    rows, err := d.SQL.QueryContext(ctx, `SELECT name FROM departments ORDER BY name`)
-    if err != nil { return nil, err }
-    defer rows.Close()
-    var teams []string
-    for rows.Next() {
-        var name string
-        if err := rows.Scan(&name); err != nil { return nil, err }
-        teams = append(teams, name)
-    }
-    return teams, nil
+func (d *DB) SetUserTimezone(ctx context.Context, tgID int64, tz string) error {
+    _, err := d.SQL.ExecContext(ctx, `UPDATE users SET timezone=? WHERE tg_id=?`, sql.NullString{String: tz, Valid: tz != ""}, tgID)
+    return err
 }
 
-func (d *DB) SetWorkerTeamByDeptID(ctx context.Context, tgID, deptID int64) error {
-    dep, err := d.GetDepartmentByID(ctx, deptID)
-    if err != nil { return err }
-    _, err = d.SQL.ExecContext(ctx, `UPDATE users SET team=? WHERE tg_id=?`, dep.Name, tgID)
+func (d *DB) SetUserWorkHours(ctx context.Context, tgID int64, spec string) error {
+    _, err := d.SQL.ExecContext(ctx, `UPDATE users SET work_hours=? WHERE tg_id=?`, sql.NullString{String: spec, Valid: spec != ""}, tgID)
     return err
 }
 
-func (d *DB) ListWorkersByTeam(ctx context.Context, team string) ([]*User, error) {
-    rows, err := d.SQL.QueryContext(ctx, `SELECT id, tg_id, username, role, name, team, created_at
-        FROM users WHERE role='worker' AND team=? ORDER BY name`, team)
-    if err != nil { return nil, err }
-    defer rows.Close()
-    var out []*User
-    for rows.Next() {
-        u := &User{}
-        if err := rows.Scan(&u.ID, &u.TgID, &u.Username, &u.Role, &u.Name, &u.Team, &u.CreatedAt); err != nil { return nil, err }
-        out = append(out, u)
-    }
-    return out, nil
+func (d *DB) ListWorkersByDepartment(ctx context.Context, deptID int64) ([]*storage.User, error) {
+    return d.queryUsers(ctx, `SELECT `+userColumns+`
+        FROM `+userTables+` WHERE u.role='worker' AND u.department_id=? ORDER BY u.name`, deptID)
 }
 
-func (d *DB) ListAllWorkers(ctx context.Context) ([]*User, error) {
-    rows, err := d.SQL.QueryContext(ctx, `SELECT id, tg_id, username, role, name, team, created_at
-        FROM users WHERE role='worker' ORDER BY team, name`)
-    if err != nil { return nil, err }
-    defer rows.Close()
-    var out []*User
-    for rows.Next() {
-        u := &User{}
-        if err := rows.Scan(&u.ID, &u.TgID, &u.Username, &u.Role, &u.Name, &u.Team, &u.CreatedAt); err != nil { return nil, err }
-        out = append(out, u)
-    }
-    return out, nil
+func (d *DB) ListAllWorkers(ctx context.Context) ([]*storage.User, error) {
+    return d.queryUsers(ctx, `SELECT `+userColumns+`
+        FROM `+userTables+` WHERE u.role='worker' ORDER BY dp.name, u.name`)
 }
 
-var ErrNotFound = errors.New("not found")
+var ErrNotFound = storage.ErrNotFound
 
-func (d *DB) FindWorkerByUsername(ctx context.Context, username string) (*User, error) {
-    row := d.SQL.QueryRowContext(ctx, `SELECT id, tg_id, username, role, name, team, created_at
-        FROM users WHERE role='worker' AND lower(username)=lower(?)`, username)
-    u := &User{}
-    if err := row.Scan(&u.ID, &u.TgID, &u.Username, &u.Role, &u.Name, &u.Team, &u.CreatedAt); err != nil {
-        if err == sql.ErrNoRows { return nil, ErrNotFound }
-        return nil, err
-    }
-    return u, nil
+func (d *DB) FindWorkerByUsername(ctx context.Context, username string) (*storage.User, error) {
+    u, err := scanUser(d.SQL.QueryRowContext(ctx, `SELECT `+userColumns+`
+        FROM `+userTables+` WHERE u.role='worker' AND lower(u.username)=lower(?)`, username))
+    if err == sql.ErrNoRows { return nil, ErrNotFound }
+    return u, err
 }
 
 func (d *DB) DeleteWorkerByTgID(ctx context.Context, tgID int64) (int64, error) {
     u, err := d.GetUserByTgID(ctx, tgID)
     if err != nil { return 0, err }
 
-    _, _ = d.SQL.ExecContext(ctx, `UPDATE task_assignees SET user_id=NULL WHERE user_id=?`, u.ID)
-    _, _ = d.SQL.ExecContext(ctx, `DELETE FROM reminders WHERE user_id=?`, u.ID)
+    tx, err := d.SQL.BeginTx(ctx, nil)
+    if err != nil { return 0, err }
+    defer func() { _ = tx.Rollback() }()
+
+    name := u.Name
+    if !name.Valid && u.Username.Valid { name = sql.NullString{String: "@" + u.Username.String, Valid: true} }
+    if _, err := tx.ExecContext(ctx, `
+        INSERT INTO task_events (task_id, user_id, kind, old_value, created_at)
+        SELECT task_id, ?, ?, ?, ? FROM task_assignees WHERE user_id=?`,
+        u.ID, storage.EventUnassigned, name, now(), u.ID); err != nil {
+        return 0, err
+    }
+    if _, err := tx.ExecContext(ctx, `UPDATE task_assignees SET user_id=NULL WHERE user_id=?`, u.ID); err != nil { return 0, err }
+    if _, err := tx.ExecContext(ctx, `DELETE FROM reminders WHERE user_id=?`, u.ID); err != nil { return 0, err }
 
-    res, err := d.SQL.ExecContext(ctx, `DELETE FROM users WHERE tg_id=? AND role='worker'`, tgID)
+    res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE tg_id=? AND role='worker'`, tgID)
     if err != nil { return 0, err }
     n, _ := res.RowsAffected()
-    return n, nil
+    return n, tx.Commit()
 }
 
-
-func (d *DB) GetUserByID(ctx context.Context, id int64) (*User, error) {
-    row := d.SQL.QueryRowContext(ctx, `SELECT id, tg_id, username, role, name, team, created_at FROM users WHERE id=?`, id)
-    u := &User{}
-    if err := row.Scan(&u.ID, &u.TgID, &u.Username, &u.Role, &u.Name, &u.Team, &u.CreatedAt); err != nil {
-        return nil, err
-    }
-    return u, nil
+func (d *DB) GetUserByID(ctx context.Context, id int64) (*storage.User, error) {
+    return scanUser(d.SQL.QueryRowContext(ctx, `SELECT `+userColumns+` FROM `+userTables+` WHERE u.id=?`, id))
 }
 
 func (d *DB) ListAssigneeTgIDsByTask(ctx context.Context, taskID int64) ([]int64, error) {
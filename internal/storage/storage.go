@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// ErrDepartmentNotEmpty is returned when deleting a department that still
+// has members.
+var ErrDepartmentNotEmpty = errors.New("department has members")
+
+// TaskStore lists only live tasks; GetTask also returns trashed ones, with
+// DeletedAt set.
+type TaskStore interface {
+	CreateTask(ctx context.Context, in NewTask) (*CreatedTask, error)
+	// CreateTasks creates all of in or, on the first error, none of them.
+	CreateTasks(ctx context.Context, in []NewTask) ([]*CreatedTask, error)
+	GetTask(ctx context.Context, id int64) (*Task, error)
+	DueAtForTask(ctx context.Context, taskID int64) (time.Time, bool, error)
+	ListAllTasks(ctx context.Context) ([]*Task, error)
+	// The active lists page by creation time, the done lists by completion
+	// time, newest first.
+	ListActiveTasksForBoss(ctx context.Context, p PageRequest) (*TaskPage, error)
+	ListActiveTasksForUser(ctx context.Context, userID int64, p PageRequest) (*TaskPage, error)
+	ListActiveTasksForDepartment(ctx context.Context, deptID int64, p PageRequest) (*TaskPage, error)
+	ListDoneTasksForBoss(ctx context.Context, creatorID int64, p PageRequest) (*TaskPage, error)
+	ListDoneTasksForUser(ctx context.Context, userID int64, p PageRequest) (*TaskPage, error)
+	ListTasksWithoutAssignees(ctx context.Context) ([]*Task, error)
+	FindTasksByTitleLike(ctx context.Context, q string, limit int) ([]*Task, error)
+	// SearchTasks ranks live tasks by how well their title, description and
+	// text results match q. With assigneeID set only that user's tasks count.
+	SearchTasks(ctx context.Context, q string, assigneeID int64, limit int) ([]*SearchHit, error)
+	UpdateTaskDeadline(ctx context.Context, taskID, actorID int64, due sql.NullTime) error
+	// Delete* move live tasks to the trash and report how many were moved.
+	DeleteTask(ctx context.Context, taskID, actorID int64) (int64, error)
+	DeleteAllTasks(ctx context.Context, actorID int64) (int64, error)
+	DeleteTasksByExactTitle(ctx context.Context, title string, actorID int64) (int64, error)
+	ListDeletedTasks(ctx context.Context) ([]*Task, error)
+	// RestoreTask takes a task out of the trash. Reminders that came due
+	// meanwhile are marked sent. ErrNotFound if the task is not in the trash.
+	RestoreTask(ctx context.Context, taskID, actorID int64) error
+	// PurgeDeletedTasks permanently removes tasks trashed before the cutoff.
+	PurgeDeletedTasks(ctx context.Context, before time.Time) (int64, error)
+	AddResult(ctx context.Context, r *TaskResult) error
+	ListResults(ctx context.Context, taskID int64) ([]*TaskResult, error)
+	ListAttachments(ctx context.Context, taskID int64) ([]*Attachment, error)
+	HasResult(ctx context.Context, taskID, userID int64) (bool, error)
+}
+
+type EventStore interface {
+	AddTaskEvent(ctx context.Context, e *TaskEvent) error
+	ListTaskEvents(ctx context.Context, taskID int64) ([]*TaskEvent, error)
+}
+
+type AssigneeStore interface {
+	UpdateAssigneeStatus(ctx context.Context, taskID, userID int64, status string) (bool, error)
+	IsAssigneeDone(ctx context.Context, taskID, userID int64) (bool, error)
+	GetAssignees(ctx context.Context, taskID int64) ([]*TaskAssignee, error)
+	ListAssigneesWithUsers(ctx context.Context, taskID int64) ([]*AssigneeRow, error)
+	ListAssigneesWithUsersAny(ctx context.Context, taskID int64) ([]*AssigneeWithUser, error)
+	ListAssigneeTgIDsByTask(ctx context.Context, taskID int64) ([]int64, error)
+	ListDoneExecutorsForTask(ctx context.Context, taskID int64) ([]*User, error)
+	// ListOverdueAssignees lists assignments still new or in progress on
+	// live tasks whose deadline is before now.
+	ListOverdueAssignees(ctx context.Context, now time.Time) ([]*OverdueAssignee, error)
+	// RecordEscalation adds e, an EventEscalated, and sets EscalatedAt of
+	// the assignment of e.UserID to e.CreatedAt.
+	RecordEscalation(ctx context.Context, e *TaskEvent) error
+	// ListAssignmentsForExport lists the assignments of live tasks by task
+	// creation time.
+	ListAssignmentsForExport(ctx context.Context, f ExportFilter) ([]*AssignmentRow, error)
+}
+
+type ReminderStore interface {
+	CreateReminders(ctx context.Context, taskID int64, userIDs []int64, reminderTimes []time.Time, kind string) error
+	ListDueReminders(ctx context.Context, until time.Time) ([]*Reminder, error)
+	MarkReminderSent(ctx context.Context, id int64) error
+	MarkAllRemindersSentFor(ctx context.Context, taskID, userID int64) error
+	// DeferReminder moves an unsent reminder to at.
+	DeferReminder(ctx context.Context, id int64, at time.Time) error
+	// SnoozeReminder repeats reminder id of userID at "at" as a new row and
+	// records an EventSnoozed. It returns ErrNotFound if id is not userID's.
+	SnoozeReminder(ctx context.Context, id, userID int64, at time.Time) (*Reminder, error)
+	// ListTaskReminders lists every reminder of a task, sent or not, by time.
+	ListTaskReminders(ctx context.Context, taskID int64) ([]*Reminder, error)
+}
+
+type UserStore interface {
+	UpsertUser(ctx context.Context, tgID int64, username *string, role string) (*User, error)
+	GetUserByTgID(ctx context.Context, tgID int64) (*User, error)
+	GetUserByID(ctx context.Context, id int64) (*User, error)
+	SetWorkerProfile(ctx context.Context, tgID int64, name string, deptID int64) error
+	// SetUserTimezone stores an IANA zone name; "" resets it to the default.
+	SetUserTimezone(ctx context.Context, tgID int64, tz string) error
+	// SetUserWorkHours stores working hours; "" falls back to the department's.
+	SetUserWorkHours(ctx context.Context, tgID int64, spec string) error
+	ListWorkersByDepartment(ctx context.Context, deptID int64) ([]*User, error)
+	ListAllWorkers(ctx context.Context) ([]*User, error)
+	SearchWorkers(ctx context.Context, q string) ([]*User, error)
+	FindWorkerByUsername(ctx context.Context, username string) (*User, error)
+	DeleteWorkerByTgID(ctx context.Context, tgID int64) (int64, error)
+}
+
+type DepartmentStore interface {
+	CreateDepartment(ctx context.Context, name string, createdBy *int64) (int64, error)
+	ListDepartments(ctx context.Context) ([]*Department, error)
+	GetDepartmentByID(ctx context.Context, id int64) (*Department, error)
+	RenameDepartment(ctx context.Context, id int64, name string) error
+	// SetDepartmentWorkHours stores working hours; "" resets to the default.
+	SetDepartmentWorkHours(ctx context.Context, id int64, spec string) error
+	// SetDepartmentHead sets the head by users.id; 0 removes it.
+	SetDepartmentHead(ctx context.Context, id, userID int64) error
+	// DeleteDepartment fails with ErrDepartmentNotEmpty while it has members.
+	DeleteDepartment(ctx context.Context, id int64) error
+	// MoveDepartmentMembers moves every member of fromID to toID.
+	MoveDepartmentMembers(ctx context.Context, fromID, toID int64) (int64, error)
+}
+
+type StateStore interface {
+	SaveState(ctx context.Context, userID int64, state string, payload any) error
+	LoadState(ctx context.Context, userID int64, dst any) (string, error)
+	ClearState(ctx context.Context, userID int64) error
+	// ExpireStates removes the states not updated since before and returns
+	// them.
+	ExpireStates(ctx context.Context, before time.Time) ([]*State, error)
+}
+
+type DraftStore interface {
+	SaveDraft(ctx context.Context, userID int64, state string, payload any) (int64, error)
+	// ListDrafts returns the drafts of userID, newest first.
+	ListDrafts(ctx context.Context, userID int64) ([]*Draft, error)
+	// TakeDraft removes a draft of userID and returns it. ErrNotFound if
+	// userID has no such draft.
+	TakeDraft(ctx context.Context, id, userID int64) (*Draft, error)
+	PurgeDrafts(ctx context.Context, before time.Time) (int64, error)
+}
+
+type RecurringStore interface {
+	CreateRecurring(ctx context.Context, r *Recurring) (int64, error)
+	GetRecurring(ctx context.Context, id int64) (*Recurring, error)
+	ListRecurring(ctx context.Context) ([]*Recurring, error)
+	// ListDueRecurring lists the definitions not paused whose NextAt is not
+	// after now.
+	ListDueRecurring(ctx context.Context, now time.Time) ([]*Recurring, error)
+	// SaveRecurring stores NextAt, Created and Paused of r.
+	SaveRecurring(ctx context.Context, r *Recurring) error
+	DeleteRecurring(ctx context.Context, id int64) error
+}
+
+type TemplateStore interface {
+	CreateTemplate(ctx context.Context, t *Template) (int64, error)
+	GetTemplate(ctx context.Context, id int64) (*Template, error)
+	// ListTemplates returns every template by name.
+	ListTemplates(ctx context.Context) ([]*Template, error)
+	DeleteTemplate(ctx context.Context, id int64) error
+}
+
+// Store is everything the bot needs from a storage backend.
+type Store interface {
+	TaskStore
+	EventStore
+	AssigneeStore
+	ReminderStore
+	UserStore
+	DepartmentStore
+	StateStore
+	DraftStore
+	RecurringStore
+	TemplateStore
+}
+
+// Backuper is implemented by backends that can snapshot themselves into a
+// single file while online.
+type Backuper interface {
+	Backup(ctx context.Context, path string) error
+}
+
+func Now() time.Time { return time.Now().UTC() }
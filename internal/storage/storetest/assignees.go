@@ -0,0 +1,238 @@
+package storetest
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func testEvents(f *fixture) {
+	boss := f.boss(1)
+	anna := f.worker(11, "anna", "Анна", f.dept("Склад"))
+	task := f.task(boss, "Пересчитать коробки", time.Time{}, anna)
+	_, err := f.s.UpdateAssigneeStatus(f.ctx, task.ID, anna.ID, "in_progress")
+	f.check(err)
+	f.check(f.s.AddTaskEvent(f.ctx, &storage.TaskEvent{
+		TaskID: task.ID, ActorID: sql.NullInt64{Int64: boss.ID, Valid: true}, Kind: storage.EventEscalated,
+		OldValue: str("head"), NewValue: str("Иван"), CreatedAt: f.now,
+	}))
+
+	evs, err := f.s.ListTaskEvents(f.ctx, task.ID)
+	f.check(err)
+	var kinds []string
+	for _, e := range evs {
+		kinds = append(kinds, e.Kind)
+	}
+	want := []string{storage.EventCreated, storage.EventAssigned, storage.EventStatus, storage.EventEscalated}
+	if len(kinds) != len(want) {
+		f.t.Fatalf("events = %q, want %q", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			f.t.Fatalf("events = %q, want %q", kinds, want)
+		}
+	}
+
+	created, assigned, status, added := evs[0], evs[1], evs[2], evs[3]
+	if created.NewValue.String != "Пересчитать коробки" || created.ActorName.String != "@boss" {
+		f.t.Fatalf("created event = %+v", created)
+	}
+	if assigned.UserID.Int64 != anna.ID || assigned.UserName.String != "Анна" {
+		f.t.Fatalf("assigned event = %+v", assigned)
+	}
+	if status.OldValue.String != "new" || status.NewValue.String != "in_progress" || status.ActorName.String != "Анна" {
+		f.t.Fatalf("status event = %+v", status)
+	}
+	if added.OldValue.String != "head" || added.NewValue.String != "Иван" || added.UserID.Valid {
+		f.t.Fatalf("added event = %+v", added)
+	}
+	f.equalTime("added event time", added.CreatedAt, f.now)
+}
+
+func testAssignees(f *fixture) {
+	boss := f.boss(1)
+	sklad, buh := f.dept("Склад"), f.dept("Бухгалтерия")
+	boris := f.worker(12, "boris", "Борис", sklad)
+	anna := f.worker(11, "anna", "Анна", sklad)
+	vera := f.worker(13, "vera", "Вера", buh)
+	task := f.task(boss, "Сверка", time.Time{}, boris, anna, vera)
+
+	as, err := f.s.GetAssignees(f.ctx, task.ID)
+	f.check(err)
+	if len(as) != 3 {
+		f.t.Fatalf("GetAssignees = %d rows, want 3", len(as))
+	}
+	for _, a := range as {
+		if a.TaskID != task.ID || a.Status != "new" {
+			f.t.Fatalf("assignee %+v", a)
+		}
+	}
+
+	rows, err := f.s.ListAssigneesWithUsers(f.ctx, task.ID)
+	f.check(err)
+	var order []string
+	for _, r := range rows {
+		order = append(order, r.Team.String+"/"+r.Name.String)
+	}
+	if len(order) != 3 || order[0] != "Бухгалтерия/Вера" || order[1] != "Склад/Анна" || order[2] != "Склад/Борис" {
+		f.t.Fatalf("ListAssigneesWithUsers by department and name = %q", order)
+	}
+	tgIDs, err := f.s.ListAssigneeTgIDsByTask(f.ctx, task.ID)
+	f.check(err)
+	if len(tgIDs) != 3 {
+		f.t.Fatalf("ListAssigneeTgIDsByTask = %v", tgIDs)
+	}
+
+	if n, err := f.s.DeleteWorkerByTgID(f.ctx, boris.TgID); err != nil || n != 1 {
+		f.t.Fatalf("DeleteWorkerByTgID = %d, %v", n, err)
+	}
+	rows, err = f.s.ListAssigneesWithUsers(f.ctx, task.ID)
+	f.check(err)
+	if len(rows) != 2 {
+		f.t.Fatalf("ListAssigneesWithUsers lists %d assignees after a deletion, want 2", len(rows))
+	}
+	anyRows, err := f.s.ListAssigneesWithUsersAny(f.ctx, task.ID)
+	f.check(err)
+	gone := 0
+	for _, r := range anyRows {
+		if !r.UserID.Valid {
+			gone++
+		}
+	}
+	if len(anyRows) != 3 || gone != 1 {
+		f.t.Fatalf("ListAssigneesWithUsersAny = %d rows, %d without a user; want 3 and 1", len(anyRows), gone)
+	}
+	if tgIDs, _ = f.s.ListAssigneeTgIDsByTask(f.ctx, task.ID); len(tgIDs) != 2 {
+		f.t.Fatalf("ListAssigneeTgIDsByTask after a deletion = %v", tgIDs)
+	}
+
+	evs, err := f.s.ListTaskEvents(f.ctx, task.ID)
+	f.check(err)
+	last := evs[len(evs)-1]
+	if last.Kind != storage.EventUnassigned || last.OldValue.String != "Борис" {
+		f.t.Fatalf("last event = %+v, want boris unassigned", last)
+	}
+}
+
+func testOverdue(f *fixture) {
+	boss := f.boss(1)
+	dep := f.dept("Склад")
+	anna := f.worker(11, "anna", "Анна", dep)
+	boris := f.worker(12, "boris", "Борис", dep)
+	late := f.task(boss, "Просрочена", f.now.Add(-2*time.Hour), anna, boris)
+	f.task(boss, "В срок", f.now.Add(2*time.Hour), anna)
+	f.task(boss, "Без срока", time.Time{}, anna)
+
+	_, err := f.s.UpdateAssigneeStatus(f.ctx, late.ID, boris.ID, "done")
+	f.check(err)
+	over, err := f.s.ListOverdueAssignees(f.ctx, f.now)
+	f.check(err)
+	if len(over) != 1 || over[0].TaskID != late.ID || over[0].UserID != anna.ID || over[0].EscalatedAt.Valid {
+		f.t.Fatalf("ListOverdueAssignees = %+v", over)
+	}
+	f.equalTime("overdue due", over[0].DueAt, f.now.Add(-2*time.Hour))
+
+	step := f.now.Add(-time.Hour)
+	f.check(f.s.RecordEscalation(f.ctx, &storage.TaskEvent{
+		TaskID: late.ID, UserID: sql.NullInt64{Int64: anna.ID, Valid: true}, Kind: storage.EventEscalated,
+		OldValue: str("head"), NewValue: str("@boss"), CreatedAt: step,
+	}))
+	over, err = f.s.ListOverdueAssignees(f.ctx, f.now)
+	f.check(err)
+	if len(over) != 1 || !over[0].EscalatedAt.Valid {
+		f.t.Fatalf("ListOverdueAssignees after escalation = %+v", over)
+	}
+	f.equalTime("escalated at", over[0].EscalatedAt.Time, step)
+	evs, err := f.s.ListTaskEvents(f.ctx, late.ID)
+	f.check(err)
+	if e := evs[len(evs)-1]; e.Kind != storage.EventEscalated || e.UserID.Int64 != anna.ID {
+		f.t.Fatalf("last event = %+v, want the escalation", e)
+	}
+
+	// a new deadline starts escalation over
+	f.check(f.s.UpdateTaskDeadline(f.ctx, late.ID, boss.ID, at(f.now.Add(-time.Minute))))
+	over, err = f.s.ListOverdueAssignees(f.ctx, f.now)
+	f.check(err)
+	if len(over) != 1 || over[0].EscalatedAt.Valid {
+		f.t.Fatalf("ListOverdueAssignees after a new deadline = %+v", over)
+	}
+
+	_, err = f.s.DeleteTask(f.ctx, late.ID, boss.ID)
+	f.check(err)
+	if over, _ = f.s.ListOverdueAssignees(f.ctx, f.now); len(over) != 0 {
+		f.t.Fatalf("trashed task is overdue: %+v", over)
+	}
+}
+
+func testExport(f *fixture) {
+	boss := f.boss(1)
+	other := f.user(2, "other", "boss")
+	sklad, buh := f.dept("Склад"), f.dept("Бухгалтерия")
+	anna := f.worker(11, "anna", "Анна", sklad)
+	vera := f.worker(13, "vera", "Вера", buh)
+	first := f.task(boss, "Первая", f.now.Add(time.Hour), anna, vera)
+	second := f.task(other, "Вторая", time.Time{}, anna)
+	trashed := f.task(boss, "В корзине", time.Time{}, anna)
+
+	_, err := f.s.UpdateAssigneeStatus(f.ctx, first.ID, anna.ID, "done")
+	f.check(err)
+	f.check(f.s.AddResult(f.ctx, &storage.TaskResult{TaskID: first.ID, UserID: anna.ID, Text: str("Готово")}))
+	_, err = f.s.DeleteTask(f.ctx, trashed.ID, boss.ID)
+	f.check(err)
+
+	list := func(flt storage.ExportFilter) []*storage.AssignmentRow {
+		f.t.Helper()
+		rows, err := f.s.ListAssignmentsForExport(f.ctx, flt)
+		f.check(err)
+		return rows
+	}
+	rows := list(storage.ExportFilter{})
+	if len(rows) != 3 {
+		f.t.Fatalf("export of everything = %d rows, want 3", len(rows))
+	}
+	if rows[0].TaskID != first.ID || rows[1].TaskID != first.ID || rows[2].TaskID != second.ID {
+		f.t.Fatalf("export is not by creation time: %d %d %d", rows[0].TaskID, rows[1].TaskID, rows[2].TaskID)
+	}
+	done := rows[0]
+	if done.AssigneeName.String != "Анна" || done.AssigneeTgID.Int64 != anna.TgID || done.Department.String != "Склад" ||
+		done.Status != "done" || !done.DoneAt.Valid || done.Results != 1 || done.CreatorTgID.Int64 != boss.TgID ||
+		done.CreatorUsername.String != "boss" || done.Title.String != "Первая" {
+		f.t.Fatalf("done row = %+v", done)
+	}
+	f.equalTime("row due", done.DueAt.Time, f.now.Add(time.Hour))
+	if rows[1].Status != "new" || rows[1].DoneAt.Valid || rows[1].Results != 0 {
+		f.t.Fatalf("open row = %+v", rows[1])
+	}
+
+	if rows = list(storage.ExportFilter{Status: "done"}); len(rows) != 1 || rows[0].AssigneeName.String != "Анна" {
+		f.t.Fatalf("export of done = %+v", rows)
+	}
+	if rows = list(storage.ExportFilter{DepartmentID: buh}); len(rows) != 1 || rows[0].AssigneeName.String != "Вера" {
+		f.t.Fatalf("export of a department = %+v", rows)
+	}
+	if rows = list(storage.ExportFilter{CreatorID: other.ID}); len(rows) != 1 || rows[0].TaskID != second.ID {
+		f.t.Fatalf("export of a creator = %+v", rows)
+	}
+	if rows = list(storage.ExportFilter{From: f.now.Add(-time.Hour), To: f.now.Add(time.Hour)}); len(rows) != 3 {
+		f.t.Fatalf("export of the last hour = %d rows, want 3", len(rows))
+	}
+	if rows = list(storage.ExportFilter{From: f.now.Add(time.Hour)}); len(rows) != 0 {
+		f.t.Fatalf("export from the future = %d rows", len(rows))
+	}
+	if rows = list(storage.ExportFilter{To: f.now.Add(-time.Hour)}); len(rows) != 0 {
+		f.t.Fatalf("export up to an hour ago = %d rows", len(rows))
+	}
+
+	_, err = f.s.DeleteWorkerByTgID(f.ctx, vera.TgID)
+	f.check(err)
+	gone := 0
+	for _, r := range list(storage.ExportFilter{}) {
+		if !r.AssigneeTgID.Valid && !r.AssigneeName.Valid {
+			gone++
+		}
+	}
+	if gone != 1 {
+		f.t.Fatalf("export has %d rows without a user after a deletion, want 1", gone)
+	}
+}
@@ -0,0 +1,73 @@
+package storetest
+
+import (
+	"errors"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func testDepartments(f *fixture) {
+	sklad, buh := f.dept("Склад"), f.dept("Бухгалтерия")
+	if _, err := f.s.CreateDepartment(f.ctx, "Склад", nil); err == nil {
+		f.t.Fatal("created a second department with the same name")
+	}
+	deps, err := f.s.ListDepartments(f.ctx)
+	f.check(err)
+	if len(deps) != 2 || deps[0].ID != buh || deps[1].ID != sklad {
+		f.t.Fatalf("ListDepartments by name = %+v", deps)
+	}
+
+	f.check(f.s.RenameDepartment(f.ctx, sklad, "Логистика"))
+	if err := f.s.RenameDepartment(f.ctx, sklad, "Бухгалтерия"); err == nil {
+		f.t.Fatal("renamed a department to a taken name")
+	}
+	if err := f.s.RenameDepartment(f.ctx, sklad+100, "Нет"); !errors.Is(err, storage.ErrNotFound) {
+		f.t.Fatalf("renaming a missing department: %v, want ErrNotFound", err)
+	}
+	f.check(f.s.SetDepartmentWorkHours(f.ctx, sklad, "пн-пт 08:00-17:00"))
+	d, err := f.s.GetDepartmentByID(f.ctx, sklad)
+	f.check(err)
+	if d.Name != "Логистика" || d.WorkHours.String != "пн-пт 08:00-17:00" || d.HeadID.Valid {
+		f.t.Fatalf("department = %+v", d)
+	}
+	f.check(f.s.SetDepartmentWorkHours(f.ctx, sklad, ""))
+	if d, _ = f.s.GetDepartmentByID(f.ctx, sklad); d.WorkHours.Valid {
+		f.t.Fatalf("reset work hours = %v", d.WorkHours)
+	}
+
+	anna := f.worker(11, "anna", "Анна", sklad)
+	f.worker(12, "boris", "Борис", sklad)
+	f.check(f.s.SetDepartmentHead(f.ctx, sklad, anna.ID))
+	if d, _ = f.s.GetDepartmentByID(f.ctx, sklad); d.HeadID.Int64 != anna.ID {
+		f.t.Fatalf("head = %v, want anna", d.HeadID)
+	}
+	f.check(f.s.SetDepartmentHead(f.ctx, sklad, 0))
+	if d, _ = f.s.GetDepartmentByID(f.ctx, sklad); d.HeadID.Valid {
+		f.t.Fatalf("removed head = %v", d.HeadID)
+	}
+	f.check(f.s.SetDepartmentHead(f.ctx, sklad, anna.ID))
+	_, err = f.s.DeleteWorkerByTgID(f.ctx, anna.TgID)
+	f.check(err)
+	if d, _ = f.s.GetDepartmentByID(f.ctx, sklad); d.HeadID.Valid {
+		f.t.Fatalf("head of a deleted worker = %v", d.HeadID)
+	}
+
+	if err := f.s.DeleteDepartment(f.ctx, sklad); !errors.Is(err, storage.ErrDepartmentNotEmpty) {
+		f.t.Fatalf("deleting a department with members: %v, want ErrDepartmentNotEmpty", err)
+	}
+	n, err := f.s.MoveDepartmentMembers(f.ctx, sklad, buh)
+	f.check(err)
+	if n != 1 {
+		f.t.Fatalf("MoveDepartmentMembers moved %d, want 1", n)
+	}
+	if ws, _ := f.s.ListWorkersByDepartment(f.ctx, buh); len(ws) != 1 || ws[0].Team.String != "Бухгалтерия" {
+		f.t.Fatalf("moved workers = %+v", ws)
+	}
+	f.check(f.s.DeleteDepartment(f.ctx, sklad))
+	if _, err := f.s.GetDepartmentByID(f.ctx, sklad); !errors.Is(err, storage.ErrNotFound) {
+		f.t.Fatalf("deleted department: %v, want ErrNotFound", err)
+	}
+	if err := f.s.DeleteDepartment(f.ctx, sklad); !errors.Is(err, storage.ErrNotFound) {
+		f.t.Fatalf("deleting a department twice: %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,116 @@
+package storetest
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func testRecurring(f *fixture) {
+	boss := f.boss(1)
+	def := func(title string, next time.Time, paused bool) int64 {
+		f.t.Helper()
+		id, err := f.s.CreateRecurring(f.ctx, &storage.Recurring{
+			CreatorID: boss.ID, Title: title, Schedule: "каждый день 09:00",
+			Payload: []byte(`{"title":"` + title + `"}`), NextAt: at(next), Paused: paused,
+		})
+		f.check(err)
+		return id
+	}
+	due := def("Планёрка", f.now.Add(-time.Minute), false)
+	later := def("Отчёт", f.now.Add(time.Hour), false)
+	paused := def("Пауза", f.now.Add(-time.Hour), true)
+
+	r, err := f.s.GetRecurring(f.ctx, due)
+	f.check(err)
+	if r.Title != "Планёрка" || r.Schedule != "каждый день 09:00" || r.CreatorID != boss.ID || r.Created != 0 || r.Paused || r.CreatedAt.IsZero() {
+		f.t.Fatalf("GetRecurring = %+v", r)
+	}
+	f.equalTime("next", r.NextAt.Time, f.now.Add(-time.Minute))
+	f.equalJSON("payload", r.Payload, []byte(`{"title":"Планёрка"}`))
+	if _, err := f.s.GetRecurring(f.ctx, due+100); !errors.Is(err, storage.ErrNotFound) {
+		f.t.Fatalf("GetRecurring of a missing one: %v, want ErrNotFound", err)
+	}
+
+	all, err := f.s.ListRecurring(f.ctx)
+	f.check(err)
+	if len(all) != 3 || all[0].ID != due || all[1].ID != later || all[2].ID != paused {
+		f.t.Fatalf("ListRecurring = %+v", all)
+	}
+	list, err := f.s.ListDueRecurring(f.ctx, f.now)
+	f.check(err)
+	if len(list) != 1 || list[0].ID != due {
+		f.t.Fatalf("ListDueRecurring = %+v", list)
+	}
+
+	r.NextAt, r.Created = at(f.now.Add(24*time.Hour)), 1
+	f.check(f.s.SaveRecurring(f.ctx, r))
+	if list, _ = f.s.ListDueRecurring(f.ctx, f.now); len(list) != 0 {
+		f.t.Fatalf("ListDueRecurring after moving on = %+v", list)
+	}
+	r, err = f.s.GetRecurring(f.ctx, due)
+	f.check(err)
+	if r.Created != 1 {
+		f.t.Fatalf("Created = %d, want 1", r.Created)
+	}
+	f.equalTime("saved next", r.NextAt.Time, f.now.Add(24*time.Hour))
+
+	// an ended schedule has no next time and is never due
+	r.NextAt = sql.NullTime{}
+	f.check(f.s.SaveRecurring(f.ctx, r))
+	if r, _ = f.s.GetRecurring(f.ctx, due); r.NextAt.Valid {
+		f.t.Fatalf("ended schedule has next %v", r.NextAt)
+	}
+	p, err := f.s.GetRecurring(f.ctx, paused)
+	f.check(err)
+	p.Paused = false
+	f.check(f.s.SaveRecurring(f.ctx, p))
+	if list, _ = f.s.ListDueRecurring(f.ctx, f.now); len(list) != 1 || list[0].ID != paused {
+		f.t.Fatalf("ListDueRecurring after resuming = %+v", list)
+	}
+	if err := f.s.SaveRecurring(f.ctx, &storage.Recurring{ID: due + 100}); !errors.Is(err, storage.ErrNotFound) {
+		f.t.Fatalf("saving a missing one: %v, want ErrNotFound", err)
+	}
+
+	f.check(f.s.DeleteRecurring(f.ctx, later))
+	if err := f.s.DeleteRecurring(f.ctx, later); !errors.Is(err, storage.ErrNotFound) {
+		f.t.Fatalf("deleting twice: %v, want ErrNotFound", err)
+	}
+	if all, _ = f.s.ListRecurring(f.ctx); len(all) != 2 {
+		f.t.Fatalf("ListRecurring after a deletion = %d, want 2", len(all))
+	}
+}
+
+func testTemplates(f *fixture) {
+	boss := f.boss(1)
+	create := func(name string) int64 {
+		f.t.Helper()
+		id, err := f.s.CreateTemplate(f.ctx, &storage.Template{Name: name, CreatorID: boss.ID, Payload: []byte(`{"title":"` + name + ` {date}"}`)})
+		f.check(err)
+		return id
+	}
+	weekly, daily := create("Недельный отчёт"), create("Ежедневная сводка")
+
+	t, err := f.s.GetTemplate(f.ctx, weekly)
+	f.check(err)
+	if t.Name != "Недельный отчёт" || t.CreatorID != boss.ID || t.CreatedAt.IsZero() {
+		f.t.Fatalf("GetTemplate = %+v", t)
+	}
+	f.equalJSON("payload", t.Payload, []byte(`{"title":"Недельный отчёт {date}"}`))
+
+	ts, err := f.s.ListTemplates(f.ctx)
+	f.check(err)
+	if len(ts) != 2 || ts[0].ID != daily || ts[1].ID != weekly {
+		f.t.Fatalf("ListTemplates by name = %+v", ts)
+	}
+
+	f.check(f.s.DeleteTemplate(f.ctx, weekly))
+	if _, err := f.s.GetTemplate(f.ctx, weekly); !errors.Is(err, storage.ErrNotFound) {
+		f.t.Fatalf("deleted template: %v, want ErrNotFound", err)
+	}
+	if err := f.s.DeleteTemplate(f.ctx, weekly); !errors.Is(err, storage.ErrNotFound) {
+		f.t.Fatalf("deleting twice: %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,99 @@
+package storetest
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func testReminders(f *fixture) {
+	boss := f.boss(1)
+	dep := f.dept("Склад")
+	anna := f.worker(11, "anna", "Анна", dep)
+	boris := f.worker(12, "boris", "Борис", dep)
+	task := f.task(boss, "Разгрузка", f.now.Add(2*time.Hour), anna, boris)
+
+	soon, later := f.now.Add(-time.Minute), f.now.Add(time.Hour)
+	f.check(f.s.CreateReminders(f.ctx, task.ID, []int64{anna.ID, boris.ID}, []time.Time{soon, later}, "before"))
+	all, err := f.s.ListTaskReminders(f.ctx, task.ID)
+	f.check(err)
+	if len(all) != 4 {
+		f.t.Fatalf("ListTaskReminders = %d, want 4", len(all))
+	}
+
+	due := func(until time.Time) []*storage.Reminder {
+		f.t.Helper()
+		rs, err := f.s.ListDueReminders(f.ctx, until)
+		f.check(err)
+		return rs
+	}
+	rs := due(f.now)
+	if len(rs) != 2 {
+		f.t.Fatalf("ListDueReminders = %d, want 2", len(rs))
+	}
+	for _, r := range rs {
+		if r.TaskID != task.ID || r.Kind != "before" || !r.UserID.Valid {
+			f.t.Fatalf("due reminder %+v", r)
+		}
+		f.equalTime("due reminder", r.At, soon)
+	}
+	annas, boriss := rs[0], rs[1]
+	if annas.UserID.Int64 != anna.ID {
+		annas, boriss = boriss, annas
+	}
+
+	f.check(f.s.MarkReminderSent(f.ctx, annas.ID))
+	f.check(f.s.DeferReminder(f.ctx, boriss.ID, f.now.Add(30*time.Minute)))
+	if rs = due(f.now); len(rs) != 0 {
+		f.t.Fatalf("%d reminders due after sending and deferring", len(rs))
+	}
+	if rs = due(f.now.Add(30 * time.Minute)); len(rs) != 1 || rs[0].ID != boriss.ID {
+		f.t.Fatalf("deferred reminder = %+v", rs)
+	}
+	// a sent reminder stays where it was
+	f.check(f.s.DeferReminder(f.ctx, annas.ID, f.now.Add(30*time.Minute)))
+	if rs = due(f.now.Add(30 * time.Minute)); len(rs) != 1 {
+		f.t.Fatalf("deferring a sent reminder made it due: %+v", rs)
+	}
+
+	if _, err := f.s.SnoozeReminder(f.ctx, annas.ID, boris.ID, f.now); !errors.Is(err, storage.ErrNotFound) {
+		f.t.Fatalf("snoozing someone else's reminder: %v, want ErrNotFound", err)
+	}
+	snoozed, err := f.s.SnoozeReminder(f.ctx, annas.ID, anna.ID, f.now.Add(10*time.Minute))
+	f.check(err)
+	if snoozed.ID == annas.ID || snoozed.TaskID != task.ID || snoozed.UserID.Int64 != anna.ID || snoozed.Kind != "before" {
+		f.t.Fatalf("snoozed reminder = %+v", snoozed)
+	}
+	f.equalTime("snoozed", snoozed.At, f.now.Add(10*time.Minute))
+	if rs = due(f.now.Add(10 * time.Minute)); len(rs) != 1 || rs[0].ID != snoozed.ID {
+		f.t.Fatalf("due after a snooze = %+v", rs)
+	}
+	evs, err := f.s.ListTaskEvents(f.ctx, task.ID)
+	f.check(err)
+	if e := evs[len(evs)-1]; e.Kind != storage.EventSnoozed || e.UserID.Int64 != anna.ID || e.NewValue.String == "" {
+		f.t.Fatalf("last event = %+v, want the snooze", e)
+	}
+
+	f.check(f.s.MarkAllRemindersSentFor(f.ctx, task.ID, anna.ID))
+	for _, r := range due(f.now.Add(2 * time.Hour)) {
+		if r.UserID.Int64 == anna.ID {
+			f.t.Fatalf("anna still has reminder %+v", r)
+		}
+	}
+	if n := len(due(f.now.Add(2 * time.Hour))); n != 2 {
+		f.t.Fatalf("boris has %d reminders due, want 2", n)
+	}
+	if all, _ = f.s.ListTaskReminders(f.ctx, task.ID); len(all) != 5 {
+		f.t.Fatalf("ListTaskReminders keeps sent ones: %d, want 5", len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].At.Before(all[i-1].At) {
+			f.t.Fatal("ListTaskReminders is not by time")
+		}
+	}
+
+	if err := f.s.CreateReminders(f.ctx, task.ID+100, []int64{anna.ID}, []time.Time{later}, "before"); err == nil {
+		f.t.Fatal("CreateReminders for a missing task succeeded")
+	}
+}
@@ -0,0 +1,99 @@
+package storetest
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+type payload struct {
+	Title string `json:"title"`
+	Step  int    `json:"step"`
+}
+
+func testStates(f *fixture) {
+	if state, err := f.s.LoadState(f.ctx, 11, nil); err == nil || state != "" {
+		f.t.Fatalf("LoadState without a state = %q, %v", state, err)
+	}
+
+	f.check(f.s.SaveState(f.ctx, 11, "newtask_title", payload{Title: "Отчёт", Step: 1}))
+	f.check(f.s.SaveState(f.ctx, 12, "idle", nil))
+	var p payload
+	state, err := f.s.LoadState(f.ctx, 11, &p)
+	f.check(err)
+	if state != "newtask_title" || p.Title != "Отчёт" || p.Step != 1 {
+		f.t.Fatalf("LoadState = %q, %+v", state, p)
+	}
+
+	f.check(f.s.SaveState(f.ctx, 11, "newtask_desc", payload{Title: "Отчёт", Step: 2}))
+	if state, _ = f.s.LoadState(f.ctx, 11, &p); state != "newtask_desc" || p.Step != 2 {
+		f.t.Fatalf("LoadState after an update = %q, %+v", state, p)
+	}
+
+	if st, _ := f.s.ExpireStates(f.ctx, f.now.Add(-time.Hour)); len(st) != 0 {
+		f.t.Fatalf("expired %d fresh states", len(st))
+	}
+	st, err := f.s.ExpireStates(f.ctx, f.now.Add(time.Hour))
+	f.check(err)
+	if len(st) != 2 {
+		f.t.Fatalf("ExpireStates = %d states, want 2", len(st))
+	}
+	for _, s := range st {
+		if s.UserID == 11 && (s.State != "newtask_desc" || len(s.Payload) == 0) {
+			f.t.Fatalf("expired state = %+v", s)
+		}
+	}
+	if _, err := f.s.LoadState(f.ctx, 11, nil); err == nil {
+		f.t.Fatal("expired state is still there")
+	}
+
+	f.check(f.s.SaveState(f.ctx, 11, "idle", nil))
+	f.check(f.s.ClearState(f.ctx, 11))
+	if _, err := f.s.LoadState(f.ctx, 11, nil); err == nil {
+		f.t.Fatal("cleared state is still there")
+	}
+}
+
+func testDrafts(f *fixture) {
+	first, err := f.s.SaveDraft(f.ctx, 11, "newtask_title", payload{Title: "Первый"})
+	f.check(err)
+	second, err := f.s.SaveDraft(f.ctx, 11, "newtask_desc", payload{Title: "Второй"})
+	f.check(err)
+	other, err := f.s.SaveDraft(f.ctx, 12, "newtask_title", payload{Title: "Чужой"})
+	f.check(err)
+
+	ds, err := f.s.ListDrafts(f.ctx, 11)
+	f.check(err)
+	if len(ds) != 2 || ds[0].ID != second || ds[1].ID != first {
+		f.t.Fatalf("ListDrafts newest first = %+v", ds)
+	}
+	if ds[0].State != "newtask_desc" || ds[0].UserID != 11 || ds[0].CreatedAt.IsZero() {
+		f.t.Fatalf("draft = %+v", ds[0])
+	}
+	f.equalJSON("draft payload", ds[0].Payload, []byte(`{"title":"Второй","step":0}`))
+
+	if _, err := f.s.TakeDraft(f.ctx, other, 11); !errors.Is(err, storage.ErrNotFound) {
+		f.t.Fatalf("taking someone else's draft: %v, want ErrNotFound", err)
+	}
+	d, err := f.s.TakeDraft(f.ctx, first, 11)
+	f.check(err)
+	if d.ID != first || d.State != "newtask_title" {
+		f.t.Fatalf("TakeDraft = %+v", d)
+	}
+	if _, err := f.s.TakeDraft(f.ctx, first, 11); !errors.Is(err, storage.ErrNotFound) {
+		f.t.Fatalf("taking a draft twice: %v, want ErrNotFound", err)
+	}
+
+	if n, _ := f.s.PurgeDrafts(f.ctx, f.now.Add(-time.Hour)); n != 0 {
+		f.t.Fatalf("purged %d fresh drafts", n)
+	}
+	n, err := f.s.PurgeDrafts(f.ctx, f.now.Add(time.Hour))
+	f.check(err)
+	if n != 2 {
+		f.t.Fatalf("PurgeDrafts = %d, want 2", n)
+	}
+	if ds, _ = f.s.ListDrafts(f.ctx, 12); len(ds) != 0 {
+		f.t.Fatalf("purged drafts are still listed: %+v", ds)
+	}
+}
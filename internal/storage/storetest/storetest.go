@@ -0,0 +1,151 @@
+// Package storetest is the behaviour every storage.Store backend shares.
+// Each backend runs it from its own tests against a fresh store.
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+// Run runs the suite; open returns a new, empty store for every test.
+func Run(t *testing.T, open func(t *testing.T) storage.Store) {
+	tests := []struct {
+		name string
+		fn   func(f *fixture)
+	}{
+		{"Tasks", testTasks},
+		{"TaskPages", testTaskPages},
+		{"Trash", testTrash},
+		{"CreateTasks", testCreateTasks},
+		{"Deadline", testDeadline},
+		{"Search", testSearch},
+		{"Results", testResults},
+		{"Events", testEvents},
+		{"Assignees", testAssignees},
+		{"Overdue", testOverdue},
+		{"Export", testExport},
+		{"Reminders", testReminders},
+		{"Users", testUsers},
+		{"Departments", testDepartments},
+		{"States", testStates},
+		{"Drafts", testDrafts},
+		{"Recurring", testRecurring},
+		{"Templates", testTemplates},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(&fixture{t: t, ctx: context.Background(), s: open(t), now: storage.Now().Truncate(time.Second)})
+		})
+	}
+}
+
+type fixture struct {
+	t   *testing.T
+	ctx context.Context
+	s   storage.Store
+	// now is when the test started, to the second, so it survives every
+	// backend's timestamp precision
+	now time.Time
+}
+
+func (f *fixture) check(err error) {
+	f.t.Helper()
+	if err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fixture) user(tgID int64, username, role string) *storage.User {
+	f.t.Helper()
+	u, err := f.s.UpsertUser(f.ctx, tgID, &username, role)
+	f.check(err)
+	return u
+}
+
+func (f *fixture) boss(tgID int64) *storage.User {
+	f.t.Helper()
+	return f.user(tgID, "boss", "boss")
+}
+
+// worker registers a worker the way /start does.
+func (f *fixture) worker(tgID int64, username, name string, deptID int64) *storage.User {
+	f.t.Helper()
+	f.user(tgID, username, "worker")
+	f.check(f.s.SetWorkerProfile(f.ctx, tgID, name, deptID))
+	u, err := f.s.GetUserByTgID(f.ctx, tgID)
+	f.check(err)
+	return u
+}
+
+func (f *fixture) dept(name string) int64 {
+	f.t.Helper()
+	id, err := f.s.CreateDepartment(f.ctx, name, nil)
+	f.check(err)
+	return id
+}
+
+// task creates a task of creator for the assignees, due at due unless
+// that is zero.
+func (f *fixture) task(creator *storage.User, title string, due time.Time, assignees ...*storage.User) *storage.Task {
+	f.t.Helper()
+	nt := storage.NewTask{Task: &storage.Task{CreatorID: creator.ID, Title: str(title), DueAt: at(due)}}
+	for _, u := range assignees {
+		nt.AssigneeIDs = append(nt.AssigneeIDs, u.ID)
+	}
+	ct, err := f.s.CreateTask(f.ctx, nt)
+	f.check(err)
+	return ct.Task
+}
+
+func taskIDs(ts []*storage.Task) []int64 {
+	ids := make([]int64, len(ts))
+	for i, t := range ts {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+func (f *fixture) equalIDs(what string, got, want []int64) {
+	f.t.Helper()
+	if len(got) != len(want) {
+		f.t.Fatalf("%s: got %v, want %v", what, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			f.t.Fatalf("%s: got %v, want %v", what, got, want)
+		}
+	}
+}
+
+func (f *fixture) equalTime(what string, got, want time.Time) {
+	f.t.Helper()
+	if !got.Equal(want) {
+		f.t.Fatalf("%s: got %v, want %v", what, got, want)
+	}
+}
+
+// equalJSON compares payloads as values; some backends store them as JSONB
+// and give them back reformatted.
+func (f *fixture) equalJSON(what string, got, want []byte) {
+	f.t.Helper()
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		f.t.Fatalf("%s: %v in %q", what, err, got)
+	}
+	f.check(json.Unmarshal(want, &w))
+	gb, _ := json.Marshal(g)
+	wb, _ := json.Marshal(w)
+	if !bytes.Equal(gb, wb) {
+		f.t.Fatalf("%s: got %s, want %s", what, got, want)
+	}
+}
+
+func str(s string) sql.NullString { return sql.NullString{String: s, Valid: s != ""} }
+
+func at(t time.Time) sql.NullTime { return sql.NullTime{Time: t, Valid: !t.IsZero()} }
@@ -0,0 +1,357 @@
+package storetest
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func testTasks(f *fixture) {
+	boss := f.boss(1)
+	dep := f.dept("Продажи")
+	anna := f.worker(11, "anna", "Анна", dep)
+	boris := f.worker(12, "boris", "Борис", dep)
+	due := f.now.Add(48 * time.Hour)
+
+	ct, err := f.s.CreateTask(f.ctx, storage.NewTask{
+		Task:        &storage.Task{CreatorID: boss.ID, Title: str("Отчёт за квартал"), Description: str("Собрать цифры"), DueAt: at(due)},
+		AssigneeIDs: []int64{anna.ID, boris.ID},
+		Reminders: []storage.NewReminder{
+			{At: due.Add(-time.Hour), Kind: "before"},
+			{At: due, Kind: "deadline", UserID: anna.ID},
+		},
+		Attachments: []*storage.Attachment{{Kind: storage.MediaDocument, FileID: "file-1", FileName: str("plan.pdf")}},
+	})
+	f.check(err)
+	if ct.Reminders != 3 {
+		f.t.Fatalf("created %d reminders, want 3: one per assignee and one for anna", ct.Reminders)
+	}
+	id := ct.Task.ID
+
+	got, err := f.s.GetTask(f.ctx, id)
+	f.check(err)
+	if got.Title.String != "Отчёт за квартал" || got.Description.String != "Собрать цифры" || got.CreatorID != boss.ID {
+		f.t.Fatalf("GetTask = %+v", got)
+	}
+	f.equalTime("due", got.DueAt.Time, due)
+	if got.DeletedAt.Valid {
+		f.t.Fatal("new task is in the trash")
+	}
+	if d, ok, err := f.s.DueAtForTask(f.ctx, id); err != nil || !ok || !d.Equal(due) {
+		f.t.Fatalf("DueAtForTask = %v, %v, %v", d, ok, err)
+	}
+	if _, err := f.s.GetTask(f.ctx, id+100); err == nil {
+		f.t.Fatal("GetTask of a missing task succeeded")
+	}
+
+	atts, err := f.s.ListAttachments(f.ctx, id)
+	f.check(err)
+	if len(atts) != 1 || atts[0].FileID != "file-1" || atts[0].FileName.String != "plan.pdf" || atts[0].TaskID != id {
+		f.t.Fatalf("attachments = %+v", atts)
+	}
+
+	bare := f.task(boss, "Без исполнителей", time.Time{})
+	all, err := f.s.ListAllTasks(f.ctx)
+	f.check(err)
+	f.equalIDs("ListAllTasks", taskIDs(all), []int64{id, bare.ID})
+	without, err := f.s.ListTasksWithoutAssignees(f.ctx)
+	f.check(err)
+	f.equalIDs("ListTasksWithoutAssignees", taskIDs(without), []int64{bare.ID})
+	like, err := f.s.FindTasksByTitleLike(f.ctx, "за квартал", 10)
+	f.check(err)
+	f.equalIDs("FindTasksByTitleLike", taskIDs(like), []int64{id})
+
+	active := func(what string, p *storage.TaskPage, err error, want ...int64) {
+		f.t.Helper()
+		f.check(err)
+		f.equalIDs(what, taskIDs(p.Tasks), want)
+	}
+	p, err := f.s.ListActiveTasksForBoss(f.ctx, storage.PageRequest{})
+	active("active for boss", p, err, bare.ID, id)
+	p, err = f.s.ListActiveTasksForUser(f.ctx, anna.ID, storage.PageRequest{})
+	active("active for anna", p, err, id)
+	p, err = f.s.ListActiveTasksForDepartment(f.ctx, dep, storage.PageRequest{})
+	active("active for department", p, err, id)
+
+	changed, err := f.s.UpdateAssigneeStatus(f.ctx, id, anna.ID, "done")
+	f.check(err)
+	if !changed {
+		f.t.Fatal("UpdateAssigneeStatus reported no change")
+	}
+	if changed, _ = f.s.UpdateAssigneeStatus(f.ctx, id, anna.ID, "done"); changed {
+		f.t.Fatal("setting the same status again reported a change")
+	}
+	if done, err := f.s.IsAssigneeDone(f.ctx, id, anna.ID); err != nil || !done {
+		f.t.Fatalf("IsAssigneeDone = %v, %v", done, err)
+	}
+	p, err = f.s.ListActiveTasksForUser(f.ctx, anna.ID, storage.PageRequest{})
+	active("active for anna once done", p, err)
+	p, err = f.s.ListDoneTasksForUser(f.ctx, anna.ID, storage.PageRequest{})
+	active("done for anna", p, err, id)
+	p, err = f.s.ListActiveTasksForBoss(f.ctx, storage.PageRequest{})
+	active("active for boss while boris works", p, err, bare.ID, id)
+
+	_, err = f.s.UpdateAssigneeStatus(f.ctx, id, boris.ID, "done")
+	f.check(err)
+	p, err = f.s.ListActiveTasksForBoss(f.ctx, storage.PageRequest{})
+	active("active for boss once all done", p, err, bare.ID)
+	p, err = f.s.ListDoneTasksForBoss(f.ctx, boss.ID, storage.PageRequest{})
+	active("done for boss", p, err, id)
+	execs, err := f.s.ListDoneExecutorsForTask(f.ctx, id)
+	f.check(err)
+	if len(execs) != 2 {
+		f.t.Fatalf("ListDoneExecutorsForTask = %d users, want 2", len(execs))
+	}
+}
+
+func testTaskPages(f *fixture) {
+	boss := f.boss(1)
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		ids = append(ids, f.task(boss, "Задача", time.Time{}).ID)
+	}
+
+	list := func(req storage.PageRequest, hasPrev, hasNext bool, want ...int64) *storage.TaskPage {
+		f.t.Helper()
+		p, err := f.s.ListActiveTasksForBoss(f.ctx, req)
+		f.check(err)
+		f.equalIDs("page", taskIDs(p.Tasks), want)
+		if p.HasPrev != hasPrev || p.HasNext != hasNext {
+			f.t.Fatalf("page %v: HasPrev, HasNext = %v, %v, want %v, %v", want, p.HasPrev, p.HasNext, hasPrev, hasNext)
+		}
+		if len(p.Keys) != len(p.Tasks) {
+			f.t.Fatalf("page %v has %d keys", want, len(p.Keys))
+		}
+		return p
+	}
+	p := list(storage.PageRequest{Limit: 2}, false, true, ids[4], ids[3])
+	p = list(storage.PageRequest{Cursor: p.Last(), Limit: 2}, true, true, ids[2], ids[1])
+	p = list(storage.PageRequest{Cursor: p.Last(), Limit: 2}, true, false, ids[0])
+	p = list(storage.PageRequest{Cursor: p.First(), Backward: true, Limit: 2}, true, true, ids[2], ids[1])
+	list(storage.PageRequest{Cursor: p.First(), Backward: true, Limit: 2}, false, true, ids[4], ids[3])
+}
+
+func testTrash(f *fixture) {
+	boss := f.boss(1)
+	dep := f.dept("Склад")
+	anna := f.worker(11, "anna", "Анна", dep)
+	ct, err := f.s.CreateTask(f.ctx, storage.NewTask{
+		Task:        &storage.Task{CreatorID: boss.ID, Title: str("Инвентаризация"), DueAt: at(f.now.Add(time.Hour))},
+		AssigneeIDs: []int64{anna.ID},
+		Reminders:   []storage.NewReminder{{At: f.now.Add(-time.Minute), Kind: "before"}},
+	})
+	f.check(err)
+	id := ct.Task.ID
+	other := f.task(boss, "Другая", time.Time{})
+
+	n, err := f.s.DeleteTask(f.ctx, id, boss.ID)
+	f.check(err)
+	if n != 1 {
+		f.t.Fatalf("DeleteTask moved %d tasks", n)
+	}
+	if n, _ = f.s.DeleteTask(f.ctx, id, boss.ID); n != 0 {
+		f.t.Fatalf("deleting a trashed task moved %d tasks", n)
+	}
+	got, err := f.s.GetTask(f.ctx, id)
+	f.check(err)
+	if !got.DeletedAt.Valid || got.DeletedBy.Int64 != boss.ID {
+		f.t.Fatalf("trashed task: DeletedAt %v, DeletedBy %v", got.DeletedAt, got.DeletedBy)
+	}
+	all, err := f.s.ListAllTasks(f.ctx)
+	f.check(err)
+	f.equalIDs("ListAllTasks without the trash", taskIDs(all), []int64{other.ID})
+	deleted, err := f.s.ListDeletedTasks(f.ctx)
+	f.check(err)
+	f.equalIDs("ListDeletedTasks", taskIDs(deleted), []int64{id})
+	rs, err := f.s.ListDueReminders(f.ctx, f.now.Add(time.Hour))
+	f.check(err)
+	if len(rs) != 0 {
+		f.t.Fatalf("trashed task has %d due reminders", len(rs))
+	}
+
+	f.check(f.s.RestoreTask(f.ctx, id, boss.ID))
+	if err := f.s.RestoreTask(f.ctx, id, boss.ID); !errors.Is(err, storage.ErrNotFound) {
+		f.t.Fatalf("restoring a live task: %v, want ErrNotFound", err)
+	}
+	got, err = f.s.GetTask(f.ctx, id)
+	f.check(err)
+	if got.DeletedAt.Valid {
+		f.t.Fatal("restored task is still in the trash")
+	}
+	// the reminder came due in the trash and is not sent late
+	rs, err = f.s.ListDueReminders(f.ctx, f.now.Add(time.Hour))
+	f.check(err)
+	if len(rs) != 0 {
+		f.t.Fatalf("restored task has %d due reminders", len(rs))
+	}
+
+	if n, _ = f.s.DeleteAllTasks(f.ctx, boss.ID); n != 2 {
+		f.t.Fatalf("DeleteAllTasks moved %d tasks, want 2", n)
+	}
+	f.check(f.s.RestoreTask(f.ctx, other.ID, boss.ID))
+	if n, _ = f.s.DeleteTasksByExactTitle(f.ctx, "Другая", boss.ID); n != 1 {
+		f.t.Fatalf("DeleteTasksByExactTitle moved %d tasks, want 1", n)
+	}
+
+	if n, _ = f.s.PurgeDeletedTasks(f.ctx, f.now.Add(-time.Hour)); n != 0 {
+		f.t.Fatalf("purged %d tasks trashed after the cutoff", n)
+	}
+	n, err = f.s.PurgeDeletedTasks(f.ctx, f.now.Add(time.Hour))
+	f.check(err)
+	if n != 2 {
+		f.t.Fatalf("PurgeDeletedTasks removed %d tasks, want 2", n)
+	}
+	if _, err := f.s.GetTask(f.ctx, id); err == nil {
+		f.t.Fatal("GetTask of a purged task succeeded")
+	}
+}
+
+func testCreateTasks(f *fixture) {
+	boss := f.boss(1)
+	anna := f.worker(11, "anna", "Анна", f.dept("Склад"))
+	task := func(title string, assignees ...int64) storage.NewTask {
+		return storage.NewTask{
+			Task:        &storage.Task{CreatorID: boss.ID, Title: str(title)},
+			AssigneeIDs: assignees,
+			Reminders:   []storage.NewReminder{{At: f.now.Add(time.Hour), Kind: "before"}},
+		}
+	}
+
+	cts, err := f.s.CreateTasks(f.ctx, []storage.NewTask{task("Первая", anna.ID), task("Вторая", anna.ID)})
+	f.check(err)
+	if len(cts) != 2 || cts[0].Task.Title.String != "Первая" || cts[1].Task.Title.String != "Вторая" || cts[1].Reminders != 1 {
+		f.t.Fatalf("CreateTasks = %+v", cts)
+	}
+
+	if _, err := f.s.CreateTasks(f.ctx, []storage.NewTask{task("Третья", anna.ID), task("Четвёртая", anna.ID+100)}); err == nil {
+		f.t.Fatal("CreateTasks with an unknown assignee succeeded")
+	}
+	all, err := f.s.ListAllTasks(f.ctx)
+	f.check(err)
+	f.equalIDs("tasks after a failed batch", taskIDs(all), []int64{cts[0].Task.ID, cts[1].Task.ID})
+	rs, err := f.s.ListDueReminders(f.ctx, f.now.Add(2*time.Hour))
+	f.check(err)
+	if len(rs) != 2 {
+		f.t.Fatalf("%d reminders after a failed batch, want 2", len(rs))
+	}
+}
+
+func testDeadline(f *fixture) {
+	boss := f.boss(1)
+	anna := f.worker(11, "anna", "Анна", f.dept("Склад"))
+	due := f.now.Add(24 * time.Hour)
+	ct, err := f.s.CreateTask(f.ctx, storage.NewTask{
+		Task:        &storage.Task{CreatorID: boss.ID, Title: str("Сдать отчёт"), DueAt: at(due)},
+		AssigneeIDs: []int64{anna.ID},
+		Reminders:   []storage.NewReminder{{At: due.Add(-time.Hour), Kind: "before"}, {At: due.Add(-2 * time.Hour), Kind: "before"}},
+	})
+	f.check(err)
+	id := ct.Task.ID
+	rs, err := f.s.ListTaskReminders(f.ctx, id)
+	f.check(err)
+	f.check(f.s.MarkReminderSent(f.ctx, rs[0].ID))
+
+	later := due.Add(3 * time.Hour)
+	f.check(f.s.UpdateTaskDeadline(f.ctx, id, boss.ID, at(later)))
+	got, err := f.s.GetTask(f.ctx, id)
+	f.check(err)
+	f.equalTime("new deadline", got.DueAt.Time, later)
+	rs, err = f.s.ListTaskReminders(f.ctx, id)
+	f.check(err)
+	// the sent reminder stays, the other keeps its hour before the deadline
+	f.equalTime("sent reminder", rs[0].At, due.Add(-2*time.Hour))
+	f.equalTime("shifted reminder", rs[1].At, later.Add(-time.Hour))
+
+	f.check(f.s.UpdateTaskDeadline(f.ctx, id, boss.ID, sql.NullTime{}))
+	rs, err = f.s.ListTaskReminders(f.ctx, id)
+	f.check(err)
+	if len(rs) != 1 {
+		f.t.Fatalf("%d reminders without a deadline, want only the sent one", len(rs))
+	}
+
+	evs, err := f.s.ListTaskEvents(f.ctx, id)
+	f.check(err)
+	var changes []string
+	for _, e := range evs {
+		if e.Kind == storage.EventDeadline {
+			changes = append(changes, e.NewValue.String)
+		}
+	}
+	if len(changes) != 2 || changes[0] == "" || changes[1] != "" {
+		f.t.Fatalf("deadline events = %q", changes)
+	}
+}
+
+func testSearch(f *fixture) {
+	boss := f.boss(1)
+	dep := f.dept("Склад")
+	anna := f.worker(11, "anna", "Анна", dep)
+	boris := f.worker(12, "boris", "Борис", dep)
+	ct, err := f.s.CreateTask(f.ctx, storage.NewTask{
+		Task:        &storage.Task{CreatorID: boss.ID, Title: str("Квартальный отчёт"), Description: str("Свести продажи по регионам")},
+		AssigneeIDs: []int64{anna.ID},
+	})
+	f.check(err)
+	f.task(boss, "Заказать воду", time.Time{}, boris)
+
+	hits, err := f.s.SearchTasks(f.ctx, "квартальный", 0, 10)
+	f.check(err)
+	if len(hits) != 1 || hits[0].Task.ID != ct.Task.ID {
+		f.t.Fatalf("search by title = %+v", hits)
+	}
+	if !strings.Contains(hits[0].Snippet, storage.SnippetOpen+"Квартальный"+storage.SnippetClose) {
+		f.t.Fatalf("snippet %q does not mark the match", hits[0].Snippet)
+	}
+	hits, err = f.s.SearchTasks(f.ctx, "регионам", 0, 10)
+	f.check(err)
+	if len(hits) != 1 || hits[0].Task.ID != ct.Task.ID {
+		f.t.Fatalf("search by description = %+v", hits)
+	}
+	if hits, _ = f.s.SearchTasks(f.ctx, "квартальный", boris.ID, 10); len(hits) != 0 {
+		f.t.Fatalf("boris finds a task that is not his: %+v", hits)
+	}
+	if hits, _ = f.s.SearchTasks(f.ctx, "квартальный воду", 0, 10); len(hits) != 0 {
+		f.t.Fatalf("every word must match: %+v", hits)
+	}
+
+	f.check(f.s.AddResult(f.ctx, &storage.TaskResult{TaskID: ct.Task.ID, UserID: anna.ID, Text: str("Таблица приложена")}))
+	hits, err = f.s.SearchTasks(f.ctx, "таблица", 0, 10)
+	f.check(err)
+	if len(hits) != 1 {
+		f.t.Fatalf("search by result = %+v", hits)
+	}
+
+	_, err = f.s.DeleteTask(f.ctx, ct.Task.ID, boss.ID)
+	f.check(err)
+	if hits, _ = f.s.SearchTasks(f.ctx, "квартальный", 0, 10); len(hits) != 0 {
+		f.t.Fatalf("search finds a trashed task: %+v", hits)
+	}
+}
+
+func testResults(f *fixture) {
+	boss := f.boss(1)
+	anna := f.worker(11, "anna", "Анна", f.dept("Склад"))
+	task := f.task(boss, "Фото витрины", time.Time{}, anna)
+
+	if has, _ := f.s.HasResult(f.ctx, task.ID, anna.ID); has {
+		f.t.Fatal("HasResult before any result")
+	}
+	f.check(f.s.AddResult(f.ctx, &storage.TaskResult{TaskID: task.ID, UserID: anna.ID, Text: str("Готово")}))
+	f.check(f.s.AddResult(f.ctx, &storage.TaskResult{
+		TaskID: task.ID, UserID: anna.ID, Kind: str(storage.MediaPhoto), FileID: str("photo-1"), Caption: str("витрина"),
+	}))
+	if has, err := f.s.HasResult(f.ctx, task.ID, anna.ID); err != nil || !has {
+		f.t.Fatalf("HasResult = %v, %v", has, err)
+	}
+	rs, err := f.s.ListResults(f.ctx, task.ID)
+	f.check(err)
+	if len(rs) != 2 || rs[0].Text.String != "Готово" || rs[1].FileID.String != "photo-1" || rs[1].Kind.String != storage.MediaPhoto {
+		f.t.Fatalf("ListResults = %+v", rs)
+	}
+	if rs[0].ID == 0 || rs[0].CreatedAt.IsZero() {
+		f.t.Fatalf("result without id or time: %+v", rs[0])
+	}
+}
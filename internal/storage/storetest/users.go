@@ -0,0 +1,77 @@
+package storetest
+
+import (
+	"errors"
+
+	"github.com/hihikaAAa/task-manager/internal/storage"
+)
+
+func testUsers(f *fixture) {
+	boss := f.boss(1)
+	again, err := f.s.UpsertUser(f.ctx, 1, nil, "boss")
+	f.check(err)
+	if again.ID != boss.ID || again.Username.Valid {
+		f.t.Fatalf("upserting again = %+v, want the same user without a username", again)
+	}
+	if _, err := f.s.GetUserByTgID(f.ctx, 99); err == nil {
+		f.t.Fatal("GetUserByTgID of a stranger succeeded")
+	}
+
+	dep := f.dept("Склад")
+	anna := f.worker(11, "anna", "Анна", dep)
+	if anna.Role != "worker" || anna.Name.String != "Анна" || anna.DepartmentID.Int64 != dep || anna.Team.String != "Склад" {
+		f.t.Fatalf("worker = %+v", anna)
+	}
+	if err := f.s.SetWorkerProfile(f.ctx, 11, "Анна", dep+100); err == nil {
+		f.t.Fatal("SetWorkerProfile into a missing department succeeded")
+	}
+	byID, err := f.s.GetUserByID(f.ctx, anna.ID)
+	f.check(err)
+	if byID.TgID != 11 {
+		f.t.Fatalf("GetUserByID = %+v", byID)
+	}
+
+	f.check(f.s.SetUserTimezone(f.ctx, 11, "Asia/Yekaterinburg"))
+	f.check(f.s.SetUserWorkHours(f.ctx, 11, "пн-пт 10:00-19:00"))
+	u, err := f.s.GetUserByTgID(f.ctx, 11)
+	f.check(err)
+	if u.Timezone.String != "Asia/Yekaterinburg" || u.WorkHours.String != "пн-пт 10:00-19:00" {
+		f.t.Fatalf("zone and hours = %v, %v", u.Timezone, u.WorkHours)
+	}
+	f.check(f.s.SetUserTimezone(f.ctx, 11, ""))
+	f.check(f.s.SetUserWorkHours(f.ctx, 11, ""))
+	if u, _ = f.s.GetUserByTgID(f.ctx, 11); u.Timezone.Valid || u.WorkHours.Valid {
+		f.t.Fatalf("reset zone and hours = %v, %v", u.Timezone, u.WorkHours)
+	}
+
+	f.worker(12, "boris", "Борис", dep)
+	ws, err := f.s.ListAllWorkers(f.ctx)
+	f.check(err)
+	if len(ws) != 2 || ws[0].Name.String != "Анна" || ws[1].Name.String != "Борис" {
+		f.t.Fatalf("ListAllWorkers = %d workers, want anna and boris", len(ws))
+	}
+	if ws, _ = f.s.ListWorkersByDepartment(f.ctx, dep); len(ws) != 2 {
+		f.t.Fatalf("ListWorkersByDepartment = %d workers, want 2", len(ws))
+	}
+	if ws, _ = f.s.SearchWorkers(f.ctx, "BOR"); len(ws) != 1 || ws[0].TgID != 12 {
+		f.t.Fatalf("SearchWorkers by username = %+v", ws)
+	}
+	w, err := f.s.FindWorkerByUsername(f.ctx, "boris")
+	f.check(err)
+	if w.TgID != 12 {
+		f.t.Fatalf("FindWorkerByUsername = %+v", w)
+	}
+	if _, err := f.s.FindWorkerByUsername(f.ctx, "boss"); !errors.Is(err, storage.ErrNotFound) {
+		f.t.Fatalf("FindWorkerByUsername of a boss: %v, want ErrNotFound", err)
+	}
+
+	if n, err := f.s.DeleteWorkerByTgID(f.ctx, 99); err == nil && n != 0 {
+		f.t.Fatalf("deleting a stranger deleted %d users", n)
+	}
+	if n, err := f.s.DeleteWorkerByTgID(f.ctx, 11); err != nil || n != 1 {
+		f.t.Fatalf("DeleteWorkerByTgID = %d, %v", n, err)
+	}
+	if _, err := f.s.GetUserByTgID(f.ctx, 11); err == nil {
+		f.t.Fatal("deleted worker is still there")
+	}
+}